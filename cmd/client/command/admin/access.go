@@ -0,0 +1,120 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kenlabs/pando/cmd/client/command/api"
+	"github.com/kenlabs/pando/pkg/access"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/spf13/cobra"
+)
+
+const accessPath = "/access"
+
+func accessCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "access",
+		Short: "show or change the access rules of the provider collections",
+	}
+
+	childCommands := []*cobra.Command{
+		accessShowCmd(),
+		accessSetCmd(),
+	}
+	cmd.AddCommand(childCommands...)
+
+	return cmd
+}
+
+func accessShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <peerid>",
+		Short: "show the access rules and the granted consumers of the provider collections",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := peer.Decode(args[0]); err != nil {
+				return fmt.Errorf("invalid peerid: %v", err)
+			}
+			res, err := api.Client.R().
+				SetQueryParam("peerid", args[0]).
+				Get(joinAPIPath(accessPath))
+			if err != nil {
+				return err
+			}
+			return api.PrintResponseData(res)
+		},
+	}
+}
+
+type accessReq struct {
+	collection string
+	restricted bool
+	grant      []string
+	revoke     []string
+}
+
+var accessRequest = &accessReq{}
+
+func accessSetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set <peerid>",
+		Short: "restrict a collection of the provider or make it public, and grant or revoke consumers",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := peer.Decode(args[0]); err != nil {
+				return fmt.Errorf("invalid peerid: %v", err)
+			}
+			if accessRequest.collection == "" {
+				return fmt.Errorf("collection is required")
+			}
+			update := &access.Update{Collection: accessRequest.collection}
+			if cmd.Flags().Changed("restricted") {
+				update.Restricted = &accessRequest.restricted
+			}
+			var err error
+			if update.Grant, err = decodePeerIDs(accessRequest.grant); err != nil {
+				return err
+			}
+			if update.Revoke, err = decodePeerIDs(accessRequest.revoke); err != nil {
+				return err
+			}
+			body, err := json.Marshal(update)
+			if err != nil {
+				return err
+			}
+
+			res, err := api.Client.R().
+				SetQueryParam("peerid", args[0]).
+				SetHeader("Content-Type", "application/json").
+				SetBody(body).
+				Post(joinAPIPath(accessPath))
+			if err != nil {
+				return err
+			}
+			return api.PrintResponseData(res)
+		},
+	}
+
+	cmd.Flags().StringVar(&accessRequest.collection, "collection", "",
+		"collection name, required")
+	cmd.Flags().BoolVar(&accessRequest.restricted, "restricted", false,
+		"restrict the collection to the granted consumers, or make it public with --restricted=false")
+	cmd.Flags().StringSliceVar(&accessRequest.grant, "grant", []string{},
+		"peerIDs of the consumers granted to read the collection")
+	cmd.Flags().StringSliceVar(&accessRequest.revoke, "revoke", []string{},
+		"peerIDs of the consumers revoked from reading the collection")
+
+	return cmd
+}
+
+func decodePeerIDs(ids []string) ([]peer.ID, error) {
+	peerIDs := make([]peer.ID, 0, len(ids))
+	for _, id := range ids {
+		peerID, err := peer.Decode(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peerid %s: %v", id, err)
+		}
+		peerIDs = append(peerIDs, peerID)
+	}
+	return peerIDs, nil
+}
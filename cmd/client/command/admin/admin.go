@@ -16,7 +16,15 @@ func NewAdminCmd() *cobra.Command {
 	}
 
 	childCommands := []*cobra.Command{
+		accessCmd(),
+		authCmd(),
 		backupCmd(),
+		healthCmd(),
+		policyCmd(),
+		providerCmd(),
+		rateLimitCmd(),
+		registryCmd(),
+		taskCmd(),
 	}
 	adminCmd.AddCommand(childCommands...)
 
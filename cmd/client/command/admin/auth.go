@@ -0,0 +1,108 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kenlabs/pando/cmd/client/command/api"
+	"github.com/kenlabs/pando/pkg/auth"
+	"github.com/spf13/cobra"
+)
+
+const (
+	authKeysPath   = "/auth/keys"
+	authRevokePath = "/auth/keys/revoke"
+)
+
+func authCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "auth",
+		Short: "create, list or revoke the api keys",
+	}
+
+	childCommands := []*cobra.Command{
+		authCreateCmd(),
+		authListCmd(),
+		authRevokeCmd(),
+	}
+	cmd.AddCommand(childCommands...)
+
+	return cmd
+}
+
+type createKeyReq struct {
+	name   string
+	scopes []string
+}
+
+var createKeyRequest = &createKeyReq{}
+
+func authCreateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "create an api key, its token is only shown once",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, s := range createKeyRequest.scopes {
+				if _, err := auth.ParseScope(s); err != nil {
+					return err
+				}
+			}
+			body, err := json.Marshal(map[string]interface{}{
+				"Name":   createKeyRequest.name,
+				"Scopes": createKeyRequest.scopes,
+			})
+			if err != nil {
+				return err
+			}
+
+			res, err := api.Client.R().
+				SetHeader("Content-Type", "application/json").
+				SetBody(body).
+				Post(joinAPIPath(authKeysPath))
+			if err != nil {
+				return err
+			}
+			return api.PrintResponseData(res)
+		},
+	}
+
+	cmd.Flags().StringVar(&createKeyRequest.name, "name", "",
+		"name of the api key")
+	cmd.Flags().StringSliceVar(&createKeyRequest.scopes, "scope", []string{string(auth.ScopeRead)},
+		"scopes of the api key: admin, read or ingest")
+
+	return cmd
+}
+
+func authListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "list the api keys, without their tokens",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			res, err := api.Client.R().Get(joinAPIPath(authKeysPath))
+			if err != nil {
+				return err
+			}
+			return api.PrintResponseData(res)
+		},
+	}
+}
+
+func authRevokeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "revoke <id>",
+		Short: "revoke an api key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if args[0] == "" {
+				return fmt.Errorf("id is required")
+			}
+			res, err := api.Client.R().
+				SetQueryParam("id", args[0]).
+				Post(joinAPIPath(authRevokePath))
+			if err != nil {
+				return err
+			}
+			return api.PrintResponseData(res)
+		},
+	}
+}
@@ -0,0 +1,31 @@
+package admin
+
+import (
+	"fmt"
+	"github.com/kenlabs/pando/cmd/client/command/api"
+	"github.com/spf13/cobra"
+	"net/http"
+)
+
+const healthPath = "/health"
+
+func healthCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "health",
+		Short: "check the health of Pando server with the details of its components",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			res, err := api.Client.R().Get(joinAPIPath(healthPath))
+			if err != nil {
+				return err
+			}
+
+			if err = api.PrintResponseData(res); err != nil {
+				return err
+			}
+			if res.StatusCode() != http.StatusOK {
+				return fmt.Errorf("not healthy, status: %d", res.StatusCode())
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,136 @@
+package admin
+
+import (
+	"fmt"
+	"github.com/kenlabs/pando/cmd/client/command/api"
+	"github.com/kenlabs/pando/pkg/registry"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/spf13/cobra"
+	"strconv"
+)
+
+const (
+	policyPath      = "/policy"
+	policyAuditPath = "/policy/audit"
+)
+
+func policyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "policy",
+		Short: "show or change the allow/trust policy of the registry",
+	}
+
+	childCommands := []*cobra.Command{
+		policyShowCmd(),
+		policyAuditCmd(),
+		policyDefaultCmd("allow", registry.PolicySetAllow),
+		policyDefaultCmd("trust", registry.PolicySetTrust),
+		policyExceptCmd(),
+	}
+	cmd.AddCommand(childCommands...)
+
+	return cmd
+}
+
+func policyShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show",
+		Short: "show the current policy",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			res, err := api.Client.R().Get(joinAPIPath(policyPath))
+			if err != nil {
+				return err
+			}
+			return api.PrintResponseData(res)
+		},
+	}
+}
+
+func policyAuditCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "audit",
+		Short: "show the audit records of policy changes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			res, err := api.Client.R().Get(joinAPIPath(policyAuditPath))
+			if err != nil {
+				return err
+			}
+			return api.PrintResponseData(res)
+		},
+	}
+}
+
+func policyDefaultCmd(name string, action registry.PolicyAction) *cobra.Command {
+	return &cobra.Command{
+		Use:   fmt.Sprintf("%s <true|false>", name),
+		Short: fmt.Sprintf("switch the default %s mode", name),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			value, err := strconv.ParseBool(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid value: %v", err)
+			}
+			return sendPolicyUpdate(&registry.PolicyUpdate{
+				Action: action,
+				Value:  value,
+			})
+		},
+	}
+}
+
+type policyExceptReq struct {
+	trust bool
+}
+
+var policyExceptRequest = &policyExceptReq{}
+
+func policyExceptCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "except <add|remove> <peerid>",
+		Short: "add or remove a peer from the exceptions of the allow(or trust) policy",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			peerID, err := peer.Decode(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid peerid: %v", err)
+			}
+
+			var action registry.PolicyAction
+			switch args[0] {
+			case "add":
+				action = registry.PolicyAddExcept
+				if policyExceptRequest.trust {
+					action = registry.PolicyAddTrustExcept
+				}
+			case "remove":
+				action = registry.PolicyRemoveExcept
+				if policyExceptRequest.trust {
+					action = registry.PolicyRemoveTrustExcept
+				}
+			default:
+				return fmt.Errorf("unknown operation: %s, should be add or remove", args[0])
+			}
+
+			return sendPolicyUpdate(&registry.PolicyUpdate{
+				Action: action,
+				PeerID: peerID,
+			})
+		},
+	}
+
+	cmd.Flags().BoolVarP(&policyExceptRequest.trust, "trust", "t", false,
+		"change the exceptions of the trust policy instead of the allow policy")
+
+	return cmd
+}
+
+func sendPolicyUpdate(update *registry.PolicyUpdate) error {
+	res, err := api.Client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(update).
+		Post(joinAPIPath(policyPath))
+	if err != nil {
+		return err
+	}
+	return api.PrintResponseData(res)
+}
@@ -0,0 +1,180 @@
+package admin
+
+import (
+	"fmt"
+	"github.com/kenlabs/pando/cmd/client/command/api"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/spf13/cobra"
+)
+
+const (
+	providerDeregisterPath = "/provider/deregister"
+	providerBanPath        = "/provider/ban"
+	providerUnbanPath      = "/provider/unban"
+	providerBannedPath     = "/provider/banned"
+	providerPurgePath      = "/provider/purge"
+	providerQuarantinePath = "/provider/quarantine"
+	providerReleasePath    = "/provider/release"
+)
+
+func providerCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "provider",
+		Short: "deregister, ban, quarantine or purge providers",
+	}
+
+	childCommands := []*cobra.Command{
+		providerDeregisterCmd(),
+		providerBanCmd(),
+		providerUnbanCmd(),
+		providerBannedCmd(),
+		providerPurgeCmd(),
+		providerQuarantineCmd(),
+		providerReleaseCmd(),
+	}
+	cmd.AddCommand(childCommands...)
+
+	return cmd
+}
+
+type deregisterReq struct {
+	ban    bool
+	purge  bool
+	reason string
+}
+
+var deregisterRequest = &deregisterReq{}
+
+func providerDeregisterCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "deregister <peerid>",
+		Short: "remove a provider from registry",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := peer.Decode(args[0]); err != nil {
+				return fmt.Errorf("invalid peerid: %v", err)
+			}
+			flagTable := map[bool]string{true: "1", false: "0"}
+
+			res, err := api.Client.R().
+				SetQueryParam("peerid", args[0]).
+				SetQueryParam("ban", flagTable[deregisterRequest.ban]).
+				SetQueryParam("purge", flagTable[deregisterRequest.purge]).
+				SetQueryParam("reason", deregisterRequest.reason).
+				Post(joinAPIPath(providerDeregisterPath))
+			if err != nil {
+				return err
+			}
+			return api.PrintResponseData(res)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&deregisterRequest.ban, "ban", "b", false,
+		"ban the provider as well")
+	cmd.Flags().BoolVarP(&deregisterRequest.purge, "purge", "p", false,
+		"purge the data of the provider in background")
+	cmd.Flags().StringVarP(&deregisterRequest.reason, "reason", "r", "",
+		"the reason of the ban")
+
+	return cmd
+}
+
+var banReason string
+
+func providerBanCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ban <peerid>",
+		Short: "ban a peer from registering and syncing with Pando",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return postPeerIDWithReason(providerBanPath, args[0], banReason)
+		},
+	}
+
+	cmd.Flags().StringVarP(&banReason, "reason", "r", "", "the reason of the ban")
+
+	return cmd
+}
+
+var quarantineReason string
+
+func providerQuarantineCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "quarantine <peerid>",
+		Short: "suspend a provider from syncing with Pando until released",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return postPeerIDWithReason(providerQuarantinePath, args[0], quarantineReason)
+		},
+	}
+
+	cmd.Flags().StringVarP(&quarantineReason, "reason", "r", "", "the reason of the quarantine")
+
+	return cmd
+}
+
+func providerReleaseCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "release <peerid>",
+		Short: "lift the quarantine of a provider",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return postPeerID(providerReleasePath, args[0])
+		},
+	}
+}
+
+func providerUnbanCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unban <peerid>",
+		Short: "lift the ban of a peer",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return postPeerID(providerUnbanPath, args[0])
+		},
+	}
+}
+
+func providerBannedCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "banned",
+		Short: "list the banned peers",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			res, err := api.Client.R().Get(joinAPIPath(providerBannedPath))
+			if err != nil {
+				return err
+			}
+			return api.PrintResponseData(res)
+		},
+	}
+}
+
+func providerPurgeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "purge <peerid>",
+		Short: "purge the data of a provider in background, see `task` for its progress",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return postPeerID(providerPurgePath, args[0])
+		},
+	}
+}
+
+func postPeerID(apiPath string, peerIDStr string) error {
+	return postPeerIDWithReason(apiPath, peerIDStr, "")
+}
+
+func postPeerIDWithReason(apiPath string, peerIDStr string, reason string) error {
+	if _, err := peer.Decode(peerIDStr); err != nil {
+		return fmt.Errorf("invalid peerid: %v", err)
+	}
+	req := api.Client.R().SetQueryParam("peerid", peerIDStr)
+	if reason != "" {
+		req = req.SetQueryParam("reason", reason)
+	}
+	res, err := req.Post(joinAPIPath(apiPath))
+	if err != nil {
+		return err
+	}
+	return api.PrintResponseData(res)
+}
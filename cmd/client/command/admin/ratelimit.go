@@ -0,0 +1,100 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kenlabs/pando/cmd/client/command/api"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+)
+
+const (
+	ratePolicyPath = "/ratelimit/policy"
+	rateStatePath  = "/ratelimit/state"
+	consumersPath  = "/ratelimit/consumers"
+)
+
+func rateLimitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ratelimit",
+		Short: "show or change the rate tiers of the DAG sync, or show the rate limiter state",
+	}
+
+	childCommands := []*cobra.Command{
+		ratePolicyShowCmd(),
+		ratePolicySetCmd(),
+		rateStateCmd(),
+		consumersCmd(),
+	}
+	cmd.AddCommand(childCommands...)
+
+	return cmd
+}
+
+func ratePolicyShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show",
+		Short: "show the rate policy in use",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			res, err := api.Client.R().Get(joinAPIPath(ratePolicyPath))
+			if err != nil {
+				return err
+			}
+			return api.PrintResponseData(res)
+		},
+	}
+}
+
+func ratePolicySetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <file>",
+		Short: "replace the rate policy with the one in a JSON file, until the daemon restarts",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			policyBytes, err := ioutil.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			if !json.Valid(policyBytes) {
+				return fmt.Errorf("%s is not a valid JSON file", args[0])
+			}
+
+			res, err := api.Client.R().
+				SetHeader("Content-Type", "application/json").
+				SetBody(policyBytes).
+				Post(joinAPIPath(ratePolicyPath))
+			if err != nil {
+				return err
+			}
+			return api.PrintResponseData(res)
+		},
+	}
+}
+
+func rateStateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "state",
+		Short: "show the tokens of the gate limiter, and the tier, tokens and throttling history of each peer limiter",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			res, err := api.Client.R().Get(joinAPIPath(rateStatePath))
+			if err != nil {
+				return err
+			}
+			return api.PrintResponseData(res)
+		},
+	}
+}
+
+func consumersCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "consumers",
+		Short: "show the tier, requests, bytes sent and throttling history of each consumer",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			res, err := api.Client.R().Get(joinAPIPath(consumersPath))
+			if err != nil {
+				return err
+			}
+			return api.PrintResponseData(res)
+		},
+	}
+}
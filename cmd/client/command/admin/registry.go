@@ -0,0 +1,99 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/kenlabs/pando/cmd/client/command/api"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"net/http"
+)
+
+const (
+	registryExportPath = "/registry/export"
+	registryImportPath = "/registry/import"
+)
+
+func registryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "registry",
+		Short: "export or import the registry to migrate or seed Pando nodes",
+	}
+
+	childCommands := []*cobra.Command{
+		registryExportCmd(),
+		registryImportCmd(),
+	}
+	cmd.AddCommand(childCommands...)
+
+	return cmd
+}
+
+func registryExportCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "export <file>",
+		Short: "export the providers, policy, bans, sequences and sync heads to a JSON file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			res, err := api.Client.R().Get(joinAPIPath(registryExportPath))
+			if err != nil {
+				return err
+			}
+			if res.StatusCode() != http.StatusOK {
+				return api.PrintResponseData(res)
+			}
+
+			resJson := struct {
+				Data json.RawMessage
+			}{}
+			if err = json.Unmarshal(res.Body(), &resJson); err != nil {
+				return err
+			}
+			var doc bytes.Buffer
+			if err = json.Indent(&doc, resJson.Data, "", " "); err != nil {
+				return err
+			}
+			if err = ioutil.WriteFile(args[0], doc.Bytes(), 0600); err != nil {
+				return err
+			}
+			fmt.Printf("registry exported to %s\n", args[0])
+			return nil
+		},
+	}
+}
+
+type registryImportReq struct {
+	mode string
+}
+
+var registryImportRequest = &registryImportReq{}
+
+func registryImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "import an exported JSON file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			docBytes, err := ioutil.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+
+			res, err := api.Client.R().
+				SetHeader("Content-Type", "application/json").
+				SetQueryParam("mode", registryImportRequest.mode).
+				SetBody(docBytes).
+				Post(joinAPIPath(registryImportPath))
+			if err != nil {
+				return err
+			}
+			return api.PrintResponseData(res)
+		},
+	}
+
+	cmd.Flags().StringVarP(&registryImportRequest.mode, "mode", "m", "merge",
+		"merge: keep the local entries on conflict, replace: remove the local entries not imported")
+
+	return cmd
+}
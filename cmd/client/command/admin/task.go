@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"github.com/kenlabs/pando/cmd/client/command/api"
+	"github.com/spf13/cobra"
+)
+
+const (
+	taskPath     = "/task"
+	taskListPath = "/task/list"
+)
+
+func taskCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "task",
+		Short: "show the background tasks",
+	}
+
+	childCommands := []*cobra.Command{
+		taskShowCmd(),
+		taskListCmd(),
+	}
+	cmd.AddCommand(childCommands...)
+
+	return cmd
+}
+
+func taskShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <id>",
+		Short: "show the status of a task",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			res, err := api.Client.R().
+				SetQueryParam("id", args[0]).
+				Get(joinAPIPath(taskPath))
+			if err != nil {
+				return err
+			}
+			return api.PrintResponseData(res)
+		},
+	}
+}
+
+func taskListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "list all the tasks",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			res, err := api.Client.R().Get(joinAPIPath(taskListPath))
+			if err != nil {
+				return err
+			}
+			return api.PrintResponseData(res)
+		},
+	}
+}
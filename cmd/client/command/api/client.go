@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"github.com/go-resty/resty/v2"
 	"github.com/kenlabs/pando/pkg/api/types"
+	"github.com/kenlabs/pando/pkg/auth"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"io/ioutil"
+	"net/http"
 	"path"
 	"strings"
 	"time"
@@ -17,6 +21,34 @@ func NewClient(apiBaseURL string) {
 	Client = resty.New().SetBaseURL(apiBaseURL).SetDebug(false).SetTimeout(10 * time.Second)
 }
 
+// SetAPIKey authenticates the requests of the client by the API key token
+func SetAPIKey(token string) {
+	Client.SetHeader("X-API-Key", token)
+}
+
+// SignWith signs the requests of the client by the private key of a peer, so
+// that they are authenticated as the peer.
+func SignWith(privateKey crypto.PrivKey) {
+	Client.SetPreRequestHook(func(_ *resty.Client, req *http.Request) error {
+		var body []byte
+		if req.GetBody != nil {
+			bodyReader, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+			if body, err = ioutil.ReadAll(bodyReader); err != nil {
+				return err
+			}
+		}
+		signature, err := auth.SignRequest(privateKey, req.Method, req.URL.RequestURI(), body)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(auth.SignatureHeader, signature)
+		return nil
+	})
+}
+
 func PrintResponseData(res *resty.Response) error {
 	resJson := types.ResponseJson{}
 	err := json.Unmarshal(res.Body(), &resJson)
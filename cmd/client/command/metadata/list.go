@@ -1,24 +1,118 @@
 package metadata
 
 import (
+	"fmt"
 	"github.com/kenlabs/pando/cmd/client/command/api"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/spf13/cobra"
+	"strconv"
+	"time"
 )
 
 const listPath = "/list"
 
+type listAPIQuery struct {
+	from     string
+	to       string
+	since    string
+	until    string
+	provider string
+	compact  bool
+	limit    int
+	cursor   string
+}
+
+var listQuery = &listAPIQuery{}
+
 func listCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "return a list of metadata snapshots",
+		Long: "return a list of metadata snapshots, or a page of the snapshots " +
+			"if any of the filter or page flags is given",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			res, err := api.Client.R().Get(joinAPIPath(listPath))
+			if err := listQuery.validateFlags(); err != nil {
+				return err
+			}
+			res, err := api.Client.R().
+				SetQueryParams(listQuery.queryParams(cmd)).
+				Get(joinAPIPath(listPath))
 			if err != nil {
 				return err
 			}
 			return api.PrintResponseData(res)
 		},
 	}
+	listQuery.setFlags(cmd)
 
 	return cmd
 }
+
+func (q *listAPIQuery) setFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&q.from, "from", "",
+		"only list the snapshots from the height")
+	cmd.Flags().StringVar(&q.to, "to", "",
+		"only list the snapshots up to the height")
+	cmd.Flags().StringVar(&q.since, "since", "",
+		"only list the snapshots created since the time, in RFC3339")
+	cmd.Flags().StringVar(&q.until, "until", "",
+		"only list the snapshots created until the time, in RFC3339")
+	cmd.Flags().StringVarP(&q.provider, "provider", "p", "",
+		"only list the snapshots including updates of the provider")
+	cmd.Flags().BoolVar(&q.compact, "compact", false,
+		"only list the heights and cids of the snapshots")
+	cmd.Flags().IntVar(&q.limit, "limit", 0,
+		"max number of snapshots in a page, the default is 100 and the max is 1000")
+	cmd.Flags().StringVar(&q.cursor, "cursor", "",
+		"list the page from the NextCursor of the previous page")
+}
+
+func (q *listAPIQuery) validateFlags() error {
+	for _, height := range []string{q.from, q.to} {
+		if height == "" {
+			continue
+		}
+		if _, err := strconv.ParseUint(height, 10, 64); err != nil {
+			return fmt.Errorf("invalid height: %v", err)
+		}
+	}
+	for _, t := range []string{q.since, q.until} {
+		if t == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, t); err != nil {
+			return fmt.Errorf("invalid time: %v", err)
+		}
+	}
+	if q.provider != "" {
+		if _, err := peer.Decode(q.provider); err != nil {
+			return fmt.Errorf("invalid provider: %v", err)
+		}
+	}
+	if q.limit < 0 {
+		return fmt.Errorf("invalid limit: %d", q.limit)
+	}
+	return nil
+}
+
+// queryParams returns the query parameters of the flags changed, so that the
+// whole list is returned without any.
+func (q *listAPIQuery) queryParams(cmd *cobra.Command) map[string]string {
+	flags := map[string]func() string{
+		"from":     func() string { return q.from },
+		"to":       func() string { return q.to },
+		"since":    func() string { return q.since },
+		"until":    func() string { return q.until },
+		"provider": func() string { return q.provider },
+		"compact":  func() string { return strconv.FormatBool(q.compact) },
+		"limit":    func() string { return strconv.Itoa(q.limit) },
+		"cursor":   func() string { return q.cursor },
+	}
+	params := make(map[string]string)
+	for name, value := range flags {
+		if cmd.Flags().Changed(name) {
+			params[name] = value()
+		}
+	}
+	return params
+}
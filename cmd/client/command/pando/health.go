@@ -4,29 +4,40 @@ import (
 	"fmt"
 	"github.com/kenlabs/pando/cmd/client/command/api"
 	"github.com/spf13/cobra"
+	"net/http"
 )
 
-const healthPath = "/health"
+const (
+	healthPath     = "/health/ready"
+	healthLivePath = "/health/live"
+)
 
 func healthCmd() *cobra.Command {
+	var live bool
 	cmd := &cobra.Command{
 		Use:   "health",
-		Short: "Check if Pando server is alive",
+		Short: "Check the health of Pando server, the components are shown by admin health",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			res, err := api.Client.R().Options(joinAPIPath(healthPath))
+			path := healthPath
+			if live {
+				path = healthLivePath
+			}
+			res, err := api.Client.R().Get(joinAPIPath(path))
 			if err != nil {
 				return err
 			}
 
-			if res.StatusCode() >= 200 && res.StatusCode() < 300 {
-				fmt.Println("I'm good.")
-			} else {
-				fmt.Println("Not healthy.")
+			if err = api.PrintResponseData(res); err != nil {
+				return err
+			}
+			if res.StatusCode() != http.StatusOK {
+				return fmt.Errorf("not healthy, status: %d", res.StatusCode())
 			}
-
 			return nil
 		},
 	}
+	cmd.Flags().BoolVar(&live, "live", false,
+		"only check if Pando server is running, without checking its components")
 
 	return cmd
 }
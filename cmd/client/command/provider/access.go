@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/kenlabs/pando/pkg/api/v1/model"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/spf13/cobra"
+
+	"github.com/kenlabs/pando/cmd/client/command/api"
+)
+
+const accessPath = "/access"
+
+type accessInfo struct {
+	peerID     string
+	privateKey string
+	collection string
+	restricted bool
+	grant      []string
+	revoke     []string
+}
+
+var accessRequest = &accessInfo{}
+
+func accessCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "access",
+		Short: "restrict a collection of the provider or make it public, and grant or revoke consumers",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := accessRequest.validateFlags(); err != nil {
+				return err
+			}
+
+			peerID, err := peer.Decode(accessRequest.peerID)
+			if err != nil {
+				return err
+			}
+
+			privateKeyEncoded, err := base64.StdEncoding.DecodeString(accessRequest.privateKey)
+			if err != nil {
+				return err
+			}
+			privateKey, err := crypto.UnmarshalPrivateKey(privateKeyEncoded)
+			if err != nil {
+				return err
+			}
+
+			var restricted *bool
+			if cmd.Flags().Changed("restricted") {
+				restricted = &accessRequest.restricted
+			}
+			grant, err := decodePeerIDs(accessRequest.grant)
+			if err != nil {
+				return err
+			}
+			revoke, err := decodePeerIDs(accessRequest.revoke)
+			if err != nil {
+				return err
+			}
+
+			data, err := model.MakeAccessRequest(peerID, privateKey, accessRequest.collection, restricted, grant, revoke)
+			if err != nil {
+				return err
+			}
+
+			// Sign the request by the provider, to authenticate it with ingest scope
+			// once registered, an API key of ingest scope takes precedence
+			api.SignWith(privateKey)
+			res, err := api.Client.R().
+				SetBody(data).
+				SetHeader("Content-Type", "application/octet-stream").
+				Post(joinAPIPath(accessPath))
+			if err != nil {
+				return err
+			}
+			return api.PrintResponseData(res)
+		},
+	}
+
+	accessRequest.setFlags(cmd)
+
+	return cmd
+}
+
+func (f *accessInfo) setFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.peerID, "peer-id", "",
+		"peerID of provider, required")
+	cmd.Flags().StringVar(&f.privateKey, "private-key", "",
+		"private key of provider, required")
+	cmd.Flags().StringVar(&f.collection, "collection", "",
+		"collection name, required")
+	cmd.Flags().BoolVar(&f.restricted, "restricted", false,
+		"restrict the collection to the granted consumers, or make it public with --restricted=false")
+	cmd.Flags().StringSliceVar(&f.grant, "grant", []string{},
+		"peerIDs of the consumers granted to read the collection")
+	cmd.Flags().StringSliceVar(&f.revoke, "revoke", []string{},
+		"peerIDs of the consumers revoked from reading the collection")
+}
+
+func (f *accessInfo) validateFlags() error {
+	if f.peerID == "" || f.privateKey == "" || f.collection == "" {
+		return fmt.Errorf("peerID, privateKey and collection are requied")
+	}
+
+	return nil
+}
+
+func decodePeerIDs(ids []string) ([]peer.ID, error) {
+	peerIDs := make([]peer.ID, 0, len(ids))
+	for _, id := range ids {
+		peerID, err := peer.Decode(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peerid %s: %v", id, err)
+		}
+		peerIDs = append(peerIDs, peerID)
+	}
+	return peerIDs, nil
+}
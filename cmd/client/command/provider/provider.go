@@ -17,6 +17,7 @@ func NewProviderCmd() *cobra.Command {
 
 	childCommands := []*cobra.Command{
 		registerCmd(),
+		accessCmd(),
 	}
 	cmd.AddCommand(childCommands...)
 
@@ -67,6 +67,9 @@ func registerCmd() *cobra.Command {
 				return nil
 			}
 
+			// Sign the request by the provider, to authenticate it with ingest scope
+			// once registered, an API key of ingest scope takes precedence
+			api.SignWith(privateKey)
 			res, err := api.Client.R().
 				SetBody(data).
 				SetHeader("Content-Type", "application/octet-stream").
@@ -1,17 +1,28 @@
 package command
 
 import (
+	"encoding/base64"
 	"fmt"
 	"github.com/kenlabs/pando/cmd/client/command/admin"
 	"github.com/kenlabs/pando/cmd/client/command/metadata"
 	"github.com/kenlabs/pando/cmd/client/command/pando"
 	"github.com/kenlabs/pando/cmd/client/command/provider"
+	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/spf13/cobra"
 	"net/url"
+	"os"
 
 	"github.com/kenlabs/pando/cmd/client/command/api"
 )
 
+// apiKeyEnv is the environment variable of the api key
+const apiKeyEnv = "PD_API_KEY"
+
+var (
+	apiKey  string
+	signKey string
+)
+
 func NewRoot() *cobra.Command {
 	rootCmd := &cobra.Command{
 		Use:        "pando",
@@ -22,12 +33,33 @@ func NewRoot() *cobra.Command {
 			if api.PandoAPIBaseURL == "" || err != nil {
 				return fmt.Errorf("pando api url is invalid, given: \"%s\"\n", api.PandoAPIBaseURL)
 			}
+			if apiKey == "" {
+				apiKey = os.Getenv(apiKeyEnv)
+			}
+			if apiKey != "" {
+				api.SetAPIKey(apiKey)
+			}
+			if signKey != "" {
+				privateKeyEncoded, err := base64.StdEncoding.DecodeString(signKey)
+				if err != nil {
+					return fmt.Errorf("sign key is invalid: %v", err)
+				}
+				privateKey, err := crypto.UnmarshalPrivateKey(privateKeyEncoded)
+				if err != nil {
+					return fmt.Errorf("sign key is invalid: %v", err)
+				}
+				api.SignWith(privateKey)
+			}
 			return nil
 		},
 	}
 
 	rootCmd.PersistentFlags().StringVarP(&api.PandoAPIBaseURL, "pando-api", "a", "",
 		"set pando api url")
+	rootCmd.PersistentFlags().StringVar(&apiKey, "api-key", "",
+		"api key to authenticate the requests, or set by "+apiKeyEnv)
+	rootCmd.PersistentFlags().StringVar(&signKey, "sign-key", "",
+		"private key of a peer to sign the requests, authenticating them as the peer")
 	api.NewClient(api.PandoAPIBaseURL)
 
 	childCommands := []*cobra.Command{
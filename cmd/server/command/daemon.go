@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/dgraph-io/badger/v3"
+	"github.com/ipfs/go-datastore"
 	mutexDataStoreFactory "github.com/ipfs/go-datastore/sync"
 	dataStoreFactory "github.com/ipfs/go-ds-leveldb"
 	logging "github.com/ipfs/go-log/v2"
@@ -11,14 +12,27 @@ import (
 	"github.com/kenlabs/pando-store/pkg/config"
 	"github.com/kenlabs/pando-store/pkg/migrate"
 	"github.com/kenlabs/pando-store/pkg/store"
+	"github.com/kenlabs/pando/pkg/access"
 	"github.com/kenlabs/pando/pkg/api/core"
 	"github.com/kenlabs/pando/pkg/api/v1/server"
+	"github.com/kenlabs/pando/pkg/auth"
+	"github.com/kenlabs/pando/pkg/dns"
+	"github.com/kenlabs/pando/pkg/evm"
+	"github.com/kenlabs/pando/pkg/health"
+	"github.com/kenlabs/pando/pkg/ingest"
 	"github.com/kenlabs/pando/pkg/legs"
+	"github.com/kenlabs/pando/pkg/lifecycle"
 	"github.com/kenlabs/pando/pkg/lotus"
+	"github.com/kenlabs/pando/pkg/metacache"
 	"github.com/kenlabs/pando/pkg/metadata"
+	"github.com/kenlabs/pando/pkg/migration"
 	"github.com/kenlabs/pando/pkg/policy"
+	"github.com/kenlabs/pando/pkg/purge"
 	"github.com/kenlabs/pando/pkg/registry"
+	"github.com/kenlabs/pando/pkg/registry/discovery"
+	"github.com/kenlabs/pando/pkg/task"
 	"github.com/kenlabs/pando/pkg/util/log"
+	"github.com/kenlabs/pando/pkg/webhook"
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p-core/crypto"
 	libp2pHost "github.com/libp2p/go-libp2p-core/host"
@@ -48,53 +62,95 @@ func DaemonCmd() *cobra.Command {
 				return fmt.Errorf(failedError, err)
 			}
 
-			mongoClient, err := connectMetaCache(Opt.MetaCache.Type, Opt.MetaCache.ConnectionURI)
-			if err != nil {
+			// The components are appended to the lifecycle once created, so that
+			// they are stopped in reverse if the daemon fails to start.
+			lc := lifecycle.New()
+			if err = initDaemon(lc); err != nil {
+				_ = stopDaemon(lc, nil)
 				return fmt.Errorf(failedError, err)
 			}
-			Opt.MetaCache.Client = mongoClient
-
-			storeInstance, err := initStoreInstance()
-			if err != nil {
+			if err = lc.Start(context.Background()); err != nil {
+				_ = stopDaemon(lc, nil)
 				return fmt.Errorf(failedError, err)
 			}
-			defer storeInstance.CacheStore.Close()
 
-			_, privateKey, err := Opt.Identity.Decode()
-			if err != nil {
-				return fmt.Errorf(failedError, err)
-			}
-
-			p2pHost, err := initP2PHost(privateKey)
-			if err != nil {
-				return fmt.Errorf(failedError, err)
-			}
-
-			c, err := initCore(storeInstance, p2pHost)
-			if err != nil {
-				return fmt.Errorf(failedError, err)
-			}
-
-			server, err := server.NewAPIServer(Opt, c)
-			if err != nil {
-				return fmt.Errorf(failedError, err)
-			}
-
-			server.MustStartAllServers()
-
-			quit := make(chan os.Signal)
+			quit := make(chan os.Signal, 1)
 			signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 			<-quit
-			fmt.Println("Shutting down servers...")
-			err = server.StopAllServers()
-			if err != nil {
+			fmt.Println("Shutting down Pando, interrupt again to stop without waiting...")
+			if err = stopDaemon(lc, quit); err != nil {
 				return err
 			}
+			fmt.Println("Bye, Pando!")
 			return nil
 		},
 	}
 }
 
+// initDaemon creates the components of the daemon in the order of their
+// dependencies, and appends them to the lifecycle.
+func initDaemon(lc *lifecycle.Lifecycle) error {
+	mongoClient, err := connectMetaCache(Opt.MetaCache.Type, Opt.MetaCache.ConnectionURI)
+	if err != nil {
+		return err
+	}
+	Opt.MetaCache.Client = mongoClient
+	lc.Append(lifecycle.Hook{Name: "metacache", OnStop: mongoClient.Disconnect})
+
+	storeInstance, err := initStoreInstance(lc)
+	if err != nil {
+		return err
+	}
+
+	_, privateKey, err := Opt.Identity.Decode()
+	if err != nil {
+		return err
+	}
+
+	p2pHost, err := initP2PHost(privateKey)
+	if err != nil {
+		return err
+	}
+	lc.Append(lifecycle.Hook{Name: "libp2p host", OnStop: func(context.Context) error {
+		return p2pHost.Close()
+	}})
+
+	c, err := initCore(lc, storeInstance, p2pHost)
+	if err != nil {
+		return err
+	}
+
+	apiServer, err := server.NewAPIServer(Opt, c)
+	if err != nil {
+		return err
+	}
+	lc.Append(lifecycle.Hook{
+		Name: "api servers",
+		OnStart: func(context.Context) error {
+			return apiServer.StartAllServers()
+		},
+		OnStop: apiServer.StopAllServers,
+	})
+	return nil
+}
+
+// stopDaemon stops the components within Lifecycle.ShutdownTimeout, or at
+// once on a signal from quit.
+func stopDaemon(lc *lifecycle.Lifecycle, quit <-chan os.Signal) error {
+	ctx, cancel := context.WithTimeout(context.Background(),
+		time.Duration(Opt.Lifecycle.ShutdownTimeoutInDurationFormat()))
+	defer cancel()
+	go func() {
+		select {
+		case <-quit:
+			logger.Warn("stopping without waiting for the pending work")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return lc.Stop(ctx)
+}
+
 func setLoglevel() error {
 	// Supported LogLevel are: DEBUG, INFO, WARN, ERROR, DPANIC, PANIC, FATAL, and
 	// their lower-case forms.
@@ -115,7 +171,7 @@ func setLoglevel() error {
 	return nil
 }
 
-func initStoreInstance() (*core.StoreInstance, error) {
+func initStoreInstance(lc *lifecycle.Lifecycle) (*core.StoreInstance, error) {
 	if Opt.DataStore.Type != "levelds" {
 		return nil, fmt.Errorf("only levelds datastore type supported")
 	}
@@ -151,33 +207,47 @@ func initStoreInstance() (*core.StoreInstance, error) {
 		}
 	}
 
-	dataStore, err := dataStoreFactory.NewDatastore(dataStoreDir, nil)
+	cacheStoreDir := filepath.Join(Opt.PandoRoot, Opt.CacheStore.Dir)
+	cacheStore, err := badger.Open(badger.DefaultOptions(cacheStoreDir))
 	if err != nil {
 		return nil, err
 	}
-	mutexDataStore := mutexDataStoreFactory.MutexWrap(dataStore)
-	//blockStore := blockStoreFactory.NewBlockstore(mutexDataStore)
+	lc.Append(lifecycle.Hook{Name: "cachestore", OnStop: func(context.Context) error {
+		return cacheStore.Close()
+	}})
 
-	cacheStoreDir := filepath.Join(Opt.PandoRoot, Opt.CacheStore.Dir)
-	cacheStore, err := badger.Open(badger.DefaultOptions(cacheStoreDir))
+	dataStore, err := dataStoreFactory.NewDatastore(dataStoreDir, nil)
 	if err != nil {
 		return nil, err
 	}
+	mutexDataStore := mutexDataStoreFactory.MutexWrap(dataStore)
+	//blockStore := blockStoreFactory.NewBlockstore(mutexDataStore)
 
 	pandoStore, err := store.NewStoreFromDatastore(context.Background(), mutexDataStore, &config.StoreConfig{
 		SnapShotInterval: Opt.DataStore.SnapShotInterval,
 		CacheSize:        config.DefaultCacheSize,
 	})
+	if err != nil {
+		_ = dataStore.Close()
+		return nil, err
+	}
+	// PandoStore persists its cache and closes the datastore
+	lc.Append(lifecycle.Hook{Name: "pandostore", OnStop: func(context.Context) error {
+		return pandoStore.Close()
+	}})
+
+	metadataQuerier, err := metacache.NewQuerier(&Opt.MetaCache)
 	if err != nil {
 		return nil, err
 	}
 
 	return &core.StoreInstance{
 		//DataStore:      dataStore,
-		CacheStore:     cacheStore,
-		MutexDataStore: mutexDataStore,
-		PandoStore:     pandoStore,
-		MetadataCache:  Opt.MetaCache.Client,
+		CacheStore:      cacheStore,
+		MutexDataStore:  mutexDataStore,
+		PandoStore:      pandoStore,
+		MetadataCache:   Opt.MetaCache.Client,
+		MetadataQuerier: metadataQuerier,
 	}, nil
 }
 
@@ -202,14 +272,12 @@ func initP2PHost(privateKey crypto.PrivKey) (libp2pHost.Host, error) {
 	return p2pHost, nil
 }
 
-func initCore(storeInstance *core.StoreInstance, p2pHost libp2pHost.Host) (*core.Core, error) {
-	c := &core.Core{}
+// initDiscoverer chains the configured discoverers, routing the discovery
+// addresses by their formats.  It returns nil if none is configured.
+func initDiscoverer(c *core.Core) (discovery.Discoverer, error) {
+	var sources []discovery.Source
 	var err error
 
-	c.StoreInstance = storeInstance
-	linkSystem := legs.MkLinkSystem(c.StoreInstance.PandoStore, nil, nil)
-	c.LinkSystem = &linkSystem
-
 	if Opt.Discovery.LotusGateway != "" {
 		logger.Infow("discovery using lotus", "gateway", Opt.Discovery.LotusGateway)
 		// Create lotus client
@@ -217,13 +285,79 @@ func initCore(storeInstance *core.StoreInstance, p2pHost libp2pHost.Host) (*core
 		if err != nil {
 			return nil, fmt.Errorf("cannot create lotus client: %v", err)
 		}
+		sources = append(sources, discovery.Source{
+			Name:       "lotus",
+			Match:      discovery.IsFilecoinAddr,
+			Discoverer: c.LotusDiscover,
+			Timeout:    time.Duration(Opt.Discovery.LotusTimeoutInDurationFormat()),
+		})
+	}
+
+	if Opt.Discovery.EVM.Endpoint != "" {
+		logger.Infow("discovery using evm", "endpoint", Opt.Discovery.EVM.Endpoint)
+		evmDiscover, err := evm.NewDiscoverer(Opt.Discovery.EVM.Endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create evm client: %v", err)
+		}
+		sources = append(sources, discovery.Source{
+			Name:       "evm",
+			Match:      discovery.IsEVMAddr,
+			Discoverer: evmDiscover,
+			Timeout:    time.Duration(Opt.Discovery.EVM.TimeoutInDurationFormat()),
+		})
+	}
+
+	if Opt.Discovery.DNS.Enable {
+		logger.Infow("discovery using dns", "resolver", Opt.Discovery.DNS.Resolver)
+		dnsDiscover, err := dns.NewDiscoverer(Opt.Discovery.DNS.Resolver)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create dns resolver: %v", err)
+		}
+		sources = append(sources, discovery.Source{
+			Name:       "dns",
+			Match:      discovery.IsDNSAddr,
+			Discoverer: dnsDiscover,
+			Timeout:    time.Duration(Opt.Discovery.DNS.TimeoutInDurationFormat()),
+		})
+	}
+
+	if len(sources) == 0 {
+		return nil, nil
+	}
+	return discovery.NewChain(time.Duration(Opt.Discovery.CacheTTLInDurationFormat()), sources...), nil
+}
+
+// sharedDatastore is the datastore shared with PandoStore, which closes it
+// after the other components stop.
+type sharedDatastore struct {
+	datastore.Batching
+}
+
+func (sharedDatastore) Close() error {
+	return nil
+}
+
+func initCore(lc *lifecycle.Lifecycle, storeInstance *core.StoreInstance, p2pHost libp2pHost.Host) (*core.Core, error) {
+	c := &core.Core{}
+	var err error
+
+	c.StoreInstance = storeInstance
+	linkSystem := legs.MkLinkSystem(c.StoreInstance.PandoStore, nil, nil)
+	c.LinkSystem = &linkSystem
+
+	c.Discoverer, err = initDiscoverer(c)
+	if err != nil {
+		return nil, err
 	}
 
 	c.Registry, err = registry.NewRegistry(context.Background(), &Opt.Discovery, &Opt.AccountLevel,
-		storeInstance.MutexDataStore, c.LotusDiscover)
+		sharedDatastore{storeInstance.MutexDataStore}, c.Discoverer)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create provider registryInstance: %v", err)
 	}
+	lc.Append(lifecycle.Hook{Name: "registry", OnStop: func(context.Context) error {
+		return c.Registry.Close()
+	}})
 
 	c.MetaManager, err = metadata.New(context.Background(),
 		storeInstance.MutexDataStore,
@@ -233,11 +367,22 @@ func initCore(storeInstance *core.StoreInstance, p2pHost libp2pHost.Host) (*core
 	if err != nil {
 		return nil, err
 	}
+	lc.Append(lifecycle.Hook{Name: "metadata manager", OnStop: c.MetaManager.Shutdown})
+	if Opt.Backup.APIKey != "" {
+		c.BackupGateways = health.NewCache(time.Duration(Opt.Health.GatewayCheckIntervalInDurationFormat()),
+			time.Duration(Opt.Health.CheckTimeoutInDurationFormat()),
+			health.Reachable(Opt.Backup.EstuaryGateway, Opt.Backup.ShuttleGateway))
+	}
 
 	backupGenInterval, err := time.ParseDuration(Opt.Backup.BackupGenInterval)
 	if err != nil {
 		return nil, err
 	}
+	// the access controller is ready before graphsync serves the consumers
+	c.Access, err = access.New(context.Background(), storeInstance.MutexDataStore, storeInstance.PandoStore)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create access controller: %v", err)
+	}
 	c.LegsCore, err = legs.NewLegsCore(context.Background(),
 		p2pHost,
 		storeInstance.MutexDataStore,
@@ -247,26 +392,116 @@ func initCore(storeInstance *core.StoreInstance, p2pHost libp2pHost.Host) (*core
 		backupGenInterval,
 		nil,
 		c.Registry,
+		c.Access,
 		Opt,
 	)
+	if err != nil {
+		return nil, err
+	}
+	lc.Append(lifecycle.Hook{Name: "legs", OnStop: c.LegsCore.Shutdown})
 
 	tokenRate := math.Ceil((0.8 * float64(Opt.RateLimit.Bandwidth)) / Opt.RateLimit.SingleDAGSize)
 	rateConfig := &policy.LimiterConfig{
-		TotalRate:     tokenRate,
-		TotalBurst:    int(math.Ceil(tokenRate)),
-		BaseTokenRate: tokenRate,
-		Registry:      c.Registry,
+		TotalRate:         tokenRate,
+		TotalBurst:        int(math.Ceil(tokenRate)),
+		BaseTokenRate:     tokenRate,
+		Registry:          c.Registry,
+		RatePolicy:        Opt.RateLimit.Policy,
+		PeerIdleTimeout:   time.Duration(Opt.RateLimit.PeerIdleTimeoutInDurationFormat()),
+		ReconcileInterval: time.Duration(Opt.RateLimit.ReconcileIntervalInDurationFormat()),
 	}
 	rateLimiter, err := policy.NewLimiter(*rateConfig)
 	if err != nil {
 		return nil, err
 	}
 
+	rateLimiter.WatchRegistry()
 	c.LegsCore.SetRatelimiter(rateLimiter)
+	c.RateLimiter = rateLimiter
+	lc.Append(lifecycle.Hook{Name: "rate limiter", OnStop: func(context.Context) error {
+		return rateLimiter.Close()
+	}})
+
+	if Opt.RateLimit.Consumer.Enable {
+		consumerConfig := *rateConfig
+		consumerConfig.RatePolicy = Opt.RateLimit.Consumer.Policy
+		consumerLimiter, err := policy.NewLimiter(consumerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create consumer rate limiter: %v", err)
+		}
+		consumerLimiter.WatchRegistry()
+		c.LegsCore.SetConsumerRatelimiter(consumerLimiter)
+		c.ConsumerLimiter = consumerLimiter
+		lc.Append(lifecycle.Hook{Name: "consumer rate limiter", OnStop: func(context.Context) error {
+			return consumerLimiter.Close()
+		}})
+	}
+
+	c.Webhooks, err = webhook.New(&Opt.Webhook)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create webhooks: %v", err)
+	}
+	c.Webhooks.WatchRegistry(c.Registry)
+	lc.Append(lifecycle.Hook{Name: "webhooks", OnStop: func(context.Context) error {
+		return c.Webhooks.Close()
+	}})
+
+	c.IngestLog, err = ingest.New(context.Background(), storeInstance.MutexDataStore,
+		storeInstance.PandoStore.SnapShotStore(), storeInstance.PandoStore, &Opt.IngestLog)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create ingest log: %v", err)
+	}
+	c.LegsCore.SetIngestLog(c.IngestLog)
+	lc.Append(lifecycle.Hook{Name: "ingest log", OnStop: func(context.Context) error {
+		return c.IngestLog.Close()
+	}})
+
+	if Opt.Auth.Enable {
+		c.Auth, err = initAuth(storeInstance)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.Migrator = migration.New(c.Registry, storeInstance.MutexDataStore, c.LegsCore.LS)
+
+	c.TaskManager = task.NewManager(context.Background())
+	lc.Append(lifecycle.Hook{Name: "task manager", OnStop: func(context.Context) error {
+		return c.TaskManager.Close()
+	}})
+	c.Purger = purge.New(storeInstance.PandoStore,
+		storeInstance.MutexDataStore,
+		storeInstance.MetadataCache,
+		rateLimiter,
+		c.TaskManager)
 
 	return c, nil
 }
 
+// initAuth creates the authenticator of the APIs, and writes a bootstrap
+// admin key to Auth.AdminKeyFile if there is no admin key.
+func initAuth(storeInstance *core.StoreInstance) (*auth.Authenticator, error) {
+	authenticator, err := auth.New(context.Background(), storeInstance.MutexDataStore,
+		time.Duration(Opt.Auth.SignatureMaxAgeInDurationFormat()))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create authenticator: %v", err)
+	}
+	if authenticator.HasKey(auth.ScopeAdmin) {
+		return authenticator, nil
+	}
+
+	_, token, err := authenticator.CreateKey(context.Background(), "bootstrap", []auth.Scope{auth.ScopeAdmin})
+	if err != nil {
+		return nil, fmt.Errorf("cannot create bootstrap admin key: %v", err)
+	}
+	keyFile := filepath.Join(Opt.PandoRoot, Opt.Auth.AdminKeyFile)
+	if err = os.WriteFile(keyFile, []byte(token), 0600); err != nil {
+		return nil, fmt.Errorf("cannot write bootstrap admin key: %v", err)
+	}
+	logger.Infof("bootstrap admin key is written to %s", keyFile)
+	return authenticator, nil
+}
+
 func connectMetaCache(storeType string, connectionURI string) (*mongo.Client, error) {
 	switch storeType {
 	case "mongodb":
@@ -0,0 +1,318 @@
+package access
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/kenlabs/pando/pkg/util/log"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+var logger = log.NewSubsystemLogger()
+
+// accessKeyPath is where the collections of the providers are stored
+const accessKeyPath = "/access"
+
+var (
+	// ErrDenied is returned when the consumer is not granted to read a
+	// restricted collection.
+	ErrDenied = errors.New("access denied")
+	// ErrBadUpdate is returned for invalid access updates
+	ErrBadUpdate = errors.New("invalid access update")
+)
+
+// Collection is the access rule of a collection of a provider, the
+// collections without rule are public.
+type Collection struct {
+	Name       string
+	Restricted bool
+	// Readers are the consumers granted to read the restricted collection
+	Readers []peer.ID
+}
+
+// Update changes the access rule of a collection
+type Update struct {
+	Collection string
+	// Restricted is nil to keep the current one
+	Restricted *bool
+	Grant      []peer.ID
+	Revoke     []peer.ID
+}
+
+// BlockGetter reads the blocks to find the collections they belong to
+type BlockGetter interface {
+	Get(ctx context.Context, c cid.Cid) ([]byte, error)
+}
+
+// Controller keeps the access rules of the provider collections, and checks
+// if the consumers can read the provider data.
+type Controller struct {
+	ds     datastore.Datastore
+	blocks BlockGetter
+
+	mutex sync.RWMutex
+	// rules are the collections by provider and collection name
+	rules map[peer.ID]map[string]*Collection
+	// restricted is the number of restricted collections
+	restricted int
+
+	targets *targetCache
+}
+
+// New creates a Controller with the rules persisted in ds
+func New(ctx context.Context, ds datastore.Datastore, blocks BlockGetter) (*Controller, error) {
+	c := &Controller{
+		ds:      ds,
+		blocks:  blocks,
+		rules:   make(map[peer.ID]map[string]*Collection),
+		targets: newTargetCache(defaultTargetCacheSize),
+	}
+	if err := c.load(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Controller) load(ctx context.Context) error {
+	results, err := c.ds.Query(ctx, query.Query{Prefix: accessKeyPath})
+	if err != nil {
+		return fmt.Errorf("cannot query access rules: %v", err)
+	}
+	defer results.Close()
+
+	for r := range results.Next() {
+		if r.Error != nil {
+			return fmt.Errorf("cannot read access rules: %v", r.Error)
+		}
+		provider, err := peer.Decode(path.Base(r.Key))
+		if err != nil {
+			return fmt.Errorf("invalid provider of access rules %s: %v", r.Key, err)
+		}
+		var collections []*Collection
+		if err = json.Unmarshal(r.Value, &collections); err != nil {
+			return fmt.Errorf("cannot decode access rules of %s: %v", provider, err)
+		}
+		rules := make(map[string]*Collection, len(collections))
+		for _, coll := range collections {
+			rules[coll.Name] = coll
+			if coll.Restricted {
+				c.restricted++
+			}
+		}
+		c.rules[provider] = rules
+	}
+	logger.Infow("loaded access rules", "providers", len(c.rules), "restricted", c.restricted)
+	return nil
+}
+
+// Update changes the access rule of a provider collection and persists it
+func (c *Controller) Update(ctx context.Context, provider peer.ID, update *Update) (*Collection, error) {
+	if err := provider.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: invalid provider: %v", ErrBadUpdate, err)
+	}
+	if update == nil || update.Collection == "" {
+		return nil, fmt.Errorf("%w: empty collection", ErrBadUpdate)
+	}
+	for _, reader := range append(update.Grant, update.Revoke...) {
+		if err := reader.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: invalid consumer: %v", ErrBadUpdate, err)
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	rules := c.rules[provider]
+	if rules == nil {
+		rules = make(map[string]*Collection)
+	}
+	prev := rules[update.Collection]
+
+	coll := &Collection{Name: update.Collection}
+	readers := make(map[peer.ID]struct{})
+	if prev != nil {
+		coll.Restricted = prev.Restricted
+		for _, reader := range prev.Readers {
+			readers[reader] = struct{}{}
+		}
+	}
+	if update.Restricted != nil {
+		coll.Restricted = *update.Restricted
+	}
+	for _, reader := range update.Grant {
+		readers[reader] = struct{}{}
+	}
+	for _, reader := range update.Revoke {
+		delete(readers, reader)
+	}
+	for reader := range readers {
+		coll.Readers = append(coll.Readers, reader)
+	}
+	sort.Slice(coll.Readers, func(i, j int) bool { return coll.Readers[i] < coll.Readers[j] })
+
+	// The collections public to all are not kept
+	if coll.Restricted || len(coll.Readers) != 0 {
+		rules[update.Collection] = coll
+	} else {
+		delete(rules, update.Collection)
+	}
+	if err := c.persist(ctx, provider, rules); err != nil {
+		// Roll back
+		if prev != nil {
+			rules[update.Collection] = prev
+		} else {
+			delete(rules, update.Collection)
+		}
+		return nil, err
+	}
+	if prev != nil && prev.Restricted {
+		c.restricted--
+	}
+	if coll.Restricted {
+		c.restricted++
+	}
+	if len(rules) == 0 {
+		delete(c.rules, provider)
+	} else {
+		c.rules[provider] = rules
+	}
+
+	logger.Infow("updated access rule", "provider", provider, "collection", coll.Name,
+		"restricted", coll.Restricted, "readers", len(coll.Readers))
+	return coll, nil
+}
+
+func (c *Controller) persist(ctx context.Context, provider peer.ID, rules map[string]*Collection) error {
+	key := datastore.NewKey(path.Join(accessKeyPath, provider.String()))
+	if len(rules) == 0 {
+		if err := c.ds.Delete(ctx, key); err != nil {
+			return fmt.Errorf("cannot delete access rules: %v", err)
+		}
+		return nil
+	}
+	value, err := json.Marshal(sortedCollections(rules))
+	if err != nil {
+		return fmt.Errorf("cannot encode access rules: %v", err)
+	}
+	if err = c.ds.Put(ctx, key, value); err != nil {
+		return fmt.Errorf("cannot save access rules: %v", err)
+	}
+	return nil
+}
+
+// Collections returns the access rules of the provider collections
+func (c *Controller) Collections(provider peer.ID) []*Collection {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return sortedCollections(c.rules[provider])
+}
+
+// HasRestricted checks if any collection is restricted, otherwise all the
+// consumers read the same data.
+func (c *Controller) HasRestricted() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.restricted > 0
+}
+
+// Restricted checks if the provider has any restricted collection
+func (c *Controller) Restricted(provider peer.ID) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if c.restricted == 0 {
+		return false
+	}
+	for _, coll := range c.rules[provider] {
+		if coll.Restricted {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed checks if the consumer can read the collection of the provider.
+// The providers can always read their own data.
+func (c *Controller) Allowed(provider peer.ID, collection string, consumer peer.ID) bool {
+	if consumer != "" && consumer == provider {
+		return true
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if c.restricted == 0 {
+		return true
+	}
+	coll, exists := c.rules[provider][collection]
+	if !exists || !coll.Restricted {
+		return true
+	}
+	for _, reader := range coll.Readers {
+		if reader == consumer {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckCid checks if the consumer can read the block of the cid, it returns
+// ErrDenied if the block is the metadata of a restricted collection the
+// consumer is not granted to.
+func (c *Controller) CheckCid(ctx context.Context, blockCid cid.Cid, consumer peer.ID) error {
+	c.mutex.RLock()
+	restricted := c.restricted
+	c.mutex.RUnlock()
+	if restricted == 0 {
+		return nil
+	}
+
+	t, err := c.target(ctx, blockCid)
+	if err != nil {
+		return err
+	}
+	if t == nil || c.Allowed(t.provider, t.collection, consumer) {
+		return nil
+	}
+	return fmt.Errorf("%w: collection %q of provider %s", ErrDenied, t.collection, t.provider)
+}
+
+func (c *Controller) target(ctx context.Context, blockCid cid.Cid) (*target, error) {
+	if t, ok := c.targets.get(blockCid); ok {
+		return t, nil
+	}
+	data, err := c.blocks.Get(ctx, blockCid)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot read block %s: %v", blockCid, err)
+	}
+	t, err := decodeTarget(blockCid, data)
+	if err != nil {
+		// Not decodable as IPLD, so not metadata
+		logger.Debugw("cannot decode block to check access", "cid", blockCid, "err", err)
+		t = nil
+	}
+	c.targets.put(blockCid, t)
+	return t, nil
+}
+
+func sortedCollections(rules map[string]*Collection) []*Collection {
+	collections := make([]*Collection, 0, len(rules))
+	for _, coll := range rules {
+		collections = append(collections, coll)
+	}
+	sort.Slice(collections, func(i, j int) bool { return collections[i].Name < collections[j].Name })
+	return collections
+}
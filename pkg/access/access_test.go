@@ -0,0 +1,140 @@
+package access
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/kenlabs/pando/pkg/types/schema"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type mapBlocks map[cid.Cid][]byte
+
+func (b mapBlocks) Get(_ context.Context, c cid.Cid) ([]byte, error) {
+	data, ok := b[c]
+	if !ok {
+		return nil, datastore.ErrNotFound
+	}
+	return data, nil
+}
+
+func (b mapBlocks) linkSystem() ipld.LinkSystem {
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.StorageWriteOpener = func(ipld.LinkContext) (io.Writer, ipld.BlockWriteCommitter, error) {
+		buf := bytes.NewBuffer(nil)
+		return buf, func(lnk ipld.Link) error {
+			b[lnk.(cidlink.Link).Cid] = buf.Bytes()
+			return nil
+		}, nil
+	}
+	return lsys
+}
+
+func newKey() (crypto.PrivKey, peer.ID) {
+	privKey, pubKey, err := crypto.GenerateEd25519Key(nil)
+	So(err, ShouldBeNil)
+	id, err := peer.IDFromPublicKey(pubKey)
+	So(err, ShouldBeNil)
+	return privKey, id
+}
+
+func storeMetadata(blocks mapBlocks, provider peer.ID, privKey crypto.PrivKey, collection string) cid.Cid {
+	meta, err := schema.NewMetaWithBytesPayload([]byte("payload"), provider, privKey)
+	So(err, ShouldBeNil)
+	meta.Collection = &collection
+	lnk, err := schema.MetadataLink(blocks.linkSystem(), meta)
+	So(err, ShouldBeNil)
+	return lnk.(cidlink.Link).Cid
+}
+
+func TestController(t *testing.T) {
+	Convey("TestController", t, func() {
+		ctx := context.Background()
+		ds := dssync.MutexWrap(datastore.NewMapDatastore())
+		blocks := mapBlocks{}
+		c, err := New(ctx, ds, blocks)
+		So(err, ShouldBeNil)
+
+		providerKey, provider := newKey()
+		_, reader := newKey()
+		_, stranger := newKey()
+		restricted := true
+
+		Convey("public by default", func() {
+			So(c.Restricted(provider), ShouldBeFalse)
+			So(c.Allowed(provider, "private", stranger), ShouldBeTrue)
+			So(c.Allowed(provider, "private", ""), ShouldBeTrue)
+		})
+
+		Convey("restrict collections and grant readers", func() {
+			coll, err := c.Update(ctx, provider, &Update{Collection: "private", Restricted: &restricted, Grant: []peer.ID{reader}})
+			So(err, ShouldBeNil)
+			So(coll.Restricted, ShouldBeTrue)
+			So(coll.Readers, ShouldResemble, []peer.ID{reader})
+			So(c.Restricted(provider), ShouldBeTrue)
+
+			So(c.Allowed(provider, "private", reader), ShouldBeTrue)
+			So(c.Allowed(provider, "private", provider), ShouldBeTrue)
+			So(c.Allowed(provider, "private", stranger), ShouldBeFalse)
+			So(c.Allowed(provider, "private", ""), ShouldBeFalse)
+			So(c.Allowed(provider, "public", stranger), ShouldBeTrue)
+
+			Convey("reload persisted rules", func() {
+				reloaded, err := New(ctx, ds, blocks)
+				So(err, ShouldBeNil)
+				So(reloaded.Collections(provider), ShouldResemble, c.Collections(provider))
+				So(reloaded.Allowed(provider, "private", stranger), ShouldBeFalse)
+			})
+
+			Convey("revoke readers and make public", func() {
+				_, err := c.Update(ctx, provider, &Update{Collection: "private", Revoke: []peer.ID{reader}})
+				So(err, ShouldBeNil)
+				So(c.Allowed(provider, "private", reader), ShouldBeFalse)
+
+				public := false
+				_, err = c.Update(ctx, provider, &Update{Collection: "private", Restricted: &public})
+				So(err, ShouldBeNil)
+				So(c.Restricted(provider), ShouldBeFalse)
+				So(c.Collections(provider), ShouldBeEmpty)
+				So(c.Allowed(provider, "private", stranger), ShouldBeTrue)
+			})
+
+			Convey("check blocks by cid", func() {
+				private := storeMetadata(blocks, provider, providerKey, "private")
+				public := storeMetadata(blocks, provider, providerKey, "public")
+				lsys := blocks.linkSystem()
+				lnk, err := lsys.Store(ipld.LinkContext{}, schema.LinkProto, basicnode.NewString("snapshot"))
+				So(err, ShouldBeNil)
+				other := lnk.(cidlink.Link).Cid
+
+				So(c.CheckCid(ctx, private, reader), ShouldBeNil)
+				So(errors.Is(c.CheckCid(ctx, private, stranger), ErrDenied), ShouldBeTrue)
+				So(c.CheckCid(ctx, public, stranger), ShouldBeNil)
+				So(c.CheckCid(ctx, other, stranger), ShouldBeNil)
+			})
+		})
+
+		Convey("reject invalid updates", func() {
+			_, err := c.Update(ctx, provider, &Update{})
+			So(errors.Is(err, ErrBadUpdate), ShouldBeTrue)
+			_, err = c.Update(ctx, provider, &Update{Collection: "private", Grant: []peer.ID{""}})
+			So(errors.Is(err, ErrBadUpdate), ShouldBeTrue)
+		})
+
+		Convey("consumer in context", func() {
+			So(ConsumerFrom(ctx), ShouldEqual, peer.ID(""))
+			So(ConsumerFrom(WithConsumer(ctx, reader)), ShouldEqual, reader)
+		})
+	})
+}
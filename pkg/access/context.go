@@ -0,0 +1,21 @@
+package access
+
+import (
+	"context"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+type consumerKey struct{}
+
+// WithConsumer returns a context carrying the consumer peer reading the data
+func WithConsumer(ctx context.Context, consumer peer.ID) context.Context {
+	return context.WithValue(ctx, consumerKey{}, consumer)
+}
+
+// ConsumerFrom returns the consumer peer of the context, empty for the
+// anonymous consumers.
+func ConsumerFrom(ctx context.Context) peer.ID {
+	consumer, _ := ctx.Value(consumerKey{}).(peer.ID)
+	return consumer
+}
@@ -0,0 +1,100 @@
+package access
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	_ "github.com/ipld/go-ipld-prime/codec/dagcbor"
+	_ "github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/multicodec"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// defaultTargetCacheSize bounds the number of blocks whose collections are
+// cached
+const defaultTargetCacheSize = 100000
+
+// target is the provider collection a metadata block belongs to
+type target struct {
+	provider   peer.ID
+	collection string
+}
+
+// decodeTarget reads the provider and the collection of a metadata block, it
+// returns nil for the blocks that are not metadata, such as snapshots.
+func decodeTarget(blockCid cid.Cid, data []byte) (*target, error) {
+	decoder, err := multicodec.LookupDecoder(blockCid.Prefix().Codec)
+	if err != nil {
+		return nil, err
+	}
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err = decoder(nb, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	n := nb.Build()
+
+	signature, _ := n.LookupByString("Signature")
+	provider, _ := n.LookupByString("Provider")
+	payload, _ := n.LookupByString("Payload")
+	if signature == nil || provider == nil || payload == nil {
+		return nil, nil
+	}
+
+	providerStr, err := provider.AsString()
+	if err != nil {
+		return nil, err
+	}
+	providerID, err := peer.Decode(providerStr)
+	if err != nil {
+		return nil, err
+	}
+	return &target{
+		provider:   providerID,
+		collection: lookupString(n, "Collection"),
+	}, nil
+}
+
+func lookupString(n ipld.Node, key string) string {
+	v, err := n.LookupByString(key)
+	if err != nil || v == nil {
+		return ""
+	}
+	s, _ := v.AsString()
+	return s
+}
+
+// targetCache caches the targets of the blocks, the blocks are immutable so
+// the entries never expire.  It is reset when full.
+type targetCache struct {
+	size    int
+	mutex   sync.Mutex
+	targets map[cid.Cid]*target
+}
+
+func newTargetCache(size int) *targetCache {
+	return &targetCache{
+		size:    size,
+		targets: make(map[cid.Cid]*target),
+	}
+}
+
+func (tc *targetCache) get(c cid.Cid) (*target, bool) {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	t, ok := tc.targets[c]
+	return t, ok
+}
+
+func (tc *targetCache) put(c cid.Cid, t *target) {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	if len(tc.targets) >= tc.size {
+		tc.targets = make(map[cid.Cid]*target)
+	}
+	tc.targets[c] = t
+}
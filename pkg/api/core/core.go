@@ -5,10 +5,21 @@ import (
 	"github.com/ipfs/go-datastore/sync"
 	"github.com/ipld/go-ipld-prime"
 	"github.com/kenlabs/pando-store/pkg/store"
+	"github.com/kenlabs/pando/pkg/access"
+	"github.com/kenlabs/pando/pkg/auth"
+	"github.com/kenlabs/pando/pkg/health"
+	"github.com/kenlabs/pando/pkg/ingest"
 	"github.com/kenlabs/pando/pkg/legs"
 	"github.com/kenlabs/pando/pkg/lotus"
+	"github.com/kenlabs/pando/pkg/metacache"
 	"github.com/kenlabs/pando/pkg/metadata"
+	"github.com/kenlabs/pando/pkg/migration"
+	"github.com/kenlabs/pando/pkg/policy"
+	"github.com/kenlabs/pando/pkg/purge"
 	"github.com/kenlabs/pando/pkg/registry"
+	"github.com/kenlabs/pando/pkg/registry/discovery"
+	"github.com/kenlabs/pando/pkg/task"
+	"github.com/kenlabs/pando/pkg/webhook"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -16,10 +27,25 @@ type Core struct {
 	MetaManager *metadata.MetaManager
 	//StateTree     *statetree.StateTree
 	LotusDiscover *lotus.Discoverer
+	Discoverer    discovery.Discoverer
 	Registry      *registry.Registry
 	LegsCore      *legs.Core
 	StoreInstance *StoreInstance
 	LinkSystem    *ipld.LinkSystem
+	RateLimiter   *policy.Limiter
+	// ConsumerLimiter is nil if the consumers are not rate limited
+	ConsumerLimiter *policy.Limiter
+	TaskManager     *task.Manager
+	Purger          *purge.Purger
+	Webhooks        *webhook.Dispatcher
+	Migrator        *migration.Migrator
+	Access          *access.Controller
+	IngestLog       *ingest.Log
+	// Auth is nil if the APIs are not authenticated
+	Auth *auth.Authenticator
+	// BackupGateways caches the reachability of the backup gateways for the
+	// health checks, it is nil if the backup is not configured.
+	BackupGateways *health.Cache
 }
 
 type StoreInstance struct {
@@ -29,4 +55,6 @@ type StoreInstance struct {
 	PandoStore    *store.PandoStore
 	CacheStore    *badger.DB
 	MetadataCache *mongo.Client
+	// MetadataQuerier runs the metadata queries on the metadata cache
+	MetadataQuerier metacache.Querier
 }
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/kenlabs/pando/pkg/access"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// AuthPeerContextKey is the gin context key of the authenticated peer ID of
+// the consumer, the reads of restricted collections are checked against it.
+const AuthPeerContextKey = "pando/auth-peer-id"
+
+// ConsumerContext returns the context of the request carrying the
+// authenticated consumer peer, anonymous if the request is not authenticated.
+func ConsumerContext(ctx *gin.Context) context.Context {
+	reqCtx := ctx.Request.Context()
+	if v, exists := ctx.Get(AuthPeerContextKey); exists {
+		if consumer, ok := v.(peer.ID); ok {
+			return access.WithConsumer(reqCtx, consumer)
+		}
+	}
+	return reqCtx
+}
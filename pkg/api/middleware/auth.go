@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/kenlabs/pando/pkg/api/types"
+	"github.com/kenlabs/pando/pkg/auth"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader is the header carrying the API key token, the token can also
+// be given as a bearer token of the Authorization header.
+const APIKeyHeader = "X-API-Key"
+
+// AuthScopesContextKey is the gin context key of the scopes of the
+// authenticated request
+const AuthScopesContextKey = "pando/auth-scopes"
+
+// peerKeyPrefix prefixes the peer IDs used as the key IDs of the peer-signed
+// requests, so that they are rate limited by peer.
+const peerKeyPrefix = "peer/"
+
+// maxSignedBodySize bounds the body read to verify the signature
+const maxSignedBodySize = 32 << 20
+
+// ScopeFunc returns the scope required by the request
+type ScopeFunc func(ctx *gin.Context) auth.Scope
+
+// RouteScopes returns a ScopeFunc requiring the scopes of the routes, like
+// "POST /provider/register", and the default scope of the other routes.
+func RouteScopes(routes map[string]auth.Scope, defaultScope auth.Scope) ScopeFunc {
+	return func(ctx *gin.Context) auth.Scope {
+		if scope, exists := routes[ctx.Request.Method+" "+ctx.FullPath()]; exists {
+			return scope
+		}
+		return defaultScope
+	}
+}
+
+// WithAuth authenticates the requests by API keys or by the signatures of the
+// peers in auth.SignatureHeader, and checks they are granted the scopes of
+// the routes.  The scopes of the peers are checked against providers, see
+// auth.PeerScopes.  The requests without credentials are allowed to the routes
+// of read scope if anonymousRead, and all the requests to the routes of
+// auth.ScopeNone.
+func WithAuth(authenticator *auth.Authenticator, providers auth.Providers, scopeOf ScopeFunc, anonymousRead bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		required := scopeOf(ctx)
+		if required == auth.ScopeNone {
+			ctx.Next()
+			return
+		}
+
+		var scopes []auth.Scope
+		if token := apiKeyToken(ctx); token != "" {
+			key, err := authenticator.AuthenticateKey(token)
+			if err != nil {
+				abortUnauthenticated(ctx, err)
+				return
+			}
+			ctx.Set(AuthKeyContextKey, key.ID)
+			scopes = key.Scopes
+		} else if signature := ctx.GetHeader(auth.SignatureHeader); signature != "" {
+			var body []byte
+			if ctx.Request.Body != nil {
+				var err error
+				body, err = ioutil.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxSignedBodySize))
+				if err != nil {
+					abortUnauthenticated(ctx, errors.New("cannot read request body"))
+					return
+				}
+				ctx.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+			}
+
+			peerID, err := authenticator.VerifyRequest(signature, ctx.Request.Method, ctx.Request.URL.RequestURI(), body)
+			if err != nil {
+				abortUnauthenticated(ctx, err)
+				return
+			}
+			ctx.Set(AuthPeerContextKey, peerID)
+			ctx.Set(AuthKeyContextKey, peerKeyPrefix+peerID.String())
+			scopes = auth.PeerScopes(providers, peerID)
+		} else {
+			if anonymousRead && required == auth.ScopeRead {
+				ctx.Next()
+				return
+			}
+			abortUnauthenticated(ctx, errors.New("credentials are required"))
+			return
+		}
+
+		if !auth.HasScope(scopes, required) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden,
+				types.NewErrorResponse(http.StatusForbidden, "scope "+string(required)+" is required"))
+			return
+		}
+		ctx.Set(AuthScopesContextKey, scopes)
+		ctx.Next()
+	}
+}
+
+func apiKeyToken(ctx *gin.Context) string {
+	if token := ctx.GetHeader(APIKeyHeader); token != "" {
+		return token
+	}
+	authorization := ctx.GetHeader("Authorization")
+	if strings.HasPrefix(authorization, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))
+	}
+	return ""
+}
+
+func abortUnauthenticated(ctx *gin.Context, err error) {
+	ctx.AbortWithStatusJSON(http.StatusUnauthorized,
+		types.NewErrorResponse(http.StatusUnauthorized, err.Error()))
+}
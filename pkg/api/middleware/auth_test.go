@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/kenlabs/pando/pkg/access"
+	"github.com/kenlabs/pando/pkg/auth"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testProviders map[peer.ID]bool
+
+func (p testProviders) IsRegistered(providerID peer.ID) bool {
+	_, exists := p[providerID]
+	return exists
+}
+
+// IsBanned is true for the providers mapped to true
+func (p testProviders) IsBanned(peerID peer.ID) bool { return p[peerID] }
+
+func TestWithAuth(t *testing.T) {
+	Convey("TestWithAuth", t, func() {
+		ctx := context.Background()
+		authenticator, err := auth.New(ctx, dssync.MutexWrap(datastore.NewMapDatastore()), time.Minute)
+		So(err, ShouldBeNil)
+		_, readToken, err := authenticator.CreateKey(ctx, "reader", []auth.Scope{auth.ScopeRead})
+		So(err, ShouldBeNil)
+		_, adminToken, err := authenticator.CreateKey(ctx, "admin", []auth.Scope{auth.ScopeAdmin})
+		So(err, ShouldBeNil)
+
+		scopes := RouteScopes(map[string]auth.Scope{
+			"POST /register": auth.ScopeIngest,
+			"GET /health":    auth.ScopeNone,
+		}, auth.ScopeRead)
+		providers := testProviders{}
+		newRouter := func(anonymousRead bool) *gin.Engine {
+			router := gin.New()
+			router.Use(WithAuth(authenticator, providers, scopes, anonymousRead))
+			router.GET("/list", func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, "%s", access.ConsumerFrom(ConsumerContext(ctx)))
+			})
+			router.GET("/health", func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, "ok")
+			})
+			router.POST("/register", func(ctx *gin.Context) {
+				var body []byte
+				if ctx.Request.Body != nil {
+					body, _ = ioutil.ReadAll(ctx.Request.Body)
+				}
+				ctx.String(http.StatusOK, "%s %s", ctx.GetString(AuthKeyContextKey), body)
+			})
+			return router
+		}
+		router := newRouter(true)
+
+		request := func(router *gin.Engine, req *http.Request) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("routes open to all", func() {
+			req, _ := http.NewRequest(http.MethodGet, "/health", nil)
+			So(request(newRouter(false), req).Code, ShouldEqual, http.StatusOK)
+			req.Header.Set(APIKeyHeader, "invalid")
+			So(request(newRouter(false), req).Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("anonymous read", func() {
+			req, _ := http.NewRequest(http.MethodGet, "/list", nil)
+			So(request(router, req).Code, ShouldEqual, http.StatusOK)
+			So(request(newRouter(false), req).Code, ShouldEqual, http.StatusUnauthorized)
+
+			req, _ = http.NewRequest(http.MethodPost, "/register", nil)
+			So(request(router, req).Code, ShouldEqual, http.StatusUnauthorized)
+		})
+
+		Convey("api keys with scopes", func() {
+			req, _ := http.NewRequest(http.MethodPost, "/register", nil)
+			req.Header.Set(APIKeyHeader, readToken)
+			So(request(router, req).Code, ShouldEqual, http.StatusForbidden)
+
+			req.Header.Del(APIKeyHeader)
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+			So(request(router, req).Code, ShouldEqual, http.StatusOK)
+
+			req.Header.Set("Authorization", "Bearer "+adminToken+"x")
+			So(request(router, req).Code, ShouldEqual, http.StatusUnauthorized)
+		})
+
+		Convey("peer-signed requests", func() {
+			privateKey, pubKey, err := crypto.GenerateEd25519Key(nil)
+			So(err, ShouldBeNil)
+			peerID, err := peer.IDFromPublicKey(pubKey)
+			So(err, ShouldBeNil)
+
+			body := []byte("envelope")
+			register := func() *httptest.ResponseRecorder {
+				signature, err := auth.SignRequest(privateKey, http.MethodPost, "/register", body)
+				So(err, ShouldBeNil)
+				req, _ := http.NewRequest(http.MethodPost, "/register", bytes.NewReader(body))
+				req.Header.Set(auth.SignatureHeader, signature)
+				return request(router, req)
+			}
+			// unknown peers cannot ingest
+			So(register().Code, ShouldEqual, http.StatusForbidden)
+
+			providers[peerID] = false
+			w := register()
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Body.String(), ShouldEqual, "peer/"+peerID.String()+" envelope")
+
+			providers[peerID] = true
+			So(register().Code, ShouldEqual, http.StatusForbidden)
+
+			signature, err := auth.SignRequest(privateKey, http.MethodGet, "/list", nil)
+			So(err, ShouldBeNil)
+			req, _ := http.NewRequest(http.MethodGet, "/list", nil)
+			req.Header.Set(auth.SignatureHeader, signature)
+			w = request(router, req)
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Body.String(), ShouldEqual, peerID.String())
+
+			// replayed
+			So(request(router, req).Code, ShouldEqual, http.StatusUnauthorized)
+		})
+	})
+}
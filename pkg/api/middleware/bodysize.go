@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/kenlabs/pando/pkg/api/types"
+	"net/http"
+)
+
+// WithMaxBodySize limits the request bodies to maxSize bytes, 0 for no limit.
+// The requests declaring a larger body are rejected, and reading over the
+// limit fails for the others.
+func WithMaxBodySize(maxSize int64) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if maxSize <= 0 {
+			ctx.Next()
+			return
+		}
+		if ctx.Request.ContentLength > maxSize {
+			ctx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge,
+				types.NewErrorResponse(http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("request body is larger than %d bytes", maxSize)))
+			return
+		}
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxSize)
+		ctx.Next()
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithMaxBodySize(t *testing.T) {
+	Convey("TestWithMaxBodySize", t, func() {
+		router := gin.New()
+		router.Use(WithMaxBodySize(8))
+		router.POST("/", func(ctx *gin.Context) {
+			if _, err := ioutil.ReadAll(ctx.Request.Body); err != nil {
+				ctx.Status(http.StatusBadRequest)
+				return
+			}
+			ctx.Status(http.StatusOK)
+		})
+
+		request := func(body string, contentLength int64) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+			req.ContentLength = contentLength
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("pass the bodies within the limit", func() {
+			So(request("12345678", 8).Code, ShouldEqual, http.StatusOK)
+		})
+		Convey("reject the bodies declared over the limit", func() {
+			So(request("123456789", 9).Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+		})
+		Convey("fail reading the bodies over the limit of unknown length", func() {
+			So(request("123456789", -1).Code, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
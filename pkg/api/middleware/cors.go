@@ -5,10 +5,19 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func WithCorsAllowAllOrigin() gin.HandlerFunc {
+// WithCors allows the cross-origin requests from allowOrigins, or from all
+// the origins if it is empty.
+func WithCors(allowOrigins []string) (gin.HandlerFunc, error) {
 	corsConf := cors.DefaultConfig()
 	corsConf.AddAllowHeaders("Authorization")
-	corsConf.AllowAllOrigins = true
+	if len(allowOrigins) == 0 {
+		corsConf.AllowAllOrigins = true
+	} else {
+		corsConf.AllowOrigins = allowOrigins
+	}
+	if err := corsConf.Validate(); err != nil {
+		return nil, err
+	}
 
-	return cors.New(corsConf)
+	return cors.New(corsConf), nil
 }
@@ -8,24 +8,40 @@ import (
 	"testing"
 )
 
-func TestWithCorsAllowAllOrigin(t *testing.T) {
-	Convey("TestWithCorsAllowAllOrigin", t, func() {
-		router := gin.New()
-		router.GET("/", gin.Logger(), WithCorsAllowAllOrigin(), func(ctx *gin.Context) {
-			ctx.Data(http.StatusOK, "text/plain", nil)
-		})
+func TestWithCors(t *testing.T) {
+	Convey("TestWithCors", t, func() {
+		originOf := func(allowOrigins []string, origin string) string {
+			withCors, err := WithCors(allowOrigins)
+			So(err, ShouldBeNil)
+			router := gin.New()
+			router.GET("/", gin.Logger(), withCors, func(ctx *gin.Context) {
+				ctx.Data(http.StatusOK, "text/plain", nil)
+			})
 
-		server := httptest.NewServer(router)
-		defer server.Close()
+			server := httptest.NewServer(router)
+			defer server.Close()
 
-		req, _ := http.NewRequest("GET", "http://"+server.Listener.Addr().String(), nil)
-		req.Header.Add("Origin", "https://kencloud.com")
+			req, _ := http.NewRequest("GET", "http://"+server.Listener.Addr().String(), nil)
+			req.Header.Add("Origin", origin)
 
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			t.Error(err)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Error(err)
+			}
+			return resp.Header.Get("Access-Control-Allow-Origin")
 		}
 
-		So(resp.Header.Get("Access-Control-Allow-Origin"), ShouldEqual, "*")
+		Convey("allow all the origins without an allow-list", func() {
+			So(originOf(nil, "https://kencloud.com"), ShouldEqual, "*")
+		})
+		Convey("allow the origins in the allow-list only", func() {
+			allowOrigins := []string{"https://kencloud.com"}
+			So(originOf(allowOrigins, "https://kencloud.com"), ShouldEqual, "https://kencloud.com")
+			So(originOf(allowOrigins, "https://example.com"), ShouldBeEmpty)
+		})
+		Convey("reject the invalid origins", func() {
+			_, err := WithCors([]string{"kencloud.com"})
+			So(err, ShouldNotBeNil)
+		})
 	})
 }
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/kenlabs/pando/pkg/api/types"
+	"github.com/kenlabs/pando/pkg/metrics"
+	"github.com/kenlabs/pando/pkg/option"
+	"golang.org/x/time/rate"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AuthKeyContextKey is the gin context key of the authenticated API key ID,
+// the requests with it are limited by key instead of by client IP.
+const AuthKeyContextKey = "pando/auth-key-id"
+
+const (
+	limitByIP  = "ip"
+	limitByKey = "key"
+)
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// apiRateLimiter keeps the token buckets of the API clients
+type apiRateLimiter struct {
+	cfg         option.APIRateLimit
+	idleTimeout time.Duration
+	costs       map[string]int
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func newAPIRateLimiter(cfg option.APIRateLimit) (*apiRateLimiter, error) {
+	if cfg.IPRate <= 0 || cfg.IPBurst <= 0 || cfg.KeyRate <= 0 || cfg.KeyBurst <= 0 {
+		return nil, fmt.Errorf("rates and bursts of API rate limit should be positive")
+	}
+	l := &apiRateLimiter{
+		cfg:         cfg,
+		idleTimeout: time.Duration(cfg.IdleTimeoutInDurationFormat()),
+		costs:       make(map[string]int, len(cfg.RouteCosts)),
+		buckets:     make(map[string]*bucket),
+		lastSweep:   time.Now(),
+	}
+	for _, rc := range cfg.RouteCosts {
+		if rc.Cost < 1 {
+			return nil, fmt.Errorf("cost of API route %q should be positive", rc.Route)
+		}
+		l.costs[rc.Route] = rc.Cost
+	}
+	return l, nil
+}
+
+// reserve takes cost tokens from the bucket of the client, and returns how long
+// to wait before retrying if there are not enough tokens.
+func (l *apiRateLimiter) reserve(limit string, client string, cost int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.idleTimeout > 0 && now.Sub(l.lastSweep) > l.idleTimeout {
+		for k, b := range l.buckets {
+			if now.Sub(b.lastSeen) > l.idleTimeout {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	key := limit + "/" + client
+	b, exists := l.buckets[key]
+	if !exists {
+		if limit == limitByKey {
+			b = &bucket{limiter: rate.NewLimiter(rate.Limit(l.cfg.KeyRate), l.cfg.KeyBurst)}
+		} else {
+			b = &bucket{limiter: rate.NewLimiter(rate.Limit(l.cfg.IPRate), l.cfg.IPBurst)}
+		}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	// A request costing more than the burst would never be allowed
+	if cost > b.limiter.Burst() {
+		cost = b.limiter.Burst()
+	}
+	r := b.limiter.ReserveN(now, cost)
+	delay := r.DelayFrom(now)
+	if delay > 0 {
+		r.CancelAt(now)
+	}
+	return delay
+}
+
+func (l *apiRateLimiter) cost(route string) int {
+	if cost, exists := l.costs[route]; exists {
+		return cost
+	}
+	return 1
+}
+
+// WithRateLimit limits the requests by token buckets of client IPs or of
+// authenticated API keys, a request takes the tokens of its route cost.  The
+// throttled requests get 429 with Retry-After header.
+func WithRateLimit(cfg option.APIRateLimit) (gin.HandlerFunc, error) {
+	l, err := newAPIRateLimiter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx *gin.Context) {
+		route := ctx.Request.Method + " " + ctx.FullPath()
+
+		limit, client := limitByIP, ctx.ClientIP()
+		if keyID := ctx.GetString(AuthKeyContextKey); keyID != "" {
+			limit, client = limitByKey, keyID
+		}
+
+		delay := l.reserve(limit, client, l.cost(route))
+		if delay == 0 {
+			ctx.Next()
+			return
+		}
+
+		metrics.Throttled(ctx, route, limit)
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+		ctx.AbortWithStatusJSON(http.StatusTooManyRequests,
+			types.NewErrorResponse(http.StatusTooManyRequests, "too many requests, retry later"))
+	}, nil
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kenlabs/pando/pkg/option"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithRateLimit(t *testing.T) {
+	Convey("TestWithRateLimit", t, func() {
+		cfg := option.APIRateLimit{
+			Enable:      true,
+			IPRate:      0.001,
+			IPBurst:     3,
+			KeyRate:     0.001,
+			KeyBurst:    5,
+			IdleTimeout: "10m",
+			RouteCosts: []option.APIRouteCost{
+				{Route: "POST /query", Cost: 2},
+			},
+		}
+		rateLimit, err := WithRateLimit(cfg)
+		So(err, ShouldBeNil)
+
+		router := gin.New()
+		router.Use(func(ctx *gin.Context) {
+			if key := ctx.GetHeader("X-Test-Key"); key != "" {
+				ctx.Set(AuthKeyContextKey, key)
+			}
+		}, rateLimit)
+		router.GET("/list", func(ctx *gin.Context) {
+			ctx.Status(http.StatusOK)
+		})
+		router.POST("/query", func(ctx *gin.Context) {
+			ctx.Status(http.StatusOK)
+		})
+
+		request := func(method string, path string, key string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, path, nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			if key != "" {
+				req.Header.Set("X-Test-Key", key)
+			}
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("throttle client IP with Retry-After", func() {
+			for i := 0; i < 3; i++ {
+				So(request(http.MethodGet, "/list", "").Code, ShouldEqual, http.StatusOK)
+			}
+			w := request(http.MethodGet, "/list", "")
+			So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+			So(w.Header().Get("Retry-After"), ShouldNotBeEmpty)
+		})
+
+		Convey("take the tokens of route cost", func() {
+			So(request(http.MethodPost, "/query", "").Code, ShouldEqual, http.StatusOK)
+			So(request(http.MethodPost, "/query", "").Code, ShouldEqual, http.StatusTooManyRequests)
+			So(request(http.MethodGet, "/list", "").Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("limit authenticated keys separately", func() {
+			for i := 0; i < 3; i++ {
+				So(request(http.MethodGet, "/list", "").Code, ShouldEqual, http.StatusOK)
+			}
+			for i := 0; i < 5; i++ {
+				So(request(http.MethodGet, "/list", "key1").Code, ShouldEqual, http.StatusOK)
+			}
+			So(request(http.MethodGet, "/list", "key1").Code, ShouldEqual, http.StatusTooManyRequests)
+			So(request(http.MethodGet, "/list", "key2").Code, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("reject invalid config", func() {
+			cfg.IPRate = 0
+			_, err := WithRateLimit(cfg)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
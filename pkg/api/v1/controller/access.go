@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kenlabs/pando/pkg/access"
+	v1 "github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/api/v1/model"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"net/http"
+)
+
+var errAccessDisabled = v1.NewError(errors.New("access control is not enabled"), http.StatusNotFound)
+
+// ProviderAccess changes the access rule of a collection by the signed
+// request of its provider.
+func (c *Controller) ProviderAccess(ctx context.Context, data []byte) (*access.Collection, error) {
+	if c.Core.Access == nil {
+		return nil, errAccessDisabled
+	}
+	accessRequest, err := model.ReadAccessRequest(data)
+	if err != nil {
+		logger.Errorf("read access request failed: %v", err)
+		return nil, v1.NewError(err, http.StatusBadRequest)
+	}
+
+	if err = c.Core.Registry.CheckSequence(accessRequest.PeerID, accessRequest.Seq); err != nil {
+		logger.Errorf("bad sequence: %v", err.Error())
+		return nil, v1.NewError(fmt.Errorf("bad sequence: %v", err.Error()), http.StatusBadRequest)
+	}
+
+	return c.UpdateAccess(ctx, accessRequest.PeerID, &access.Update{
+		Collection: accessRequest.Collection,
+		Restricted: accessRequest.Restricted,
+		Grant:      accessRequest.Grant,
+		Revoke:     accessRequest.Revoke,
+	})
+}
+
+// UpdateAccess changes the access rule of a collection of the provider
+func (c *Controller) UpdateAccess(ctx context.Context, provider peer.ID, update *access.Update) (*access.Collection, error) {
+	if c.Core.Access == nil {
+		return nil, errAccessDisabled
+	}
+	coll, err := c.Core.Access.Update(ctx, provider, update)
+	if err != nil {
+		if errors.Is(err, access.ErrBadUpdate) {
+			return nil, v1.NewError(err, http.StatusBadRequest)
+		}
+		logger.Errorf("failed to update access of provider %s: %v", provider, err)
+		return nil, v1.NewError(v1.InternalServerError, http.StatusInternalServerError)
+	}
+	return coll, nil
+}
+
+// ProviderCollections returns the access rules of the provider collections
+func (c *Controller) ProviderCollections(provider peer.ID) ([]*access.Collection, error) {
+	if c.Core.Access == nil {
+		return nil, errAccessDisabled
+	}
+	if provider == "" {
+		return nil, v1.NewError(errors.New("peerid is required"), http.StatusBadRequest)
+	}
+	return c.Core.Access.Collections(provider), nil
+}
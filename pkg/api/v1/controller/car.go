@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	golegs "github.com/filecoin-project/go-legs"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipld/go-car/v2"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/traversal"
+	"github.com/ipld/go-ipld-prime/traversal/selector"
+	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
+	"github.com/kenlabs/pando/pkg/access"
+	v1 "github.com/kenlabs/pando/pkg/api/v1"
+	"io"
+	"net/http"
+)
+
+// The dag-scope of the CARs, as of the trustless gateway
+const (
+	CarScopeAll = "all"
+	// CarScopeEntity is the same as CarScopeBlock, as the blocks of Pando are
+	// not UnixFS.
+	CarScopeEntity = "entity"
+	CarScopeBlock  = "block"
+)
+
+// maxCarLinks bounds the links walked for a CAR, the larger DAGs are fetched
+// in parts by Depth or Stop.
+const maxCarLinks = 1000000
+
+// CarQuery selects the DAG of a root written in a CAR
+type CarQuery struct {
+	Root string
+	// Scope is all by default
+	Scope string
+	// Depth limits the recursion of the all scope, 0 is unlimited
+	Depth int
+	// Stop is the cid the walk stops at without including it, as the backups
+	// do from the last backup.
+	Stop string
+}
+
+// Car is a CAR of a DAG ready to be written
+type Car struct {
+	Root cid.Cid
+	// Public is true if no collection is restricted, so that the CAR is the
+	// same for all the consumers.
+	Public   bool
+	selector ipld.Node
+}
+
+// PrepareCar checks the query and the root, so that the errors are known
+// before the CAR is written.
+func (c *Controller) PrepareCar(ctx context.Context, q *CarQuery) (*Car, error) {
+	root, err := cid.Decode(q.Root)
+	if err != nil {
+		return nil, v1.NewError(errors.New("invalid cid"), http.StatusBadRequest)
+	}
+	if q.Depth < 0 {
+		return nil, v1.NewError(errors.New("invalid depth"), http.StatusBadRequest)
+	}
+
+	var sel ipld.Node
+	switch q.Scope {
+	case "", CarScopeAll:
+		limit := selector.RecursionLimitNone()
+		if q.Depth != 0 {
+			limit = selector.RecursionLimitDepth(int64(q.Depth))
+		}
+		var stopLnk ipld.Link
+		if q.Stop != "" {
+			stop, err := cid.Decode(q.Stop)
+			if err != nil {
+				return nil, v1.NewError(errors.New("invalid stop cid"), http.StatusBadRequest)
+			}
+			stopLnk = cidlink.Link{Cid: stop}
+		}
+		sel = golegs.ExploreRecursiveWithStopNode(limit, nil, stopLnk)
+	case CarScopeEntity, CarScopeBlock:
+		sel = selectorparse.CommonSelector_MatchPoint
+	default:
+		return nil, v1.NewError(fmt.Errorf("unsupported dag-scope: %s", q.Scope), http.StatusBadRequest)
+	}
+
+	if err = c.checkCidAccess(ctx, root); err != nil {
+		return nil, err
+	}
+	exists, err := c.Core.StoreInstance.PandoStore.Get(ctx, root)
+	if err != nil || exists == nil {
+		if err == nil || errors.Is(err, datastore.ErrNotFound) {
+			return nil, v1.NewError(errors.New("root not found"), http.StatusNotFound)
+		}
+		logger.Errorf("failed to get block %s: %v", root, err)
+		return nil, v1.NewError(v1.InternalServerError, http.StatusInternalServerError)
+	}
+
+	return &Car{
+		Root:     root,
+		Public:   c.Core.Access == nil || !c.Core.Access.HasRestricted(),
+		selector: sel,
+	}, nil
+}
+
+// WriteCar writes the CARv1 of the DAG to w.  The blocks of the restricted
+// collections the consumer is not granted to are skipped along with their
+// links.
+func (c *Controller) WriteCar(ctx context.Context, carFile *Car, w io.Writer) error {
+	lsys := c.carLinkSystem(ctx)
+	_, err := car.TraverseV1(ctx, &lsys, carFile.Root, carFile.selector, w, car.MaxTraversalLinks(maxCarLinks))
+	return err
+}
+
+// carLinkSystem reads the blocks from PandoStore for the consumer of ctx
+func (c *Controller) carLinkSystem(ctx context.Context) ipld.LinkSystem {
+	consumer := access.ConsumerFrom(ctx)
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.TrustedStorage = true
+	lsys.StorageReadOpener = func(_ ipld.LinkContext, lnk ipld.Link) (io.Reader, error) {
+		asCidLink, ok := lnk.(cidlink.Link)
+		if !ok {
+			return nil, fmt.Errorf("unsupported link types")
+		}
+		if c.Core.Access != nil {
+			if err := c.Core.Access.CheckCid(ctx, asCidLink.Cid, consumer); err != nil {
+				if errors.Is(err, access.ErrDenied) {
+					return nil, traversal.SkipMe{}
+				}
+				return nil, err
+			}
+		}
+		block, err := c.Core.StoreInstance.PandoStore.Get(ctx, asCidLink.Cid)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(block), nil
+	}
+	return lsys
+}
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipld/go-car/v2"
+	pandostore "github.com/kenlabs/pando-store/pkg/store"
+	"github.com/kenlabs/pando/pkg/access"
+	v1 "github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/types/schema"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func readCarBlocks(data []byte) ([]cid.Cid, []cid.Cid, error) {
+	reader, err := car.NewBlockReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	var blocks []cid.Cid
+	for {
+		block, err := reader.Next()
+		if err == io.EOF {
+			return reader.Roots, blocks, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		blocks = append(blocks, block.Cid())
+	}
+}
+
+func TestCar(t *testing.T) {
+	Convey("test writing the dag of a root in a car", t, func() {
+		ctx := context.Background()
+		signKey, _, err := crypto.GenerateEd25519Key(nil)
+		So(err, ShouldBeNil)
+		provider, err := peer.IDFromPrivateKey(signKey)
+		So(err, ShouldBeNil)
+
+		chain, blocks, err := newTestChain(signKey, provider, 5, func(int) string { return "" })
+		So(err, ShouldBeNil)
+		head := chain[len(chain)-1]
+
+		patch := gomonkey.ApplyMethodFunc(
+			reflect.TypeOf(&pandostore.PandoStore{}),
+			"Get",
+			func(ctx context.Context, key cid.Cid) ([]byte, error) {
+				data, ok := blocks[key]
+				if !ok {
+					return nil, datastore.ErrNotFound
+				}
+				return data, nil
+			},
+		)
+		defer patch.Reset()
+
+		writeCar := func(q *CarQuery) ([]cid.Cid, []cid.Cid) {
+			carFile, err := mockController.PrepareCar(ctx, q)
+			So(err, ShouldBeNil)
+			var buf bytes.Buffer
+			So(mockController.WriteCar(ctx, carFile, &buf), ShouldBeNil)
+			roots, got, err := readCarBlocks(buf.Bytes())
+			So(err, ShouldBeNil)
+			return roots, got
+		}
+
+		Convey("writes the whole chain from the root", func() {
+			roots, got := writeCar(&CarQuery{Root: head.String()})
+			So(roots, ShouldResemble, []cid.Cid{head})
+			So(got, ShouldHaveLength, 5)
+			for i, c := range got {
+				So(c, ShouldResemble, chain[len(chain)-1-i])
+			}
+		})
+
+		Convey("limits the chain by depth and stop", func() {
+			_, got := writeCar(&CarQuery{Root: head.String(), Depth: 2})
+			So(got, ShouldResemble, []cid.Cid{chain[4], chain[3]})
+
+			_, got = writeCar(&CarQuery{Root: head.String(), Stop: chain[1].String()})
+			So(got, ShouldResemble, []cid.Cid{chain[4], chain[3], chain[2]})
+		})
+
+		Convey("writes the root block only in the block scope", func() {
+			_, got := writeCar(&CarQuery{Root: head.String(), Scope: CarScopeBlock})
+			So(got, ShouldResemble, []cid.Cid{head})
+		})
+
+		Convey("is public only if no collection is restricted", func() {
+			carFile, err := mockController.PrepareCar(ctx, &CarQuery{Root: head.String()})
+			So(err, ShouldBeNil)
+			So(carFile.Public, ShouldBeTrue)
+
+			ac, err := access.New(ctx, dssync.MutexWrap(datastore.NewMapDatastore()), testBlocks(blocks))
+			So(err, ShouldBeNil)
+			restricted := true
+			_, err = ac.Update(ctx, provider, &access.Update{Collection: "private", Restricted: &restricted})
+			So(err, ShouldBeNil)
+			mockController.Core.Access = ac
+			defer func() {
+				mockController.Core.Access = nil
+			}()
+			carFile, err = mockController.PrepareCar(ctx, &CarQuery{Root: head.String()})
+			So(err, ShouldBeNil)
+			So(carFile.Public, ShouldBeFalse)
+		})
+
+		Convey("returns errors for invalid queries or unknown roots", func() {
+			var apiError *v1.Error
+			_, err := mockController.PrepareCar(ctx, &CarQuery{Root: "invalid"})
+			So(errors.As(err, &apiError), ShouldBeTrue)
+			So(apiError.Status(), ShouldEqual, http.StatusBadRequest)
+
+			_, err = mockController.PrepareCar(ctx, &CarQuery{Root: head.String(), Scope: "unknown"})
+			So(errors.As(err, &apiError), ShouldBeTrue)
+			So(apiError.Status(), ShouldEqual, http.StatusBadRequest)
+
+			unknownCid, err := schema.LinkProto.Prefix.Sum([]byte("unknown"))
+			So(err, ShouldBeNil)
+			_, err = mockController.PrepareCar(ctx, &CarQuery{Root: unknownCid.String()})
+			So(errors.As(err, &apiError), ShouldBeTrue)
+			So(apiError.Status(), ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
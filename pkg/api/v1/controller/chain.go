@@ -0,0 +1,273 @@
+package controller
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/ipfs/go-cid"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/kenlabs/pando-store/pkg/types/store"
+	"github.com/kenlabs/pando/pkg/access"
+	v1 "github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultChainLimit = 100
+	maxChainLimit     = 1000
+	// maxChainScan is the max number of records walked for a page, the page
+	// may be short but with a NextCursor to continue walking once reached.
+	maxChainScan = 10000
+)
+
+// chainCursorAEAD seals the chain cursors, so that they do not reveal the
+// cids of the records skipped as denied to the consumer.  The key is random
+// per process, the cursors are invalid after restart.
+var chainCursorAEAD = newChainCursorAEAD()
+
+func newChainCursorAEAD() cipher.AEAD {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("cannot generate chain cursor key: %v", err))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+// ChainQuery walks the metadata chain backwards by PreviousID, from the head
+// of the provider or the Start cid.  Empty fields do not filter or stop.
+type ChainQuery struct {
+	// Provider is the provider whose head the walk starts from if Start is
+	// empty, and only the records of the provider are returned.
+	Provider peer.ID `json:",omitempty"`
+	Start    string  `json:",omitempty"`
+	// Stop is the cid the walk stops before
+	Stop string `json:",omitempty"`
+	// Depth is the max number of records walked from the start
+	Depth int `json:",omitempty"`
+	// Since stops the walk at the records in the snapshots created before
+	Since      *time.Time `json:",omitempty"`
+	Collection string     `json:",omitempty"`
+	// Limit is the max number of records in a page, defaults to 100
+	Limit int `json:",omitempty"`
+	// Cursor is the NextCursor of the previous page
+	Cursor string `json:",omitempty"`
+}
+
+// ChainRecord is the summary of a metadata in the chain
+type ChainRecord struct {
+	Cid string
+	// PreviousID is empty if the previous record is denied to the consumer
+	PreviousID string `json:",omitempty"`
+	Provider   string
+	Collection string `json:",omitempty"`
+	// Depth is the number of records walked from the start before the record
+	Depth int
+	// SnapShotHeight and SnapShotTime are of the snapshot including the
+	// metadata, they are nil if the metadata is not in a snapshot yet.
+	SnapShotHeight *uint64    `json:",omitempty"`
+	SnapShotTime   *time.Time `json:",omitempty"`
+	Verified       bool
+}
+
+// ChainPage is a page of the records walked
+type ChainPage struct {
+	Records []*ChainRecord
+	// NextCursor is empty if the walk is done
+	NextCursor string `json:",omitempty"`
+}
+
+// ProviderChain walks the metadata chain and returns a page of the records
+// matching the query.
+func (c *Controller) ProviderChain(ctx context.Context, q *ChainQuery) (*ChainPage, error) {
+	limit := q.Limit
+	switch {
+	case limit < 0:
+		return nil, v1.NewError(errors.New("invalid limit"), http.StatusBadRequest)
+	case limit == 0:
+		limit = defaultChainLimit
+	case limit > maxChainLimit:
+		limit = maxChainLimit
+	}
+	if q.Depth < 0 {
+		return nil, v1.NewError(errors.New("invalid depth"), http.StatusBadRequest)
+	}
+
+	var next cid.Cid
+	var depth int
+	var err error
+	switch {
+	case q.Cursor != "":
+		next, depth, err = decodeChainCursor(q.Cursor)
+		if err != nil {
+			return nil, v1.NewError(err, http.StatusBadRequest)
+		}
+	case q.Start != "":
+		next, err = cid.Decode(q.Start)
+		if err != nil {
+			return nil, v1.NewError(errors.New("invalid start cid"), http.StatusBadRequest)
+		}
+	case q.Provider != "":
+		next, err = c.ListProviderHead(q.Provider)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, v1.NewError(errors.New("peerid or start cid is required"), http.StatusBadRequest)
+	}
+	stop := cid.Undef
+	if q.Stop != "" {
+		stop, err = cid.Decode(q.Stop)
+		if err != nil {
+			return nil, v1.NewError(errors.New("invalid stop cid"), http.StatusBadRequest)
+		}
+	}
+
+	snapshots, err := c.Core.StoreInstance.PandoStore.SnapShotStore().GetSnapShotList(ctx)
+	if err != nil {
+		logger.Errorf("failed to get snapshot list: %v", err)
+		return nil, v1.NewError(v1.InternalServerError, http.StatusInternalServerError)
+	}
+	consumer := access.ConsumerFrom(ctx)
+
+	page := &ChainPage{Records: []*ChainRecord{}}
+	for scanned := 0; next.Defined(); scanned++ {
+		if (q.Depth != 0 && depth >= q.Depth) || next.Equals(stop) {
+			break
+		}
+		if len(page.Records) == limit || scanned == maxChainScan {
+			page.NextCursor = encodeChainCursor(next, depth)
+			break
+		}
+
+		block, err := c.readMetadata(ctx, next)
+		if err != nil {
+			if scanned == 0 {
+				return nil, err
+			}
+			// The rest of the chain is not synced
+			logger.Warnf("chain stopped at %s: %v", next, err)
+			break
+		}
+		record := c.chainRecord(ctx, block, depth, snapshots)
+		if q.Since != nil && record.SnapShotTime != nil && record.SnapShotTime.Before(*q.Since) {
+			break
+		}
+
+		next = cid.Undef
+		if block.meta.PreviousID != nil {
+			if prev, ok := (*block.meta.PreviousID).(cidlink.Link); ok {
+				next = prev.Cid
+			}
+		}
+		depth++
+
+		if q.Provider != "" && record.Provider != q.Provider.String() {
+			continue
+		}
+		if q.Collection != "" && record.Collection != q.Collection {
+			continue
+		}
+		if !c.chainRecordAllowed(record, consumer) {
+			continue
+		}
+		if next.Defined() && c.Core.Access != nil && c.Core.Access.CheckCid(ctx, next, consumer) != nil {
+			record.PreviousID = ""
+		}
+		page.Records = append(page.Records, record)
+	}
+	return page, nil
+}
+
+func (c *Controller) chainRecord(ctx context.Context, block *metadataBlock, depth int, snapshots *store.SnapShotList) *ChainRecord {
+	record := &ChainRecord{
+		Cid:      block.cid.String(),
+		Provider: block.meta.Provider,
+		Depth:    depth,
+		Verified: verifyMetadata(block.meta).Verified,
+	}
+	if block.meta.PreviousID != nil {
+		record.PreviousID = (*block.meta.PreviousID).String()
+	}
+	if block.meta.Collection != nil {
+		record.Collection = *block.meta.Collection
+	}
+
+	inclusion, err := c.Core.StoreInstance.PandoStore.MetaInclusion(ctx, block.cid)
+	if err != nil {
+		logger.Warnf("failed to get meta inclusion for cid: %s, err: %v", block.cid, err)
+		return record
+	}
+	if inclusion.InSnapShot {
+		height := inclusion.SnapShotHeight
+		record.SnapShotHeight = &height
+		if snapshots != nil && height < uint64(len(snapshots.List)) {
+			created := time.Unix(0, int64(snapshots.List[height].CreatedTime))
+			record.SnapShotTime = &created
+		}
+	}
+	return record
+}
+
+// chainRecordAllowed checks if the consumer can read the collection of the
+// record, the records denied are skipped.
+func (c *Controller) chainRecordAllowed(record *ChainRecord, consumer peer.ID) bool {
+	if c.Core.Access == nil {
+		return true
+	}
+	provider, err := peer.Decode(record.Provider)
+	if err != nil {
+		return true
+	}
+	return c.Core.Access.Allowed(provider, record.Collection, consumer)
+}
+
+// The cursor is the sealed cid the next page starts from and its depth
+func encodeChainCursor(next cid.Cid, depth int) string {
+	nonce := make([]byte, chainCursorAEAD.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(fmt.Sprintf("cannot generate chain cursor nonce: %v", err))
+	}
+	sealed := chainCursorAEAD.Seal(nonce, nonce, []byte(fmt.Sprintf("%s/%d", next, depth)), nil)
+	return base64.RawURLEncoding.EncodeToString(sealed)
+}
+
+func decodeChainCursor(cursor string) (cid.Cid, int, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(sealed) < chainCursorAEAD.NonceSize() {
+		return cid.Undef, 0, errors.New("invalid cursor")
+	}
+	nonceSize := chainCursorAEAD.NonceSize()
+	b, err := chainCursorAEAD.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return cid.Undef, 0, errors.New("invalid cursor")
+	}
+	parts := strings.SplitN(string(b), "/", 2)
+	if len(parts) != 2 {
+		return cid.Undef, 0, errors.New("invalid cursor")
+	}
+	next, err := cid.Decode(parts[0])
+	if err != nil {
+		return cid.Undef, 0, errors.New("invalid cursor")
+	}
+	depth, err := strconv.Atoi(parts[1])
+	if err != nil || depth < 0 {
+		return cid.Undef, 0, errors.New("invalid cursor")
+	}
+	return next, depth, nil
+}
@@ -0,0 +1,234 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/kenlabs/pando-store/pkg/snapshotstore"
+	pandostore "github.com/kenlabs/pando-store/pkg/store"
+	"github.com/kenlabs/pando-store/pkg/types/store"
+	"github.com/kenlabs/pando/pkg/access"
+	v1 "github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/types/schema"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testBlocks reads the blocks of a test chain
+type testBlocks map[cid.Cid][]byte
+
+func (b testBlocks) Get(_ context.Context, c cid.Cid) ([]byte, error) {
+	data, ok := b[c]
+	if !ok {
+		return nil, datastore.ErrNotFound
+	}
+	return data, nil
+}
+
+// newTestChain creates a chain of n metadata, the first is chain[0]
+func newTestChain(signKey crypto.PrivKey, provider peer.ID, n int, collection func(int) string) ([]cid.Cid, map[cid.Cid][]byte, error) {
+	var chain []cid.Cid
+	blocks := make(map[cid.Cid][]byte)
+	var prev datamodel.Link
+	for i := 0; i < n; i++ {
+		meta, err := schema.NewMetaWithPayloadNode(basicnode.NewString(fmt.Sprint(i)), provider, signKey, prev)
+		if err != nil {
+			return nil, nil, err
+		}
+		coll := collection(i)
+		meta.Collection = &coll
+		node, err := meta.ToNode()
+		if err != nil {
+			return nil, nil, err
+		}
+		var buf bytes.Buffer
+		if err = dagjson.Encode(node, &buf); err != nil {
+			return nil, nil, err
+		}
+		c, err := schema.LinkProto.Prefix.Sum(buf.Bytes())
+		if err != nil {
+			return nil, nil, err
+		}
+		blocks[c] = buf.Bytes()
+		chain = append(chain, c)
+		prev = cidlink.Link{Cid: c}
+	}
+	return chain, blocks, nil
+}
+
+func TestProviderChain(t *testing.T) {
+	Convey("test walking the metadata chain of a provider", t, func() {
+		ctx := context.Background()
+		signKey, _, err := crypto.GenerateEd25519Key(nil)
+		So(err, ShouldBeNil)
+		provider, err := peer.IDFromPrivateKey(signKey)
+		So(err, ShouldBeNil)
+
+		// A chain of 10 metadata in snapshots created a minute apart, the
+		// collections alternate between even and odd.
+		base := time.Now()
+		chain, blocks, err := newTestChain(signKey, provider, 10, func(i int) string {
+			return []string{"even", "odd"}[i%2]
+		})
+		So(err, ShouldBeNil)
+		heights := make(map[cid.Cid]uint64)
+		snapshots := &store.SnapShotList{}
+		for i, c := range chain {
+			heights[c] = uint64(i)
+			snapshots.List = append(snapshots.List, struct {
+				CreatedTime uint64
+				SnapShotCid cid.Cid
+			}{CreatedTime: uint64(base.Add(time.Duration(i) * time.Minute).UnixNano()), SnapShotCid: c})
+		}
+		head := chain[len(chain)-1]
+
+		patches := gomonkey.ApplyMethodFunc(
+			reflect.TypeOf(&pandostore.PandoStore{}),
+			"Get",
+			func(ctx context.Context, key cid.Cid) ([]byte, error) {
+				data, ok := blocks[key]
+				if !ok {
+					return nil, datastore.ErrNotFound
+				}
+				return data, nil
+			},
+		)
+		defer patches.Reset()
+		patches.ApplyMethodFunc(
+			reflect.TypeOf(&pandostore.PandoStore{}),
+			"MetaInclusion",
+			func(ctx context.Context, c cid.Cid) (*store.MetaInclusion, error) {
+				return &store.MetaInclusion{ID: c, InPando: true, InSnapShot: true, SnapShotHeight: heights[c]}, nil
+			},
+		)
+		patches.ApplyMethodFunc(
+			reflect.TypeOf(&snapshotstore.SnapShotStore{}),
+			"GetSnapShotList",
+			func(ctx context.Context) (*store.SnapShotList, error) {
+				return snapshots, nil
+			},
+		)
+		cids := func(page *ChainPage) []string {
+			var res []string
+			for _, record := range page.Records {
+				res = append(res, record.Cid)
+			}
+			return res
+		}
+
+		Convey("pages backwards through the chain by cursor", func() {
+			var walked []string
+			q := &ChainQuery{Start: head.String(), Limit: 3}
+			for {
+				page, err := mockController.ProviderChain(ctx, q)
+				So(err, ShouldBeNil)
+				walked = append(walked, cids(page)...)
+				if page.NextCursor == "" {
+					break
+				}
+				q.Cursor = page.NextCursor
+			}
+			So(len(walked), ShouldEqual, 10)
+			So(walked[0], ShouldEqual, head.String())
+			So(walked[9], ShouldEqual, chain[0].String())
+
+			page, err := mockController.ProviderChain(ctx, &ChainQuery{Start: head.String(), Limit: 1})
+			So(err, ShouldBeNil)
+			record := page.Records[0]
+			So(record.PreviousID, ShouldEqual, chain[8].String())
+			So(record.Provider, ShouldEqual, provider.String())
+			So(record.Collection, ShouldEqual, "odd")
+			So(*record.SnapShotHeight, ShouldEqual, 9)
+			So(record.Verified, ShouldBeTrue)
+		})
+
+		Convey("stops at the cid, depth or time", func() {
+			page, err := mockController.ProviderChain(ctx, &ChainQuery{Start: head.String(), Stop: chain[6].String()})
+			So(err, ShouldBeNil)
+			So(cids(page), ShouldResemble, []string{chain[9].String(), chain[8].String(), chain[7].String()})
+
+			page, err = mockController.ProviderChain(ctx, &ChainQuery{Start: head.String(), Depth: 2})
+			So(err, ShouldBeNil)
+			So(cids(page), ShouldResemble, []string{chain[9].String(), chain[8].String()})
+
+			since := base.Add(8 * time.Minute)
+			page, err = mockController.ProviderChain(ctx, &ChainQuery{Start: head.String(), Since: &since})
+			So(err, ShouldBeNil)
+			So(cids(page), ShouldResemble, []string{chain[9].String(), chain[8].String()})
+		})
+
+		Convey("returns the last records of the collection", func() {
+			page, err := mockController.ProviderChain(ctx, &ChainQuery{
+				Start:      head.String(),
+				Provider:   provider,
+				Collection: "even",
+				Limit:      2,
+			})
+			So(err, ShouldBeNil)
+			So(cids(page), ShouldResemble, []string{chain[8].String(), chain[6].String()})
+			So(page.NextCursor, ShouldNotBeEmpty)
+		})
+
+		Convey("hides the records denied to the consumer", func() {
+			ac, err := access.New(ctx, dssync.MutexWrap(datastore.NewMapDatastore()), testBlocks(blocks))
+			So(err, ShouldBeNil)
+			restricted := true
+			_, err = ac.Update(ctx, provider, &access.Update{Collection: "odd", Restricted: &restricted})
+			So(err, ShouldBeNil)
+			mockController.Core.Access = ac
+			defer func() {
+				mockController.Core.Access = nil
+			}()
+
+			var walked []string
+			q := &ChainQuery{Start: head.String(), Limit: 2}
+			for {
+				page, err := mockController.ProviderChain(ctx, q)
+				So(err, ShouldBeNil)
+				for _, record := range page.Records {
+					// the previous records are odd ones
+					So(record.PreviousID, ShouldBeEmpty)
+				}
+				walked = append(walked, cids(page)...)
+				if page.NextCursor == "" {
+					break
+				}
+				// the cursor does not reveal the odd record the next page
+				// starts from
+				decoded, err := base64.RawURLEncoding.DecodeString(page.NextCursor)
+				So(err, ShouldBeNil)
+				for _, c := range chain {
+					So(string(decoded), ShouldNotContainSubstring, c.String())
+				}
+				q.Cursor = page.NextCursor
+			}
+			So(walked, ShouldResemble, []string{chain[8].String(), chain[6].String(), chain[4].String(), chain[2].String(), chain[0].String()})
+		})
+
+		Convey("rejects invalid queries", func() {
+			var apiError *v1.Error
+			_, err := mockController.ProviderChain(ctx, &ChainQuery{})
+			So(errors.As(err, &apiError), ShouldBeTrue)
+			So(apiError.Status(), ShouldEqual, http.StatusBadRequest)
+
+			_, err = mockController.ProviderChain(ctx, &ChainQuery{Start: head.String(), Cursor: "!"})
+			So(errors.As(err, &apiError), ShouldBeTrue)
+			So(apiError.Status(), ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
@@ -11,6 +11,9 @@ var logger = log.NewSubsystemLogger()
 type Controller struct {
 	Core    *core.Core
 	Options *option.DaemonOptions
+
+	// snapShots indexes the snapshots for the diffs
+	snapShots snapShotIndex
 }
 
 func New(core *core.Core, opt *option.DaemonOptions) *Controller {
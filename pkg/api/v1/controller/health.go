@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/ipfs/go-datastore"
+	"github.com/kenlabs/pando/pkg/health"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// healthKey is read from the stores to check them, it does not need to exist
+const healthKey = "/health"
+
+// Liveness reports that the server is running, without checking its
+// components, so that it is not restarted for the failures of the others.
+func (c *Controller) Liveness() *health.Report {
+	return &health.Report{
+		Status: health.StatusOK,
+		Time:   time.Now(),
+	}
+}
+
+// Readiness checks the components the server depends on to serve the
+// requests.
+func (c *Controller) Readiness(ctx context.Context) *health.Report {
+	return health.Run(ctx, time.Duration(c.Options.Health.CheckTimeoutInDurationFormat()), c.healthChecks())
+}
+
+func (c *Controller) healthChecks() []health.Check {
+	var checks []health.Check
+	if c.Core.StoreInstance != nil {
+		checks = append(checks,
+			health.Check{Name: "datastore", Critical: true, Check: c.checkDatastore},
+			health.Check{Name: "cachestore", Critical: true, Check: c.checkCacheStore},
+		)
+		if c.Core.StoreInstance.MetadataCache != nil {
+			checks = append(checks, health.Check{Name: "metacache", Critical: true, Check: c.checkMetaCache})
+		}
+	}
+	if c.Core.LegsCore != nil {
+		checks = append(checks,
+			health.Check{Name: "libp2p", Critical: true, Check: c.checkLibp2p},
+			health.Check{Name: "legs", Critical: true, Check: c.checkLegs},
+		)
+	}
+	if c.Core.MetaManager != nil {
+		checks = append(checks, health.Check{Name: "backup", Check: c.checkBackup})
+	}
+	return checks
+}
+
+func (c *Controller) checkDatastore(ctx context.Context) *health.Result {
+	if _, err := c.Core.StoreInstance.MutexDataStore.Has(ctx, datastore.NewKey(healthKey)); err != nil {
+		return health.Fail(err, nil)
+	}
+	return health.OK(nil)
+}
+
+func (c *Controller) checkCacheStore(_ context.Context) *health.Result {
+	err := c.Core.StoreInstance.CacheStore.View(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte(healthKey))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return health.Fail(err, nil)
+	}
+	return health.OK(nil)
+}
+
+func (c *Controller) checkMetaCache(ctx context.Context) *health.Result {
+	if err := c.Core.StoreInstance.MetadataCache.Ping(ctx, readpref.Primary()); err != nil {
+		return health.Fail(err, nil)
+	}
+	return health.OK(nil)
+}
+
+type libp2pHealth struct {
+	PeerID    string
+	Addresses []string
+	Peers     int
+}
+
+func (c *Controller) checkLibp2p(_ context.Context) *health.Result {
+	h := c.Core.LegsCore.Host
+	detail := &libp2pHealth{
+		PeerID: h.ID().String(),
+		Peers:  len(h.Network().Peers()),
+	}
+	for _, addr := range h.Network().ListenAddresses() {
+		detail.Addresses = append(detail.Addresses, addr.String())
+	}
+	if len(detail.Addresses) == 0 {
+		return health.Fail(errors.New("no listen address"), detail)
+	}
+	if detail.Peers == 0 {
+		return health.Warn("no connected peer", detail)
+	}
+	return health.OK(detail)
+}
+
+type legsHealth struct {
+	PendingSyncs int
+}
+
+func (c *Controller) checkLegs(_ context.Context) *health.Result {
+	if c.Core.LegsCore.LS == nil {
+		return health.Fail(errors.New("legs subscriber is not running"), nil)
+	}
+	detail := &legsHealth{PendingSyncs: c.Core.LegsCore.PendingSyncs()}
+	if max := c.Options.Health.MaxSyncBacklog; max > 0 && detail.PendingSyncs > max {
+		return health.Warn(fmt.Sprintf("pending syncs over %d", max), detail)
+	}
+	return health.OK(detail)
+}
+
+type backupHealth struct {
+	QueuedMetadata int
+	PendingFiles   int
+	Gateways       interface{} `json:",omitempty"`
+}
+
+// checkBackup checks the backlog of the backup, and if the estuary gateways
+// are reachable when the backup is configured.  The gateways are checked at
+// most once per Health.GatewayCheckInterval.
+func (c *Controller) checkBackup(ctx context.Context) *health.Result {
+	queued, files, err := c.Core.MetaManager.BackupBacklog()
+	detail := &backupHealth{QueuedMetadata: queued, PendingFiles: files}
+	if err != nil {
+		return health.Fail(err, detail)
+	}
+
+	if c.Core.BackupGateways != nil {
+		gateways := c.Core.BackupGateways.Check(ctx)
+		detail.Gateways = gateways.Detail
+		if gateways.Status == health.StatusFail {
+			return health.Fail(fmt.Errorf("backup gateway %s", gateways.Message), detail)
+		}
+	}
+	if max := c.Options.Health.MaxBackupFiles; max > 0 && files > max {
+		return health.Warn(fmt.Sprintf("pending backup files over %d", max), detail)
+	}
+	return health.OK(detail)
+}
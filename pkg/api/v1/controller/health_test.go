@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kenlabs/pando/pkg/health"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReadiness(t *testing.T) {
+	Convey("TestReadiness", t, func() {
+		Convey("should check the stores and the libp2p host", func() {
+			report := mockController.Readiness(context.Background())
+			So(report.Ready(), ShouldBeTrue)
+
+			components := make(map[string]*health.Component)
+			for _, component := range report.Components {
+				components[component.Name] = component
+			}
+			So(components["datastore"].Status, ShouldEqual, health.StatusOK)
+			So(components["cachestore"].Status, ShouldEqual, health.StatusOK)
+			So(components["legs"].Status, ShouldEqual, health.StatusOK)
+			So(components["libp2p"].Detail.(*libp2pHealth).Addresses, ShouldNotBeEmpty)
+		})
+
+		Convey("should report the liveness without the components", func() {
+			report := mockController.Liveness()
+			So(report.Status, ShouldEqual, health.StatusOK)
+			So(report.Components, ShouldBeEmpty)
+		})
+	})
+}
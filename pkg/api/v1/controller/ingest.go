@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/kenlabs/pando/pkg/access"
+	v1 "github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/ingest"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"net/http"
+	"strconv"
+)
+
+// IngestEventQuery selects the ingestion events followed.  Empty fields do
+// not filter, and the events are followed from now without Cursor or
+// FromStart.
+type IngestEventQuery struct {
+	Provider   peer.ID `json:",omitempty"`
+	Collection string  `json:",omitempty"`
+	// Cursor is the cursor of the last event received to resume after
+	Cursor string `json:",omitempty"`
+	// FromStart follows from the oldest event kept in the log
+	FromStart bool `json:",omitempty"`
+}
+
+// IngestEvent is an ingestion event with its cursor
+type IngestEvent struct {
+	Cursor string
+	*ingest.Event
+}
+
+// IngestFollow is a checked query of the ingestion events to be followed
+type IngestFollow struct {
+	after  uint64
+	filter *ingest.Filter
+}
+
+// PrepareIngestEvents checks the query, so that the errors are known before
+// the events are followed.
+func (c *Controller) PrepareIngestEvents(ctx context.Context, q *IngestEventQuery) (*IngestFollow, error) {
+	if c.Core.IngestLog == nil {
+		return nil, v1.NewError(errors.New("ingest log is not enabled"), http.StatusNotFound)
+	}
+	if q.Provider != "" {
+		if err := q.Provider.Validate(); err != nil {
+			return nil, v1.NewError(errors.New("invalid provider"), http.StatusBadRequest)
+		}
+	}
+
+	f := &IngestFollow{
+		filter: &ingest.Filter{
+			Provider:   q.Provider,
+			Collection: q.Collection,
+		},
+	}
+	switch {
+	case q.Cursor != "":
+		after, err := decodeIngestCursor(q.Cursor)
+		if err != nil {
+			return nil, v1.NewError(err, http.StatusBadRequest)
+		}
+		f.after = after
+	case q.FromStart:
+		f.after = 0
+	default:
+		f.after = c.Core.IngestLog.Last()
+	}
+
+	if c.Core.Access != nil {
+		consumer := access.ConsumerFrom(ctx)
+		f.filter.Allowed = func(e *ingest.Event) bool {
+			return c.Core.Access.Allowed(e.Provider, e.Collection, consumer)
+		}
+	}
+	return f, nil
+}
+
+// FollowIngestEvents delivers the ingestion events of the query until ctx is
+// done or deliver fails.
+func (c *Controller) FollowIngestEvents(ctx context.Context, f *IngestFollow, deliver func(e *IngestEvent) error) error {
+	return c.Core.IngestLog.Follow(ctx, f.after, f.filter, func(e *ingest.Event) error {
+		return deliver(&IngestEvent{
+			Cursor: encodeIngestCursor(e.Seq),
+			Event:  e,
+		})
+	})
+}
+
+// The cursor is the sequence of the event in the ingest log
+func encodeIngestCursor(seq uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(seq, 10)))
+}
+
+func decodeIngestCursor(cursor string) (uint64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+	seq, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+	return seq, nil
+}
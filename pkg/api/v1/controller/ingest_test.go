@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/kenlabs/pando/pkg/api/core"
+	v1 "github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/ingest"
+	"github.com/kenlabs/pando/pkg/option"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIngestEvents(t *testing.T) {
+	Convey("TestIngestEvents", t, func() {
+		ctx := context.Background()
+		provider, _ := peer.Decode("12D3KooWNtUworDmrdTUjrPrwvE5ejKNMn8mnDkFFcuTzKoeDykp")
+		metaCid, _ := cid.Decode("bafkreigks6arfsq3xxfpvqrrwonchxcnu6do76auprhhfomao6c273sixm")
+
+		Convey("should fail when the ingest log is not enabled", func() {
+			c := New(&core.Core{}, mockController.Options)
+			_, err := c.PrepareIngestEvents(ctx, &IngestEventQuery{})
+			So(err, ShouldNotBeNil)
+			So(err.(*v1.Error).Status(), ShouldEqual, 404)
+		})
+
+		l, err := ingest.New(ctx, dssync.MutexWrap(datastore.NewMapDatastore()), nil, nil,
+			&option.IngestLog{MaxEvents: 100})
+		So(err, ShouldBeNil)
+		defer l.Close()
+		c := New(&core.Core{IngestLog: l}, mockController.Options)
+		for _, collection := range []string{"c1", "c2", "c1"} {
+			So(l.Ingested(ctx, metaCid, provider, collection), ShouldBeNil)
+		}
+
+		follow := func(q *IngestEventQuery, n int) []*IngestEvent {
+			f, err := c.PrepareIngestEvents(ctx, q)
+			So(err, ShouldBeNil)
+			cctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			var events []*IngestEvent
+			errStop := errors.New("stop")
+			err = c.FollowIngestEvents(cctx, f, func(e *IngestEvent) error {
+				events = append(events, e)
+				if len(events) == n {
+					return errStop
+				}
+				return nil
+			})
+			So(err, ShouldEqual, errStop)
+			return events
+		}
+
+		Convey("should resume the events after the cursor", func() {
+			events := follow(&IngestEventQuery{FromStart: true, Collection: "c1"}, 1)
+			So(events[0].Seq, ShouldEqual, 1)
+
+			events = follow(&IngestEventQuery{Cursor: events[0].Cursor}, 1)
+			So(events[0].Seq, ShouldEqual, 2)
+			So(events[0].Collection, ShouldEqual, "c2")
+		})
+
+		Convey("should follow the new events without cursor", func() {
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				_ = l.Ingested(ctx, metaCid, provider, "c3")
+			}()
+			events := follow(&IngestEventQuery{}, 1)
+			So(events[0].Seq, ShouldEqual, 4)
+			So(events[0].Collection, ShouldEqual, "c3")
+		})
+
+		Convey("should fail with invalid cursor", func() {
+			_, err := c.PrepareIngestEvents(ctx, &IngestEventQuery{Cursor: "!!"})
+			So(err, ShouldNotBeNil)
+			So(err.(*v1.Error).Status(), ShouldEqual, 400)
+		})
+	})
+}
@@ -4,13 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/ipfs/go-cid"
 	storeError "github.com/kenlabs/pando-store/pkg/error"
 	"github.com/kenlabs/pando-store/pkg/types/cbortypes"
+	"github.com/kenlabs/pando/pkg/access"
 	v1 "github.com/kenlabs/pando/pkg/api/v1"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"github.com/kenlabs/pando/pkg/metacache"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"net/http"
 	"strconv"
 )
@@ -92,6 +93,10 @@ func (c *Controller) MetaInclusion(ctx context.Context, cidstr string) ([]byte,
 		return nil, v1.NewError(errors.New("invalid cid"), http.StatusBadRequest)
 	}
 
+	if err = c.checkCidAccess(ctx, metaCid); err != nil {
+		return nil, err
+	}
+
 	inclusion, err := c.Core.StoreInstance.PandoStore.MetaInclusion(ctx, metaCid)
 	if err != nil {
 		logger.Errorf("failed to get meta inclusion for cid: %s, err:%v", metaCid.String(), err)
@@ -106,25 +111,61 @@ func (c *Controller) MetaInclusion(ctx context.Context, cidstr string) ([]byte,
 	return res, nil
 }
 
-func (c *Controller) MetadataQuery(ctx context.Context, providerID string, queryStr string) (queryResult interface{}, err error) {
-	var bsonQuery bson.D
-	err = bson.UnmarshalExtJSON([]byte(queryStr), true, &bsonQuery)
+// MetadataQuery runs the query on the metadata cache of the provider, the
+// query is validated before it is run.
+func (c *Controller) MetadataQuery(ctx context.Context, providerID string, q *metacache.Query) ([]map[string]interface{}, error) {
+	if q == nil {
+		return nil, v1.NewError(v1.InvalidQuery, http.StatusBadRequest)
+	}
+	provider, err := peer.Decode(providerID)
 	if err != nil {
+		return nil, v1.NewError(errors.New("invalid provider id"), http.StatusBadRequest)
+	}
+	if err = q.Validate(c.Options.MetaCache.QueryMaxLimit); err != nil {
+		return nil, v1.NewError(err, http.StatusBadRequest)
+	}
+	if err = c.checkQueryAccess(ctx, provider, q.Collection); err != nil {
 		return nil, err
 	}
-	var resJson []bson.M
-	opts := options.RunCmd().SetReadPreference(readpref.Primary())
-	res, err := c.Core.StoreInstance.MetadataCache.Database(providerID).RunCommandCursor(
-		ctx,
-		bsonQuery,
-		opts,
-	)
+
+	results, err := c.Core.StoreInstance.MetadataQuerier.Query(ctx, provider.String(), q)
 	if err != nil {
-		return nil, err
+		switch {
+		case errors.Is(err, metacache.ErrInvalidQuery):
+			return nil, v1.NewError(err, http.StatusBadRequest)
+		case errors.Is(err, metacache.ErrTimeout):
+			return nil, v1.NewError(err, http.StatusGatewayTimeout)
+		}
+		logger.Errorf("failed to query metadata cache of provider: %s, err: %v", providerID, err)
+		return nil, v1.NewError(v1.InternalServerError, http.StatusInternalServerError)
 	}
-	if err = res.All(ctx, &resJson); err != nil {
-		return nil, err
+	return results, nil
+}
+
+// checkCidAccess denies reading the metadata of the restricted collections
+// the consumer is not granted to.
+func (c *Controller) checkCidAccess(ctx context.Context, metaCid cid.Cid) error {
+	if c.Core.Access == nil {
+		return nil
 	}
+	if err := c.Core.Access.CheckCid(ctx, metaCid, access.ConsumerFrom(ctx)); err != nil {
+		if errors.Is(err, access.ErrDenied) {
+			return v1.NewError(err, http.StatusForbidden)
+		}
+		logger.Errorf("failed to check access for cid: %s, err:%v", metaCid.String(), err)
+		return v1.NewError(v1.InternalServerError, http.StatusInternalServerError)
+	}
+	return nil
+}
 
-	return resJson, nil
+// checkQueryAccess denies the queries on the restricted collections of the
+// provider the consumer is not granted to.
+func (c *Controller) checkQueryAccess(ctx context.Context, provider peer.ID, collection string) error {
+	if c.Core.Access == nil || !c.Core.Access.Restricted(provider) {
+		return nil
+	}
+	if !c.Core.Access.Allowed(provider, collection, access.ConsumerFrom(ctx)) {
+		return v1.NewError(fmt.Errorf("%w: collection %q", access.ErrDenied, collection), http.StatusForbidden)
+	}
+	return nil
 }
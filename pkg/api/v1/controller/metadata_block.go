@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipld/go-car/v2"
+	"github.com/ipld/go-ipld-prime"
+	_ "github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/multicodec"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
+	v1 "github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/api/v1/model"
+	"github.com/kenlabs/pando/pkg/types/schema"
+	"github.com/libp2p/go-libp2p-core/peer"
+	mc "github.com/multiformats/go-multicodec"
+	"io"
+	"net/http"
+)
+
+// MetadataFormat is the encoding a metadata block is returned in
+type MetadataFormat string
+
+const (
+	MetadataDagJSON MetadataFormat = "dag-json"
+	MetadataDagCBOR MetadataFormat = "dag-cbor"
+	// MetadataCAR is a CARv1 with the metadata block only
+	MetadataCAR MetadataFormat = "car"
+)
+
+var errNotMetadata = errors.New("metadata not found")
+
+// metadataBlock is a stored block decoded as schema.Metadata
+type metadataBlock struct {
+	cid  cid.Cid
+	data []byte
+	node ipld.Node
+	meta *schema.Metadata
+}
+
+// Metadata returns the decoded metadata block of the cid
+func (c *Controller) Metadata(ctx context.Context, cidStr string) (*model.Metadata, error) {
+	block, err := c.loadMetadata(ctx, cidStr)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &model.Metadata{
+		Cid:          block.cid.String(),
+		Provider:     block.meta.Provider,
+		Cache:        block.meta.Cache,
+		Signature:    block.meta.Signature,
+		Verification: verifyMetadata(block.meta),
+	}
+	if block.meta.PreviousID != nil {
+		res.PreviousID = (*block.meta.PreviousID).String()
+	}
+	if block.meta.Collection != nil {
+		res.Collection = *block.meta.Collection
+	}
+	var payload bytes.Buffer
+	if err = dagjson.Encode(block.meta.Payload, &payload); err != nil {
+		logger.Errorf("failed to encode payload of metadata %s: %v", block.cid, err)
+		return nil, v1.NewError(v1.InternalServerError, http.StatusInternalServerError)
+	}
+	res.Payload = payload.Bytes()
+	return res, nil
+}
+
+// MetadataBlock returns the metadata block of the cid encoded in the format,
+// along with its signature verification status.
+func (c *Controller) MetadataBlock(ctx context.Context, cidStr string, format MetadataFormat) ([]byte, *model.MetadataVerification, error) {
+	block, err := c.loadMetadata(ctx, cidStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	verification := verifyMetadata(block.meta)
+
+	var data []byte
+	switch format {
+	case MetadataDagJSON:
+		data, err = encodeBlock(block, mc.DagJson)
+	case MetadataDagCBOR:
+		data, err = encodeBlock(block, mc.DagCbor)
+	case MetadataCAR:
+		data, err = blockCar(ctx, block)
+	default:
+		return nil, nil, v1.NewError(fmt.Errorf("unsupported format: %s", format), http.StatusBadRequest)
+	}
+	if err != nil {
+		logger.Errorf("failed to encode metadata %s in %s: %v", block.cid, format, err)
+		return nil, nil, v1.NewError(v1.InternalServerError, http.StatusInternalServerError)
+	}
+	return data, &verification, nil
+}
+
+func (c *Controller) loadMetadata(ctx context.Context, cidStr string) (*metadataBlock, error) {
+	metaCid, err := cid.Decode(cidStr)
+	if err != nil {
+		return nil, v1.NewError(errors.New("invalid cid"), http.StatusBadRequest)
+	}
+	if err = c.checkCidAccess(ctx, metaCid); err != nil {
+		return nil, err
+	}
+	return c.readMetadata(ctx, metaCid)
+}
+
+// readMetadata reads the metadata block of the cid without checking access
+func (c *Controller) readMetadata(ctx context.Context, metaCid cid.Cid) (*metadataBlock, error) {
+	data, err := c.Core.StoreInstance.PandoStore.Get(ctx, metaCid)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNotFound) {
+			return nil, v1.NewError(errNotMetadata, http.StatusNotFound)
+		}
+		logger.Errorf("failed to get block %s: %v", metaCid, err)
+		return nil, v1.NewError(v1.InternalServerError, http.StatusInternalServerError)
+	}
+
+	// Decode as a map first, the blocks of other types such as snapshots are
+	// not metadata.
+	decoder, err := multicodec.LookupDecoder(metaCid.Prefix().Codec)
+	if err != nil {
+		return nil, v1.NewError(errNotMetadata, http.StatusNotFound)
+	}
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err = decoder(nb, bytes.NewReader(data)); err != nil {
+		return nil, v1.NewError(errNotMetadata, http.StatusNotFound)
+	}
+	node := nb.Build()
+	signature, _ := node.LookupByString("Signature")
+	provider, _ := node.LookupByString("Provider")
+	payload, _ := node.LookupByString("Payload")
+	if signature == nil || provider == nil || payload == nil {
+		return nil, v1.NewError(errNotMetadata, http.StatusNotFound)
+	}
+	meta, err := schema.UnwrapMetadata(node)
+	if err != nil {
+		logger.Warnf("cannot decode metadata %s: %v", metaCid, err)
+		return nil, v1.NewError(errNotMetadata, http.StatusNotFound)
+	}
+
+	return &metadataBlock{
+		cid:  metaCid,
+		data: data,
+		node: node,
+		meta: meta,
+	}, nil
+}
+
+// verifyMetadata checks if the metadata is signed by its provider
+func verifyMetadata(meta *schema.Metadata) model.MetadataVerification {
+	signer, err := schema.VerifyMetadata(meta)
+	if err != nil {
+		return model.MetadataVerification{Error: err.Error()}
+	}
+	res := model.MetadataVerification{Signer: signer.String()}
+	provider, err := peer.Decode(meta.Provider)
+	if err != nil {
+		res.Error = fmt.Sprintf("invalid provider: %v", err)
+		return res
+	}
+	if signer != provider {
+		res.Error = "metadata not signed by provider"
+		return res
+	}
+	res.Verified = true
+	return res
+}
+
+// encodeBlock returns the block data as is if it is already in the codec,
+// otherwise the block node is encoded in the codec.
+func encodeBlock(block *metadataBlock, codec mc.Code) ([]byte, error) {
+	if block.cid.Prefix().Codec == uint64(codec) {
+		return block.data, nil
+	}
+	encoder, err := multicodec.LookupEncoder(uint64(codec))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = encoder(block.node, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// blockCar writes a CARv1 with the block as the only root and block
+func blockCar(ctx context.Context, block *metadataBlock) ([]byte, error) {
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.TrustedStorage = true
+	lsys.StorageReadOpener = func(_ ipld.LinkContext, lnk ipld.Link) (io.Reader, error) {
+		asCidLink, ok := lnk.(cidlink.Link)
+		if !ok || !asCidLink.Cid.Equals(block.cid) {
+			return nil, datastore.ErrNotFound
+		}
+		return bytes.NewReader(block.data), nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := car.TraverseV1(ctx, &lsys, block.cid, selectorparse.CommonSelector_MatchPoint, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipld/go-car/v2"
+	"github.com/ipld/go-ipld-prime/codec/dagcbor"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	pandostore "github.com/kenlabs/pando-store/pkg/store"
+	v1 "github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/types/schema"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func newTestMetadataBlock(signKey crypto.PrivKey, provider peer.ID) (cid.Cid, []byte, error) {
+	meta, err := schema.NewMetaWithBytesPayload([]byte("payload"), provider, signKey)
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	n, err := meta.ToNode()
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	var buf bytes.Buffer
+	if err = dagjson.Encode(n, &buf); err != nil {
+		return cid.Undef, nil, err
+	}
+	c, err := schema.LinkProto.Prefix.Sum(buf.Bytes())
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	return c, buf.Bytes(), nil
+}
+
+func TestMetadataBlock(t *testing.T) {
+	Convey("test fetching metadata blocks by cid", t, func() {
+		ctx := context.Background()
+		signKey, _, err := crypto.GenerateEd25519Key(nil)
+		So(err, ShouldBeNil)
+		provider, err := peer.IDFromPrivateKey(signKey)
+		So(err, ShouldBeNil)
+		otherKey, _, err := crypto.GenerateEd25519Key(nil)
+		So(err, ShouldBeNil)
+
+		metaCid, metaData, err := newTestMetadataBlock(signKey, provider)
+		So(err, ShouldBeNil)
+		// Signed by another peer than the provider
+		forgedCid, forgedData, err := newTestMetadataBlock(otherKey, provider)
+		So(err, ShouldBeNil)
+		blocks := map[cid.Cid][]byte{metaCid: metaData, forgedCid: forgedData}
+
+		patch := gomonkey.ApplyMethodFunc(
+			reflect.TypeOf(&pandostore.PandoStore{}),
+			"Get",
+			func(ctx context.Context, key cid.Cid) ([]byte, error) {
+				data, ok := blocks[key]
+				if !ok {
+					return nil, datastore.ErrNotFound
+				}
+				return data, nil
+			},
+		)
+		defer patch.Reset()
+
+		Convey("returns the decoded metadata with the verification status", func() {
+			meta, err := mockController.Metadata(ctx, metaCid.String())
+			So(err, ShouldBeNil)
+			So(meta.Cid, ShouldEqual, metaCid.String())
+			So(meta.Provider, ShouldEqual, provider.String())
+			So(string(meta.Payload), ShouldEqual, `{"/":{"bytes":"cGF5bG9hZA"}}`)
+			So(meta.Verification.Verified, ShouldBeTrue)
+			So(meta.Verification.Signer, ShouldEqual, provider.String())
+
+			meta, err = mockController.Metadata(ctx, forgedCid.String())
+			So(err, ShouldBeNil)
+			So(meta.Verification.Verified, ShouldBeFalse)
+			So(meta.Verification.Error, ShouldNotBeEmpty)
+		})
+
+		Convey("returns the raw block in dag-json, dag-cbor or a car", func() {
+			data, verification, err := mockController.MetadataBlock(ctx, metaCid.String(), MetadataDagJSON)
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, metaData)
+			So(verification.Verified, ShouldBeTrue)
+
+			data, _, err = mockController.MetadataBlock(ctx, metaCid.String(), MetadataDagCBOR)
+			So(err, ShouldBeNil)
+			nb := basicnode.Prototype.Any.NewBuilder()
+			So(dagcbor.Decode(nb, bytes.NewReader(data)), ShouldBeNil)
+			provNode, err := nb.Build().LookupByString("Provider")
+			So(err, ShouldBeNil)
+			provStr, _ := provNode.AsString()
+			So(provStr, ShouldEqual, provider.String())
+
+			data, _, err = mockController.MetadataBlock(ctx, metaCid.String(), MetadataCAR)
+			So(err, ShouldBeNil)
+			reader, err := car.NewBlockReader(bytes.NewReader(data))
+			So(err, ShouldBeNil)
+			So(reader.Roots, ShouldResemble, []cid.Cid{metaCid})
+			block, err := reader.Next()
+			So(err, ShouldBeNil)
+			So(block.Cid(), ShouldResemble, metaCid)
+			So(block.RawData(), ShouldResemble, metaData)
+			_, err = reader.Next()
+			So(err, ShouldEqual, io.EOF)
+		})
+
+		Convey("returns errors for invalid or unknown cids", func() {
+			var apiError *v1.Error
+			_, err := mockController.Metadata(ctx, "invalid")
+			So(errors.As(err, &apiError), ShouldBeTrue)
+			So(apiError.Status(), ShouldEqual, http.StatusBadRequest)
+
+			unknownCid, err := schema.LinkProto.Prefix.Sum([]byte("unknown"))
+			So(err, ShouldBeNil)
+			_, _, err = mockController.MetadataBlock(ctx, unknownCid.String(), MetadataCAR)
+			So(errors.As(err, &apiError), ShouldBeTrue)
+			So(apiError.Status(), ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
@@ -8,7 +8,9 @@ import (
 	"github.com/agiledragon/gomonkey/v2"
 	"github.com/kenlabs/pando-store/pkg/snapshotstore"
 	"github.com/kenlabs/pando-store/pkg/types/store"
+	"github.com/kenlabs/pando/pkg/api/core"
 	v1 "github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/metacache"
 	"github.com/kenlabs/pando/pkg/util/cids"
 	. "github.com/smartystreets/goconvey/convey"
 	"net/http"
@@ -87,3 +89,63 @@ func TestMetadataList(t *testing.T) {
 		})
 	})
 }
+
+type testQuerier struct {
+	query   *metacache.Query
+	results []map[string]interface{}
+	err     error
+}
+
+func (q *testQuerier) Query(_ context.Context, _ string, query *metacache.Query) ([]map[string]interface{}, error) {
+	q.query = query
+	return q.results, q.err
+}
+
+func TestMetadataQuery(t *testing.T) {
+	Convey("TestMetadataQuery", t, func() {
+		ctx := context.Background()
+		provider := "12D3KooWNtUworDmrdTUjrPrwvE5ejKNMn8mnDkFFcuTzKoeDykp"
+		querier := &testQuerier{}
+		c := New(&core.Core{
+			StoreInstance: &core.StoreInstance{MetadataQuerier: querier},
+		}, mockController.Options)
+		maxLimit := mockController.Options.MetaCache.QueryMaxLimit
+
+		Convey("should run the validated query with the default limit", func() {
+			querier.results = []map[string]interface{}{{"size": 2.0}}
+			results, err := c.MetadataQuery(ctx, provider, &metacache.Query{
+				Collection: "deals",
+				Filter:     &metacache.Filter{Field: "size", Op: metacache.OpGt, Value: 1.0},
+			})
+			So(err, ShouldBeNil)
+			So(results, ShouldResemble, querier.results)
+			So(querier.query.Limit, ShouldEqual, maxLimit)
+		})
+
+		Convey("should fail with bad request for the invalid queries", func() {
+			for _, q := range []*metacache.Query{
+				nil,
+				{Collection: "$cmd"},
+				{Collection: "deals", Limit: maxLimit + 1},
+				{Collection: "deals", Filter: &metacache.Filter{Field: "$where", Op: metacache.OpEq, Value: "1"}},
+			} {
+				_, err := c.MetadataQuery(ctx, provider, q)
+				So(err, ShouldNotBeNil)
+				So(err.(*v1.Error).Status(), ShouldEqual, http.StatusBadRequest)
+			}
+			_, err := c.MetadataQuery(ctx, "invalid", &metacache.Query{Collection: "deals"})
+			So(err.(*v1.Error).Status(), ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("should map the errors of the querier", func() {
+			querier.err = metacache.ErrTimeout
+			_, err := c.MetadataQuery(ctx, provider, &metacache.Query{Collection: "deals"})
+			So(err.(*v1.Error).Status(), ShouldEqual, http.StatusGatewayTimeout)
+
+			querier.err = errors.New("connection refused")
+			_, err = c.MetadataQuery(ctx, provider, &metacache.Query{Collection: "deals"})
+			So(err.(*v1.Error).Status(), ShouldEqual, http.StatusInternalServerError)
+			So(err.Error(), ShouldEqual, v1.InternalServerError.Error())
+		})
+	})
+}
@@ -49,6 +49,10 @@ func (c *Controller) ProviderRegister(ctx context.Context, data []byte) error {
 		},
 	}
 	err = c.Core.Registry.Register(ctx, info)
+	if err != nil {
+		logger.Errorf("failed to register provider %s: %v", info.AddrInfo.ID, err)
+		return err
+	}
 
 	logger.Debugf("pando register success: %s", info.AddrInfo.ID)
 
@@ -64,6 +68,33 @@ func (c *Controller) ListProviderInfo(p peer.ID) ([]*registry.ProviderInfo, erro
 	return info, nil
 }
 
+// QueryProviderInfo returns a page of the registered providers matching the
+// query, see registry.ProviderQuery.
+func (c *Controller) QueryProviderInfo(q *registry.ProviderQuery) (*registry.ProviderPage, error) {
+	return c.Core.Registry.QueryProviders(q)
+}
+
+func (c *Controller) ProviderStatusHistory(ctx context.Context, p peer.ID) ([]*registry.StatusTransition, error) {
+	if p == "" {
+		return nil, v1.NewError(errors.New("peerid is required"), http.StatusBadRequest)
+	}
+	history, err := c.Core.Registry.StatusHistory(ctx, p)
+	if err != nil {
+		logger.Errorf("failed to read status history of provider %s: %v", p, err)
+		return nil, v1.NewError(v1.InternalServerError, http.StatusInternalServerError)
+	}
+	if len(history) == 0 {
+		return nil, v1.NewError(errors.New("provider not found"), http.StatusNotFound)
+	}
+	return history, nil
+}
+
+// SubscribeProviderEvents returns the events of registry, see
+// registry.Registry.Subscribe.
+func (c *Controller) SubscribeProviderEvents() (<-chan *registry.Event, func()) {
+	return c.Core.Registry.Subscribe()
+}
+
 func (c *Controller) ListProviderHead(p peer.ID) (cid.Cid, error) {
 	var cidBytes []byte
 	var err error
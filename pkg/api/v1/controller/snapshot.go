@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/ipfs/go-cid"
+	"github.com/kenlabs/pando-store/pkg/types/cbortypes"
+	"github.com/kenlabs/pando-store/pkg/types/store"
+	v1 "github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultSnapShotLimit = 100
+	maxSnapShotLimit     = 1000
+	// maxSnapShotScan is the max number of snapshots loaded for a page when
+	// filtering by provider, the page may be short but with a NextCursor to
+	// continue scanning once reached.
+	maxSnapShotScan = 10000
+)
+
+// SnapShotQuery selects and paginates the snapshots in height order.  Empty
+// fields do not filter.
+type SnapShotQuery struct {
+	// FromHeight and ToHeight are the inclusive range of heights
+	FromHeight *uint64 `json:",omitempty"`
+	ToHeight   *uint64 `json:",omitempty"`
+	// Since and Until are the inclusive window of the created time
+	Since *time.Time `json:",omitempty"`
+	Until *time.Time `json:",omitempty"`
+	// Provider selects the snapshots including updates of the provider
+	Provider peer.ID `json:",omitempty"`
+	// Compact only returns the heights and cids of the snapshots
+	Compact bool `json:",omitempty"`
+	// Limit is the max number of snapshots in a page, defaults to 100
+	Limit int `json:",omitempty"`
+	// Cursor is the NextCursor of the previous page
+	Cursor string `json:",omitempty"`
+}
+
+// SnapShotInfo is a snapshot in a page, only Height and Cid are set in
+// compact mode.
+type SnapShotInfo struct {
+	Height      uint64
+	Cid         cid.Cid
+	CreatedTime uint64              `json:",omitempty"`
+	SnapShot    *cbortypes.SnapShot `json:",omitempty"`
+}
+
+// SnapShotPage is a page of queried snapshots
+type SnapShotPage struct {
+	SnapShots []*SnapShotInfo
+	// NextCursor is empty if there is no more page
+	NextCursor string `json:",omitempty"`
+}
+
+// snapShotLoader loads a snapshot by its cid
+type snapShotLoader func(ctx context.Context, c cid.Cid) (*cbortypes.SnapShot, error)
+
+// QuerySnapShots returns a page of the snapshots matching the query
+func (c *Controller) QuerySnapShots(ctx context.Context, q *SnapShotQuery) (*SnapShotPage, error) {
+	snapShotStore := c.Core.StoreInstance.PandoStore.SnapShotStore()
+	list, err := snapShotStore.GetSnapShotList(ctx)
+	if err != nil {
+		return nil, v1.NewError(err, http.StatusInternalServerError)
+	}
+	return querySnapShots(ctx, list, q, snapShotStore.GetSnapShotByCid)
+}
+
+func querySnapShots(ctx context.Context, list *store.SnapShotList, q *SnapShotQuery, load snapShotLoader) (*SnapShotPage, error) {
+	if q == nil {
+		q = &SnapShotQuery{}
+	}
+	limit := q.Limit
+	switch {
+	case limit < 0:
+		return nil, v1.NewError(errors.New("invalid limit"), http.StatusBadRequest)
+	case limit == 0:
+		limit = defaultSnapShotLimit
+	case limit > maxSnapShotLimit:
+		limit = maxSnapShotLimit
+	}
+	if q.Provider != "" {
+		if err := q.Provider.Validate(); err != nil {
+			return nil, v1.NewError(errors.New("invalid provider"), http.StatusBadRequest)
+		}
+	}
+
+	page := &SnapShotPage{SnapShots: []*SnapShotInfo{}}
+	if list == nil || len(list.List) == 0 {
+		return page, nil
+	}
+
+	start := uint64(0)
+	if q.FromHeight != nil {
+		start = *q.FromHeight
+	}
+	if q.Cursor != "" {
+		next, err := decodeSnapShotCursor(q.Cursor)
+		if err != nil {
+			return nil, v1.NewError(err, http.StatusBadRequest)
+		}
+		if next > start {
+			start = next
+		}
+	}
+	end := uint64(len(list.List) - 1)
+	if q.ToHeight != nil && *q.ToHeight < end {
+		end = *q.ToHeight
+	}
+
+	var scanned int
+	for height := start; height <= end; height++ {
+		if len(page.SnapShots) == limit || scanned == maxSnapShotScan {
+			page.NextCursor = encodeSnapShotCursor(height)
+			break
+		}
+		entry := list.List[height]
+		if q.Since != nil && entry.CreatedTime < uint64(q.Since.UnixNano()) {
+			continue
+		}
+		if q.Until != nil && entry.CreatedTime > uint64(q.Until.UnixNano()) {
+			// The snapshots are created in height order
+			break
+		}
+
+		info := &SnapShotInfo{Height: height, Cid: entry.SnapShotCid}
+		if q.Provider != "" || !q.Compact {
+			scanned++
+			snapshot, err := load(ctx, entry.SnapShotCid)
+			if err != nil {
+				logger.Errorf("failed to load snapshot %s at height %d: %v", entry.SnapShotCid, height, err)
+				return nil, v1.NewError(v1.InternalServerError, http.StatusInternalServerError)
+			}
+			if q.Provider != "" {
+				if _, ok := snapshot.Update[q.Provider.String()]; !ok {
+					continue
+				}
+			}
+			if !q.Compact {
+				info.CreatedTime = entry.CreatedTime
+				info.SnapShot = snapshot
+			}
+		}
+		page.SnapShots = append(page.SnapShots, info)
+	}
+	return page, nil
+}
+
+// The cursor is the height the next page starts from
+func encodeSnapShotCursor(height uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(height, 10)))
+}
+
+func decodeSnapShotCursor(cursor string) (uint64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+	height, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+	return height, nil
+}
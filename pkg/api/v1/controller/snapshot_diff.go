@@ -0,0 +1,413 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ipfs/go-cid"
+	"github.com/kenlabs/pando-store/pkg/types/cbortypes"
+	"github.com/kenlabs/pando-store/pkg/types/store"
+	"github.com/kenlabs/pando/pkg/access"
+	v1 "github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// The types of the entries of a snapshot diff
+const (
+	// SnapShotDiffMetadata lists the new metadata of a provider in a snapshot
+	SnapShotDiffMetadata = "metadata"
+	// SnapShotDiffHead is the change of the head of a provider over the range
+	SnapShotDiffHead = "head"
+	// SnapShotDiffAppeared are the providers whose chains start in the range,
+	// SnapShotDiffDisappeared are the providers with metadata at or before
+	// From but none in the range.
+	SnapShotDiffAppeared    = "appeared"
+	SnapShotDiffDisappeared = "disappeared"
+	// SnapShotDiffError ends a stream failed midway
+	SnapShotDiffError = "error"
+)
+
+// maxCollectedSnapShotDiff bounds the number of snapshots of a diff collected
+// in memory, the diffs of larger ranges are streamed.
+const maxCollectedSnapShotDiff = 1000
+
+// SnapShotDiffQuery is the range of a diff.  From and To are the heights or
+// cids of the snapshots, the diff is of the snapshots after From up to To.
+type SnapShotDiffQuery struct {
+	From string
+	To   string
+}
+
+// SnapShotDiff is the range of a diff checked to be walked
+type SnapShotDiff struct {
+	FromHeight uint64
+	FromCid    cid.Cid
+	ToHeight   uint64
+	ToCid      cid.Cid
+}
+
+// SnapShotDiffEntry is an entry of a diff, the fields set depend on Type
+type SnapShotDiffEntry struct {
+	Type     string
+	Provider string `json:",omitempty"`
+	// Height, SnapShot and Metadata are set in the metadata entries
+	Height   uint64   `json:",omitempty"`
+	SnapShot string   `json:",omitempty"`
+	Metadata []string `json:",omitempty"`
+	// PreviousHead is the head before the range, it is empty if the range
+	// starts the chain of the provider.
+	PreviousHead string `json:",omitempty"`
+	Head         string `json:",omitempty"`
+	Error        string `json:",omitempty"`
+}
+
+// PrepareSnapShotDiff checks the range of the diff, so that the errors are
+// known before the diff is walked.
+func (c *Controller) PrepareSnapShotDiff(ctx context.Context, q *SnapShotDiffQuery) (*SnapShotDiff, error) {
+	if q.From == "" || q.To == "" {
+		return nil, v1.NewError(errors.New("from and to are required"), http.StatusBadRequest)
+	}
+	list, err := c.Core.StoreInstance.PandoStore.SnapShotStore().GetSnapShotList(ctx)
+	if err != nil {
+		logger.Errorf("failed to get snapshot list: %v", err)
+		return nil, v1.NewError(v1.InternalServerError, http.StatusInternalServerError)
+	}
+
+	diff := &SnapShotDiff{}
+	if diff.FromHeight, diff.FromCid, err = resolveSnapShot(list, q.From); err != nil {
+		return nil, err
+	}
+	if diff.ToHeight, diff.ToCid, err = resolveSnapShot(list, q.To); err != nil {
+		return nil, err
+	}
+	if diff.FromHeight > diff.ToHeight {
+		return nil, v1.NewError(errors.New("from is after to"), http.StatusBadRequest)
+	}
+	return diff, nil
+}
+
+// providerRange is the head and the oldest metadata of a provider in the range
+// of a diff
+type providerRange struct {
+	head   cid.Cid
+	oldest cid.Cid
+}
+
+// WalkSnapShotDiff walks the PrevSnapShot chain from the To snapshot back to
+// the From snapshot and emits the metadata entries of each snapshot on the
+// way, then the head entries and the providers appeared or disappeared in the
+// range.  The providers known at From are looked up in the index of the
+// snapshots, which is extended to the latest snapshot.  The metadata of the restricted collections the
+// consumer is not granted to are left out.
+func (c *Controller) WalkSnapShotDiff(ctx context.Context, diff *SnapShotDiff, emit func(*SnapShotDiffEntry) error) error {
+	if diff.FromHeight == diff.ToHeight {
+		return nil
+	}
+	load := c.Core.StoreInstance.PandoStore.SnapShotStore().GetSnapShotByCid
+	consumer := access.ConsumerFrom(ctx)
+
+	ranges := make(map[string]*providerRange)
+
+	next := diff.ToCid
+	for height := diff.ToHeight; height > diff.FromHeight; height-- {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		snapshot, err := load(ctx, next)
+		if err != nil {
+			return fmt.Errorf("failed to load snapshot %s: %w", next, err)
+		}
+		if snapshot.Height != height {
+			return fmt.Errorf("snapshot %s is at height %d, expected %d", next, snapshot.Height, height)
+		}
+		for _, provider := range sortedUpdateProviders(snapshot.Update) {
+			metaList, err := c.visibleMetaList(ctx, provider, snapshot.Update[provider], consumer)
+			if err != nil {
+				return err
+			}
+			if len(metaList) == 0 {
+				continue
+			}
+			r, ok := ranges[provider]
+			if !ok {
+				r = &providerRange{head: metaList[len(metaList)-1]}
+				ranges[provider] = r
+			}
+			r.oldest = metaList[0]
+
+			entry := &SnapShotDiffEntry{
+				Type:     SnapShotDiffMetadata,
+				Provider: provider,
+				Height:   height,
+				SnapShot: next.String(),
+			}
+			for _, metaCid := range metaList {
+				entry.Metadata = append(entry.Metadata, metaCid.String())
+			}
+			if err = emit(entry); err != nil {
+				return err
+			}
+		}
+
+		next, err = cid.Decode(snapshot.PrevSnapShot)
+		if err != nil {
+			return fmt.Errorf("invalid previous snapshot of height %d: %w", snapshot.Height, err)
+		}
+	}
+	if !next.Equals(diff.FromCid) {
+		return fmt.Errorf("snapshot chain reaches %s instead of %s", next, diff.FromCid)
+	}
+
+	providers := make([]string, 0, len(ranges))
+	for provider := range ranges {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	var appeared []string
+	for _, provider := range providers {
+		r := ranges[provider]
+		previous, err := c.previousMetadata(ctx, r.oldest)
+		if err != nil {
+			logger.Warnf("failed to read metadata %s for its previous: %v", r.oldest, err)
+		} else if previous == "" {
+			appeared = append(appeared, provider)
+		}
+		entry := &SnapShotDiffEntry{
+			Type:         SnapShotDiffHead,
+			Provider:     provider,
+			PreviousHead: previous,
+			Head:         r.head.String(),
+		}
+		if err = emit(entry); err != nil {
+			return err
+		}
+	}
+	for _, provider := range appeared {
+		if err := emit(&SnapShotDiffEntry{Type: SnapShotDiffAppeared, Provider: provider}); err != nil {
+			return err
+		}
+	}
+
+	disappeared, err := c.knownProviders(ctx, diff.FromHeight, consumer, ranges)
+	if err != nil {
+		return err
+	}
+	for _, provider := range disappeared {
+		if err = emit(&SnapShotDiffEntry{Type: SnapShotDiffDisappeared, Provider: provider}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapShotIndex is the heights of the snapshots updating each provider, so
+// that the providers known at a height are found without walking the chain
+// back to the first snapshot on each diff.
+type snapShotIndex struct {
+	mutex sync.Mutex
+	// last is the last snapshot indexed, the index is rebuilt if it is no
+	// longer in the snapshot list.
+	last    cid.Cid
+	length  int
+	heights map[string][]uint64
+}
+
+// indexSnapShots indexes the snapshots of the list after the last one indexed.
+// It is called with the index locked.
+func (c *Controller) indexSnapShots(ctx context.Context, list *store.SnapShotList) error {
+	index := &c.snapShots
+	if index.heights == nil || index.length > len(list.List) ||
+		(index.length > 0 && !list.List[index.length-1].SnapShotCid.Equals(index.last)) {
+		index.heights = make(map[string][]uint64)
+		index.length = 0
+	}
+	load := c.Core.StoreInstance.PandoStore.SnapShotStore().GetSnapShotByCid
+	for ; index.length < len(list.List); index.length++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		height := uint64(index.length)
+		snapshotCid := list.List[index.length].SnapShotCid
+		snapshot, err := load(ctx, snapshotCid)
+		if err != nil {
+			return fmt.Errorf("failed to load snapshot %s: %w", snapshotCid, err)
+		}
+		if snapshot.Height != height {
+			return fmt.Errorf("snapshot %s is at height %d, expected %d", snapshotCid, snapshot.Height, height)
+		}
+		for provider, metaList := range snapshot.Update {
+			if metaList != nil && len(metaList.MetaList) > 0 {
+				index.heights[provider] = append(index.heights[provider], height)
+			}
+		}
+		index.last = snapshotCid
+	}
+	return nil
+}
+
+// knownProviders returns the providers, except the ones in exclude, with any
+// metadata the consumer can read at or before the height, in order.  The
+// snapshots are only loaded for the restricted providers, until one of them
+// has metadata the consumer is granted to.
+func (c *Controller) knownProviders(ctx context.Context, height uint64, consumer peer.ID, exclude map[string]*providerRange) ([]string, error) {
+	list, err := c.Core.StoreInstance.PandoStore.SnapShotStore().GetSnapShotList(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshot list: %w", err)
+	}
+	if list == nil {
+		return nil, nil
+	}
+
+	index := &c.snapShots
+	index.mutex.Lock()
+	if err = c.indexSnapShots(ctx, list); err != nil {
+		index.mutex.Unlock()
+		return nil, err
+	}
+	candidates := make(map[string][]uint64)
+	for provider, heights := range index.heights {
+		if _, ok := exclude[provider]; ok || heights[0] > height {
+			continue
+		}
+		n := sort.Search(len(heights), func(i int) bool { return heights[i] > height })
+		candidates[provider] = heights[:n]
+	}
+	index.mutex.Unlock()
+
+	load := c.Core.StoreInstance.PandoStore.SnapShotStore().GetSnapShotByCid
+	known := make([]string, 0, len(candidates))
+	for provider, heights := range candidates {
+		if !c.restrictedProvider(provider) {
+			known = append(known, provider)
+			continue
+		}
+		for _, h := range heights {
+			if err = ctx.Err(); err != nil {
+				return nil, err
+			}
+			snapshotCid := list.List[h].SnapShotCid
+			snapshot, err := load(ctx, snapshotCid)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load snapshot %s: %w", snapshotCid, err)
+			}
+			visible, err := c.visibleMetaList(ctx, provider, snapshot.Update[provider], consumer)
+			if err != nil {
+				return nil, err
+			}
+			if len(visible) > 0 {
+				known = append(known, provider)
+				break
+			}
+		}
+	}
+	sort.Strings(known)
+	return known, nil
+}
+
+// CollectSnapShotDiff returns the entries of the diff in memory, for the
+// clients not reading streams such as GraphQL.
+func (c *Controller) CollectSnapShotDiff(ctx context.Context, q *SnapShotDiffQuery) ([]*SnapShotDiffEntry, error) {
+	diff, err := c.PrepareSnapShotDiff(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if diff.ToHeight-diff.FromHeight > maxCollectedSnapShotDiff {
+		return nil, v1.NewError(fmt.Errorf("range of more than %d snapshots should be streamed by /metadata/snapshot/diff",
+			maxCollectedSnapShotDiff), http.StatusBadRequest)
+	}
+
+	entries := []*SnapShotDiffEntry{}
+	err = c.WalkSnapShotDiff(ctx, diff, func(entry *SnapShotDiffEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		logger.Errorf("failed to walk snapshot diff from %d to %d: %v", diff.FromHeight, diff.ToHeight, err)
+		return nil, v1.NewError(v1.InternalServerError, http.StatusInternalServerError)
+	}
+	return entries, nil
+}
+
+// visibleMetaList returns the metadata of the provider the consumer can read
+func (c *Controller) visibleMetaList(ctx context.Context, provider string, metaList *cbortypes.Metalist, consumer peer.ID) ([]cid.Cid, error) {
+	if metaList == nil {
+		return nil, nil
+	}
+	if !c.restrictedProvider(provider) {
+		return metaList.MetaList, nil
+	}
+	ac := c.Core.Access
+	visible := make([]cid.Cid, 0, len(metaList.MetaList))
+	for _, metaCid := range metaList.MetaList {
+		err := ac.CheckCid(ctx, metaCid, consumer)
+		if err == nil {
+			visible = append(visible, metaCid)
+			continue
+		}
+		if !errors.Is(err, access.ErrDenied) {
+			return nil, err
+		}
+	}
+	return visible, nil
+}
+
+// restrictedProvider returns whether the metadata of the provider are checked
+// for the consumers.  The providers are restricted unless the access
+// controller knows otherwise.
+func (c *Controller) restrictedProvider(provider string) bool {
+	ac := c.Core.Access
+	if ac == nil {
+		return false
+	}
+	providerID, err := peer.Decode(provider)
+	return err != nil || ac.Restricted(providerID)
+}
+
+// previousMetadata returns the PreviousID of the metadata, or empty if it is
+// the first of the chain.
+func (c *Controller) previousMetadata(ctx context.Context, metaCid cid.Cid) (string, error) {
+	block, err := c.readMetadata(ctx, metaCid)
+	if err != nil {
+		return "", err
+	}
+	if block.meta.PreviousID == nil {
+		return "", nil
+	}
+	return (*block.meta.PreviousID).String(), nil
+}
+
+// resolveSnapShot returns the height and cid of the snapshot by its height or
+// cid in ref.
+func resolveSnapShot(list *store.SnapShotList, ref string) (uint64, cid.Cid, error) {
+	if height, err := strconv.ParseUint(ref, 10, 64); err == nil {
+		if list == nil || height >= uint64(len(list.List)) {
+			return 0, cid.Undef, v1.NewError(fmt.Errorf("snapshot not found at height %d", height), http.StatusNotFound)
+		}
+		return height, list.List[height].SnapShotCid, nil
+	}
+	snapshotCid, err := cid.Decode(ref)
+	if err != nil {
+		return 0, cid.Undef, v1.NewError(fmt.Errorf("invalid snapshot height or cid: %s", ref), http.StatusBadRequest)
+	}
+	if list != nil {
+		for height, entry := range list.List {
+			if entry.SnapShotCid.Equals(snapshotCid) {
+				return uint64(height), snapshotCid, nil
+			}
+		}
+	}
+	return 0, cid.Undef, v1.NewError(fmt.Errorf("snapshot not found: %s", ref), http.StatusNotFound)
+}
+
+func sortedUpdateProviders(update map[string]*cbortypes.Metalist) []string {
+	providers := make([]string, 0, len(update))
+	for provider := range update {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	return providers
+}
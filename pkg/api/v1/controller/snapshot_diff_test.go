@@ -0,0 +1,227 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/kenlabs/pando-store/pkg/snapshotstore"
+	pandostore "github.com/kenlabs/pando-store/pkg/store"
+	"github.com/kenlabs/pando-store/pkg/types/cbortypes"
+	"github.com/kenlabs/pando-store/pkg/types/store"
+	v1 "github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSnapShotDiff(t *testing.T) {
+	Convey("test diffing the snapshots between two heights", t, func() {
+		ctx := context.Background()
+		keyA, _, err := crypto.GenerateEd25519Key(nil)
+		So(err, ShouldBeNil)
+		providerA, err := peer.IDFromPrivateKey(keyA)
+		So(err, ShouldBeNil)
+		keyB, _, err := crypto.GenerateEd25519Key(nil)
+		So(err, ShouldBeNil)
+		providerB, err := peer.IDFromPrivateKey(keyB)
+		So(err, ShouldBeNil)
+		keyC, _, err := crypto.GenerateEd25519Key(nil)
+		So(err, ShouldBeNil)
+		providerC, err := peer.IDFromPrivateKey(keyC)
+		So(err, ShouldBeNil)
+
+		noCollection := func(int) string { return "" }
+		chainA, blocks, err := newTestChain(keyA, providerA, 4, noCollection)
+		So(err, ShouldBeNil)
+		chainB, blocksB, err := newTestChain(keyB, providerB, 2, noCollection)
+		So(err, ShouldBeNil)
+		chainC, blocksC, err := newTestChain(keyC, providerC, 1, noCollection)
+		So(err, ShouldBeNil)
+		for _, other := range []map[cid.Cid][]byte{blocksB, blocksC} {
+			for c, data := range other {
+				blocks[c] = data
+			}
+		}
+
+		// The updates of the snapshots at the heights 0 to 3, C only updates
+		// in the middle
+		updates := []map[peer.ID][]cid.Cid{
+			{providerA: chainA[:1]},
+			{providerA: chainA[1:2], providerB: chainB[:1]},
+			{providerA: chainA[2:], providerC: chainC},
+			{providerB: chainB[1:]},
+		}
+		prefix := cid.Prefix{Version: 1, Codec: cid.DagCBOR, MhType: 0x12, MhLength: -1}
+		list := &store.SnapShotList{}
+		snapshots := make(map[cid.Cid]*cbortypes.SnapShot)
+		prev := ""
+		for height, update := range updates {
+			snapshotCid, err := prefix.Sum([]byte(fmt.Sprint("snapshot", height)))
+			So(err, ShouldBeNil)
+			snapshot := &cbortypes.SnapShot{
+				Update:       make(map[string]*cbortypes.Metalist),
+				Height:       uint64(height),
+				PrevSnapShot: prev,
+			}
+			for provider, metaList := range update {
+				snapshot.Update[provider.String()] = &cbortypes.Metalist{MetaList: metaList}
+			}
+			snapshots[snapshotCid] = snapshot
+			list.List = append(list.List, struct {
+				CreatedTime uint64
+				SnapShotCid cid.Cid
+			}{SnapShotCid: snapshotCid})
+			prev = snapshotCid.String()
+		}
+
+		patches := gomonkey.ApplyMethodFunc(
+			reflect.TypeOf(&pandostore.PandoStore{}),
+			"Get",
+			func(ctx context.Context, key cid.Cid) ([]byte, error) {
+				data, ok := blocks[key]
+				if !ok {
+					return nil, datastore.ErrNotFound
+				}
+				return data, nil
+			},
+		)
+		defer patches.Reset()
+		patches.ApplyMethodFunc(
+			reflect.TypeOf(&snapshotstore.SnapShotStore{}),
+			"GetSnapShotList",
+			func(ctx context.Context) (*store.SnapShotList, error) {
+				return list, nil
+			},
+		)
+		// the snapshots of each run have the same cids but other providers
+		mockController.snapShots.heights = nil
+		loads := 0
+		patches.ApplyMethodFunc(
+			reflect.TypeOf(&snapshotstore.SnapShotStore{}),
+			"GetSnapShotByCid",
+			func(ctx context.Context, c cid.Cid) (*cbortypes.SnapShot, error) {
+				loads++
+				snapshot, ok := snapshots[c]
+				if !ok {
+					return nil, datastore.ErrNotFound
+				}
+				return snapshot, nil
+			},
+		)
+
+		sorted := func(providers ...peer.ID) []string {
+			ids := make([]string, 0, len(providers))
+			for _, provider := range providers {
+				ids = append(ids, provider.String())
+			}
+			sort.Strings(ids)
+			return ids
+		}
+		type diffEntries struct {
+			metadata              []string
+			heads                 map[string]*SnapShotDiffEntry
+			appeared, disappeared []string
+		}
+		collect := func(from, to string) *diffEntries {
+			entries, err := mockController.CollectSnapShotDiff(ctx, &SnapShotDiffQuery{From: from, To: to})
+			So(err, ShouldBeNil)
+			d := &diffEntries{heads: make(map[string]*SnapShotDiffEntry)}
+			for _, entry := range entries {
+				switch entry.Type {
+				case SnapShotDiffMetadata:
+					d.metadata = append(d.metadata, entry.Metadata...)
+				case SnapShotDiffHead:
+					d.heads[entry.Provider] = entry
+				case SnapShotDiffAppeared:
+					d.appeared = append(d.appeared, entry.Provider)
+				case SnapShotDiffDisappeared:
+					d.disappeared = append(d.disappeared, entry.Provider)
+				}
+			}
+			return d
+		}
+
+		Convey("walks back from to and emits the metadata, heads and providers changed", func() {
+			d := collect("0", list.List[3].SnapShotCid.String())
+			So(d.metadata, ShouldHaveLength, 6)
+			So(d.metadata[0], ShouldEqual, chainB[1].String())
+			So(d.metadata, ShouldContain, chainA[1].String())
+			So(d.metadata, ShouldNotContain, chainA[0].String())
+
+			So(d.heads, ShouldHaveLength, 3)
+			So(d.heads[providerA.String()].PreviousHead, ShouldEqual, chainA[0].String())
+			So(d.heads[providerA.String()].Head, ShouldEqual, chainA[3].String())
+			So(d.heads[providerB.String()].PreviousHead, ShouldBeEmpty)
+			So(d.heads[providerB.String()].Head, ShouldEqual, chainB[1].String())
+
+			// A is known at from, B and C are new
+			So(d.appeared, ShouldResemble, sorted(providerB, providerC))
+			So(d.disappeared, ShouldBeEmpty)
+		})
+
+		Convey("compares the providers known at from to the ones in the range", func() {
+			// C updates in the middle of the range only, A and B before it
+			d := collect("1", "3")
+			So(d.appeared, ShouldResemble, []string{providerC.String()})
+			So(d.disappeared, ShouldBeEmpty)
+
+			// only B updates in the range
+			d = collect("2", "3")
+			So(d.appeared, ShouldBeEmpty)
+			So(d.disappeared, ShouldResemble, sorted(providerA, providerC))
+		})
+
+		Convey("indexes the snapshots once for the providers known at from", func() {
+			collect("2", "3")
+			// the snapshot at 3, then the 4 snapshots indexed
+			So(loads, ShouldEqual, 5)
+			loads = 0
+			collect("2", "3")
+			So(loads, ShouldEqual, 1)
+
+			snapshotCid, err := prefix.Sum([]byte("snapshot4"))
+			So(err, ShouldBeNil)
+			snapshots[snapshotCid] = &cbortypes.SnapShot{
+				Update:       map[string]*cbortypes.Metalist{providerB.String(): {MetaList: chainB[1:]}},
+				Height:       4,
+				PrevSnapShot: prev,
+			}
+			list.List = append(list.List, struct {
+				CreatedTime uint64
+				SnapShotCid cid.Cid
+			}{SnapShotCid: snapshotCid})
+			loads = 0
+			d := collect("2", "4")
+			So(loads, ShouldEqual, 3)
+			So(d.disappeared, ShouldResemble, sorted(providerA, providerC))
+		})
+
+		Convey("returns an empty diff for the same snapshot", func() {
+			entries, err := mockController.CollectSnapShotDiff(ctx, &SnapShotDiffQuery{From: "2", To: "2"})
+			So(err, ShouldBeNil)
+			So(entries, ShouldBeEmpty)
+		})
+
+		Convey("returns errors for invalid or unknown ranges", func() {
+			var apiError *v1.Error
+			_, err := mockController.CollectSnapShotDiff(ctx, &SnapShotDiffQuery{From: "3", To: "1"})
+			So(errors.As(err, &apiError), ShouldBeTrue)
+			So(apiError.Status(), ShouldEqual, http.StatusBadRequest)
+
+			_, err = mockController.CollectSnapShotDiff(ctx, &SnapShotDiffQuery{From: "0", To: "invalid"})
+			So(errors.As(err, &apiError), ShouldBeTrue)
+			So(apiError.Status(), ShouldEqual, http.StatusBadRequest)
+
+			_, err = mockController.CollectSnapShotDiff(ctx, &SnapShotDiffQuery{From: "0", To: "4"})
+			So(errors.As(err, &apiError), ShouldBeTrue)
+			So(apiError.Status(), ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
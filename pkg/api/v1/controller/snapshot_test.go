@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ipfs/go-cid"
+	"github.com/kenlabs/pando-store/pkg/types/cbortypes"
+	"github.com/kenlabs/pando-store/pkg/types/store"
+	v1 "github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// sha2-256 of dag-cbor, as the cids of the snapshots
+var snapShotPrefix = cid.Prefix{Version: 1, Codec: cid.DagCBOR, MhType: 0x12, MhLength: -1}
+
+func TestQuerySnapShots(t *testing.T) {
+	Convey("test query snapshots with filters and pagination", t, func() {
+		ctx := context.Background()
+		_, pubKey, err := crypto.GenerateEd25519Key(nil)
+		So(err, ShouldBeNil)
+		provider, err := peer.IDFromPublicKey(pubKey)
+		So(err, ShouldBeNil)
+
+		// 30 snapshots created a second apart, the provider is in the even ones
+		base := time.Now()
+		list := &store.SnapShotList{}
+		snapshots := make(map[cid.Cid]*cbortypes.SnapShot)
+		for i := 0; i < 30; i++ {
+			c, err := snapShotPrefix.Sum([]byte(fmt.Sprintf("snapshot-%d", i)))
+			So(err, ShouldBeNil)
+			created := uint64(base.Add(time.Duration(i) * time.Second).UnixNano())
+			list.List = append(list.List, struct {
+				CreatedTime uint64
+				SnapShotCid cid.Cid
+			}{CreatedTime: created, SnapShotCid: c})
+			update := map[string]*cbortypes.Metalist{"other": {}}
+			if i%2 == 0 {
+				update[provider.String()] = &cbortypes.Metalist{}
+			}
+			snapshots[c] = &cbortypes.SnapShot{Update: update, Height: uint64(i), CreateTime: created}
+		}
+		list.Length = len(list.List)
+		var loaded int
+		load := func(ctx context.Context, c cid.Cid) (*cbortypes.SnapShot, error) {
+			loaded++
+			return snapshots[c], nil
+		}
+		heights := func(page *SnapShotPage) []uint64 {
+			var res []uint64
+			for _, info := range page.SnapShots {
+				res = append(res, info.Height)
+			}
+			return res
+		}
+
+		Convey("pages through all the snapshots by cursor", func() {
+			var all []uint64
+			q := &SnapShotQuery{Limit: 7}
+			for {
+				page, err := querySnapShots(ctx, list, q, load)
+				So(err, ShouldBeNil)
+				So(len(page.SnapShots), ShouldBeLessThanOrEqualTo, 7)
+				all = append(all, heights(page)...)
+				if page.NextCursor == "" {
+					break
+				}
+				q.Cursor = page.NextCursor
+			}
+			So(len(all), ShouldEqual, 30)
+			So(all[0], ShouldEqual, 0)
+			So(all[29], ShouldEqual, 29)
+		})
+
+		Convey("compact mode returns only heights and cids without loading", func() {
+			page, err := querySnapShots(ctx, list, &SnapShotQuery{Compact: true, Limit: 3}, load)
+			So(err, ShouldBeNil)
+			So(loaded, ShouldEqual, 0)
+			So(page.SnapShots[1].Cid, ShouldResemble, list.List[1].SnapShotCid)
+			So(page.SnapShots[1].SnapShot, ShouldBeNil)
+			So(page.SnapShots[1].CreatedTime, ShouldEqual, 0)
+
+			page, err = querySnapShots(ctx, list, &SnapShotQuery{Limit: 3}, load)
+			So(err, ShouldBeNil)
+			So(page.SnapShots[1].SnapShot, ShouldEqual, snapshots[list.List[1].SnapShotCid])
+			So(page.SnapShots[1].CreatedTime, ShouldEqual, list.List[1].CreatedTime)
+		})
+
+		Convey("filters by height range, time window and provider", func() {
+			from, to := uint64(5), uint64(12)
+			page, err := querySnapShots(ctx, list, &SnapShotQuery{FromHeight: &from, ToHeight: &to, Compact: true}, load)
+			So(err, ShouldBeNil)
+			So(heights(page), ShouldResemble, []uint64{5, 6, 7, 8, 9, 10, 11, 12})
+			So(page.NextCursor, ShouldBeEmpty)
+
+			since := base.Add(20 * time.Second)
+			until := base.Add(23 * time.Second)
+			page, err = querySnapShots(ctx, list, &SnapShotQuery{Since: &since, Until: &until, Compact: true}, load)
+			So(err, ShouldBeNil)
+			So(heights(page), ShouldResemble, []uint64{20, 21, 22, 23})
+
+			page, err = querySnapShots(ctx, list, &SnapShotQuery{Provider: provider, ToHeight: &to, Compact: true}, load)
+			So(err, ShouldBeNil)
+			So(heights(page), ShouldResemble, []uint64{0, 2, 4, 6, 8, 10, 12})
+			So(page.SnapShots[0].SnapShot, ShouldBeNil)
+		})
+
+		Convey("returns an empty page without snapshots", func() {
+			page, err := querySnapShots(ctx, nil, &SnapShotQuery{}, load)
+			So(err, ShouldBeNil)
+			So(page.SnapShots, ShouldBeEmpty)
+		})
+
+		Convey("rejects invalid queries with code 400", func() {
+			var apiError *v1.Error
+			_, err := querySnapShots(ctx, list, &SnapShotQuery{Cursor: "!"}, load)
+			So(errors.As(err, &apiError), ShouldBeTrue)
+			So(apiError.Status(), ShouldEqual, http.StatusBadRequest)
+
+			_, err = querySnapShots(ctx, list, &SnapShotQuery{Limit: -1}, load)
+			So(errors.As(err, &apiError), ShouldBeTrue)
+			So(apiError.Status(), ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
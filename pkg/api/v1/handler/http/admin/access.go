@@ -0,0 +1,76 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/kenlabs/pando/pkg/access"
+	"github.com/kenlabs/pando/pkg/api/types"
+	"github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/api/v1/handler/http/pando"
+	"io/ioutil"
+	"net/http"
+)
+
+func (a *API) registerAccess() {
+	accessGroup := a.router.Group("/access")
+	{
+		accessGroup.GET("", a.showAccess)
+		accessGroup.POST("", a.updateAccess)
+	}
+}
+
+// showAccess shows the access rules of the collections of the provider,
+// with the granted consumers.
+func (a *API) showAccess(ctx *gin.Context) {
+	providerID, err := decodePeerID(ctx)
+	if err != nil {
+		pando.HandleError(ctx, err)
+		return
+	}
+	if a.core.Access == nil {
+		pando.HandleError(ctx, v1.NewError(errors.New("access control is not enabled"), http.StatusNotFound))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", a.core.Access.Collections(providerID)))
+}
+
+// updateAccess changes the access rule of a collection of the provider on
+// behalf of it, the body is an access.Update.
+func (a *API) updateAccess(ctx *gin.Context) {
+	providerID, err := decodePeerID(ctx)
+	if err != nil {
+		pando.HandleError(ctx, err)
+		return
+	}
+	if a.core.Access == nil {
+		pando.HandleError(ctx, v1.NewError(errors.New("access control is not enabled"), http.StatusNotFound))
+		return
+	}
+
+	bodyBytes, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		logger.Errorf("read access body failed: %v\n", err)
+		pando.HandleError(ctx, v1.NewError(v1.InternalServerError, http.StatusInternalServerError))
+		return
+	}
+	update := &access.Update{}
+	if err = json.Unmarshal(bodyBytes, update); err != nil {
+		pando.HandleError(ctx, v1.NewError(errors.New("invalid access update"), http.StatusBadRequest))
+		return
+	}
+
+	coll, err := a.core.Access.Update(ctx, providerID, update)
+	if err != nil {
+		logger.Errorf("failed to update access of provider %s, err: %v", providerID, err)
+		if errors.Is(err, access.ErrBadUpdate) {
+			pando.HandleError(ctx, v1.NewError(err, http.StatusBadRequest))
+			return
+		}
+		pando.HandleError(ctx, v1.NewError(v1.InternalServerError, http.StatusInternalServerError))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.NewOKResponse("access updated", coll))
+}
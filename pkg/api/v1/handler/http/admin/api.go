@@ -3,6 +3,7 @@ package admin
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/kenlabs/pando/pkg/api/core"
+	"github.com/kenlabs/pando/pkg/api/v1/controller"
 	"github.com/kenlabs/pando/pkg/option"
 	"github.com/kenlabs/pando/pkg/util/log"
 )
@@ -10,21 +11,31 @@ import (
 var logger = log.NewSubsystemLogger()
 
 type API struct {
-	router  *gin.Engine
-	core    *core.Core
-	options *option.DaemonOptions
+	router     *gin.Engine
+	core       *core.Core
+	options    *option.DaemonOptions
+	controller *controller.Controller
 }
 
 func NewV1AdminAPI(router *gin.Engine, core *core.Core, opt *option.DaemonOptions) *API {
 	return &API{
-		router:  router,
-		core:    core,
-		options: opt,
+		router:     router,
+		core:       core,
+		options:    opt,
+		controller: controller.New(core, opt),
 	}
 }
 
 func (a *API) RegisterAPIs() {
+	a.registerAccess()
+	a.registerAuth()
 	a.registerBackup()
+	a.registerHealth()
+	a.registerPolicy()
+	a.registerProvider()
+	a.registerRateLimit()
+	a.registerRegistry()
+	a.registerTask()
 }
 
 //func handleError(ctx *gin.Context, code int, errStr string) {
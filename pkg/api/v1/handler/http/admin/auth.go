@@ -0,0 +1,109 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/kenlabs/pando/pkg/api/types"
+	"github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/api/v1/handler/http/pando"
+	"github.com/kenlabs/pando/pkg/auth"
+	"io/ioutil"
+	"net/http"
+)
+
+func (a *API) registerAuth() {
+	authGroup := a.router.Group("/auth")
+	{
+		authGroup.GET("/keys", a.listAPIKeys)
+		authGroup.POST("/keys", a.createAPIKey)
+		authGroup.POST("/keys/revoke", a.revokeAPIKey)
+	}
+}
+
+// createKeyRequest is the body to create an API key
+type createKeyRequest struct {
+	Name   string
+	Scopes []string
+}
+
+// createdKey is the created API key with its token, which is only shown once
+type createdKey struct {
+	*auth.Key
+	Token string
+}
+
+var errAuthDisabled = v1.NewError(errors.New("authentication is not enabled"), http.StatusNotFound)
+
+func (a *API) listAPIKeys(ctx *gin.Context) {
+	if a.core.Auth == nil {
+		pando.HandleError(ctx, errAuthDisabled)
+		return
+	}
+	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", a.core.Auth.Keys()))
+}
+
+func (a *API) createAPIKey(ctx *gin.Context) {
+	if a.core.Auth == nil {
+		pando.HandleError(ctx, errAuthDisabled)
+		return
+	}
+
+	bodyBytes, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		logger.Errorf("read api key body failed: %v\n", err)
+		pando.HandleError(ctx, v1.NewError(v1.InternalServerError, http.StatusInternalServerError))
+		return
+	}
+	req := &createKeyRequest{}
+	if err = json.Unmarshal(bodyBytes, req); err != nil {
+		pando.HandleError(ctx, v1.NewError(errors.New("invalid api key request"), http.StatusBadRequest))
+		return
+	}
+	scopes := make([]auth.Scope, 0, len(req.Scopes))
+	for _, s := range req.Scopes {
+		scope, err := auth.ParseScope(s)
+		if err != nil {
+			pando.HandleError(ctx, v1.NewError(err, http.StatusBadRequest))
+			return
+		}
+		scopes = append(scopes, scope)
+	}
+
+	key, token, err := a.core.Auth.CreateKey(ctx, req.Name, scopes)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidScope) {
+			pando.HandleError(ctx, v1.NewError(err, http.StatusBadRequest))
+			return
+		}
+		logger.Errorf("failed to create api key, err: %v", err)
+		pando.HandleError(ctx, v1.NewError(v1.InternalServerError, http.StatusInternalServerError))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.NewOKResponse("api key created", createdKey{Key: key, Token: token}))
+}
+
+func (a *API) revokeAPIKey(ctx *gin.Context) {
+	if a.core.Auth == nil {
+		pando.HandleError(ctx, errAuthDisabled)
+		return
+	}
+	id := ctx.Query("id")
+	if id == "" {
+		pando.HandleError(ctx, v1.NewError(errors.New("id is required"), http.StatusBadRequest))
+		return
+	}
+
+	if err := a.core.Auth.RevokeKey(ctx, id); err != nil {
+		if errors.Is(err, auth.ErrKeyNotFound) {
+			pando.HandleError(ctx, v1.NewError(err, http.StatusNotFound))
+			return
+		}
+		logger.Errorf("failed to revoke api key %s, err: %v", id, err)
+		pando.HandleError(ctx, v1.NewError(v1.InternalServerError, http.StatusInternalServerError))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.NewOKResponse("api key revoked", nil))
+}
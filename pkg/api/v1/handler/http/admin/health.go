@@ -0,0 +1,16 @@
+package admin
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kenlabs/pando/pkg/api/v1/handler/http/pando"
+)
+
+func (a *API) registerHealth() {
+	a.router.GET("/health", a.health)
+}
+
+// health checks the components like the readiness of the HTTP API, and shows
+// their details, such as the errors and the backup gateways, to the admins.
+func (a *API) health(ctx *gin.Context) {
+	pando.WriteHealthReport(ctx, a.controller.Readiness(ctx.Request.Context()))
+}
@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/kenlabs/pando/pkg/api/types"
+	"github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/api/v1/handler/http/pando"
+	"github.com/kenlabs/pando/pkg/registry"
+	"io/ioutil"
+	"net/http"
+)
+
+func (a *API) registerPolicy() {
+	policy := a.router.Group("/policy")
+	{
+		policy.GET("", a.showPolicy)
+		policy.POST("", a.updatePolicy)
+		policy.GET("/audit", a.policyAudit)
+	}
+}
+
+func (a *API) showPolicy(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", a.core.Registry.Policy()))
+}
+
+func (a *API) updatePolicy(ctx *gin.Context) {
+	bodyBytes, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		logger.Errorf("read policy update body failed: %v\n", err)
+		pando.HandleError(ctx, v1.NewError(v1.InternalServerError, http.StatusInternalServerError))
+		return
+	}
+
+	update := new(registry.PolicyUpdate)
+	if err = json.Unmarshal(bodyBytes, update); err != nil {
+		pando.HandleError(ctx, v1.NewError(errors.New("invalid policy update"), http.StatusBadRequest))
+		return
+	}
+
+	err = a.core.Registry.UpdatePolicy(ctx, update, ctx.ClientIP())
+	if err != nil {
+		logger.Errorf("failed to update policy, err: %v", err)
+		pando.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.NewOKResponse("policy updated", a.core.Registry.Policy()))
+}
+
+func (a *API) policyAudit(ctx *gin.Context) {
+	records, err := a.core.Registry.PolicyAudit(ctx)
+	if err != nil {
+		logger.Errorf("failed to read policy audit, err: %v", err)
+		pando.HandleError(ctx, v1.NewError(v1.InternalServerError, http.StatusInternalServerError))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", records))
+}
@@ -0,0 +1,149 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/kenlabs/pando/pkg/api/types"
+	"github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/api/v1/handler/http/pando"
+	"github.com/kenlabs/pando/pkg/purge"
+	"github.com/kenlabs/pando/pkg/task"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"net/http"
+)
+
+func (a *API) registerProvider() {
+	provider := a.router.Group("/provider")
+	{
+		provider.POST("/deregister", a.deregisterProvider)
+		provider.POST("/ban", a.banProvider)
+		provider.POST("/unban", a.unbanProvider)
+		provider.GET("/banned", a.bannedProviders)
+		provider.POST("/purge", a.purgeProvider)
+		provider.POST("/quarantine", a.quarantineProvider)
+		provider.POST("/release", a.releaseProvider)
+	}
+}
+
+// deregisterProvider removes the provider from registry, and optionally bans
+// it (ban=1) and purges its data in background (purge=1).
+func (a *API) deregisterProvider(ctx *gin.Context) {
+	providerID, err := decodePeerID(ctx)
+	if err != nil {
+		pando.HandleError(ctx, err)
+		return
+	}
+
+	if ctx.Query("ban") == "1" {
+		if err = a.core.Registry.Ban(ctx, providerID, ctx.Query("reason")); err != nil {
+			logger.Errorf("failed to ban provider %s, err: %v", providerID, err)
+			pando.HandleError(ctx, err)
+			return
+		}
+	}
+
+	if err = a.core.Registry.Deregister(ctx, providerID); err != nil {
+		logger.Errorf("failed to deregister provider %s, err: %v", providerID, err)
+		pando.HandleError(ctx, err)
+		return
+	}
+
+	var t *task.Task
+	if ctx.Query("purge") == "1" {
+		t = a.core.Purger.Submit(providerID, purge.AllOptions)
+	}
+
+	ctx.JSON(http.StatusOK, types.NewOKResponse(fmt.Sprintf("provider %s deregistered", providerID), t))
+}
+
+func (a *API) banProvider(ctx *gin.Context) {
+	peerID, err := decodePeerID(ctx)
+	if err != nil {
+		pando.HandleError(ctx, err)
+		return
+	}
+
+	if err = a.core.Registry.Ban(ctx, peerID, ctx.Query("reason")); err != nil {
+		logger.Errorf("failed to ban peer %s, err: %v", peerID, err)
+		pando.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.NewOKResponse(fmt.Sprintf("peer %s banned", peerID), ""))
+}
+
+func (a *API) unbanProvider(ctx *gin.Context) {
+	peerID, err := decodePeerID(ctx)
+	if err != nil {
+		pando.HandleError(ctx, err)
+		return
+	}
+
+	if err = a.core.Registry.Unban(ctx, peerID); err != nil {
+		logger.Errorf("failed to unban peer %s, err: %v", peerID, err)
+		pando.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.NewOKResponse(fmt.Sprintf("peer %s unbanned", peerID), ""))
+}
+
+func (a *API) bannedProviders(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", a.core.Registry.BannedPeers()))
+}
+
+func (a *API) purgeProvider(ctx *gin.Context) {
+	providerID, err := decodePeerID(ctx)
+	if err != nil {
+		pando.HandleError(ctx, err)
+		return
+	}
+
+	t := a.core.Purger.Submit(providerID, purge.AllOptions)
+	ctx.JSON(http.StatusAccepted, types.NewOKResponse(fmt.Sprintf("purging provider %s", providerID), t))
+}
+
+func (a *API) quarantineProvider(ctx *gin.Context) {
+	providerID, err := decodePeerID(ctx)
+	if err != nil {
+		pando.HandleError(ctx, err)
+		return
+	}
+
+	if err = a.core.Registry.Quarantine(ctx, providerID, ctx.Query("reason")); err != nil {
+		logger.Errorf("failed to quarantine provider %s, err: %v", providerID, err)
+		pando.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.NewOKResponse(fmt.Sprintf("provider %s quarantined", providerID), ""))
+}
+
+func (a *API) releaseProvider(ctx *gin.Context) {
+	providerID, err := decodePeerID(ctx)
+	if err != nil {
+		pando.HandleError(ctx, err)
+		return
+	}
+
+	if err = a.core.Registry.Release(ctx, providerID); err != nil {
+		logger.Errorf("failed to release provider %s, err: %v", providerID, err)
+		pando.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.NewOKResponse(fmt.Sprintf("provider %s released", providerID), ""))
+}
+
+func decodePeerID(ctx *gin.Context) (peer.ID, error) {
+	peerIDStr := ctx.Query("peerid")
+	if peerIDStr == "" {
+		return "", v1.NewError(errors.New("peerid is required"), http.StatusBadRequest)
+	}
+	peerID, err := peer.Decode(peerIDStr)
+	if err != nil {
+		return "", v1.NewError(fmt.Errorf("invalid peerid: %v", err), http.StatusBadRequest)
+	}
+	return peerID, nil
+}
@@ -0,0 +1,68 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/kenlabs/pando/pkg/api/types"
+	"github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/api/v1/handler/http/pando"
+	"github.com/kenlabs/pando/pkg/option"
+	"io/ioutil"
+	"net/http"
+)
+
+func (a *API) registerRateLimit() {
+	rateLimit := a.router.Group("/ratelimit")
+	{
+		rateLimit.GET("/policy", a.showRatePolicy)
+		rateLimit.POST("/policy", a.updateRatePolicy)
+		rateLimit.GET("/state", a.rateLimitState)
+		rateLimit.GET("/consumers", a.consumerUsage)
+	}
+}
+
+func (a *API) showRatePolicy(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", a.core.RateLimiter.RatePolicy()))
+}
+
+// updateRatePolicy replaces the rate policy until the daemon restarts, the
+// peers are assigned to the new tiers on their next requests.
+func (a *API) updateRatePolicy(ctx *gin.Context) {
+	bodyBytes, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		logger.Errorf("read rate policy body failed: %v\n", err)
+		pando.HandleError(ctx, v1.NewError(v1.InternalServerError, http.StatusInternalServerError))
+		return
+	}
+
+	cfg := option.RatePolicy{}
+	if err = json.Unmarshal(bodyBytes, &cfg); err != nil {
+		pando.HandleError(ctx, v1.NewError(errors.New("invalid rate policy"), http.StatusBadRequest))
+		return
+	}
+
+	if err = a.core.RateLimiter.SetRatePolicy(cfg); err != nil {
+		logger.Errorf("failed to update rate policy, err: %v", err)
+		pando.HandleError(ctx, v1.NewError(err, http.StatusBadRequest))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.NewOKResponse("rate policy updated", a.core.RateLimiter.RatePolicy()))
+}
+
+// rateLimitState shows the tokens of the gate rate-limiter and the tiers,
+// tokens and throttling history of the peer rate-limiters.
+func (a *API) rateLimitState(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", a.core.RateLimiter.State()))
+}
+
+// consumerUsage shows the tiers, requests, bytes sent and throttling history
+// of the consumers fetching from Pando.
+func (a *API) consumerUsage(ctx *gin.Context) {
+	if a.core.ConsumerLimiter == nil {
+		pando.HandleError(ctx, v1.NewError(errors.New("consumer rate limit is disabled"), http.StatusNotFound))
+		return
+	}
+	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", a.core.ConsumerLimiter.State()))
+}
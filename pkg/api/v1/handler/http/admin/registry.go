@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/kenlabs/pando/pkg/api/types"
+	"github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/api/v1/handler/http/pando"
+	"github.com/kenlabs/pando/pkg/migration"
+	"io/ioutil"
+	"net/http"
+)
+
+func (a *API) registerRegistry() {
+	registry := a.router.Group("/registry")
+	{
+		registry.GET("/export", a.exportRegistry)
+		registry.POST("/import", a.importRegistry)
+	}
+}
+
+func (a *API) exportRegistry(ctx *gin.Context) {
+	doc, err := a.core.Migrator.Export(ctx)
+	if err != nil {
+		logger.Errorf("failed to export registry, err: %v", err)
+		pando.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", doc))
+}
+
+// importRegistry imports the exported document in body, in the mode of merge
+// (default) or replace.
+func (a *API) importRegistry(ctx *gin.Context) {
+	mode, err := migration.ParseMode(ctx.Query("mode"))
+	if err != nil {
+		pando.HandleError(ctx, v1.NewError(err, http.StatusBadRequest))
+		return
+	}
+
+	bodyBytes, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		logger.Errorf("read import body failed: %v\n", err)
+		pando.HandleError(ctx, v1.NewError(v1.InternalServerError, http.StatusInternalServerError))
+		return
+	}
+	doc := new(migration.Document)
+	if err = json.Unmarshal(bodyBytes, doc); err != nil {
+		pando.HandleError(ctx, v1.NewError(errors.New("invalid migration document"), http.StatusBadRequest))
+		return
+	}
+
+	res, err := a.core.Migrator.Import(ctx, doc, mode, ctx.ClientIP())
+	if errors.Is(err, migration.ErrPartialImport) {
+		logger.Errorf("partially imported registry, err: %v", err)
+		ctx.JSON(http.StatusInternalServerError, &types.ResponseJson{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+			Data:    res,
+		})
+		return
+	}
+	if err != nil {
+		logger.Errorf("failed to import registry, err: %v", err)
+		if errors.Is(err, migration.ErrBadDocument) {
+			err = v1.NewError(err, http.StatusBadRequest)
+		}
+		pando.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.NewOKResponse("registry imported", res))
+}
@@ -0,0 +1,37 @@
+package admin
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/kenlabs/pando/pkg/api/types"
+	"github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/api/v1/handler/http/pando"
+	"net/http"
+)
+
+func (a *API) registerTask() {
+	t := a.router.Group("/task")
+	{
+		t.GET("", a.getTask)
+		t.GET("/list", a.listTasks)
+	}
+}
+
+func (a *API) getTask(ctx *gin.Context) {
+	id := ctx.Query("id")
+	if id == "" {
+		pando.HandleError(ctx, v1.NewError(errors.New("id is required"), http.StatusBadRequest))
+		return
+	}
+	t := a.core.TaskManager.Get(id)
+	if t == nil {
+		pando.HandleError(ctx, v1.NewError(errors.New("task not found"), http.StatusNotFound))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", t))
+}
+
+func (a *API) listTasks(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", a.core.TaskManager.List()))
+}
@@ -4,23 +4,27 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/graphql-go/graphql"
 	"github.com/kenlabs/pando/pkg/api/core"
+	"github.com/kenlabs/pando/pkg/api/v1/controller"
+	"github.com/kenlabs/pando/pkg/option"
 	"github.com/kenlabs/pando/pkg/util/log"
 )
 
 var logger = log.NewSubsystemLogger()
 
 type API struct {
-	router *gin.Engine
-	core   *core.Core
-	schema graphql.Schema
+	router     *gin.Engine
+	core       *core.Core
+	controller *controller.Controller
+	schema     graphql.Schema
 }
 
 type ErrorTemplate map[string]string
 
-func NewV1GraphqlAPI(router *gin.Engine, core *core.Core) *API {
+func NewV1GraphqlAPI(router *gin.Engine, core *core.Core, opt *option.DaemonOptions) *API {
 	return &API{
-		router: router,
-		core:   core,
+		router:     router,
+		core:       core,
+		controller: controller.New(core, opt),
 	}
 }
 
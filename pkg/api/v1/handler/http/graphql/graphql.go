@@ -3,9 +3,14 @@ package graphql
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/graphql-go/graphql"
 	"github.com/ipfs/go-cid"
+	"github.com/kenlabs/pando-store/pkg/statestore/registry"
+	"github.com/kenlabs/pando/pkg/access"
+	"github.com/kenlabs/pando/pkg/api/middleware"
+	"github.com/kenlabs/pando/pkg/api/v1/controller"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"html/template"
 	"io"
@@ -43,7 +48,7 @@ func (a *API) search(ctx *gin.Context) {
 		}
 
 		result = graphql.Do(graphql.Params{
-			Context:        context.Background(),
+			Context:        middleware.ConsumerContext(ctx),
 			Schema:         a.schema,
 			RequestString:  p.Query,
 			VariableValues: p.Variables,
@@ -56,7 +61,7 @@ func (a *API) search(ctx *gin.Context) {
 			return
 		}
 		result = graphql.Do(graphql.Params{
-			Context:       context.Background(),
+			Context:       middleware.ConsumerContext(ctx),
 			Schema:        a.schema,
 			RequestString: ctx.Request.Form.Get("query"),
 		})
@@ -75,12 +80,16 @@ func (a *API) search(ctx *gin.Context) {
 
 func (a *API) NewSchema() error {
 	var err error
+	providerType := a.newProviderType()
 	a.schema, err = graphql.NewSchema(graphql.SchemaConfig{
 		Query: graphql.NewObject(graphql.ObjectConfig{
 			Name: "Query",
 			Fields: graphql.Fields{
-				"State":    a.newStateField(),
-				"SnapShot": a.newSnapshotField(),
+				"State":        a.newStateField(),
+				"SnapShot":     a.newSnapshotField(),
+				"SnapShotDiff": a.newSnapshotDiffField(),
+				"Provider":     a.newProviderField(providerType),
+				"Providers":    a.newProvidersField(providerType),
 			},
 		},
 		),
@@ -111,7 +120,7 @@ func (a *API) newStateField() *graphql.Field {
 			if err != nil {
 				return nil, err
 			}
-			return providerState, nil
+			return a.filterMetaList(p.Context, providerState)
 		},
 	}
 }
@@ -140,3 +149,49 @@ func (a *API) newSnapshotField() *graphql.Field {
 		},
 	}
 }
+
+func (a *API) newSnapshotDiffField() *graphql.Field {
+	return &graphql.Field{
+		Name: "SnapShotDiff",
+		Type: graphql.NewList(SnapShotDiffEntryType),
+		Args: graphql.FieldConfigArgument{
+			"from": &graphql.ArgumentConfig{
+				Type:        graphql.NewNonNull(graphql.String),
+				Description: "height or cid of the snapshot the diff starts after",
+			},
+			"to": &graphql.ArgumentConfig{
+				Type:        graphql.NewNonNull(graphql.String),
+				Description: "height or cid of the snapshot the diff ends at",
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return a.controller.CollectSnapShotDiff(p.Context, &controller.SnapShotDiffQuery{
+				From: p.Args["from"].(string),
+				To:   p.Args["to"].(string),
+			})
+		},
+	}
+}
+
+// filterMetaList hides the metadata of the restricted collections the
+// consumer is not granted to from the state of the provider.
+func (a *API) filterMetaList(ctx context.Context, state *registry.ProviderInfo) (*registry.ProviderInfo, error) {
+	ac := a.core.Access
+	if ac == nil || state == nil || !ac.Restricted(state.PeerID) {
+		return state, nil
+	}
+	consumer := access.ConsumerFrom(ctx)
+	filtered := *state
+	filtered.MetaList = make([]cid.Cid, 0, len(state.MetaList))
+	for _, metaCid := range state.MetaList {
+		err := ac.CheckCid(ctx, metaCid, consumer)
+		if err == nil {
+			filtered.MetaList = append(filtered.MetaList, metaCid)
+			continue
+		}
+		if !errors.Is(err, access.ErrDenied) {
+			return nil, err
+		}
+	}
+	return &filtered, nil
+}
@@ -0,0 +1,168 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"github.com/graphql-go/graphql"
+	"github.com/kenlabs/pando/pkg/registry"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"time"
+)
+
+var StatusTransitionType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "StatusTransition",
+		Fields: graphql.Fields{
+			"From": &graphql.Field{
+				Type:    graphql.String,
+				Resolve: statusTransitionResolver(func(t *registry.StatusTransition) interface{} { return string(t.From) }),
+			},
+			"To": &graphql.Field{
+				Type:    graphql.String,
+				Resolve: statusTransitionResolver(func(t *registry.StatusTransition) interface{} { return string(t.To) }),
+			},
+			"Reason": &graphql.Field{
+				Type:    graphql.String,
+				Resolve: statusTransitionResolver(func(t *registry.StatusTransition) interface{} { return t.Reason }),
+			},
+			"Time": &graphql.Field{
+				Type:    graphql.String,
+				Resolve: statusTransitionResolver(func(t *registry.StatusTransition) interface{} { return t.Time.Format(time.RFC3339) }),
+			},
+		},
+	},
+)
+
+func statusTransitionResolver(get func(t *registry.StatusTransition) interface{}) graphql.FieldResolveFn {
+	return func(params graphql.ResolveParams) (interface{}, error) {
+		t, ok := params.Source.(*registry.StatusTransition)
+		if !ok {
+			return nil, fmt.Errorf(errUnexpectedType, params.Source, "StatusTransition")
+		}
+		return get(t), nil
+	}
+}
+
+func (a *API) newProviderType() *graphql.Object {
+	return graphql.NewObject(
+		graphql.ObjectConfig{
+			Name: "Provider",
+			Fields: graphql.Fields{
+				"PeerID": &graphql.Field{
+					Type:    graphql.String,
+					Resolve: providerResolver(func(info *registry.ProviderInfo) interface{} { return info.AddrInfo.ID.String() }),
+				},
+				"Name": &graphql.Field{
+					Type:    graphql.String,
+					Resolve: providerResolver(func(info *registry.ProviderInfo) interface{} { return info.Name }),
+				},
+				"MultiAddr": &graphql.Field{
+					Type: graphql.NewList(graphql.String),
+					Resolve: providerResolver(func(info *registry.ProviderInfo) interface{} {
+						addrs := make([]string, 0, len(info.AddrInfo.Addrs))
+						for _, addr := range info.AddrInfo.Addrs {
+							addrs = append(addrs, addr.String())
+						}
+						return addrs
+					}),
+				},
+				"MinerAddr": &graphql.Field{
+					Type:    graphql.String,
+					Resolve: providerResolver(func(info *registry.ProviderInfo) interface{} { return info.DiscoveryAddr }),
+				},
+				"AccountLevel": &graphql.Field{
+					Type:    graphql.Int,
+					Resolve: providerResolver(func(info *registry.ProviderInfo) interface{} { return info.AccountLevel }),
+				},
+				"Publisher": &graphql.Field{
+					Type:    graphql.String,
+					Resolve: providerResolver(func(info *registry.ProviderInfo) interface{} { return info.Publisher.String() }),
+				},
+				"LatestMeta": &graphql.Field{
+					Type:    graphql.String,
+					Resolve: providerResolver(func(info *registry.ProviderInfo) interface{} { return info.LatestMeta.String() }),
+				},
+				"LastContactTime": &graphql.Field{
+					Type:    graphql.String,
+					Resolve: providerResolver(func(info *registry.ProviderInfo) interface{} { return info.LastContactTime.Format(time.RFC3339) }),
+				},
+				"Status": &graphql.Field{
+					Type:    graphql.String,
+					Resolve: providerResolver(func(info *registry.ProviderInfo) interface{} { return string(info.Status) }),
+				},
+				"StatusChanged": &graphql.Field{
+					Type:    graphql.String,
+					Resolve: providerResolver(func(info *registry.ProviderInfo) interface{} { return info.StatusChanged.Format(time.RFC3339) }),
+				},
+				"StatusHistory": &graphql.Field{
+					Type: graphql.NewList(StatusTransitionType),
+					Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+						info, ok := params.Source.(*registry.ProviderInfo)
+						if !ok {
+							return nil, fmt.Errorf(errUnexpectedType, params.Source, "Provider.StatusHistory")
+						}
+						return a.core.Registry.StatusHistory(context.Background(), info.AddrInfo.ID)
+					},
+				},
+			},
+		},
+	)
+}
+
+func providerResolver(get func(info *registry.ProviderInfo) interface{}) graphql.FieldResolveFn {
+	return func(params graphql.ResolveParams) (interface{}, error) {
+		info, ok := params.Source.(*registry.ProviderInfo)
+		if !ok {
+			return nil, fmt.Errorf(errUnexpectedType, params.Source, "Provider")
+		}
+		return get(info), nil
+	}
+}
+
+func (a *API) newProviderField(providerType *graphql.Object) *graphql.Field {
+	return &graphql.Field{
+		Name: "Provider",
+		Type: providerType,
+		Args: graphql.FieldConfigArgument{
+			"PeerID": &graphql.ArgumentConfig{
+				Type:        graphql.NewNonNull(graphql.String),
+				Description: "account id of a provider",
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			peerID, err := peer.Decode(p.Args["PeerID"].(string))
+			if err != nil {
+				return nil, err
+			}
+			info := a.core.Registry.ProviderInfo(peerID)
+			if info == nil {
+				return nil, fmt.Errorf("provider not found: %s", peerID)
+			}
+			return info[0], nil
+		},
+	}
+}
+
+func (a *API) newProvidersField(providerType *graphql.Object) *graphql.Field {
+	return &graphql.Field{
+		Name: "Providers",
+		Type: graphql.NewList(providerType),
+		Args: graphql.FieldConfigArgument{
+			"Status": &graphql.ArgumentConfig{
+				Type:        graphql.String,
+				Description: "only return the providers in the status",
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			statusStr, ok := p.Args["Status"].(string)
+			if !ok || statusStr == "" {
+				return a.core.Registry.AllProviderInfo(), nil
+			}
+			status, err := registry.ParseStatus(statusStr)
+			if err != nil {
+				return nil, err
+			}
+			return a.core.Registry.ProvidersByStatus(status), nil
+		},
+	}
+}
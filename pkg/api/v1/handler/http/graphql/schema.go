@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/kenlabs/pando-store/pkg/statestore/registry"
 	"github.com/kenlabs/pando-store/pkg/types/cbortypes"
+	"github.com/kenlabs/pando/pkg/api/v1/controller"
 	"strings"
 
 	"github.com/graphql-go/graphql"
@@ -125,3 +126,49 @@ func SnapshotUpdateResolver(params graphql.ResolveParams) (interface{}, error) {
 		return nil, fmt.Errorf(errUnexpectedType, params.Source, "SnapShot.Update")
 	}
 }
+
+var SnapShotDiffEntryType = graphql.NewObject(
+	graphql.ObjectConfig{
+		Name: "SnapShotDiffEntry",
+		Fields: graphql.Fields{
+			"Type": &graphql.Field{
+				Type:    graphql.String,
+				Resolve: snapshotDiffEntryResolver(func(e *controller.SnapShotDiffEntry) interface{} { return e.Type }),
+			},
+			"Provider": &graphql.Field{
+				Type:    graphql.String,
+				Resolve: snapshotDiffEntryResolver(func(e *controller.SnapShotDiffEntry) interface{} { return e.Provider }),
+			},
+			"Height": &graphql.Field{
+				Type:    graphql.Int,
+				Resolve: snapshotDiffEntryResolver(func(e *controller.SnapShotDiffEntry) interface{} { return e.Height }),
+			},
+			"SnapShot": &graphql.Field{
+				Type:    graphql.String,
+				Resolve: snapshotDiffEntryResolver(func(e *controller.SnapShotDiffEntry) interface{} { return e.SnapShot }),
+			},
+			"Metadata": &graphql.Field{
+				Type:    graphql.NewList(graphql.String),
+				Resolve: snapshotDiffEntryResolver(func(e *controller.SnapShotDiffEntry) interface{} { return e.Metadata }),
+			},
+			"PreviousHead": &graphql.Field{
+				Type:    graphql.String,
+				Resolve: snapshotDiffEntryResolver(func(e *controller.SnapShotDiffEntry) interface{} { return e.PreviousHead }),
+			},
+			"Head": &graphql.Field{
+				Type:    graphql.String,
+				Resolve: snapshotDiffEntryResolver(func(e *controller.SnapShotDiffEntry) interface{} { return e.Head }),
+			},
+		},
+	},
+)
+
+func snapshotDiffEntryResolver(get func(e *controller.SnapShotDiffEntry) interface{}) graphql.FieldResolveFn {
+	return func(params graphql.ResolveParams) (interface{}, error) {
+		e, ok := params.Source.(*controller.SnapShotDiffEntry)
+		if !ok {
+			return nil, fmt.Errorf(errUnexpectedType, params.Source, "SnapShotDiffEntry")
+		}
+		return get(e), nil
+	}
+}
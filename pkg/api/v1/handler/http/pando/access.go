@@ -0,0 +1,54 @@
+package pando
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/kenlabs/pando/pkg/api/types"
+	"github.com/kenlabs/pando/pkg/api/v1"
+	"io/ioutil"
+	"net/http"
+)
+
+// collectionAccess is the public view of the access rule of a collection,
+// the granted consumers are not listed.
+type collectionAccess struct {
+	Name       string
+	Restricted bool
+}
+
+func (a *API) providerAccess(ctx *gin.Context) {
+	bodyBytes, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		logger.Errorf("read access body failed: %v\n", err)
+		HandleError(ctx, v1.NewError(v1.InternalServerError, http.StatusInternalServerError))
+		return
+	}
+
+	coll, err := a.controller.ProviderAccess(ctx, bodyBytes)
+	if err != nil {
+		HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.NewOKResponse("access updated", coll))
+}
+
+func (a *API) listProviderAccess(ctx *gin.Context) {
+	peerid, err := decodePeerid(ctx)
+	if err != nil {
+		HandleError(ctx, v1.NewError(errors.New("invalid peerid"), http.StatusBadRequest))
+		return
+	}
+
+	collections, err := a.controller.ProviderCollections(peerid)
+	if err != nil {
+		HandleError(ctx, err)
+		return
+	}
+	res := make([]collectionAccess, 0, len(collections))
+	for _, coll := range collections {
+		res = append(res, collectionAccess{Name: coll.Name, Restricted: coll.Restricted})
+	}
+
+	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", res))
+}
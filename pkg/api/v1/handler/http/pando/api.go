@@ -30,6 +30,7 @@ func NewV1HttpAPI(router *gin.Engine, core *core.Core, opt *option.DaemonOptions
 }
 
 func (a *API) RegisterAPIs() {
+	a.registerCar()
 	a.registerMetadata()
 	a.registerProvider()
 	a.registerPando()
@@ -43,6 +44,11 @@ func HandleError(ctx *gin.Context, err error) {
 		ctx.AbortWithStatusJSON(apiErr.Status(), types.NewErrorResponse(apiErr.Status(), apiErr.Error()))
 		return
 	}
+	// errors from other subsystems, like the registry, may carry a status too
+	var statusErr interface{ Status() int }
+	if errors.As(err, &statusErr) && statusErr.Status() != 0 {
+		code = statusErr.Status()
+	}
 
 	ctx.AbortWithStatusJSON(code, types.NewErrorResponse(code, err.Error()))
 }
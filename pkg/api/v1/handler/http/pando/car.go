@@ -0,0 +1,92 @@
+package pando
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/kenlabs/pando/pkg/api/middleware"
+	v1 "github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/api/v1/controller"
+	"github.com/kenlabs/pando/pkg/auth"
+	"github.com/kenlabs/pando/pkg/metrics"
+	"net/http"
+	"strconv"
+)
+
+// carContentType is the CARv1 media type of the trustless gateway
+const carContentType = mimeCAR + "; version=1"
+
+// carMaxAge is the seconds the public CARs are cached for.  The CARs are not
+// immutable, as a collection restricted later leaves their blocks out, and a
+// CAR failed midway is truncated.
+const carMaxAge = 300
+
+func (a *API) registerCar() {
+	a.router.GET("/ipfs/:cid", a.car)
+}
+
+// car serves the DAG of the root cid as a CAR in the way of the trustless
+// gateway, selected by dag-scope and the depth and stop of Pando.
+func (a *API) car(ctx *gin.Context) {
+	record := metrics.APITimer(context.Background(), metrics.GetCarLatency)
+	defer record()
+
+	switch ctx.Query("format") {
+	case "":
+		if ctx.NegotiateFormat(mimeCAR) == "" {
+			HandleError(ctx, v1.NewError(errors.New("unsupported accept, should be "+mimeCAR), http.StatusNotAcceptable))
+			return
+		}
+	case "car":
+	default:
+		HandleError(ctx, v1.NewError(errors.New("unsupported format, should be car"), http.StatusBadRequest))
+		return
+	}
+
+	q := &controller.CarQuery{
+		Root:  ctx.Param("cid"),
+		Scope: ctx.Query("dag-scope"),
+		Stop:  ctx.Query("stop"),
+	}
+	if depth := ctx.Query("depth"); depth != "" {
+		n, err := strconv.Atoi(depth)
+		if err != nil {
+			HandleError(ctx, v1.NewError(errors.New("invalid depth"), http.StatusBadRequest))
+			return
+		}
+		q.Depth = n
+	}
+
+	consumerCtx := middleware.ConsumerContext(ctx)
+	carFile, err := a.controller.PrepareCar(consumerCtx, q)
+	if err != nil {
+		HandleError(ctx, err)
+		return
+	}
+
+	root := carFile.Root.String()
+	ctx.Header("Content-Type", carContentType)
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.car\"", root))
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	ctx.Header("X-Ipfs-Path", ctx.Request.URL.Path)
+	ctx.Header("X-Ipfs-Roots", root)
+	ctx.Header("Accept-Ranges", "none")
+	// The DAG of a cid never changes, but the selected part does
+	ctx.Header("Etag", fmt.Sprintf("\"%s.car.%s.%d.%s\"", root, q.Scope, q.Depth, q.Stop))
+	if carFile.Public {
+		ctx.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", carMaxAge))
+	} else {
+		// The blocks of the restricted collections depend on the consumer and
+		// on the access rules, which may change
+		ctx.Header("Cache-Control", "private, no-cache")
+		ctx.Header("Vary", "Authorization, "+middleware.APIKeyHeader+", "+auth.SignatureHeader)
+	}
+	ctx.Status(http.StatusOK)
+
+	if err = a.controller.WriteCar(consumerCtx, carFile, ctx.Writer); err != nil {
+		// The response is already started, the client sees a truncated CAR
+		logger.Errorf("failed to write car of %s: %v", root, err)
+		ctx.Abort()
+	}
+}
@@ -0,0 +1,106 @@
+package pando
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/ipfs/go-cid"
+	"github.com/kenlabs/pando/pkg/api/types"
+	"github.com/kenlabs/pando/pkg/api/v1/controller"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCar(t *testing.T) {
+	Convey("TestCar", t, func() {
+		responseRecorder := httptest.NewRecorder()
+		testContext, _ := gin.CreateTestContext(responseRecorder)
+		testCidStr := "baguqeeqqw34gtnf4q6jtz5bgfyjnmf3jzi"
+		testCid, err := cid.Decode(testCidStr)
+		if err != nil {
+			t.Error(err)
+		}
+
+		Convey("Given the car format, should stream the car with the gateway headers", func() {
+			testContext.Request = httptest.NewRequest(http.MethodGet, "/ipfs/"+testCidStr+"?format=car&depth=2", nil)
+			testContext.Params = gin.Params{{Key: "cid", Value: testCidStr}}
+			testCar := []byte("car")
+			patchPrepare := gomonkey.ApplyMethodFunc(reflect.TypeOf(mockAPI.controller),
+				"PrepareCar",
+				func(_ context.Context, q *controller.CarQuery) (*controller.Car, error) {
+					So(q.Root, ShouldEqual, testCidStr)
+					So(q.Depth, ShouldEqual, 2)
+					return &controller.Car{Root: testCid, Public: true}, nil
+				},
+			)
+			defer patchPrepare.Reset()
+			patchWrite := gomonkey.ApplyMethodFunc(reflect.TypeOf(mockAPI.controller),
+				"WriteCar",
+				func(_ context.Context, _ *controller.Car, w io.Writer) error {
+					_, err := w.Write(testCar)
+					return err
+				},
+			)
+			defer patchWrite.Reset()
+			mockAPI.car(testContext)
+
+			resp := responseRecorder.Result()
+			respBody, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Error(err)
+			}
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header.Get("Content-Type"), ShouldEqual, carContentType)
+			So(resp.Header.Get("X-Ipfs-Roots"), ShouldEqual, testCidStr)
+			So(resp.Header.Get("Cache-Control"), ShouldEqual, "public, max-age=300")
+			So(respBody, ShouldResemble, testCar)
+		})
+
+		Convey("Given restricted collections, should not let the shared caches keep the car", func() {
+			testContext.Request = httptest.NewRequest(http.MethodGet, "/ipfs/"+testCidStr+"?format=car", nil)
+			testContext.Params = gin.Params{{Key: "cid", Value: testCidStr}}
+			patchPrepare := gomonkey.ApplyMethodFunc(reflect.TypeOf(mockAPI.controller),
+				"PrepareCar",
+				func(_ context.Context, _ *controller.CarQuery) (*controller.Car, error) {
+					return &controller.Car{Root: testCid}, nil
+				},
+			)
+			defer patchPrepare.Reset()
+			patchWrite := gomonkey.ApplyMethodFunc(reflect.TypeOf(mockAPI.controller),
+				"WriteCar",
+				func(_ context.Context, _ *controller.Car, _ io.Writer) error {
+					return nil
+				},
+			)
+			defer patchWrite.Reset()
+			mockAPI.car(testContext)
+
+			resp := responseRecorder.Result()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header.Get("Cache-Control"), ShouldEqual, "private, no-cache")
+			So(resp.Header.Get("Vary"), ShouldContainSubstring, "X-API-Key")
+		})
+
+		Convey("Given an unsupported accept, should return not acceptable", func() {
+			testContext.Request = httptest.NewRequest(http.MethodGet, "/ipfs/"+testCidStr, nil)
+			testContext.Request.Header.Set("Accept", "text/html")
+			mockAPI.car(testContext)
+
+			var resp types.ResponseJson
+			respBody, err := ioutil.ReadAll(responseRecorder.Result().Body)
+			if err != nil {
+				t.Error(err)
+			}
+			if err = json.Unmarshal(respBody, &resp); err != nil {
+				t.Error(err)
+			}
+			So(resp.Code, ShouldEqual, http.StatusNotAcceptable)
+		})
+	})
+}
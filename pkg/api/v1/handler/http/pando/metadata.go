@@ -3,14 +3,22 @@ package pando
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
+	"github.com/kenlabs/pando/pkg/api/middleware"
 	"github.com/kenlabs/pando/pkg/api/types"
 	v1 "github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/api/v1/controller"
+	"github.com/kenlabs/pando/pkg/metacache"
 	"github.com/kenlabs/pando/pkg/metrics"
-	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func (a *API) registerMetadata() {
@@ -18,15 +26,94 @@ func (a *API) registerMetadata() {
 	{
 		metadata.GET("/list", a.snapShotList)
 		metadata.GET("/snapshot", a.metadataSnapshot)
+		metadata.GET("/snapshot/diff", a.snapShotDiff)
+		metadata.GET("/events", a.metadataEvents)
 		metadata.GET("/inclusion", a.metaInclusion)
 		metadata.POST("/query", a.metadataQuery)
+		metadata.GET("/:cid", a.metadataBlock)
 	}
 }
 
+// The media types of the metadata block encodings
+const (
+	mimeDagJSON = "application/vnd.ipld.dag-json"
+	mimeDagCBOR = "application/vnd.ipld.dag-cbor"
+	mimeCAR     = "application/vnd.ipld.car"
+)
+
+var metadataFormats = map[string]controller.MetadataFormat{
+	mimeDagJSON: controller.MetadataDagJSON,
+	mimeDagCBOR: controller.MetadataDagCBOR,
+	mimeCAR:     controller.MetadataCAR,
+}
+
+// metadataBlock returns the decoded metadata of the cid as JSON, or the raw
+// block in dag-json, dag-cbor or a CAR as negotiated by Accept, whose
+// signature verification status is set in the headers.
+func (a *API) metadataBlock(ctx *gin.Context) {
+	record := metrics.APITimer(context.Background(), metrics.GetMetadataBlockLatency)
+	defer record()
+
+	mime := ctx.NegotiateFormat(gin.MIMEJSON, mimeDagJSON, mimeDagCBOR, mimeCAR)
+	if mime == "" {
+		HandleError(ctx, v1.NewError(errors.New("unsupported accept, should be one of "+
+			gin.MIMEJSON+", "+mimeDagJSON+", "+mimeDagCBOR+" or "+mimeCAR), http.StatusNotAcceptable))
+		return
+	}
+
+	consumerCtx := middleware.ConsumerContext(ctx)
+	if mime == gin.MIMEJSON {
+		meta, err := a.controller.Metadata(consumerCtx, ctx.Param("cid"))
+		if err != nil {
+			logger.Error(fmt.Sprintf("get metadata failed: %v", err))
+			HandleError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, types.NewOKResponse("OK", meta))
+		return
+	}
+
+	data, verification, err := a.controller.MetadataBlock(consumerCtx, ctx.Param("cid"), metadataFormats[mime])
+	if err != nil {
+		logger.Error(fmt.Sprintf("get metadata block failed: %v", err))
+		HandleError(ctx, err)
+		return
+	}
+	ctx.Header(metadataVerifiedHeader, strconv.FormatBool(verification.Verified))
+	if verification.Signer != "" {
+		ctx.Header(metadataSignerHeader, verification.Signer)
+	}
+	ctx.Data(http.StatusOK, mime, data)
+}
+
+// The headers of the signature verification status of the raw metadata blocks
+const (
+	metadataVerifiedHeader = "X-Pando-Signature-Verified"
+	metadataSignerHeader   = "X-Pando-Signer"
+)
+
 func (a *API) snapShotList(ctx *gin.Context) {
 	record := metrics.APITimer(context.Background(), metrics.GetMetadataListLatency)
 	defer record()
 
+	// The snapshots are queried if any query parameter is given, otherwise
+	// the whole list is returned as before.
+	if hasSnapShotQuery(ctx) {
+		q, err := decodeSnapShotQuery(ctx)
+		if err != nil {
+			HandleError(ctx, v1.NewError(err, http.StatusBadRequest))
+			return
+		}
+		page, err := a.controller.QuerySnapShots(ctx, q)
+		if err != nil {
+			logger.Error(fmt.Sprintf("snapShotList query snapshots failed: %v", err))
+			HandleError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, types.NewOKResponse("OK", page))
+		return
+	}
+
 	snapCidList, err := a.controller.SnapShotList()
 	if err != nil {
 		logger.Error(fmt.Sprintf("snapShotList metadataSnapshot failed: %v", err))
@@ -36,6 +123,73 @@ func (a *API) snapShotList(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", snapCidList))
 }
 
+var snapShotQueryParams = []string{"from", "to", "since", "until", "provider", "compact", "limit", "cursor"}
+
+func hasSnapShotQuery(ctx *gin.Context) bool {
+	for _, param := range snapShotQueryParams {
+		if _, ok := ctx.GetQuery(param); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeSnapShotQuery(ctx *gin.Context) (*controller.SnapShotQuery, error) {
+	q := &controller.SnapShotQuery{
+		Cursor: ctx.Query("cursor"),
+	}
+	if from := ctx.Query("from"); from != "" {
+		height, err := strconv.ParseUint(from, 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid from height")
+		}
+		q.FromHeight = &height
+	}
+	if to := ctx.Query("to"); to != "" {
+		height, err := strconv.ParseUint(to, 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid to height")
+		}
+		q.ToHeight = &height
+	}
+	if since := ctx.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return nil, errors.New("invalid since, should be in RFC3339")
+		}
+		q.Since = &t
+	}
+	if until := ctx.Query("until"); until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return nil, errors.New("invalid until, should be in RFC3339")
+		}
+		q.Until = &t
+	}
+	if provider := ctx.Query("provider"); provider != "" {
+		providerID, err := peer.Decode(provider)
+		if err != nil {
+			return nil, errors.New("invalid provider")
+		}
+		q.Provider = providerID
+	}
+	if compact := ctx.Query("compact"); compact != "" {
+		isCompact, err := strconv.ParseBool(compact)
+		if err != nil {
+			return nil, errors.New("invalid compact")
+		}
+		q.Compact = isCompact
+	}
+	if limit := ctx.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, errors.New("invalid limit")
+		}
+		q.Limit = n
+	}
+	return q, nil
+}
+
 func (a *API) metadataSnapshot(ctx *gin.Context) {
 	record := metrics.APITimer(context.Background(), metrics.GetMetadataSnapshotLatency)
 	defer record()
@@ -53,13 +207,124 @@ func (a *API) metadataSnapshot(ctx *gin.Context) {
 
 }
 
+// mimeNDJSON is the media type of the streams of JSON entries
+const mimeNDJSON = "application/x-ndjson"
+
+// snapShotDiff streams the diff of the snapshots after the from snapshot up to
+// the to snapshot as newline delimited JSON entries.
+func (a *API) snapShotDiff(ctx *gin.Context) {
+	record := metrics.APITimer(context.Background(), metrics.GetMetadataSnapshotDiffLatency)
+	defer record()
+
+	consumerCtx := middleware.ConsumerContext(ctx)
+	diff, err := a.controller.PrepareSnapShotDiff(consumerCtx, &controller.SnapShotDiffQuery{
+		From: ctx.Query("from"),
+		To:   ctx.Query("to"),
+	})
+	if err != nil {
+		HandleError(ctx, err)
+		return
+	}
+
+	ctx.Header("Content-Type", mimeNDJSON)
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	encoder := json.NewEncoder(ctx.Writer)
+	err = a.controller.WalkSnapShotDiff(consumerCtx, diff, func(entry *controller.SnapShotDiffEntry) error {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+		ctx.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		// The response is already started, the error ends the stream
+		logger.Errorf("failed to walk snapshot diff from %d to %d: %v", diff.FromHeight, diff.ToHeight, err)
+		_ = encoder.Encode(&controller.SnapShotDiffEntry{
+			Type:  controller.SnapShotDiffError,
+			Error: v1.InternalServerError.Error(),
+		})
+		ctx.Abort()
+	}
+}
+
+// metadataEvents streams the ingestion events as server-sent events, filtered
+// by the provider in peerid and the collection.  The id of an event is its
+// cursor, the stream resumes after the cursor in cursor or Last-Event-ID.
+func (a *API) metadataEvents(ctx *gin.Context) {
+	peerid, err := decodePeerid(ctx)
+	if err != nil {
+		HandleError(ctx, v1.NewError(errors.New("invalid peerid"), http.StatusBadRequest))
+		return
+	}
+	q := &controller.IngestEventQuery{
+		Provider:   peerid,
+		Collection: ctx.Query("collection"),
+		Cursor:     ctx.Query("cursor"),
+		FromStart:  ctx.Query("from") == "start",
+	}
+	if q.Cursor == "" {
+		q.Cursor = ctx.GetHeader("Last-Event-ID")
+	}
+
+	followCtx, cancel := context.WithCancel(middleware.ConsumerContext(ctx))
+	defer cancel()
+	follow, err := a.controller.PrepareIngestEvents(followCtx, q)
+	if err != nil {
+		HandleError(ctx, err)
+		return
+	}
+
+	// The events are followed in another goroutine, and written with the
+	// keep-alive comments in the stream.
+	events := make(chan *controller.IngestEvent)
+	go func() {
+		defer close(events)
+		err := a.controller.FollowIngestEvents(followCtx, follow, func(e *controller.IngestEvent) error {
+			select {
+			case events <- e:
+				return nil
+			case <-followCtx.Done():
+				return followCtx.Err()
+			}
+		})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			logger.Errorf("failed to follow ingest events: %v", err)
+		}
+	}()
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return false
+			}
+			ctx.Render(-1, sse.Event{
+				Id:    e.Cursor,
+				Event: string(e.Type),
+				Data:  e,
+			})
+		case <-keepAlive.C:
+			_, _ = io.WriteString(w, ": keep-alive\n\n")
+		case <-ctx.Request.Context().Done():
+			return false
+		}
+		return true
+	})
+}
+
 func (a *API) metaInclusion(ctx *gin.Context) {
 	record := metrics.APITimer(context.Background(), metrics.GetMetadataInclusionLatency)
 	defer record()
 
 	cidQuery := ctx.Query("cid")
 
-	inclusion, err := a.controller.MetaInclusion(ctx, cidQuery)
+	inclusion, err := a.controller.MetaInclusion(middleware.ConsumerContext(ctx), cidQuery)
 	if err != nil {
 		logger.Error(fmt.Sprintf("get metaInclusion failed: %v", err))
 		HandleError(ctx, err)
@@ -69,9 +334,10 @@ func (a *API) metaInclusion(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, types.NewOKResponse("metaInclusion found", inclusion))
 }
 
+// MetadataQueryRequestBody is a query on the metadata cache of the provider
 type MetadataQueryRequestBody struct {
-	ProviderID string `json:"ProviderID"`
-	BsonQuery  string `json:"BsonQuery"`
+	ProviderID string           `json:"ProviderID"`
+	Query      *metacache.Query `json:"Query"`
 }
 
 func (a *API) metadataQuery(ctx *gin.Context) {
@@ -85,16 +351,17 @@ func (a *API) metadataQuery(ctx *gin.Context) {
 		return
 	}
 
-	var queryBody *MetadataQueryRequestBody
+	var queryBody MetadataQueryRequestBody
 	if err = json.Unmarshal(bodyBytes, &queryBody); err != nil {
-		if err == bsonrw.ErrInvalidJSON {
-			HandleError(ctx, v1.NewError(v1.InvalidQuery, http.StatusBadRequest))
-			return
-		}
-		HandleError(ctx, v1.NewError(v1.InternalServerError, http.StatusInternalServerError))
+		HandleError(ctx, v1.NewError(v1.InvalidQuery, http.StatusBadRequest))
 		return
 	}
 
-	results, err := a.controller.MetadataQuery(context.Background(), queryBody.ProviderID, queryBody.BsonQuery)
+	results, err := a.controller.MetadataQuery(middleware.ConsumerContext(ctx), queryBody.ProviderID, queryBody.Query)
+	if err != nil {
+		logger.Errorf("metadata query failed: %v", err)
+		HandleError(ctx, err)
+		return
+	}
 	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", results))
 }
@@ -10,6 +10,10 @@ import (
 	"github.com/kenlabs/pando-store/pkg/types/cbortypes"
 	"github.com/kenlabs/pando/pkg/api/core"
 	"github.com/kenlabs/pando/pkg/api/types"
+	"github.com/kenlabs/pando/pkg/api/v1/controller"
+	"github.com/kenlabs/pando/pkg/api/v1/model"
+	"github.com/kenlabs/pando/pkg/ingest"
+	"github.com/kenlabs/pando/pkg/metacache"
 	"github.com/kenlabs/pando/pkg/util/cids"
 	"github.com/kenlabs/pando/test/mock"
 	. "github.com/smartystreets/goconvey/convey"
@@ -19,6 +23,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -193,6 +198,202 @@ func TestMetadataSnapshot(t *testing.T) {
 	})
 }
 
+func TestMetadataBlock(t *testing.T) {
+	Convey("TestMetadataBlock", t, func() {
+		responseRecorder := httptest.NewRecorder()
+		testContext, _ := gin.CreateTestContext(responseRecorder)
+		testContext.Request = httptest.NewRequest(http.MethodGet, "/metadata/cid", nil)
+
+		Convey("Given a car accept, should return the car with the verification headers", func() {
+			testCar := []byte("car")
+			patch := gomonkey.ApplyMethodFunc(reflect.TypeOf(mockAPI.controller),
+				"MetadataBlock",
+				func(_ context.Context, _ string, format controller.MetadataFormat) ([]byte, *model.MetadataVerification, error) {
+					So(format, ShouldEqual, controller.MetadataCAR)
+					return testCar, &model.MetadataVerification{
+						Verified: true,
+						Signer:   "12D3KooWSS3sEujyAXB9SWUvVtQZmxH6vTi9NitqaaRQoUjeEk3M",
+					}, nil
+				},
+			)
+			defer patch.Reset()
+			testContext.Request.Header.Set("Accept", mimeCAR)
+			mockAPI.metadataBlock(testContext)
+
+			resp := responseRecorder.Result()
+			respBody, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Error(err)
+			}
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header.Get("Content-Type"), ShouldEqual, mimeCAR)
+			So(resp.Header.Get(metadataVerifiedHeader), ShouldEqual, "true")
+			So(resp.Header.Get(metadataSignerHeader), ShouldEqual, "12D3KooWSS3sEujyAXB9SWUvVtQZmxH6vTi9NitqaaRQoUjeEk3M")
+			So(respBody, ShouldResemble, testCar)
+		})
+
+		Convey("Given an unsupported accept, should return not acceptable", func() {
+			testContext.Request.Header.Set("Accept", "text/html")
+			mockAPI.metadataBlock(testContext)
+
+			var resp types.ResponseJson
+			respBody, err := ioutil.ReadAll(responseRecorder.Result().Body)
+			if err != nil {
+				t.Error(err)
+			}
+			if err = json.Unmarshal(respBody, &resp); err != nil {
+				t.Error(err)
+			}
+			So(resp.Code, ShouldEqual, http.StatusNotAcceptable)
+		})
+	})
+}
+
+func TestSnapShotDiff(t *testing.T) {
+	Convey("TestSnapShotDiff", t, func() {
+		responseRecorder := httptest.NewRecorder()
+		testContext, _ := gin.CreateTestContext(responseRecorder)
+		testContext.Request = httptest.NewRequest(http.MethodGet, "/metadata/snapshot/diff?from=1&to=3", nil)
+
+		Convey("Given a valid range, should stream the entries as ndjson", func() {
+			patch := gomonkey.ApplyMethodFunc(reflect.TypeOf(mockAPI.controller),
+				"PrepareSnapShotDiff",
+				func(_ context.Context, q *controller.SnapShotDiffQuery) (*controller.SnapShotDiff, error) {
+					So(q.From, ShouldEqual, "1")
+					So(q.To, ShouldEqual, "3")
+					return &controller.SnapShotDiff{FromHeight: 1, ToHeight: 3}, nil
+				},
+			)
+			defer patch.Reset()
+			patch.ApplyMethodFunc(reflect.TypeOf(mockAPI.controller),
+				"WalkSnapShotDiff",
+				func(_ context.Context, _ *controller.SnapShotDiff, emit func(*controller.SnapShotDiffEntry) error) error {
+					if err := emit(&controller.SnapShotDiffEntry{Type: controller.SnapShotDiffMetadata, Height: 3}); err != nil {
+						return err
+					}
+					return emit(&controller.SnapShotDiffEntry{Type: controller.SnapShotDiffAppeared, Provider: "provider"})
+				},
+			)
+			mockAPI.snapShotDiff(testContext)
+
+			resp := responseRecorder.Result()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(resp.Header.Get("Content-Type"), ShouldEqual, mimeNDJSON)
+			decoder := json.NewDecoder(resp.Body)
+			var entries []controller.SnapShotDiffEntry
+			for decoder.More() {
+				var entry controller.SnapShotDiffEntry
+				So(decoder.Decode(&entry), ShouldBeNil)
+				entries = append(entries, entry)
+			}
+			So(entries, ShouldHaveLength, 2)
+			So(entries[0].Height, ShouldEqual, 3)
+			So(entries[1].Type, ShouldEqual, controller.SnapShotDiffAppeared)
+		})
+
+		Convey("Given a missing range, should return bad request", func() {
+			testContext.Request = httptest.NewRequest(http.MethodGet, "/metadata/snapshot/diff?from=1", nil)
+			mockAPI.snapShotDiff(testContext)
+			So(responseRecorder.Result().StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
+
+// streamRecorder is a ResponseRecorder for the streams of gin, which need the
+// CloseNotifier
+type streamRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (r *streamRecorder) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+func TestMetadataEvents(t *testing.T) {
+	Convey("TestMetadataEvents", t, func() {
+		responseRecorder := httptest.NewRecorder()
+		testContext, _ := gin.CreateTestContext(&streamRecorder{responseRecorder})
+		testContext.Request = httptest.NewRequest(http.MethodGet, "/metadata/events?collection=c1", nil)
+		testContext.Request.Header.Set("Last-Event-ID", "Mg")
+
+		Convey("Given a valid query, should stream the events with their cursors", func() {
+			patch := gomonkey.ApplyMethodFunc(reflect.TypeOf(mockAPI.controller),
+				"PrepareIngestEvents",
+				func(_ context.Context, q *controller.IngestEventQuery) (*controller.IngestFollow, error) {
+					So(q.Collection, ShouldEqual, "c1")
+					So(q.Cursor, ShouldEqual, "Mg")
+					return &controller.IngestFollow{}, nil
+				},
+			)
+			defer patch.Reset()
+			patch.ApplyMethodFunc(reflect.TypeOf(mockAPI.controller),
+				"FollowIngestEvents",
+				func(_ context.Context, _ *controller.IngestFollow, deliver func(*controller.IngestEvent) error) error {
+					return deliver(&controller.IngestEvent{
+						Cursor: "Mw",
+						Event:  &ingest.Event{Seq: 3, Type: ingest.EventIngested, Collection: "c1"},
+					})
+				},
+			)
+			mockAPI.metadataEvents(testContext)
+
+			resp := responseRecorder.Result()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			body, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(string(body), ShouldContainSubstring, "id:Mw\n")
+			So(string(body), ShouldContainSubstring, "event:ingested\n")
+		})
+
+		Convey("Given an invalid peerid, should return bad request", func() {
+			testContext.Request = httptest.NewRequest(http.MethodGet, "/metadata/events?peerid=invalid", nil)
+			mockAPI.metadataEvents(testContext)
+			So(responseRecorder.Result().StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("Given no ingest log, should return not found", func() {
+			mockAPI.metadataEvents(testContext)
+			So(responseRecorder.Result().StatusCode, ShouldEqual, http.StatusNotFound)
+		})
+	})
+}
+
+func TestMetadataQuery(t *testing.T) {
+	Convey("TestMetadataQuery", t, func() {
+		responseRecorder := httptest.NewRecorder()
+		testContext, _ := gin.CreateTestContext(responseRecorder)
+
+		Convey("Given a query, should return the results", func() {
+			testContext.Request = httptest.NewRequest(http.MethodPost, "/metadata/query", strings.NewReader(
+				`{"ProviderID": "provider", "Query": {"Collection": "deals", "Filter": {"Field": "size", "Op": "gt", "Value": 1}}}`))
+			patch := gomonkey.ApplyMethodFunc(reflect.TypeOf(mockAPI.controller),
+				"MetadataQuery",
+				func(_ context.Context, providerID string, q *metacache.Query) ([]map[string]interface{}, error) {
+					So(providerID, ShouldEqual, "provider")
+					So(q.Collection, ShouldEqual, "deals")
+					So(q.Filter.Op, ShouldEqual, metacache.OpGt)
+					return []map[string]interface{}{{"size": 2}}, nil
+				},
+			)
+			defer patch.Reset()
+			mockAPI.metadataQuery(testContext)
+
+			resp := responseRecorder.Result()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			var body struct{ Data []map[string]interface{} }
+			So(json.NewDecoder(resp.Body).Decode(&body), ShouldBeNil)
+			So(body.Data, ShouldResemble, []map[string]interface{}{{"size": 2.0}})
+		})
+
+		Convey("Given an invalid body, should return bad request", func() {
+			testContext.Request = httptest.NewRequest(http.MethodPost, "/metadata/query",
+				strings.NewReader(`{"ProviderID": "provider", "Query": "{\"find\": \"deals\"}"}`))
+			mockAPI.metadataQuery(testContext)
+			So(responseRecorder.Result().StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
+
 func newHttpAPIMock() (*API, error) {
 	pandoMock, err := mock.NewPandoMock()
 	if err != nil {
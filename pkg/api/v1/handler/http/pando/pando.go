@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 	adapter "github.com/gwatts/gin-adapter"
 	"github.com/kenlabs/pando/pkg/api/types"
+	"github.com/kenlabs/pando/pkg/health"
 	"github.com/kenlabs/pando/pkg/metrics"
 	"net/http"
 )
@@ -17,7 +18,9 @@ func (a *API) registerPando() {
 		pando.GET("/metrics", adapter.Wrap(func(h http.Handler) http.Handler {
 			return metrics.Handler(coremetrics.DefaultViews)
 		}))
-		pando.GET("/health", a.pandoHealthCheck)
+		pando.GET("/health", a.pandoReadiness)
+		pando.GET("/health/live", a.pandoLiveness)
+		pando.GET("/health/ready", a.pandoReadiness)
 	}
 }
 
@@ -34,7 +37,28 @@ func (a *API) pandoInfo(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", *pandoInfo))
 }
 
-func (a *API) pandoHealthCheck(ctx *gin.Context) {
-	ctx.String(http.StatusOK, "Good")
+// pandoLiveness reports the server is running for the liveness probes
+func (a *API) pandoLiveness(ctx *gin.Context) {
+	WriteHealthReport(ctx, a.controller.Liveness())
+}
+
+// pandoReadiness checks the components for the readiness probes, it responds
+// 503 if a critical component fails.  The route is open to all, so only the
+// overall status is reported, the components are shown by the admin API.
+func (a *API) pandoReadiness(ctx *gin.Context) {
+	WriteHealthReport(ctx, a.controller.Readiness(ctx.Request.Context()).Summary())
+}
+
+// WriteHealthReport responds the report, with 503 if it is not ready
+func WriteHealthReport(ctx *gin.Context, report *health.Report) {
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	ctx.JSON(status, &types.ResponseJson{
+		Code:    status,
+		Message: string(report.Status),
+		Data:    report,
+	})
 }
 
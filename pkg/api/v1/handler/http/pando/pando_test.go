@@ -1,12 +1,14 @@
 package pando
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/agiledragon/gomonkey/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/kenlabs/pando/pkg/api/types"
 	"github.com/kenlabs/pando/pkg/api/v1/model"
+	"github.com/kenlabs/pando/pkg/health"
 	. "github.com/smartystreets/goconvey/convey"
 	"io/ioutil"
 	"net/http"
@@ -81,3 +83,38 @@ func TestPandoInfo(t *testing.T) {
 		})
 	})
 }
+
+func TestPandoHealth(t *testing.T) {
+	Convey("TestPandoHealth", t, func() {
+		responseRecorder := httptest.NewRecorder()
+		testContext, _ := gin.CreateTestContext(responseRecorder)
+		testContext.Request = httptest.NewRequest(http.MethodGet, "/pando/health/ready", nil)
+
+		Convey("Given a failed critical component, should return service unavailable without the components", func() {
+			patch := gomonkey.ApplyMethodFunc(reflect.TypeOf(mockAPI.controller), "Readiness",
+				func(_ context.Context) *health.Report {
+					return &health.Report{
+						Status:     health.StatusFail,
+						Components: []*health.Component{{Name: "datastore", Status: health.StatusFail, Critical: true}},
+					}
+				})
+			defer patch.Reset()
+
+			mockAPI.pandoReadiness(testContext)
+			So(responseRecorder.Code, ShouldEqual, http.StatusServiceUnavailable)
+			var resp struct {
+				Message string
+				Data    health.Report
+			}
+			So(json.Unmarshal(responseRecorder.Body.Bytes(), &resp), ShouldBeNil)
+			So(resp.Message, ShouldEqual, string(health.StatusFail))
+			So(resp.Data.Status, ShouldEqual, health.StatusFail)
+			So(resp.Data.Components, ShouldBeEmpty)
+		})
+
+		Convey("Given a running server, should return the liveness", func() {
+			mockAPI.pandoLiveness(testContext)
+			So(responseRecorder.Code, ShouldEqual, http.StatusOK)
+		})
+	})
+}
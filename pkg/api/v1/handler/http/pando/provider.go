@@ -4,14 +4,20 @@ import (
 	"context"
 	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/kenlabs/pando/pkg/api/middleware"
 	"github.com/kenlabs/pando/pkg/api/types"
 	"github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/api/v1/controller"
 	"github.com/kenlabs/pando/pkg/api/v1/model"
 	"github.com/kenlabs/pando/pkg/metrics"
 	"github.com/kenlabs/pando/pkg/registry"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func (a *API) registerProvider() {
@@ -20,6 +26,11 @@ func (a *API) registerProvider() {
 		provider.POST("/register", a.providerRegister)
 		provider.GET("/info", a.listProviderInfo)
 		provider.GET("/head", a.listProviderHead)
+		provider.GET("/chain", a.providerChain)
+		provider.GET("/status/history", a.providerStatusHistory)
+		provider.GET("/events", a.providerEvents)
+		provider.POST("/access", a.providerAccess)
+		provider.GET("/access", a.listProviderAccess)
 	}
 }
 
@@ -64,6 +75,23 @@ func (a *API) listProviderInfo(ctx *gin.Context) {
 		return
 	}
 
+	// Without peerid, the providers are queried if any query parameter is
+	// given, otherwise all providers are returned as before.
+	if peerid == "" && hasProviderQuery(ctx) {
+		q, err := decodeProviderQuery(ctx)
+		if err != nil {
+			HandleError(ctx, v1.NewError(err, http.StatusBadRequest))
+			return
+		}
+		page, err := a.controller.QueryProviderInfo(q)
+		if err != nil {
+			HandleError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, types.NewOKResponse("OK", page))
+		return
+	}
+
 	info, err := a.controller.ListProviderInfo(peerid)
 	if err != nil {
 		HandleError(ctx, err)
@@ -73,6 +101,125 @@ func (a *API) listProviderInfo(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", info))
 }
 
+var providerQueryParams = []string{"status", "addr", "name", "publisher", "level", "sort", "order", "limit", "cursor"}
+
+func hasProviderQuery(ctx *gin.Context) bool {
+	for _, param := range providerQueryParams {
+		if _, ok := ctx.GetQuery(param); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeProviderQuery(ctx *gin.Context) (*registry.ProviderQuery, error) {
+	q := &registry.ProviderQuery{
+		DiscoveryAddr: ctx.Query("addr"),
+		Name:          ctx.Query("name"),
+		Status:        registry.Status(ctx.Query("status")),
+		SortBy:        registry.ProviderSortKey(ctx.Query("sort")),
+		Cursor:        ctx.Query("cursor"),
+	}
+	if publisher := ctx.Query("publisher"); publisher != "" {
+		publisherID, err := peer.Decode(publisher)
+		if err != nil {
+			return nil, errors.New("invalid publisher")
+		}
+		q.Publisher = publisherID
+	}
+	if level := ctx.Query("level"); level != "" {
+		accountLevel, err := strconv.Atoi(level)
+		if err != nil {
+			return nil, errors.New("invalid level")
+		}
+		q.AccountLevel = &accountLevel
+	}
+	if limit := ctx.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, errors.New("invalid limit")
+		}
+		q.Limit = n
+	}
+	switch ctx.Query("order") {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return nil, errors.New("invalid order, should be asc or desc")
+	}
+	return q, nil
+}
+
+func (a *API) providerStatusHistory(ctx *gin.Context) {
+	peerid, err := decodePeerid(ctx)
+	if err != nil {
+		HandleError(ctx, v1.NewError(errors.New("invalid peerid"), http.StatusBadRequest))
+		return
+	}
+
+	history, err := a.controller.ProviderStatusHistory(ctx, peerid)
+	if err != nil {
+		HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", history))
+}
+
+// eventKeepAlive is the interval of the comments sent to keep the event
+// stream alive through proxies when there is no event.
+const eventKeepAlive = 30 * time.Second
+
+// providerEvents streams the registry events as server-sent events, filtered
+// by the comma separated event types in type and the provider in peerid.
+func (a *API) providerEvents(ctx *gin.Context) {
+	peerid, err := decodePeerid(ctx)
+	if err != nil {
+		HandleError(ctx, v1.NewError(errors.New("invalid peerid"), http.StatusBadRequest))
+		return
+	}
+	eventTypes := make(map[registry.EventType]struct{})
+	if typeStr := ctx.Query("type"); typeStr != "" {
+		for _, s := range strings.Split(typeStr, ",") {
+			eventType, err := registry.ParseEventType(s)
+			if err != nil {
+				HandleError(ctx, v1.NewError(err, http.StatusBadRequest))
+				return
+			}
+			eventTypes[eventType] = struct{}{}
+		}
+	}
+
+	events, cancel := a.controller.SubscribeProviderEvents()
+	defer cancel()
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return false
+			}
+			if peerid != "" && e.Provider != peerid {
+				return true
+			}
+			if _, ok = eventTypes[e.Type]; !ok && len(eventTypes) != 0 {
+				return true
+			}
+			ctx.SSEvent(string(e.Type), e)
+		case <-keepAlive.C:
+			_, _ = io.WriteString(w, ": keep-alive\n\n")
+		case <-ctx.Request.Context().Done():
+			return false
+		}
+		return true
+	})
+}
+
 func (a *API) listProviderHead(ctx *gin.Context) {
 	record := metrics.APITimer(context.Background(), metrics.GetProviderHeadLatency)
 	defer record()
@@ -95,6 +242,61 @@ func (a *API) listProviderHead(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", res))
 }
 
+// providerChain walks the metadata chain backwards from the head of the
+// provider in peerid or the cid in start.
+func (a *API) providerChain(ctx *gin.Context) {
+	record := metrics.APITimer(context.Background(), metrics.GetProviderChainLatency)
+	defer record()
+
+	q, err := decodeChainQuery(ctx)
+	if err != nil {
+		HandleError(ctx, v1.NewError(err, http.StatusBadRequest))
+		return
+	}
+	page, err := a.controller.ProviderChain(middleware.ConsumerContext(ctx), q)
+	if err != nil {
+		HandleError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, types.NewOKResponse("OK", page))
+}
+
+func decodeChainQuery(ctx *gin.Context) (*controller.ChainQuery, error) {
+	peerid, err := decodePeerid(ctx)
+	if err != nil {
+		return nil, errors.New("invalid peerid")
+	}
+	q := &controller.ChainQuery{
+		Provider:   peerid,
+		Start:      ctx.Query("start"),
+		Stop:       ctx.Query("stop"),
+		Collection: ctx.Query("collection"),
+		Cursor:     ctx.Query("cursor"),
+	}
+	if depth := ctx.Query("depth"); depth != "" {
+		n, err := strconv.Atoi(depth)
+		if err != nil {
+			return nil, errors.New("invalid depth")
+		}
+		q.Depth = n
+	}
+	if since := ctx.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return nil, errors.New("invalid since, should be in RFC3339")
+		}
+		q.Since = &t
+	}
+	if limit := ctx.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, errors.New("invalid limit")
+		}
+		q.Limit = n
+	}
+	return q, nil
+}
+
 func decodePeerid(ctx *gin.Context) (peer.ID, error) {
 	peeridStr := ctx.Query("peerid")
 	if peeridStr == "" {
@@ -0,0 +1,84 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/kenlabs/pando/pkg/access"
+	"github.com/kenlabs/pando/pkg/api/core"
+	v1 "github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/api/v1/controller"
+	"github.com/kenlabs/pando/pkg/api/v1/server/libp2p"
+	"github.com/kenlabs/pando/pkg/option"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/libp2p/go-msgio"
+	"net/http"
+)
+
+// ingestEventsHandler streams the ingestion events over libp2p.  The consumer
+// writes a controller.IngestEventQuery in JSON, then the events are written
+// as controller.IngestEvent in JSON until the consumer closes the stream.  An
+// ErrorMessage is written instead if the query fails.  All the messages are
+// varint length prefixed.
+type ingestEventsHandler struct {
+	controller *controller.Controller
+}
+
+func NewIngestEventsHandler(core *core.Core, opt *option.DaemonOptions) *ingestEventsHandler {
+	return &ingestEventsHandler{
+		controller: controller.New(core, opt),
+	}
+}
+
+func (h *ingestEventsHandler) ProtocolID() protocol.ID {
+	return libp2p.IngestEventsProtocolID
+}
+
+func (h *ingestEventsHandler) HandleStream(ctx context.Context, stream network.Stream) error {
+	r := msgio.NewVarintReaderSize(stream, network.MessageSizeMax)
+	w := msgio.NewVarintWriter(stream)
+
+	msg, err := r.ReadMsg()
+	if err != nil {
+		return err
+	}
+	q := &controller.IngestEventQuery{}
+	err = json.Unmarshal(msg, q)
+	r.ReleaseMsg(msg)
+	if err != nil {
+		return w.WriteMsg(EncodeError(v1.NewError(errors.New("invalid query"), http.StatusBadRequest)))
+	}
+
+	// The consumer of the stream is the remote peer
+	ctx, cancel := context.WithCancel(access.WithConsumer(ctx, stream.Conn().RemotePeer()))
+	defer cancel()
+	follow, err := h.controller.PrepareIngestEvents(ctx, q)
+	if err != nil {
+		return w.WriteMsg(EncodeError(HandleError(err, "ingest events")))
+	}
+
+	// The consumer closes its side of the stream to stop following
+	go func() {
+		defer cancel()
+		for {
+			msg, err := r.ReadMsg()
+			if err != nil {
+				return
+			}
+			r.ReleaseMsg(msg)
+		}
+	}()
+
+	err = h.controller.FollowIngestEvents(ctx, follow, func(e *controller.IngestEvent) error {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		return w.WriteMsg(data)
+	})
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
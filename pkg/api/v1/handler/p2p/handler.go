@@ -87,7 +87,12 @@ func (h *libp2pHandler) HandleMessage(ctx context.Context, msgPeer peer.ID, msgb
 
 func HandleError(err error, reqType string) *v1.Error {
 	var apierr *v1.Error
-	if errors.As(err, &apierr) {
+	// errors from other subsystems, like the registry, may carry a status too
+	var statusErr interface{ Status() int }
+	if !errors.As(err, &apierr) && errors.As(err, &statusErr) && statusErr.Status() != 0 {
+		apierr = v1.NewError(err, statusErr.Status())
+	}
+	if apierr != nil {
 		if apierr.Status() >= 500 {
 			logger.Errorw(fmt.Sprint("cannot handle", reqType, "request"), "err", apierr.Error(), "status", apierr.Status())
 			// Log the error and return only the 5xx status.
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	v1 "github.com/kenlabs/pando/pkg/api/v1"
+	"github.com/kenlabs/pando/pkg/api/v1/controller"
 	pb "github.com/kenlabs/pando/pkg/api/v1/server/libp2p/proto"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"net/http"
@@ -16,6 +17,25 @@ type SnapShotQuery struct {
 }
 
 func (h *libp2pHandler) metadataList(ctx context.Context, p peer.ID, msg *pb.PandoMessage) ([]byte, error) {
+	// The snapshots are queried with a snapshot query in the data, otherwise
+	// the whole list is returned as before.
+	if len(msg.GetData()) != 0 {
+		q := new(controller.SnapShotQuery)
+		if err := json.Unmarshal(msg.GetData(), q); err != nil {
+			logger.Errorw("error unmarshalling snapshot query", "err", err)
+			return nil, v1.NewError(errors.New("cannot decode snapshot query"), http.StatusBadRequest)
+		}
+		page, err := h.controller.QuerySnapShots(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		resBytes, err := json.Marshal(page)
+		if err != nil {
+			logger.Errorf("failed to marshal snapshot page, err: %v", err)
+			return nil, v1.NewError(v1.InternalServerError, http.StatusInternalServerError)
+		}
+		return resBytes, nil
+	}
 	data, err := h.controller.SnapShotList()
 	return data, err
 }
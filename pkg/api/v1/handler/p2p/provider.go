@@ -7,6 +7,7 @@ import (
 	v1 "github.com/kenlabs/pando/pkg/api/v1"
 	"github.com/kenlabs/pando/pkg/api/v1/model"
 	pb "github.com/kenlabs/pando/pkg/api/v1/server/libp2p/proto"
+	"github.com/kenlabs/pando/pkg/registry"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"net/http"
 )
@@ -24,7 +25,12 @@ func (h *libp2pHandler) listProviderInfo(ctx context.Context, p peer.ID, msg *pb
 	var peerid peer.ID
 	err := json.Unmarshal(msg.GetData(), &peerid)
 	if err != nil {
-		return nil, v1.NewError(fmt.Errorf("failed to unmarshal peerid: %v", err), http.StatusBadRequest)
+		// Not a peerid, the request may be a query of providers
+		q := new(registry.ProviderQuery)
+		if qerr := json.Unmarshal(msg.GetData(), q); qerr != nil {
+			return nil, v1.NewError(fmt.Errorf("failed to unmarshal peerid or provider query: %v", err), http.StatusBadRequest)
+		}
+		return h.queryProviderInfo(q)
 	}
 	info, err := h.controller.ListProviderInfo(peerid)
 	if err != nil {
@@ -40,6 +46,21 @@ func (h *libp2pHandler) listProviderInfo(ctx context.Context, p peer.ID, msg *pb
 	return resBytes, nil
 }
 
+func (h *libp2pHandler) queryProviderInfo(q *registry.ProviderQuery) ([]byte, error) {
+	page, err := h.controller.QueryProviderInfo(q)
+	if err != nil {
+		return nil, err
+	}
+
+	resBytes, err := json.Marshal(page)
+	if err != nil {
+		logger.Errorf("failed to marshal provider page, err: %v", err)
+		return nil, v1.NewError(v1.InternalServerError, http.StatusInternalServerError)
+	}
+
+	return resBytes, nil
+}
+
 func (h *libp2pHandler) listProviderHead(ctx context.Context, p peer.ID, msg *pb.PandoMessage) ([]byte, error) {
 	var peerid peer.ID
 	err := json.Unmarshal(msg.GetData(), &peerid)
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/record"
+)
+
+// AccessRequest is signed by a provider to change the access rule of one of
+// its collections.
+type AccessRequest struct {
+	// PeerID is the ID of the provider owning the collection.
+	PeerID peer.ID
+
+	Collection string
+
+	// Restricted is nil to keep the current restriction of the collection.
+	Restricted *bool
+
+	// Grant and Revoke are the consumer peers granted or revoked to read the
+	// collection.
+	Grant  []peer.ID
+	Revoke []peer.ID
+
+	// Seq is a monotonically-increasing sequence counter, see RegisterRequest.
+	Seq uint64
+}
+
+const AccessEnvelopeDomain = "pando-access-request-record"
+
+var AccessEnvelopePayloadType = []byte("pando-access-request")
+
+func init() {
+	record.RegisterType(&AccessRequest{})
+}
+
+// Domain is used when signing and validating AccessRequest records contained in Envelopes
+func (r *AccessRequest) Domain() string {
+	return AccessEnvelopeDomain
+}
+
+// Codec is a binary identifier for the AccessRequest types
+func (r *AccessRequest) Codec() []byte {
+	return AccessEnvelopePayloadType
+}
+
+// UnmarshalRecord parses an AccessRequest from a byte slice.
+func (r *AccessRequest) UnmarshalRecord(data []byte) error {
+	if r == nil {
+		return fmt.Errorf("cannot unmarshal AccessRequest to nil receiver")
+	}
+
+	return json.Unmarshal(data, r)
+}
+
+// MarshalRecord serializes an AccessRequest to a byte slice.
+func (r *AccessRequest) MarshalRecord() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// MakeAccessRequest creates a signed access request of the provider and
+// marshals this into bytes
+func MakeAccessRequest(providerID peer.ID, privateKey crypto.PrivKey, collection string, restricted *bool,
+	grant []peer.ID, revoke []peer.ID) ([]byte, error) {
+
+	rec := &AccessRequest{
+		PeerID:     providerID,
+		Collection: collection,
+		Restricted: restricted,
+		Grant:      grant,
+		Revoke:     revoke,
+		Seq:        peer.TimestampSeq(),
+	}
+
+	return makeRequestEnvelop(rec, privateKey)
+}
+
+// ReadAccessRequest unmarshals an AccessRequest from bytes, and verifies that
+// it is signed by the provider
+func ReadAccessRequest(data []byte) (*AccessRequest, error) {
+	env, untypedRecord, err := record.ConsumeEnvelope(data, AccessEnvelopeDomain)
+	if err != nil {
+		return nil, fmt.Errorf("cannot consume access request envelope: %s", err)
+	}
+	rec, ok := untypedRecord.(*AccessRequest)
+	if !ok {
+		return nil, fmt.Errorf("unmarshaled access request record is not a *AccessRequest")
+	}
+	if !rec.PeerID.MatchesPublicKey(env.PublicKey) {
+		return nil, fmt.Errorf("pubkey dismatch with peerid")
+	}
+	return rec, nil
+}
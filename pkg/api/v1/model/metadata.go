@@ -0,0 +1,26 @@
+package model
+
+import "encoding/json"
+
+// Metadata is a stored schema.Metadata block decoded for the clients.
+type Metadata struct {
+	Cid        string
+	PreviousID string `json:",omitempty"`
+	Provider   string
+	Cache      *bool  `json:",omitempty"`
+	Collection string `json:",omitempty"`
+	// Payload is the payload encoded in dag-json
+	Payload   json.RawMessage
+	Signature []byte
+
+	Verification MetadataVerification
+}
+
+// MetadataVerification is the signature verification status of a metadata,
+// it is verified only if the metadata is signed by its provider.
+type MetadataVerification struct {
+	Verified bool
+	// Signer is empty if the signature cannot be verified
+	Signer string `json:",omitempty"`
+	Error  string `json:",omitempty"`
+}
@@ -1,47 +1,128 @@
 package httpserver
 
 import (
+	"fmt"
 	"github.com/gin-gonic/gin"
 	v1Admin "github.com/kenlabs/pando/pkg/api/v1/handler/http/admin"
 	v1Graphql "github.com/kenlabs/pando/pkg/api/v1/handler/http/graphql"
 	"github.com/kenlabs/pando/pkg/api/v1/handler/http/pando"
+	"github.com/kenlabs/pando/pkg/auth"
 	"github.com/kenlabs/pando/pkg/option"
 
 	"github.com/kenlabs/pando/pkg/api/core"
 	"github.com/kenlabs/pando/pkg/api/middleware"
 )
 
-func NewAdminRouter(core *core.Core, opt *option.DaemonOptions) *gin.Engine {
-	adminRouter := gin.New()
+// routeScopes are the routes of the HTTP API not requiring the read scope:
+// the routes changing the data of providers, and the health checks of the
+// probes open to all.
+var routeScopes = map[string]auth.Scope{
+	"POST /provider/register": auth.ScopeIngest,
+	"POST /provider/access":   auth.ScopeIngest,
+	"GET /pando/health":       auth.ScopeNone,
+	"GET /pando/health/live":  auth.ScopeNone,
+	"GET /pando/health/ready": auth.ScopeNone,
+}
+
+// newEngine creates a gin engine trusting the client IPs forwarded only by
+// ServerAddress.TrustedProxies, so that the clients cannot choose their IPs
+// limited by the rate limit.
+func newEngine(opt *option.DaemonOptions) (*gin.Engine, error) {
+	engine := gin.New()
+	if err := engine.SetTrustedProxies(opt.ServerAddress.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %v", err)
+	}
+	return engine, nil
+}
+
+func NewAdminRouter(core *core.Core, opt *option.DaemonOptions) (*gin.Engine, error) {
+	adminRouter, err := newEngine(opt)
+	if err != nil {
+		return nil, err
+	}
 	adminRouter.Use(gin.Recovery())
+	adminRouter.Use(middleware.WithMaxBodySize(opt.ServerAddress.MaxRequestBodySize))
+	if opt.Auth.Enable {
+		adminRouter.Use(middleware.WithAuth(core.Auth, core.Registry, middleware.RouteScopes(nil, auth.ScopeAdmin), false))
+	}
 
 	v1AdminAPI := v1Admin.NewV1AdminAPI(adminRouter, core, opt)
 	v1AdminAPI.RegisterAPIs()
 
-	return adminRouter
+	return adminRouter, nil
 }
 
-func NewHttpRouter(core *core.Core, opt *option.DaemonOptions) *gin.Engine {
-	httpRouter := gin.New()
+func NewHttpRouter(core *core.Core, opt *option.DaemonOptions) (*gin.Engine, error) {
+	httpRouter, err := newEngine(opt)
+	if err != nil {
+		return nil, err
+	}
 	httpRouter.Use(middleware.WithLoggerFormatter())
-	httpRouter.Use(middleware.WithCorsAllowAllOrigin())
+	if err = useCors(httpRouter, opt); err != nil {
+		return nil, err
+	}
 	httpRouter.Use(gin.Recovery())
+	httpRouter.Use(middleware.WithMaxBodySize(opt.ServerAddress.MaxRequestBodySize))
+	useAuth(httpRouter, core, opt, middleware.RouteScopes(routeScopes, auth.ScopeRead))
+	if err = useRateLimit(httpRouter, opt); err != nil {
+		return nil, err
+	}
 	httpRouter.Use(middleware.WithAPIDoc())
 
 	v1HttpAPI := pando.NewV1HttpAPI(httpRouter, core, opt)
 	v1HttpAPI.RegisterAPIs()
 
-	return httpRouter
+	return httpRouter, nil
 }
 
-func NewGraphqlRouter(core *core.Core) *gin.Engine {
-	graphqlRouter := gin.New()
+func NewGraphqlRouter(core *core.Core, opt *option.DaemonOptions) (*gin.Engine, error) {
+	graphqlRouter, err := newEngine(opt)
+	if err != nil {
+		return nil, err
+	}
 	graphqlRouter.Use(middleware.WithLoggerFormatter())
-	graphqlRouter.Use(middleware.WithCorsAllowAllOrigin())
+	if err = useCors(graphqlRouter, opt); err != nil {
+		return nil, err
+	}
 	graphqlRouter.Use(gin.Recovery())
+	graphqlRouter.Use(middleware.WithMaxBodySize(opt.ServerAddress.MaxRequestBodySize))
+	useAuth(graphqlRouter, core, opt, middleware.RouteScopes(nil, auth.ScopeRead))
+	if err = useRateLimit(graphqlRouter, opt); err != nil {
+		return nil, err
+	}
 
-	v1GraphAPI := v1Graphql.NewV1GraphqlAPI(graphqlRouter, core)
+	v1GraphAPI := v1Graphql.NewV1GraphqlAPI(graphqlRouter, core, opt)
 	v1GraphAPI.RegisterAPIs()
 
-	return graphqlRouter
+	return graphqlRouter, nil
+}
+
+func useCors(router *gin.Engine, opt *option.DaemonOptions) error {
+	cors, err := middleware.WithCors(opt.ServerAddress.CORSAllowOrigins)
+	if err != nil {
+		return err
+	}
+	router.Use(cors)
+	return nil
+}
+
+// useAuth authenticates the requests before they are rate limited, so that
+// the authenticated clients are limited by their keys.
+func useAuth(router *gin.Engine, core *core.Core, opt *option.DaemonOptions, scopeOf middleware.ScopeFunc) {
+	if !opt.Auth.Enable {
+		return
+	}
+	router.Use(middleware.WithAuth(core.Auth, core.Registry, scopeOf, opt.Auth.AnonymousRead))
+}
+
+func useRateLimit(router *gin.Engine, opt *option.DaemonOptions) error {
+	if !opt.APIRateLimit.Enable {
+		return nil
+	}
+	rateLimit, err := middleware.WithRateLimit(opt.APIRateLimit)
+	if err != nil {
+		return err
+	}
+	router.Use(rateLimit)
+	return nil
 }
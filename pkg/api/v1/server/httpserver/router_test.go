@@ -0,0 +1,73 @@
+package httpserver
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kenlabs/pando/pkg/api/middleware"
+	"github.com/kenlabs/pando/pkg/option"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	Convey("TestTrustedProxies", t, func() {
+		opt := &option.DaemonOptions{}
+		opt.APIRateLimit = option.APIRateLimit{
+			Enable:      true,
+			IPRate:      0.001,
+			IPBurst:     1,
+			KeyRate:     0.001,
+			KeyBurst:    1,
+			IdleTimeout: "10m",
+		}
+		newRouter := func(trustedProxies []string) *gin.Engine {
+			opt.ServerAddress.TrustedProxies = trustedProxies
+			router, err := newEngine(opt)
+			So(err, ShouldBeNil)
+			rateLimit, err := middleware.WithRateLimit(opt.APIRateLimit)
+			So(err, ShouldBeNil)
+			router.Use(rateLimit)
+			router.GET("/list", func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, ctx.ClientIP())
+			})
+			return router
+		}
+		request := func(router *gin.Engine, forwardedFor string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/list", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+			req.Header.Set("X-Real-IP", forwardedFor)
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("the forwarded IPs are ignored by default", func() {
+			router := newRouter(nil)
+			w := request(router, "1.1.1.1")
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Body.String(), ShouldEqual, "10.0.0.1")
+			for i := 2; i < 5; i++ {
+				// spoofing the header does not get a new bucket
+				So(request(router, "1.1.1."+strconv.Itoa(i)).Code, ShouldEqual, http.StatusTooManyRequests)
+			}
+		})
+
+		Convey("the IPs forwarded by the trusted proxies are the client IPs", func() {
+			router := newRouter([]string{"10.0.0.0/8"})
+			w := request(router, "1.1.1.1")
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Body.String(), ShouldEqual, "1.1.1.1")
+			So(request(router, "1.1.1.2").Code, ShouldEqual, http.StatusOK)
+			So(request(router, "1.1.1.1").Code, ShouldEqual, http.StatusTooManyRequests)
+		})
+
+		Convey("invalid trusted proxies are rejected", func() {
+			opt.ServerAddress.TrustedProxies = []string{"proxy"}
+			_, err := newEngine(opt)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -5,4 +5,7 @@ import "github.com/libp2p/go-libp2p-core/protocol"
 const (
 	// PandoProtocolID is the libp2p protocol that pando API uses
 	PandoProtocolID protocol.ID = "/Pando/libp2p/0.0.1"
+	// IngestEventsProtocolID is the libp2p protocol streaming the ingestion
+	// events to the consumers
+	IngestEventsProtocolID protocol.ID = "/Pando/ingest-events/0.0.1"
 )
@@ -22,6 +22,13 @@ type Handler interface {
 	ProtocolID() protocol.ID
 }
 
+// StreamHandler handles the long-lived streams of a protocol, such as the
+// event streams, instead of the request and response messages.
+type StreamHandler interface {
+	HandleStream(ctx context.Context, stream network.Stream) error
+	ProtocolID() protocol.ID
+}
+
 // Server handles client requests over libp2p
 type Server struct {
 	ctx     context.Context
@@ -29,6 +36,9 @@ type Server struct {
 	handler Handler
 	h       host.Host
 	selfID  peer.ID
+
+	// protocols are the protocols of the stream handlers, removed on shutdown
+	protocols []protocol.ID
 }
 
 // ID returns the peer.ID of the protocol server.
@@ -50,6 +60,7 @@ func New(ctx context.Context, h host.Host, messageHandler Handler) *Server {
 
 	//Set handler for each announced protocol
 	h.SetStreamHandler(messageHandler.ProtocolID(), s.handleNewStream)
+	s.protocols = append(s.protocols, messageHandler.ProtocolID())
 
 	return s
 }
@@ -98,7 +109,25 @@ func (s *Server) handleNewMessages(stream network.Stream) bool {
 	}
 }
 
+// AddStreamHandler sets the handler of the streams of its protocol, the
+// stream is reset if the handler fails.
+func (s *Server) AddStreamHandler(handler StreamHandler) {
+	s.protocols = append(s.protocols, handler.ProtocolID())
+	s.h.SetStreamHandler(handler.ProtocolID(), func(stream network.Stream) {
+		if err := handler.HandleStream(s.ctx, stream); err != nil {
+			_ = stream.Reset()
+			return
+		}
+		_ = stream.Close()
+	})
+}
+
+// Shutdown stops accepting the streams, and cancels the context of the
+// streams being handled.
 func (s *Server) Shutdown() error {
+	for _, pid := range s.protocols {
+		s.h.RemoveStreamHandler(pid)
+	}
 	s.cncl()
 	return nil
 }
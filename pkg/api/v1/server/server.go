@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"github.com/kenlabs/pando/pkg/api/v1/handler/p2p"
 	"github.com/kenlabs/pando/pkg/util/log"
@@ -11,6 +12,7 @@ import (
 	"github.com/kenlabs/pando/pkg/api/v1/server/libp2p"
 	"go.elastic.co/apm/module/apmhttp"
 	"golang.org/x/sync/errgroup"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"time"
@@ -22,6 +24,10 @@ import (
 
 var logger = log.NewSubsystemLogger()
 
+// httpShutdownTimeout is the max time of finishing the requests being served
+// on shutdown, the long-lived streams are closed after it.
+const httpShutdownTimeout = 5 * time.Second
+
 type Server struct {
 	Opt  *option.DaemonOptions
 	Core *core.Core
@@ -57,160 +63,197 @@ func NewAPIServer(opt *option.DaemonOptions, core *core.Core) (*Server, error) {
 		return nil, err
 	}
 
-	profileListenAddress, err := multiaddress.MultiaddressToNetAddress(opt.ServerAddress.ProfileListenAddress)
+	httpRouter, err := httpserver.NewHttpRouter(core, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	graphqlRouter, err := httpserver.NewGraphqlRouter(core, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	adminRouter, err := httpserver.NewAdminRouter(core, opt)
 	if err != nil {
 		return nil, err
 	}
 
+	var tlsConfig *tls.Config
+	if opt.ServerAddress.TLSEnabled() {
+		reloader, err := newCertReloader(opt.ServerAddress.TLSCertFile, opt.ServerAddress.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %v", err)
+		}
+		tlsConfig = reloader.TLSConfig()
+	}
+
 	s := &Server{
 		Opt:  opt,
 		Core: core,
 
-		AdminServer: &http.Server{
-			Addr:    adminListenAddress,
-			Handler: apmhttp.Wrap(httpserver.NewAdminRouter(core, opt)),
-		},
+		AdminServer:     newHttpServer(adminListenAddress, apmhttp.Wrap(adminRouter), opt, tlsConfig),
 		AdminListenAddr: adminListenAddress,
 
-		HttpServer: &http.Server{
-			Addr:    httpListenAddress,
-			Handler: apmhttp.Wrap(httpserver.NewHttpRouter(core, opt)),
-		},
+		HttpServer:     newHttpServer(httpListenAddress, apmhttp.Wrap(httpRouter), opt, tlsConfig),
 		HttpListenAddr: httpListenAddress,
 
-		GraphqlServer: &http.Server{
-			Addr:    graphqlListenAddress,
-			Handler: httpserver.NewGraphqlRouter(core),
-		},
+		GraphqlServer:     newHttpServer(graphqlListenAddress, graphqlRouter, opt, tlsConfig),
 		GraphqlListenAddr: graphqlListenAddress,
+	}
 
-		ProfileServer: &http.Server{
-			Addr: profileListenAddress,
-		},
-		ProfileListenAddr: profileListenAddress,
+	if !opt.ServerAddress.DisableProfileServer {
+		profileListenAddress, err := multiaddress.MultiaddressToNetAddress(opt.ServerAddress.ProfileListenAddress)
+		if err != nil {
+			return nil, err
+		}
+		// The handlers of net/http/pprof are registered to http.DefaultServeMux
+		s.ProfileServer = newHttpServer(profileListenAddress, nil, opt, tlsConfig)
+		s.ProfileListenAddr = profileListenAddress
 	}
 
 	if !opt.ServerAddress.DisableP2PServer {
 		libp2pHandler := p2p.NewHandler(core, opt)
 		s.P2PServer = libp2p.New(context.Background(), core.LegsCore.Host, libp2pHandler)
+		s.P2PServer.AddStreamHandler(p2p.NewIngestEventsHandler(core, opt))
 	}
 
 	return s, nil
 }
 
-func (s *Server) StartAdminServer() error {
-	logger.Infof("admin server listening at: %s", s.AdminListenAddr)
-	return s.AdminServer.ListenAndServe()
+func newHttpServer(addr string, handler http.Handler, opt *option.DaemonOptions, tlsConfig *tls.Config) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: time.Duration(opt.ServerAddress.ReadHeaderTimeoutInDurationFormat()),
+		ReadTimeout:       time.Duration(opt.ServerAddress.ReadTimeoutInDurationFormat()),
+		WriteTimeout:      time.Duration(opt.ServerAddress.WriteTimeoutInDurationFormat()),
+		IdleTimeout:       time.Duration(opt.ServerAddress.IdleTimeoutInDurationFormat()),
+	}
+}
+
+// startHttpServer listens on the address of the server, and serves in
+// background so that the failure of listening is returned.  It serves over TLS
+// if the server has the TLS config, the certificate is from its
+// GetCertificate.
+func startHttpServer(name string, server *http.Server) error {
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return fmt.Errorf("%s server cannot listen: %v", name, err)
+	}
+	logger.Infof("%s server listening at: %s", name, server.Addr)
+	go func() {
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ServeTLS(ln, "", "")
+		} else {
+			err = server.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logger.Errorf("%s server stopped: %v", name, err)
+		}
+	}()
+	return nil
 }
 
-func (s *Server) StopAdminServer() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// stopHttpServer waits for the requests being served for httpShutdownTimeout
+// at most, then closes the connections left, such as the event streams.
+func stopHttpServer(ctx context.Context, name string, server *http.Server) error {
+	ctx, cancel := context.WithTimeout(ctx, httpShutdownTimeout)
 	defer cancel()
 
-	fmt.Println("stop admin server...")
-	return s.AdminServer.Shutdown(ctx)
+	logger.Infof("stopping %s server", name)
+	err := server.Shutdown(ctx)
+	if err != nil {
+		_ = server.Close()
+	}
+	return err
 }
 
-func (s *Server) StartHttpServer() error {
-	logger.Infof("http server listening at: %s", s.HttpListenAddr)
-	return s.HttpServer.ListenAndServe()
+func (s *Server) StartAdminServer() error {
+	return startHttpServer("admin", s.AdminServer)
 }
 
-func (s *Server) StopHttpServer() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+func (s *Server) StopAdminServer(ctx context.Context) error {
+	return stopHttpServer(ctx, "admin", s.AdminServer)
+}
 
-	fmt.Println("stop http server...")
-	return s.HttpServer.Shutdown(ctx)
+func (s *Server) StartHttpServer() error {
+	return startHttpServer("http", s.HttpServer)
 }
 
-func (s *Server) StartGraphqlServer() error {
-	logger.Infof("graphql server listening at: %s", s.GraphqlListenAddr)
-	return s.GraphqlServer.ListenAndServe()
+func (s *Server) StopHttpServer(ctx context.Context) error {
+	return stopHttpServer(ctx, "http", s.HttpServer)
 }
 
-func (s *Server) StopGraphqlServer() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+func (s *Server) StartGraphqlServer() error {
+	return startHttpServer("graphql", s.GraphqlServer)
+}
 
-	fmt.Println("stop graphql server...")
-	return s.GraphqlServer.Shutdown(ctx)
+func (s *Server) StopGraphqlServer(ctx context.Context) error {
+	return stopHttpServer(ctx, "graphql", s.GraphqlServer)
 }
 
 func (s *Server) StartProfileServer() error {
-	logger.Infof("profile server listening at: %s", s.ProfileListenAddr)
-	return s.ProfileServer.ListenAndServe()
+	if s.ProfileServer == nil {
+		return nil
+	}
+	return startHttpServer("profile", s.ProfileServer)
 }
 
-func (s *Server) StopProfileServer() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	fmt.Println("stop profile server...")
-	return s.ProfileServer.Shutdown(ctx)
+func (s *Server) StopProfileServer(ctx context.Context) error {
+	if s.ProfileServer == nil {
+		return nil
+	}
+	return stopHttpServer(ctx, "profile", s.ProfileServer)
 }
 
 func (s *Server) StopP2pServer() error {
+	if s.P2PServer == nil {
+		return nil
+	}
 	return s.P2PServer.Shutdown()
 }
 
-func (s *Server) MustStartAllServers() {
-	go func() {
-		err := s.StartAdminServer()
-		if err != nil && err != http.ErrServerClosed {
-			panic(fmt.Sprintf("admin server cannot start: %v", err))
-		}
-	}()
-
-	go func() {
-		err := s.StartHttpServer()
-		if err != nil && err != http.ErrServerClosed {
-			panic(fmt.Sprintf("http server cannot start: %v", err))
-		}
-	}()
-
-	go func() {
-
-	}()
-
-	go func() {
-		err := s.StartGraphqlServer()
-		if err != nil && err != http.ErrServerClosed {
-			panic(fmt.Sprintf("graphql server cannot start: %v", err))
-		}
-	}()
-
-	go func() {
-		err := s.StartProfileServer()
-		if err != nil && err != http.ErrServerClosed {
-			panic(fmt.Sprintf("profile server cannot start: %v", err))
+// StartAllServers starts the HTTP servers, the P2P server is serving since it
+// is created.  If a server fails to start, the started ones are closed.
+func (s *Server) StartAllServers() error {
+	servers := []*http.Server{s.AdminServer, s.HttpServer, s.GraphqlServer}
+	starts := []func() error{s.StartAdminServer, s.StartHttpServer, s.StartGraphqlServer}
+	if s.ProfileServer != nil {
+		servers = append(servers, s.ProfileServer)
+		starts = append(starts, s.StartProfileServer)
+	}
+	for i, start := range starts {
+		if err := start(); err != nil {
+			for _, server := range servers[:i] {
+				_ = server.Close()
+			}
+			_ = s.StopP2pServer()
+			return err
 		}
-	}()
+	}
+	return nil
 }
 
-func (s *Server) StopAllServers() error {
+// StopAllServers stops the servers concurrently, they stop accepting the
+// requests at once and finish the ones being served until ctx is done.
+func (s *Server) StopAllServers(ctx context.Context) error {
 	g := errgroup.Group{}
 	g.Go(func() error {
-		return s.Core.StoreInstance.PandoStore.Close()
+		return s.StopP2pServer()
 	})
 	g.Go(func() error {
-		return s.StopAdminServer()
+		return s.StopAdminServer(ctx)
 	})
 	g.Go(func() error {
-		return s.StopHttpServer()
+		return s.StopHttpServer(ctx)
 	})
 	g.Go(func() error {
-		return s.StopGraphqlServer()
+		return s.StopGraphqlServer(ctx)
 	})
 	g.Go(func() error {
-		return s.StopProfileServer()
+		return s.StopProfileServer(ctx)
 	})
-	err := g.Wait()
-	if err != nil {
-		return err
-	}
-
-	fmt.Println("Bye, Pando!")
-
-	return nil
+	return g.Wait()
 }
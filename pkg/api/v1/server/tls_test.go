@@ -0,0 +1,89 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// writeCert writes a self-signed certificate of the common name and its key
+func writeCert(certFile string, keyFile string, commonName string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+}
+
+func TestCertReloader(t *testing.T) {
+	Convey("TestCertReloader", t, func() {
+		dir := t.TempDir()
+		certFile := filepath.Join(dir, "cert.pem")
+		keyFile := filepath.Join(dir, "key.pem")
+		So(writeCert(certFile, keyFile, "first"), ShouldBeNil)
+
+		commonName := func(r *certReloader) string {
+			cert, err := r.GetCertificate(nil)
+			So(err, ShouldBeNil)
+			leaf, err := x509.ParseCertificate(cert.Certificate[0])
+			So(err, ShouldBeNil)
+			return leaf.Subject.CommonName
+		}
+		// changeFiles marks the files changed and due to be checked
+		changeFiles := func(r *certReloader) {
+			modTime := time.Now().Add(time.Minute)
+			So(os.Chtimes(certFile, modTime, modTime), ShouldBeNil)
+			So(os.Chtimes(keyFile, modTime, modTime), ShouldBeNil)
+			r.lastCheck = time.Time{}
+		}
+
+		r, err := newCertReloader(certFile, keyFile)
+		So(err, ShouldBeNil)
+		So(commonName(r), ShouldEqual, "first")
+		So(r.TLSConfig().MinVersion, ShouldEqual, tls.VersionTLS12)
+
+		Convey("reload the certificate when the files change", func() {
+			So(writeCert(certFile, keyFile, "second"), ShouldBeNil)
+			changeFiles(r)
+			So(commonName(r), ShouldEqual, "second")
+		})
+		Convey("keep the loaded certificate if the files are invalid", func() {
+			So(ioutil.WriteFile(certFile, []byte("invalid"), 0600), ShouldBeNil)
+			changeFiles(r)
+			So(commonName(r), ShouldEqual, "first")
+		})
+		Convey("fail to create with the invalid files", func() {
+			_, err = newCertReloader(certFile, filepath.Join(dir, "missing.pem"))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
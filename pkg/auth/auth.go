@@ -0,0 +1,280 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/kenlabs/pando/pkg/util/log"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+var logger = log.NewSubsystemLogger()
+
+// authKeyPath is where the API keys are stored
+const authKeyPath = "/auth/keys"
+
+// keySeparator separates the ID and the secret of an API key token
+const keySeparator = "."
+
+var (
+	// ErrUnauthenticated is returned for unknown API keys and bad signatures
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrKeyNotFound is returned when revoking an unknown API key
+	ErrKeyNotFound = errors.New("api key not found")
+	// ErrInvalidScope is returned for unknown scopes
+	ErrInvalidScope = errors.New("invalid scope")
+)
+
+// Scope is a permission granted to an API key
+type Scope string
+
+const (
+	// ScopeAdmin grants all, including the admin API
+	ScopeAdmin Scope = "admin"
+	// ScopeRead grants reading the data of the HTTP and GraphQL APIs
+	ScopeRead Scope = "read"
+	// ScopeIngest grants registering providers and changing their data
+	ScopeIngest Scope = "ingest"
+	// ScopeNone is required by the routes open to all, such as the health
+	// checks of the probes.  It cannot be granted to the keys.
+	ScopeNone Scope = "none"
+)
+
+// ParseScope returns the scope of s
+func ParseScope(s string) (Scope, error) {
+	switch scope := Scope(strings.ToLower(strings.TrimSpace(s))); scope {
+	case ScopeAdmin, ScopeRead, ScopeIngest:
+		return scope, nil
+	default:
+		return "", fmt.Errorf("%w: %q, should be admin, read or ingest", ErrInvalidScope, s)
+	}
+}
+
+// Providers tells the registered and the banned providers, which decide the
+// scopes of the peer-signed requests.
+type Providers interface {
+	IsRegistered(providerID peer.ID) bool
+	IsBanned(peerID peer.ID) bool
+}
+
+// PeerScopes returns the scopes of the requests signed by peerID, checked
+// against providers when authorizing.  All peers can read, only the
+// registered providers not banned can ingest, and no peer can administrate.
+func PeerScopes(providers Providers, peerID peer.ID) []Scope {
+	if providers != nil && providers.IsRegistered(peerID) && !providers.IsBanned(peerID) {
+		return []Scope{ScopeRead, ScopeIngest}
+	}
+	return []Scope{ScopeRead}
+}
+
+// HasScope checks if the scopes grant the required one
+func HasScope(scopes []Scope, required Scope) bool {
+	for _, scope := range scopes {
+		if scope == ScopeAdmin || scope == required {
+			return true
+		}
+	}
+	return false
+}
+
+// Key is an API key, its secret is only known when created
+type Key struct {
+	ID      string
+	Name    string
+	Scopes  []Scope
+	Created time.Time
+}
+
+type storedKey struct {
+	Key
+	// Hash is the sha256 of the secret
+	Hash []byte
+}
+
+// Authenticator authenticates the API requests by API keys or by the
+// signatures of the peers.
+type Authenticator struct {
+	ds     datastore.Datastore
+	maxAge time.Duration
+
+	mutex sync.RWMutex
+	keys  map[string]*storedKey
+
+	replays *replayCache
+}
+
+// New creates an Authenticator with the API keys persisted in ds, the signed
+// requests older than maxAge are rejected.
+func New(ctx context.Context, ds datastore.Datastore, maxAge time.Duration) (*Authenticator, error) {
+	if maxAge <= 0 {
+		return nil, fmt.Errorf("max age of signed requests should be positive")
+	}
+	a := &Authenticator{
+		ds:      ds,
+		maxAge:  maxAge,
+		keys:    make(map[string]*storedKey),
+		replays: newReplayCache(),
+	}
+	if err := a.load(ctx); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *Authenticator) load(ctx context.Context) error {
+	results, err := a.ds.Query(ctx, query.Query{Prefix: authKeyPath})
+	if err != nil {
+		return fmt.Errorf("cannot query api keys: %v", err)
+	}
+	defer results.Close()
+
+	for r := range results.Next() {
+		if r.Error != nil {
+			return fmt.Errorf("cannot read api keys: %v", r.Error)
+		}
+		key := &storedKey{}
+		if err = json.Unmarshal(r.Value, key); err != nil {
+			return fmt.Errorf("cannot decode api key %s: %v", r.Key, err)
+		}
+		a.keys[key.ID] = key
+	}
+	logger.Infow("loaded api keys", "count", len(a.keys))
+	return nil
+}
+
+// CreateKey creates an API key of the scopes, and returns it with its token.
+// The token is not kept and cannot be shown again.
+func (a *Authenticator) CreateKey(ctx context.Context, name string, scopes []Scope) (*Key, string, error) {
+	if len(scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+	for _, scope := range scopes {
+		if _, err := ParseScope(string(scope)); err != nil {
+			return nil, "", err
+		}
+	}
+
+	id, err := randomString(8, hex.EncodeToString)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return nil, "", err
+	}
+	hash := sha256.Sum256([]byte(secret))
+	key := &storedKey{
+		Key: Key{
+			ID:      id,
+			Name:    name,
+			Scopes:  scopes,
+			Created: time.Now().UTC(),
+		},
+		Hash: hash[:],
+	}
+
+	value, err := json.Marshal(key)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot encode api key: %v", err)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if err = a.ds.Put(ctx, datastore.NewKey(path.Join(authKeyPath, id)), value); err != nil {
+		return nil, "", fmt.Errorf("cannot save api key: %v", err)
+	}
+	a.keys[id] = key
+
+	logger.Infow("created api key", "id", id, "name", name, "scopes", scopes)
+	created := key.Key
+	return &created, id + keySeparator + secret, nil
+}
+
+// Keys returns the API keys, without their secrets
+func (a *Authenticator) Keys() []*Key {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	keys := make([]*Key, 0, len(a.keys))
+	for _, key := range a.keys {
+		k := key.Key
+		keys = append(keys, &k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Created.Before(keys[j].Created) })
+	return keys
+}
+
+// HasKey checks if there is any API key granting the scope
+func (a *Authenticator) HasKey(scope Scope) bool {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	for _, key := range a.keys {
+		if HasScope(key.Scopes, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// RevokeKey deletes the API key
+func (a *Authenticator) RevokeKey(ctx context.Context, id string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if _, exists := a.keys[id]; !exists {
+		return ErrKeyNotFound
+	}
+	if err := a.ds.Delete(ctx, datastore.NewKey(path.Join(authKeyPath, id))); err != nil {
+		return fmt.Errorf("cannot delete api key: %v", err)
+	}
+	delete(a.keys, id)
+
+	logger.Infow("revoked api key", "id", id)
+	return nil
+}
+
+// AuthenticateKey returns the API key of the token
+func (a *Authenticator) AuthenticateKey(token string) (*Key, error) {
+	parts := strings.SplitN(token, keySeparator, 2)
+	if len(parts) != 2 {
+		return nil, ErrUnauthenticated
+	}
+
+	a.mutex.RLock()
+	key, exists := a.keys[parts[0]]
+	a.mutex.RUnlock()
+	if !exists {
+		return nil, ErrUnauthenticated
+	}
+
+	hash := sha256.Sum256([]byte(parts[1]))
+	if subtle.ConstantTimeCompare(hash[:], key.Hash) != 1 {
+		return nil, ErrUnauthenticated
+	}
+	k := key.Key
+	return &k, nil
+}
+
+func randomString(n int, encode func([]byte) string) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("cannot generate api key: %v", err)
+	}
+	return encode(buf), nil
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/test"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAPIKeys(t *testing.T) {
+	Convey("TestAPIKeys", t, func() {
+		ctx := context.Background()
+		ds := dssync.MutexWrap(datastore.NewMapDatastore())
+		a, err := New(ctx, ds, time.Minute)
+		So(err, ShouldBeNil)
+		So(a.HasKey(ScopeAdmin), ShouldBeFalse)
+
+		key, token, err := a.CreateKey(ctx, "reader", []Scope{ScopeRead})
+		So(err, ShouldBeNil)
+		So(key.Scopes, ShouldResemble, []Scope{ScopeRead})
+
+		Convey("authenticate by token", func() {
+			authenticated, err := a.AuthenticateKey(token)
+			So(err, ShouldBeNil)
+			So(authenticated.ID, ShouldEqual, key.ID)
+			So(HasScope(authenticated.Scopes, ScopeRead), ShouldBeTrue)
+			So(HasScope(authenticated.Scopes, ScopeIngest), ShouldBeFalse)
+
+			_, err = a.AuthenticateKey(key.ID + keySeparator + "wrong")
+			So(errors.Is(err, ErrUnauthenticated), ShouldBeTrue)
+			_, err = a.AuthenticateKey("garbage")
+			So(errors.Is(err, ErrUnauthenticated), ShouldBeTrue)
+		})
+
+		Convey("reload persisted keys", func() {
+			reloaded, err := New(ctx, ds, time.Minute)
+			So(err, ShouldBeNil)
+			So(reloaded.Keys(), ShouldResemble, a.Keys())
+			_, err = reloaded.AuthenticateKey(token)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("revoke keys", func() {
+			So(a.RevokeKey(ctx, key.ID), ShouldBeNil)
+			_, err := a.AuthenticateKey(token)
+			So(errors.Is(err, ErrUnauthenticated), ShouldBeTrue)
+			So(a.RevokeKey(ctx, key.ID), ShouldEqual, ErrKeyNotFound)
+		})
+
+		Convey("reject invalid scopes", func() {
+			_, _, err := a.CreateKey(ctx, "none", nil)
+			So(errors.Is(err, ErrInvalidScope), ShouldBeTrue)
+			_, _, err = a.CreateKey(ctx, "root", []Scope{"root"})
+			So(errors.Is(err, ErrInvalidScope), ShouldBeTrue)
+		})
+
+		Convey("admin grants all", func() {
+			So(HasScope([]Scope{ScopeAdmin}, ScopeIngest), ShouldBeTrue)
+		})
+	})
+}
+
+type testProviders struct {
+	registered map[peer.ID]bool
+	banned     map[peer.ID]bool
+}
+
+func (p *testProviders) IsRegistered(providerID peer.ID) bool { return p.registered[providerID] }
+
+func (p *testProviders) IsBanned(peerID peer.ID) bool { return p.banned[peerID] }
+
+func TestPeerScopes(t *testing.T) {
+	Convey("TestPeerScopes", t, func() {
+		provider, err := test.RandPeerID()
+		So(err, ShouldBeNil)
+		providers := &testProviders{registered: map[peer.ID]bool{provider: true}, banned: map[peer.ID]bool{}}
+
+		Convey("registered providers can ingest", func() {
+			So(HasScope(PeerScopes(providers, provider), ScopeIngest), ShouldBeTrue)
+			So(HasScope(PeerScopes(providers, provider), ScopeAdmin), ShouldBeFalse)
+		})
+
+		Convey("unknown peers can only read", func() {
+			unknown, err := test.RandPeerID()
+			So(err, ShouldBeNil)
+			So(PeerScopes(providers, unknown), ShouldResemble, []Scope{ScopeRead})
+			So(PeerScopes(nil, provider), ShouldResemble, []Scope{ScopeRead})
+		})
+
+		Convey("banned providers can only read", func() {
+			providers.banned[provider] = true
+			So(PeerScopes(providers, provider), ShouldResemble, []Scope{ScopeRead})
+		})
+	})
+}
+
+func TestSignedRequest(t *testing.T) {
+	Convey("TestSignedRequest", t, func() {
+		a, err := New(context.Background(), dssync.MutexWrap(datastore.NewMapDatastore()), time.Minute)
+		So(err, ShouldBeNil)
+		privateKey, pubKey, err := crypto.GenerateEd25519Key(nil)
+		So(err, ShouldBeNil)
+		peerID, err := peer.IDFromPublicKey(pubKey)
+		So(err, ShouldBeNil)
+
+		body := []byte(`{"ProviderID": "x"}`)
+		signature, err := SignRequest(privateKey, http.MethodPost, "/metadata/query?a=1", body)
+		So(err, ShouldBeNil)
+
+		Convey("verify the signer once", func() {
+			signer, err := a.VerifyRequest(signature, http.MethodPost, "/metadata/query?a=1", body)
+			So(err, ShouldBeNil)
+			So(signer, ShouldEqual, peerID)
+
+			_, err = a.VerifyRequest(signature, http.MethodPost, "/metadata/query?a=1", body)
+			So(errors.Is(err, ErrUnauthenticated), ShouldBeTrue)
+		})
+
+		Convey("reject signatures of other requests", func() {
+			_, err := a.VerifyRequest(signature, http.MethodGet, "/metadata/query?a=1", body)
+			So(errors.Is(err, ErrUnauthenticated), ShouldBeTrue)
+			_, err = a.VerifyRequest(signature, http.MethodPost, "/metadata/query?a=2", body)
+			So(errors.Is(err, ErrUnauthenticated), ShouldBeTrue)
+			_, err = a.VerifyRequest(signature, http.MethodPost, "/metadata/query?a=1", []byte("{}"))
+			So(errors.Is(err, ErrUnauthenticated), ShouldBeTrue)
+			_, err = a.VerifyRequest("not base64!", http.MethodPost, "/metadata/query?a=1", body)
+			So(errors.Is(err, ErrUnauthenticated), ShouldBeTrue)
+		})
+
+		Convey("reject expired signatures", func() {
+			a.maxAge = time.Nanosecond
+			time.Sleep(time.Millisecond)
+			_, err := a.VerifyRequest(signature, http.MethodPost, "/metadata/query?a=1", body)
+			So(errors.Is(err, ErrUnauthenticated), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/record"
+)
+
+// SignatureHeader is the HTTP header carrying the base64 encoded envelope of
+// a SignedRequest.
+const SignatureHeader = "X-Pando-Signature"
+
+// SignedRequest binds an HTTP request to the peer signing it, it is sealed in
+// an envelope by the private key of the peer.
+type SignedRequest struct {
+	PeerID peer.ID
+	Method string
+	// URI is the path and the query of the request
+	URI string
+	// BodyHash is the sha256 of the request body
+	BodyHash []byte
+	// Timestamp is the unix time in nanoseconds the request was signed
+	Timestamp int64
+}
+
+const SignedRequestEnvelopeDomain = "pando-signed-request-record"
+
+var SignedRequestEnvelopePayloadType = []byte("pando-signed-request")
+
+func init() {
+	record.RegisterType(&SignedRequest{})
+}
+
+// Domain is used when signing and validating SignedRequest records contained in Envelopes
+func (r *SignedRequest) Domain() string {
+	return SignedRequestEnvelopeDomain
+}
+
+// Codec is a binary identifier for the SignedRequest types
+func (r *SignedRequest) Codec() []byte {
+	return SignedRequestEnvelopePayloadType
+}
+
+// UnmarshalRecord parses a SignedRequest from a byte slice.
+func (r *SignedRequest) UnmarshalRecord(data []byte) error {
+	if r == nil {
+		return fmt.Errorf("cannot unmarshal SignedRequest to nil receiver")
+	}
+
+	return json.Unmarshal(data, r)
+}
+
+// MarshalRecord serializes a SignedRequest to a byte slice.
+func (r *SignedRequest) MarshalRecord() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// SignRequest signs the request by the private key of the peer, and returns
+// the value of SignatureHeader.
+func SignRequest(privateKey crypto.PrivKey, method string, uri string, body []byte) (string, error) {
+	peerID, err := peer.IDFromPrivateKey(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid private key: %v", err)
+	}
+	hash := sha256.Sum256(body)
+	rec := &SignedRequest{
+		PeerID:    peerID,
+		Method:    method,
+		URI:       uri,
+		BodyHash:  hash[:],
+		Timestamp: time.Now().UnixNano(),
+	}
+	envelope, err := record.Seal(rec, privateKey)
+	if err != nil {
+		return "", fmt.Errorf("could not sign request: %v", err)
+	}
+	data, err := envelope.Marshal()
+	if err != nil {
+		return "", fmt.Errorf("could not marshal signed request: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// VerifyRequest verifies the signature of the request in the value of
+// SignatureHeader, and returns the peer signing it.  The signatures older
+// than the max age of the Authenticator, or seen before, are rejected.
+func (a *Authenticator) VerifyRequest(signature string, method string, uri string, body []byte) (peer.ID, error) {
+	data, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid signature encoding", ErrUnauthenticated)
+	}
+	env, untypedRecord, err := record.ConsumeEnvelope(data, SignedRequestEnvelopeDomain)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	rec, ok := untypedRecord.(*SignedRequest)
+	if !ok {
+		return "", fmt.Errorf("%w: not a signed request", ErrUnauthenticated)
+	}
+	if !rec.PeerID.MatchesPublicKey(env.PublicKey) {
+		return "", fmt.Errorf("%w: pubkey dismatch with peerid", ErrUnauthenticated)
+	}
+	if rec.Method != method || rec.URI != uri {
+		return "", fmt.Errorf("%w: signature is not for %s %s", ErrUnauthenticated, method, uri)
+	}
+	hash := sha256.Sum256(body)
+	if !bytes.Equal(hash[:], rec.BodyHash) {
+		return "", fmt.Errorf("%w: body dismatch with signature", ErrUnauthenticated)
+	}
+
+	now := time.Now()
+	signed := time.Unix(0, rec.Timestamp)
+	if now.Sub(signed) > a.maxAge || signed.Sub(now) > a.maxAge {
+		return "", fmt.Errorf("%w: signature expired", ErrUnauthenticated)
+	}
+	if !a.replays.add(signature, signed.Add(a.maxAge), now) {
+		return "", fmt.Errorf("%w: signature replayed", ErrUnauthenticated)
+	}
+	return rec.PeerID, nil
+}
+
+// replayCache keeps the signatures until they expire, so that each of them
+// is accepted once.
+type replayCache struct {
+	mutex     sync.Mutex
+	seen      map[[sha256.Size]byte]time.Time
+	lastSweep time.Time
+}
+
+// replaySweepInterval is the interval the expired signatures are dropped
+const replaySweepInterval = time.Minute
+
+func newReplayCache() *replayCache {
+	return &replayCache{seen: make(map[[sha256.Size]byte]time.Time)}
+}
+
+// add returns false if the signature was seen
+func (rc *replayCache) add(signature string, expires time.Time, now time.Time) bool {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	if now.Sub(rc.lastSweep) > replaySweepInterval {
+		for k, exp := range rc.seen {
+			if now.After(exp) {
+				delete(rc.seen, k)
+			}
+		}
+		rc.lastSweep = now
+	}
+	k := sha256.Sum256([]byte(signature))
+	if _, seen := rc.seen[k]; seen {
+		return false
+	}
+	rc.seen[k] = expires
+	return true
+}
@@ -0,0 +1,79 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"github.com/kenlabs/pando/pkg/registry/discovery"
+	"math/big"
+	"net"
+	"strings"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+const (
+	// RecordPrefix is the subdomain of the TXT records of a domain identity
+	RecordPrefix = "_pando."
+	// PeerKey is the key of the peer id in the TXT records, e.g.
+	// "pando-peer=12D3KooW..."
+	PeerKey = "pando-peer="
+)
+
+type txtResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Discoverer discovers the providers identified by domain names.  A domain
+// proves the ownership of a peer id by publishing it in a TXT record of the
+// _pando subdomain.  Domains have no balance.
+type Discoverer struct {
+	resolver txtResolver
+}
+
+// NewDiscoverer creates a new DNS Discoverer querying the DNS server at
+// resolverAddr(host:port), or the system resolver if it is empty.
+func NewDiscoverer(resolverAddr string) (*Discoverer, error) {
+	if resolverAddr == "" {
+		return &Discoverer{resolver: net.DefaultResolver}, nil
+	}
+	if _, _, err := net.SplitHostPort(resolverAddr); err != nil {
+		return nil, fmt.Errorf("invalid resolver address: %s", err)
+	}
+
+	return &Discoverer{
+		resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, resolverAddr)
+			},
+		},
+	}, nil
+}
+
+func (d *Discoverer) Discover(ctx context.Context, peerID peer.ID, domain string) (*discovery.Discovered, error) {
+	if !discovery.IsDNSAddr(domain) {
+		return nil, fmt.Errorf("invalid domain: %q", domain)
+	}
+	domain = strings.TrimPrefix(domain, discovery.DNSAddrPrefix)
+
+	records, err := d.resolver.LookupTXT(ctx, RecordPrefix+domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if !strings.HasPrefix(record, PeerKey) {
+			continue
+		}
+		id, err := peer.Decode(strings.TrimSpace(strings.TrimPrefix(record, PeerKey)))
+		if err != nil || id != peerID {
+			continue
+		}
+		return &discovery.Discovered{
+			AddrInfo: peer.AddrInfo{ID: peerID},
+			Type:     discovery.OtherType,
+			Balance:  big.NewInt(0),
+		}, nil
+	}
+	return nil, fmt.Errorf("peer id %s is not published by %s", peerID, domain)
+}
@@ -0,0 +1,57 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"github.com/kenlabs/pando/pkg/registry/discovery"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+	"math/big"
+	"testing"
+)
+
+const testPeerID = "12D3KooWRqmtFv7ccFfjR7RDcevoMEMXdCHNR8JNN8aNiH2dgk8Z"
+
+type mockResolver map[string][]string
+
+func (m mockResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	records, ok := m[name]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return records, nil
+}
+
+func TestDiscover(t *testing.T) {
+	Convey("test discover peer id published by domain", t, func() {
+		peerID, err := peer.Decode(testPeerID)
+		So(err, ShouldBeNil)
+		disco := &Discoverer{resolver: mockResolver{
+			"_pando.example.com": {"v=spf1 -all", PeerKey + testPeerID},
+			"_pando.example.org": {PeerKey + "12D3KooWKw5hu5QcbbFuokt3NrYe7gak5kKHzt8h1FJNqByHQ157"},
+		}}
+
+		data, err := disco.Discover(context.Background(), peerID, "example.com")
+		So(err, ShouldBeNil)
+		So(data, ShouldResemble, &discovery.Discovered{
+			AddrInfo: peer.AddrInfo{ID: peerID},
+			Type:     discovery.OtherType,
+			Balance:  big.NewInt(0),
+		})
+		_, err = disco.Discover(context.Background(), peerID, "dns:example.com")
+		So(err, ShouldBeNil)
+
+		// Published another peer id
+		_, err = disco.Discover(context.Background(), peerID, "example.org")
+		So(err, ShouldNotBeNil)
+		_, err = disco.Discover(context.Background(), peerID, "unknown.example.com")
+		So(err, ShouldNotBeNil)
+		_, err = disco.Discover(context.Background(), peerID, "t01000")
+		So(err, ShouldNotBeNil)
+
+		_, err = NewDiscoverer("8.8.8.8")
+		So(err, ShouldNotBeNil)
+		_, err = NewDiscoverer("8.8.8.8:53")
+		So(err, ShouldBeNil)
+	})
+}
@@ -0,0 +1,187 @@
+package evm
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/kenlabs/pando/pkg/registry/discovery"
+	"golang.org/x/crypto/sha3"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// ProofSeparator separates the EVM account address and the signature proving
+// its ownership in a discovery address
+const ProofSeparator = ":"
+
+// ownershipPrefix prefixes the peer id in the message signed by the account
+const ownershipPrefix = "pando-peer="
+
+// Discoverer discovers the providers identified by EVM account addresses.  The
+// discovery address is the account address followed by the signature of
+// OwnershipMessage by the account, e.g. 0x5290...9EE7:0x1b3c...1c, so that a
+// provider can not claim the balance of an account it does not own.  Only the
+// balance is discovered, in wei.
+type Discoverer struct {
+	endpoint string
+	client   *http.Client
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	Result string    `json:"result"`
+	Error  *rpcError `json:"error"`
+}
+
+// NewDiscoverer creates a new EVM Discoverer using the JSON-RPC endpoint
+func NewDiscoverer(endpoint string) (*Discoverer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported JSON-RPC endpoint scheme: %q", u.Scheme)
+	}
+
+	return &Discoverer{
+		endpoint: u.String(),
+		client:   &http.Client{},
+	}, nil
+}
+
+func (d *Discoverer) Discover(ctx context.Context, peerID peer.ID, discoveryAddr string) (*discovery.Discovered, error) {
+	if !discovery.IsEVMAddr(discoveryAddr) {
+		return nil, fmt.Errorf("invalid EVM address: %q", discoveryAddr)
+	}
+	i := strings.Index(discoveryAddr, ProofSeparator)
+	if i < 0 {
+		return nil, fmt.Errorf("missing ownership proof of EVM address: %q", discoveryAddr)
+	}
+	accountAddr := discoveryAddr[:i]
+	if err := VerifyOwnership(peerID, accountAddr, discoveryAddr[i+1:]); err != nil {
+		return nil, err
+	}
+	balance, err := d.getBalance(ctx, accountAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &discovery.Discovered{
+		AddrInfo: peer.AddrInfo{ID: peerID},
+		Type:     discovery.OtherType,
+		Balance:  big.NewInt(0),
+		Balances: map[string]*big.Int{discovery.EVMChain: balance},
+	}, nil
+}
+
+// OwnershipMessage returns the message signed by an EVM account to prove it
+// is owned by the peer
+func OwnershipMessage(peerID peer.ID) string {
+	return ownershipPrefix + peerID.String()
+}
+
+// VerifyOwnership checks that the hex encoded signature is of the ownership
+// message of the peer, signed by the account as an Ethereum personal message
+// (EIP-191), which is what wallets do for personal_sign.
+func VerifyOwnership(peerID peer.ID, accountAddr string, signature string) error {
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil || len(sig) != 65 {
+		return fmt.Errorf("invalid ownership proof of EVM address %s", accountAddr)
+	}
+	// [R || S || V] to the compact format [V || R || S], V is 27 or 28 in
+	// Ethereum signatures, or the recovery id only from some signers
+	v := sig[64]
+	if v < 27 {
+		v += 27
+	}
+	if v != 27 && v != 28 {
+		return fmt.Errorf("invalid ownership proof of EVM address %s", accountAddr)
+	}
+	compact := append([]byte{v}, sig[:64]...)
+
+	pubKey, _, err := ecdsa.RecoverCompact(compact, personalMessageHash(OwnershipMessage(peerID)))
+	if err != nil {
+		return fmt.Errorf("invalid ownership proof of EVM address %s: %v", accountAddr, err)
+	}
+	if !strings.EqualFold("0x"+hex.EncodeToString(pubKeyAddr(pubKey.SerializeUncompressed())), accountAddr) {
+		return fmt.Errorf("EVM address %s is not owned by peer %s", accountAddr, peerID)
+	}
+	return nil
+}
+
+// personalMessageHash is the hash signed for an Ethereum personal message
+func personalMessageHash(msg string) []byte {
+	return keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(msg), msg)))
+}
+
+// pubKeyAddr returns the account address of an uncompressed public key
+func pubKeyAddr(pubKey []byte) []byte {
+	return keccak256(pubKey[1:])[12:]
+}
+
+func keccak256(data []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func (d *Discoverer) getBalance(ctx context.Context, accountAddr string) (*big.Int, error) {
+	reqBody, err := json.Marshal(&rpcRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  "eth_getBalance",
+		Params:  []interface{}{accountAddr, "latest"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.endpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JSON-RPC endpoint responded %s", res.Status)
+	}
+	var rpcRes rpcResponse
+	if err = json.NewDecoder(res.Body).Decode(&rpcRes); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON-RPC response: %s", err)
+	}
+	if rpcRes.Error != nil {
+		return nil, fmt.Errorf("eth_getBalance failed: %d %s", rpcRes.Error.Code, rpcRes.Error.Message)
+	}
+
+	balance, ok := big.NewInt(0).SetString(strings.TrimPrefix(rpcRes.Result, "0x"), 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid balance: %q", rpcRes.Result)
+	}
+	return balance, nil
+}
@@ -0,0 +1,108 @@
+package evm
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/kenlabs/pando/pkg/registry/discovery"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// signOwnership signs the ownership message of the peer as personal_sign does
+func signOwnership(key *secp256k1.PrivateKey, peerID peer.ID) string {
+	compact := ecdsa.SignCompact(key, personalMessageHash(OwnershipMessage(peerID)), false)
+	// [V || R || S] to [R || S || V]
+	sig := append(compact[1:], compact[0])
+	return "0x" + hex.EncodeToString(sig)
+}
+
+func keyAddr(key *secp256k1.PrivateKey) string {
+	return "0x" + hex.EncodeToString(pubKeyAddr(key.PubKey().SerializeUncompressed()))
+}
+
+func TestDiscover(t *testing.T) {
+	Convey("test discover balance of EVM account", t, func() {
+		key, err := secp256k1.GeneratePrivateKey()
+		So(err, ShouldBeNil)
+		accountAddr := keyAddr(key)
+
+		var got rpcRequest
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewDecoder(r.Body).Decode(&got)
+			if got.Params[0] != accountAddr {
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"unknown account"}}`))
+				return
+			}
+			// 5 * 10^18
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x4563918244f40000"}`))
+		}))
+		defer srv.Close()
+
+		peerID, err := peer.Decode("12D3KooWRqmtFv7ccFfjR7RDcevoMEMXdCHNR8JNN8aNiH2dgk8Z")
+		So(err, ShouldBeNil)
+		otherPeerID, err := peer.Decode("12D3KooWNU48MUrPEoYh77k99RbskgftfmSm3CdkonijcM5VehS9")
+		So(err, ShouldBeNil)
+		disco, err := NewDiscoverer(srv.URL)
+		So(err, ShouldBeNil)
+		ctx := context.Background()
+
+		Convey("discover the balance in wei of the account owned by the peer", func() {
+			data, err := disco.Discover(ctx, peerID, accountAddr+ProofSeparator+signOwnership(key, peerID))
+			So(err, ShouldBeNil)
+			So(got.Method, ShouldEqual, "eth_getBalance")
+			So(data, ShouldResemble, &discovery.Discovered{
+				AddrInfo: peer.AddrInfo{ID: peerID},
+				Type:     discovery.OtherType,
+				Balance:  big.NewInt(0),
+				Balances: map[string]*big.Int{
+					discovery.EVMChain: big.NewInt(0).Mul(big.NewInt(5), big.NewInt(1e18)),
+				},
+			})
+
+			// the address is case-insensitive
+			So(VerifyOwnership(peerID, "0x"+strings.ToUpper(accountAddr[2:]), signOwnership(key, peerID)), ShouldBeNil)
+		})
+
+		Convey("reject the accounts not proven to be owned by the peer", func() {
+			// no proof
+			_, err := disco.Discover(ctx, peerID, accountAddr)
+			So(err, ShouldNotBeNil)
+			// proof of another peer
+			_, err = disco.Discover(ctx, peerID, accountAddr+ProofSeparator+signOwnership(key, otherPeerID))
+			So(err, ShouldNotBeNil)
+			// signed by another account
+			otherKey, err := secp256k1.GeneratePrivateKey()
+			So(err, ShouldBeNil)
+			_, err = disco.Discover(ctx, peerID, accountAddr+ProofSeparator+signOwnership(otherKey, peerID))
+			So(err, ShouldNotBeNil)
+			// malformed proof
+			_, err = disco.Discover(ctx, peerID, accountAddr+ProofSeparator+"0x"+strings.Repeat("00", 65))
+			So(err, ShouldNotBeNil)
+			So(got.Method, ShouldBeEmpty)
+		})
+
+		Convey("fail on invalid addresses and RPC errors", func() {
+			_, err := disco.Discover(ctx, otherPeerID, keyAddr(key)+"00")
+			So(err, ShouldNotBeNil)
+			_, err = disco.Discover(ctx, peerID, "t01000")
+			So(err, ShouldNotBeNil)
+
+			unknownKey, err := secp256k1.GeneratePrivateKey()
+			So(err, ShouldBeNil)
+			_, err = disco.Discover(ctx, peerID, keyAddr(unknownKey)+ProofSeparator+signOwnership(unknownKey, peerID))
+			So(err, ShouldNotBeNil)
+			So(got.Method, ShouldEqual, "eth_getBalance")
+
+			_, err = NewDiscoverer("ws://localhost")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
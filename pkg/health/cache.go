@@ -0,0 +1,115 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Cache keeps the result of a check for an interval, so that the probes
+// polling the health do not repeat the costly checks, such as of the remote
+// servers, on each request.
+type Cache struct {
+	interval time.Duration
+	timeout  time.Duration
+	check    func(ctx context.Context) *Result
+
+	mutex   sync.Mutex
+	result  *Result
+	checked time.Time
+	// refreshed is closed when the running check is done, nil if no check
+	// is running
+	refreshed chan struct{}
+}
+
+// NewCache creates a Cache running check at most once per interval, each
+// check is given the timeout.
+func NewCache(interval, timeout time.Duration, check func(ctx context.Context) *Result) *Cache {
+	return &Cache{
+		interval: interval,
+		timeout:  timeout,
+		check:    check,
+	}
+}
+
+// Check returns the cached result, or checks again if it is older than the
+// interval.  The concurrent callers wait for the same check, which runs with
+// its own timeout, so that a caller giving up does not fail the check for
+// the others.  The callers giving up get a failure that is not cached.
+func (c *Cache) Check(ctx context.Context) *Result {
+	c.mutex.Lock()
+	if c.result != nil && time.Since(c.checked) < c.interval {
+		result := c.result
+		c.mutex.Unlock()
+		return result
+	}
+	if c.refreshed == nil {
+		c.refreshed = make(chan struct{})
+		go c.refresh(c.refreshed)
+	}
+	refreshed := c.refreshed
+	c.mutex.Unlock()
+
+	select {
+	case <-refreshed:
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		return c.result
+	case <-ctx.Done():
+		return Fail(ctx.Err(), nil)
+	}
+}
+
+func (c *Cache) refresh(done chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	result := c.check(ctx)
+
+	c.mutex.Lock()
+	c.result = result
+	c.checked = time.Now()
+	c.refreshed = nil
+	c.mutex.Unlock()
+	close(done)
+}
+
+// Reachable returns a check of the servers of the urls responding to HEAD
+// requests, with any status.  Its detail maps the urls to ok or to their
+// errors, the empty urls are skipped.
+func Reachable(urls ...string) func(ctx context.Context) *Result {
+	return func(ctx context.Context) *Result {
+		detail := make(map[string]string)
+		var unreachable string
+		for _, url := range urls {
+			if url == "" {
+				continue
+			}
+			if err := headRequest(ctx, url); err != nil {
+				detail[url] = err.Error()
+				if unreachable == "" {
+					unreachable = url
+				}
+				continue
+			}
+			detail[url] = string(StatusOK)
+		}
+		if unreachable != "" {
+			return Fail(fmt.Errorf("%s is not reachable", unreachable), detail)
+		}
+		return OK(detail)
+	}
+}
+
+func headRequest(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
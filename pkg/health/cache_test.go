@@ -0,0 +1,77 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCache(t *testing.T) {
+	Convey("TestCache", t, func() {
+		ctx := context.Background()
+		checks := 0
+		var release chan struct{}
+		cache := NewCache(time.Hour, time.Second, func(context.Context) *Result {
+			if release != nil {
+				<-release
+			}
+			checks++
+			return OK(checks)
+		})
+
+		Convey("should check once per interval", func() {
+			So(cache.Check(ctx).Detail, ShouldEqual, 1)
+			So(cache.Check(ctx).Detail, ShouldEqual, 1)
+			So(checks, ShouldEqual, 1)
+
+			cache.interval = 0
+			So(cache.Check(ctx).Detail, ShouldEqual, 2)
+		})
+
+		Convey("should not cache the callers giving up", func() {
+			release = make(chan struct{})
+			canceled, cancel := context.WithCancel(ctx)
+			cancel()
+			result := cache.Check(canceled)
+			So(result.Status, ShouldEqual, StatusFail)
+			So(result.Message, ShouldEqual, context.Canceled.Error())
+
+			// the check goes on for the next callers
+			close(release)
+			So(cache.Check(ctx).Detail, ShouldEqual, 1)
+			So(checks, ShouldEqual, 1)
+		})
+	})
+}
+
+func TestReachable(t *testing.T) {
+	Convey("TestReachable", t, func() {
+		ctx := context.Background()
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+
+		Convey("should be ok for the servers responding with any status", func() {
+			result := Reachable(server.URL, "")(ctx)
+			So(result.Status, ShouldEqual, StatusOK)
+			So(result.Detail, ShouldResemble, map[string]string{server.URL: string(StatusOK)})
+			So(requests, ShouldEqual, 1)
+		})
+
+		Convey("should fail for the servers not responding", func() {
+			closed := httptest.NewServer(http.NotFoundHandler())
+			closed.Close()
+			result := Reachable(server.URL, closed.URL)(ctx)
+			So(result.Status, ShouldEqual, StatusFail)
+			So(result.Message, ShouldEqual, closed.URL+" is not reachable")
+			So(result.Detail.(map[string]string)[server.URL], ShouldEqual, string(StatusOK))
+		})
+	})
+}
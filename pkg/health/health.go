@@ -0,0 +1,144 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Status is the status of a component or of the whole service
+type Status string
+
+const (
+	StatusOK Status = "ok"
+	// StatusWarn is a component working with a problem, such as a backlog,
+	// which does not make the service unready.
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// Result is the result of checking a component
+type Result struct {
+	Status  Status
+	Message string
+	Detail  interface{}
+}
+
+func OK(detail interface{}) *Result {
+	return &Result{Status: StatusOK, Detail: detail}
+}
+
+func Warn(message string, detail interface{}) *Result {
+	return &Result{Status: StatusWarn, Message: message, Detail: detail}
+}
+
+func Fail(err error, detail interface{}) *Result {
+	return &Result{Status: StatusFail, Message: err.Error(), Detail: detail}
+}
+
+// Check checks a component.  The service is not ready if a critical
+// component fails, the failures of the others are reported as warnings.
+type Check struct {
+	Name     string
+	Critical bool
+	Check    func(ctx context.Context) *Result
+}
+
+// Component is the status of a checked component with the latency of its
+// check
+type Component struct {
+	Name     string
+	Status   Status
+	Critical bool
+	Latency  string
+	Message  string      `json:",omitempty"`
+	Detail   interface{} `json:",omitempty"`
+}
+
+// Report is the status of the service and its components
+type Report struct {
+	Status     Status
+	Time       time.Time
+	Components []*Component `json:",omitempty"`
+}
+
+// Ready checks if the service can serve the requests
+func (r *Report) Ready() bool {
+	return r.Status != StatusFail
+}
+
+// Summary returns the report without its components, for the callers not
+// allowed to see their details.
+func (r *Report) Summary() *Report {
+	return &Report{
+		Status: r.Status,
+		Time:   r.Time,
+	}
+}
+
+// Run runs the checks concurrently, each of them fails if it does not finish
+// in timeout, 0 for no timeout.  The components are in the order of the
+// checks.
+func Run(ctx context.Context, timeout time.Duration, checks []Check) *Report {
+	report := &Report{
+		Status:     StatusOK,
+		Time:       time.Now(),
+		Components: make([]*Component, len(checks)),
+	}
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			report.Components[i] = run(ctx, timeout, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, component := range report.Components {
+		switch {
+		case component.Status == StatusFail && component.Critical:
+			report.Status = StatusFail
+		case component.Status != StatusOK && report.Status == StatusOK:
+			report.Status = StatusWarn
+		}
+	}
+	return report
+}
+
+func run(ctx context.Context, timeout time.Duration, check Check) *Component {
+	var cctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		cctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		cctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan *Result, 1)
+	go func() {
+		done <- check.Check(cctx)
+	}()
+	var result *Result
+	select {
+	case result = <-done:
+	case <-cctx.Done():
+		// The checks not following ctx are left behind
+		result = &Result{Status: StatusFail, Message: "check timed out"}
+	}
+
+	status := result.Status
+	if status == StatusFail && !check.Critical {
+		status = StatusWarn
+	}
+	return &Component{
+		Name:     check.Name,
+		Status:   status,
+		Critical: check.Critical,
+		Latency:  time.Since(start).String(),
+		Message:  result.Message,
+		Detail:   result.Detail,
+	}
+}
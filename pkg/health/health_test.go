@@ -0,0 +1,52 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRun(t *testing.T) {
+	Convey("TestRun", t, func() {
+		ctx := context.Background()
+		ok := func(context.Context) *Result { return OK("detail") }
+		fail := func(context.Context) *Result { return Fail(errors.New("down"), nil) }
+		slow := func(ctx context.Context) *Result {
+			<-ctx.Done()
+			return OK(nil)
+		}
+
+		Convey("should be ok if all the components are ok", func() {
+			report := Run(ctx, time.Second, []Check{{Name: "a", Critical: true, Check: ok}, {Name: "b", Check: ok}})
+			So(report.Status, ShouldEqual, StatusOK)
+			So(report.Ready(), ShouldBeTrue)
+			So(report.Components, ShouldHaveLength, 2)
+			So(report.Components[0].Name, ShouldEqual, "a")
+			So(report.Components[0].Detail, ShouldEqual, "detail")
+			So(report.Components[0].Latency, ShouldNotBeEmpty)
+		})
+
+		Convey("should warn for the failures of the non-critical components", func() {
+			report := Run(ctx, time.Second, []Check{{Name: "a", Critical: true, Check: ok}, {Name: "b", Check: fail}})
+			So(report.Status, ShouldEqual, StatusWarn)
+			So(report.Ready(), ShouldBeTrue)
+			So(report.Components[1].Status, ShouldEqual, StatusWarn)
+			So(report.Components[1].Message, ShouldEqual, "down")
+		})
+
+		Convey("should fail for the failures of the critical components", func() {
+			report := Run(ctx, time.Second, []Check{{Name: "a", Critical: true, Check: fail}, {Name: "b", Check: ok}})
+			So(report.Status, ShouldEqual, StatusFail)
+			So(report.Ready(), ShouldBeFalse)
+		})
+
+		Convey("should fail the checks over the timeout", func() {
+			report := Run(ctx, 10*time.Millisecond, []Check{{Name: "a", Critical: true, Check: slow}})
+			So(report.Status, ShouldEqual, StatusFail)
+			So(report.Components[0].Message, ShouldEqual, "check timed out")
+		})
+	})
+}
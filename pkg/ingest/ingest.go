@@ -0,0 +1,342 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/kenlabs/pando/pkg/option"
+	"github.com/kenlabs/pando/pkg/util/log"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+var logger = log.NewSubsystemLogger()
+
+const (
+	// logKeyPath is where the events are persisted, keyed by their sequences
+	logKeyPath = "/ingest/log"
+	// snapShotHeightKey is the next snapshot height to check for the heights
+	// assigned to the ingested metadata
+	snapShotHeightKey = "/ingest/snapshot-height"
+
+	// eventBufferSize is the number of events buffered for each follower, the
+	// events dropped for a slow follower are read back from the log.
+	eventBufferSize = 256
+	// pruneBatchSize is the max number of events pruned at once
+	pruneBatchSize = 1000
+)
+
+// EventType is the kind of ingestion event
+type EventType string
+
+const (
+	// EventIngested is appended when a metadata is received and stored
+	EventIngested EventType = "ingested"
+	// EventSnapShotted is appended when a metadata is included in a snapshot,
+	// SnapShotHeight is the height of the snapshot
+	EventSnapShotted EventType = "snapshotted"
+)
+
+// Event is an ingestion event of a metadata.  Time is the receive time of the
+// ingested events, and the created time of the snapshot of the snapshotted
+// events.
+type Event struct {
+	// Seq is the position of the event in the log, starting from 1
+	Seq            uint64
+	Type           EventType
+	Provider       peer.ID
+	Cid            cid.Cid
+	Collection     string `json:",omitempty"`
+	Time           time.Time
+	SnapShotHeight *uint64 `json:",omitempty"`
+}
+
+// Filter selects the events followed, empty fields do not filter
+type Filter struct {
+	Provider   peer.ID
+	Collection string
+	// Allowed checks if the follower can read the event, such as by the access
+	// rules of the collections.
+	Allowed func(e *Event) bool
+}
+
+func (f *Filter) match(e *Event) bool {
+	if f == nil {
+		return true
+	}
+	if f.Provider != "" && e.Provider != f.Provider {
+		return false
+	}
+	if f.Collection != "" && e.Collection != f.Collection {
+		return false
+	}
+	return f.Allowed == nil || f.Allowed(e)
+}
+
+// Log persists the ingestion events in the datastore, and delivers them to
+// the followers from a cursor and then live.
+type Log struct {
+	ds        datastore.Datastore
+	maxEvents uint64
+
+	mutex sync.Mutex
+	// first and next are the sequences of the oldest event kept and of the
+	// next event appended
+	first uint64
+	next  uint64
+	subs  map[chan *Event]struct{}
+	// pending are the collections of the ingested metadata not snapshotted
+	// yet, so that the snapshotted events do not read the blocks back
+	pending map[cid.Cid]string
+
+	snapshots SnapShotSource
+	blocks    BlockGetter
+	poll      time.Duration
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// New creates a Log persisted in ds, the snapshots are checked for the heights
+// assigned to the ingested metadata until Close is called.
+func New(ctx context.Context, ds datastore.Datastore, snapshots SnapShotSource, blocks BlockGetter, cfg *option.IngestLog) (*Log, error) {
+	if cfg.MaxEvents <= 0 {
+		return nil, fmt.Errorf("invalid max events of ingest log: %d", cfg.MaxEvents)
+	}
+	l := &Log{
+		ds:        ds,
+		maxEvents: uint64(cfg.MaxEvents),
+		first:     1,
+		next:      1,
+		subs:      make(map[chan *Event]struct{}),
+		pending:   make(map[cid.Cid]string),
+		snapshots: snapshots,
+		blocks:    blocks,
+		poll:      time.Duration(cfg.SnapShotPollIntervalInDurationFormat()),
+		done:      make(chan struct{}),
+	}
+	if err := l.load(ctx); err != nil {
+		return nil, err
+	}
+
+	cctx, cancel := context.WithCancel(context.Background())
+	l.cancel = cancel
+	if snapshots == nil || l.poll <= 0 {
+		close(l.done)
+		return l, nil
+	}
+	// The height is loaded before returning, so that the snapshots created
+	// after New are not skipped.
+	next, err := l.loadSnapShotHeight(ctx)
+	if err != nil {
+		logger.Errorw("cannot load snapshot height of ingest log, snapshotted events are disabled", "err", err)
+		close(l.done)
+		return l, nil
+	}
+	go l.watchSnapShots(cctx, next)
+	return l, nil
+}
+
+// load finds the first and the last events kept in the log
+func (l *Log) load(ctx context.Context) error {
+	for _, order := range []query.Order{query.OrderByKey{}, query.OrderByKeyDescending{}} {
+		results, err := l.ds.Query(ctx, query.Query{
+			Prefix:   logKeyPath,
+			KeysOnly: true,
+			Orders:   []query.Order{order},
+			Limit:    1,
+		})
+		if err != nil {
+			return fmt.Errorf("cannot query ingest log: %v", err)
+		}
+		entries, err := results.Rest()
+		if err != nil {
+			return fmt.Errorf("cannot read ingest log: %v", err)
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		seq, err := seqFromKey(entries[0].Key)
+		if err != nil {
+			return err
+		}
+		if _, ok := order.(query.OrderByKey); ok {
+			l.first = seq
+		} else {
+			l.next = seq + 1
+		}
+	}
+	logger.Infow("loaded ingest log", "first", l.first, "last", l.next-1)
+	return nil
+}
+
+// Ingested appends the ingested event of a metadata received now
+func (l *Log) Ingested(ctx context.Context, metaCid cid.Cid, provider peer.ID, collection string) error {
+	l.mutex.Lock()
+	if len(l.pending) < defaultPendingSize {
+		l.pending[metaCid] = collection
+	}
+	l.mutex.Unlock()
+
+	return l.append(ctx, &Event{
+		Type:       EventIngested,
+		Provider:   provider,
+		Cid:        metaCid,
+		Collection: collection,
+		Time:       time.Now(),
+	})
+}
+
+func (l *Log) append(ctx context.Context, e *Event) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	e.Seq = l.next
+	value, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("cannot encode ingest event: %v", err)
+	}
+	if err = l.ds.Put(ctx, seqKey(e.Seq), value); err != nil {
+		return fmt.Errorf("cannot save ingest event: %v", err)
+	}
+	l.next++
+	l.prune(ctx)
+
+	for ch := range l.subs {
+		select {
+		case ch <- e:
+		default:
+			// The follower reads the dropped events back from the log
+		}
+	}
+	return nil
+}
+
+// prune deletes the oldest events over maxEvents, it must be called with the
+// mutex held.
+func (l *Log) prune(ctx context.Context) {
+	for n := 0; l.next-l.first > l.maxEvents && n < pruneBatchSize; n++ {
+		if err := l.ds.Delete(ctx, seqKey(l.first)); err != nil {
+			logger.Warnw("failed to prune ingest event", "seq", l.first, "err", err)
+			return
+		}
+		l.first++
+	}
+}
+
+// Last returns the sequence of the last event, 0 if there is none
+func (l *Log) Last() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.next - 1
+}
+
+// Follow delivers the events after the sequence after that match the filter,
+// first from the log and then live, until ctx is done or deliver fails.  The
+// sequences of the events are the cursors to resume from.
+func (l *Log) Follow(ctx context.Context, after uint64, filter *Filter, deliver func(e *Event) error) error {
+	ch := make(chan *Event, eventBufferSize)
+	l.mutex.Lock()
+	l.subs[ch] = struct{}{}
+	l.mutex.Unlock()
+	defer func() {
+		l.mutex.Lock()
+		delete(l.subs, ch)
+		l.mutex.Unlock()
+	}()
+
+	last, err := l.replay(ctx, after, 0, filter, deliver)
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case e := <-ch:
+			if e.Seq <= last {
+				continue
+			}
+			if e.Seq > last+1 {
+				// The events in between are dropped from the buffer
+				if last, err = l.replay(ctx, last, e.Seq-1, filter, deliver); err != nil {
+					return err
+				}
+			}
+			last = e.Seq
+			if filter.match(e) {
+				if err = deliver(e); err != nil {
+					return err
+				}
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// replay delivers the events in the log after the sequence after up to the
+// sequence until, 0 for the last one, and returns the last sequence read.
+func (l *Log) replay(ctx context.Context, after, until uint64, filter *Filter, deliver func(e *Event) error) (uint64, error) {
+	l.mutex.Lock()
+	first, next := l.first, l.next
+	l.mutex.Unlock()
+	if until == 0 || until >= next {
+		until = next - 1
+	}
+	if after+1 < first {
+		// The events pruned are skipped
+		after = first - 1
+	}
+
+	for seq := after + 1; seq <= until; seq++ {
+		if err := ctx.Err(); err != nil {
+			return after, err
+		}
+		value, err := l.ds.Get(ctx, seqKey(seq))
+		if err != nil {
+			if err == datastore.ErrNotFound {
+				// Pruned while replaying
+				continue
+			}
+			return after, fmt.Errorf("cannot read ingest event %d: %v", seq, err)
+		}
+		e := &Event{}
+		if err = json.Unmarshal(value, e); err != nil {
+			return after, fmt.Errorf("cannot decode ingest event %d: %v", seq, err)
+		}
+		if filter.match(e) {
+			if err = deliver(e); err != nil {
+				return after, err
+			}
+		}
+	}
+	if until > after {
+		return until, nil
+	}
+	return after, nil
+}
+
+// Close stops checking the snapshots
+func (l *Log) Close() error {
+	l.cancel()
+	<-l.done
+	return nil
+}
+
+// The keys are zero padded to be ordered by sequence
+func seqKey(seq uint64) datastore.Key {
+	return datastore.NewKey(fmt.Sprintf("%s/%020d", logKeyPath, seq))
+}
+
+func seqFromKey(key string) (uint64, error) {
+	seq, err := strconv.ParseUint(strings.TrimPrefix(key, logKeyPath+"/"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ingest log key %s: %v", key, err)
+	}
+	return seq, nil
+}
@@ -0,0 +1,194 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/kenlabs/pando-store/pkg/types/cbortypes"
+	"github.com/kenlabs/pando-store/pkg/types/store"
+	"github.com/kenlabs/pando/pkg/option"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multihash"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var errStop = errors.New("stop")
+
+type testSnapShots struct {
+	mutex     sync.Mutex
+	list      store.SnapShotList
+	snapshots map[cid.Cid]*cbortypes.SnapShot
+}
+
+func (s *testSnapShots) GetSnapShotList(_ context.Context) (*store.SnapShotList, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	list := s.list
+	list.List = append(list.List[:0:0], s.list.List...)
+	return &list, nil
+}
+
+func (s *testSnapShots) GetSnapShotByCid(_ context.Context, c cid.Cid) (*cbortypes.SnapShot, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	snapshot, ok := s.snapshots[c]
+	if !ok {
+		return nil, datastore.ErrNotFound
+	}
+	return snapshot, nil
+}
+
+func (s *testSnapShots) add(c cid.Cid, snapshot *cbortypes.SnapShot) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.snapshots[c] = snapshot
+	s.list.List = append(s.list.List, struct {
+		CreatedTime uint64
+		SnapShotCid cid.Cid
+	}{CreatedTime: uint64(time.Now().UnixNano()), SnapShotCid: c})
+	s.list.Length = len(s.list.List)
+}
+
+func testCid(s string) cid.Cid {
+	mh, err := multihash.Sum([]byte(s), multihash.SHA2_256, -1)
+	if err != nil {
+		panic(err)
+	}
+	return cid.NewCidV1(cid.Raw, mh)
+}
+
+func testConfig(maxEvents int, poll string) *option.IngestLog {
+	return &option.IngestLog{MaxEvents: maxEvents, SnapShotPollInterval: poll}
+}
+
+// collect follows the log until n events are delivered
+func collect(l *Log, after uint64, filter *Filter, n int) ([]*Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var events []*Event
+	err := l.Follow(ctx, after, filter, func(e *Event) error {
+		events = append(events, e)
+		if len(events) == n {
+			return errStop
+		}
+		return nil
+	})
+	if err == errStop {
+		err = nil
+	}
+	return events, err
+}
+
+func TestLog(t *testing.T) {
+	Convey("TestLog", t, func() {
+		ctx := context.Background()
+		ds := dssync.MutexWrap(datastore.NewMapDatastore())
+		provider1, _ := peer.Decode("12D3KooWNtUworDmrdTUjrPrwvE5ejKNMn8mnDkFFcuTzKoeDykp")
+		provider2, _ := peer.Decode("12D3KooWSS3sEujyAXB9SWUvVtQZmxH6vTi3NYKZaZsAnvN8EAJs")
+
+		Convey("should replay the events from the cursor and filter them", func() {
+			l, err := New(ctx, ds, nil, nil, testConfig(100, "0s"))
+			So(err, ShouldBeNil)
+			defer l.Close()
+			So(l.Last(), ShouldEqual, 0)
+
+			So(l.Ingested(ctx, testCid("a"), provider1, "c1"), ShouldBeNil)
+			So(l.Ingested(ctx, testCid("b"), provider2, "c1"), ShouldBeNil)
+			So(l.Ingested(ctx, testCid("c"), provider1, "c2"), ShouldBeNil)
+			So(l.Last(), ShouldEqual, 3)
+
+			events, err := collect(l, 0, nil, 3)
+			So(err, ShouldBeNil)
+			So(len(events), ShouldEqual, 3)
+			So(events[0].Seq, ShouldEqual, 1)
+			So(events[0].Type, ShouldEqual, EventIngested)
+			So(events[0].Cid, ShouldResemble, testCid("a"))
+			So(events[2].Collection, ShouldEqual, "c2")
+
+			events, err = collect(l, 1, &Filter{Provider: provider1}, 1)
+			So(err, ShouldBeNil)
+			So(events[0].Seq, ShouldEqual, 3)
+
+			events, err = collect(l, 0, &Filter{
+				Collection: "c1",
+				Allowed:    func(e *Event) bool { return e.Provider == provider2 },
+			}, 1)
+			So(err, ShouldBeNil)
+			So(events[0].Seq, ShouldEqual, 2)
+		})
+
+		Convey("should deliver the live events after the replayed ones", func() {
+			l, err := New(ctx, ds, nil, nil, testConfig(100, "0s"))
+			So(err, ShouldBeNil)
+			defer l.Close()
+			So(l.Ingested(ctx, testCid("a"), provider1, "c1"), ShouldBeNil)
+
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				for _, s := range []string{"b", "c"} {
+					_ = l.Ingested(ctx, testCid(s), provider1, "c1")
+				}
+			}()
+			events, err := collect(l, 0, nil, 3)
+			So(err, ShouldBeNil)
+			So(len(events), ShouldEqual, 3)
+			for i, e := range events {
+				So(e.Seq, ShouldEqual, i+1)
+			}
+		})
+
+		Convey("should prune the oldest events and keep the sequences at restart", func() {
+			l, err := New(ctx, ds, nil, nil, testConfig(2, "0s"))
+			So(err, ShouldBeNil)
+			for _, s := range []string{"a", "b", "c", "d"} {
+				So(l.Ingested(ctx, testCid(s), provider1, "c1"), ShouldBeNil)
+			}
+			events, err := collect(l, 0, nil, 2)
+			So(err, ShouldBeNil)
+			So(events[0].Seq, ShouldEqual, 3)
+			So(events[1].Seq, ShouldEqual, 4)
+			So(l.Close(), ShouldBeNil)
+
+			l, err = New(ctx, ds, nil, nil, testConfig(2, "0s"))
+			So(err, ShouldBeNil)
+			defer l.Close()
+			So(l.Last(), ShouldEqual, 4)
+			So(l.Ingested(ctx, testCid("e"), provider1, "c1"), ShouldBeNil)
+			So(l.Last(), ShouldEqual, 5)
+		})
+
+		Convey("should append the snapshotted events of the new snapshots", func() {
+			snapshots := &testSnapShots{snapshots: make(map[cid.Cid]*cbortypes.SnapShot)}
+			// The snapshots before the first start are not replayed
+			snapshots.add(testCid("s0"), &cbortypes.SnapShot{})
+
+			l, err := New(ctx, ds, snapshots, nil, testConfig(100, "10ms"))
+			So(err, ShouldBeNil)
+			defer l.Close()
+			So(l.Ingested(ctx, testCid("a"), provider1, "c1"), ShouldBeNil)
+			snapshots.add(testCid("s1"), &cbortypes.SnapShot{
+				Update: map[string]*cbortypes.Metalist{
+					provider1.String(): {MetaList: []cid.Cid{testCid("a")}},
+				},
+			})
+
+			events, err := collect(l, 0, &Filter{Collection: "c1"}, 2)
+			So(err, ShouldBeNil)
+			So(events[1].Type, ShouldEqual, EventSnapShotted)
+			So(events[1].Cid, ShouldResemble, testCid("a"))
+			So(events[1].Provider, ShouldEqual, provider1)
+			So(*events[1].SnapShotHeight, ShouldEqual, 1)
+		})
+
+		Convey("should fail with invalid max events", func() {
+			_, err := New(ctx, ds, nil, nil, testConfig(0, "0s"))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,179 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	_ "github.com/ipld/go-ipld-prime/codec/dagcbor"
+	_ "github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/multicodec"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/kenlabs/pando-store/pkg/types/cbortypes"
+	"github.com/kenlabs/pando-store/pkg/types/store"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// defaultPendingSize bounds the number of collections of the metadata kept
+// until they are snapshotted, the others are read from the blocks.
+const defaultPendingSize = 100000
+
+// SnapShotSource reads the snapshots, such as snapshotstore.SnapShotStore
+type SnapShotSource interface {
+	GetSnapShotList(ctx context.Context) (*store.SnapShotList, error)
+	GetSnapShotByCid(ctx context.Context, c cid.Cid) (*cbortypes.SnapShot, error)
+}
+
+// BlockGetter reads the metadata blocks for their collections
+type BlockGetter interface {
+	Get(ctx context.Context, c cid.Cid) ([]byte, error)
+}
+
+// watchSnapShots appends the snapshotted events of the snapshots from the
+// height next
+func (l *Log) watchSnapShots(ctx context.Context, next uint64) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			next = l.checkSnapShots(ctx, next)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// loadSnapShotHeight returns the next snapshot height to check.  The log
+// starts from the current snapshots at the first start, the metadata of the
+// earlier snapshots are not replayed.
+func (l *Log) loadSnapShotHeight(ctx context.Context) (uint64, error) {
+	value, err := l.ds.Get(ctx, datastore.NewKey(snapShotHeightKey))
+	if err == nil {
+		if len(value) != 8 {
+			return 0, fmt.Errorf("invalid snapshot height of ingest log")
+		}
+		return binary.BigEndian.Uint64(value), nil
+	}
+	if !errors.Is(err, datastore.ErrNotFound) {
+		return 0, err
+	}
+	list, err := l.snapshots.GetSnapShotList(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var next uint64
+	if list != nil {
+		next = uint64(len(list.List))
+	}
+	return next, l.saveSnapShotHeight(ctx, next)
+}
+
+func (l *Log) saveSnapShotHeight(ctx context.Context, next uint64) error {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, next)
+	return l.ds.Put(ctx, datastore.NewKey(snapShotHeightKey), value)
+}
+
+// checkSnapShots appends the snapshotted events of the snapshots from the
+// height next, and returns the next height to check.
+func (l *Log) checkSnapShots(ctx context.Context, next uint64) uint64 {
+	list, err := l.snapshots.GetSnapShotList(ctx)
+	if err != nil {
+		logger.Warnw("failed to get snapshot list for ingest log", "err", err)
+		return next
+	}
+	if list == nil {
+		return next
+	}
+	for ; next < uint64(len(list.List)); next++ {
+		entry := list.List[next]
+		snapshot, err := l.snapshots.GetSnapShotByCid(ctx, entry.SnapShotCid)
+		if err != nil {
+			logger.Warnw("failed to get snapshot for ingest log", "height", next, "err", err)
+			return next
+		}
+		if err = l.snapShotted(ctx, next, time.Unix(0, int64(entry.CreatedTime)), snapshot); err != nil {
+			logger.Errorw("failed to append snapshotted events", "height", next, "err", err)
+			return next
+		}
+		if err = l.saveSnapShotHeight(ctx, next+1); err != nil {
+			logger.Errorw("failed to save snapshot height of ingest log", "height", next, "err", err)
+		}
+	}
+	return next
+}
+
+func (l *Log) snapShotted(ctx context.Context, height uint64, created time.Time, snapshot *cbortypes.SnapShot) error {
+	providers := make([]string, 0, len(snapshot.Update))
+	for provider := range snapshot.Update {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	for _, provider := range providers {
+		providerID, err := peer.Decode(provider)
+		if err != nil {
+			logger.Warnw("invalid provider in snapshot", "height", height, "provider", provider)
+			continue
+		}
+		metaList := snapshot.Update[provider]
+		if metaList == nil {
+			continue
+		}
+		for _, metaCid := range metaList.MetaList {
+			h := height
+			err = l.append(ctx, &Event{
+				Type:           EventSnapShotted,
+				Provider:       providerID,
+				Cid:            metaCid,
+				Collection:     l.collection(ctx, metaCid),
+				Time:           created,
+				SnapShotHeight: &h,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// collection returns the collection of the metadata, from the pending ones
+// or read from the block.
+func (l *Log) collection(ctx context.Context, metaCid cid.Cid) string {
+	l.mutex.Lock()
+	collection, ok := l.pending[metaCid]
+	delete(l.pending, metaCid)
+	l.mutex.Unlock()
+	if ok || l.blocks == nil {
+		return collection
+	}
+
+	data, err := l.blocks.Get(ctx, metaCid)
+	if err != nil {
+		return ""
+	}
+	decoder, err := multicodec.LookupDecoder(metaCid.Prefix().Codec)
+	if err != nil {
+		return ""
+	}
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err = decoder(nb, bytes.NewReader(data)); err != nil {
+		return ""
+	}
+	v, err := nb.Build().LookupByString("Collection")
+	if err != nil || v == nil {
+		return ""
+	}
+	collection, _ = v.AsString()
+	return collection
+}
@@ -0,0 +1,71 @@
+package legs
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/ipfs/go-graphsync"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/kenlabs/pando/pkg/access"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// accessPersistence is the name of the persistence option loading the blocks
+// for the consumer of the response context
+const accessPersistence = "pando-access"
+
+// errNoAccessControl rejects the requests when Pando has no access controller
+var errNoAccessControl = errors.New("access control is not available")
+
+// registerAccessControl makes the responses to the consumers load the blocks
+// by a link system checking their access, so that the responses never send
+// the restricted blocks traversed into.  The consumer is put in the context of
+// the response when it is queued, and read back by the link system.
+func (c *Core) registerAccessControl(gs graphsync.GraphExchange, lsys ipld.LinkSystem) error {
+	readOpener := lsys.StorageReadOpener
+	lsys.StorageReadOpener = func(lnkCtx ipld.LinkContext, lnk ipld.Link) (io.Reader, error) {
+		if c.access == nil {
+			return nil, errNoAccessControl
+		}
+		if asCidLink, ok := lnk.(cidlink.Link); ok {
+			consumer := access.ConsumerFrom(lnkCtx.Ctx)
+			if err := c.access.CheckCid(lnkCtx.Ctx, asCidLink.Cid, consumer); err != nil {
+				logger.Debugf("block %s was not loaded for consumer %s: %v", asCidLink.Cid, consumer, err)
+				return nil, err
+			}
+		}
+		return readOpener(lnkCtx, lnk)
+	}
+	if err := gs.RegisterPersistenceOption(accessPersistence, lsys); err != nil {
+		return err
+	}
+
+	gs.RegisterIncomingRequestQueuedHook(func(p peer.ID, _ graphsync.RequestData, hookActions graphsync.RequestQueuedHookActions) {
+		hookActions.AugmentContext(func(reqCtx context.Context) context.Context {
+			return access.WithConsumer(reqCtx, p)
+		})
+	})
+	gs.RegisterIncomingRequestHook(c.accessRequestHook())
+	return nil
+}
+
+// accessRequestHook rejects the requests of the consumers for the metadata of
+// the restricted collections they are not granted to, and makes the others
+// load the blocks by the access persistence option.  All the requests are
+// rejected without an access controller.
+func (c *Core) accessRequestHook() graphsync.OnIncomingRequestHook {
+	return func(p peer.ID, request graphsync.RequestData, hookActions graphsync.IncomingRequestHookActions) {
+		if c.access == nil {
+			hookActions.TerminateWithError(errNoAccessControl)
+			return
+		}
+		if err := c.access.CheckCid(context.Background(), request.Root(), p); err != nil {
+			logger.Warnf("request %d of consumer %s for %s was rejected: %v", request.ID(), p, request.Root(), err)
+			hookActions.TerminateWithError(err)
+			return
+		}
+		hookActions.UsePersistenceOption(accessPersistence)
+	}
+}
@@ -0,0 +1,129 @@
+package legs_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/filecoin-project/go-legs"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/sync"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/kenlabs/pando/pkg/access"
+	"github.com/kenlabs/pando/pkg/types/schema"
+	"github.com/kenlabs/pando/test/mock"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	gosync "sync"
+	"testing"
+	"time"
+)
+
+// blockRecorder is a link system keeping the blocks written to it
+type blockRecorder struct {
+	mutex  gosync.Mutex
+	blocks map[cid.Cid][]byte
+}
+
+func (r *blockRecorder) has(c cid.Cid) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	_, ok := r.blocks[c]
+	return ok
+}
+
+func (r *blockRecorder) linkSystem() ipld.LinkSystem {
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.StorageReadOpener = func(_ ipld.LinkContext, lnk ipld.Link) (io.Reader, error) {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		data, ok := r.blocks[lnk.(cidlink.Link).Cid]
+		if !ok {
+			return nil, datastore.ErrNotFound
+		}
+		return bytes.NewReader(data), nil
+	}
+	lsys.StorageWriteOpener = func(ipld.LinkContext) (io.Writer, ipld.BlockWriteCommitter, error) {
+		buf := bytes.NewBuffer(nil)
+		return buf, func(lnk ipld.Link) error {
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+			r.blocks[lnk.(cidlink.Link).Cid] = buf.Bytes()
+			return nil
+		}, nil
+	}
+	return lsys
+}
+
+// storeMetadata stores the metadata of the collection in Pando
+func storeMetadata(pando *mock.PandoMock, provider peer.ID, key crypto.PrivKey, collection string, prev ipld.Link) cid.Cid {
+	meta, err := schema.NewMetaWithPayloadNode(basicnode.NewString(collection), provider, key, prev)
+	So(err, ShouldBeNil)
+	meta.Collection = &collection
+	recorder := &blockRecorder{blocks: make(map[cid.Cid][]byte)}
+	lnk, err := schema.MetadataLink(recorder.linkSystem(), meta)
+	So(err, ShouldBeNil)
+	c := lnk.(cidlink.Link).Cid
+	So(pando.PS.Store(context.Background(), c, recorder.blocks[c], provider, nil), ShouldBeNil)
+	return c
+}
+
+// fetch syncs the DAG of root from Pando by a new consumer
+func fetch(pando *mock.PandoMock, root cid.Cid) (peer.ID, func() *blockRecorder) {
+	h, err := libp2p.New()
+	So(err, ShouldBeNil)
+	recorder := &blockRecorder{blocks: make(map[cid.Cid][]byte)}
+	sub, err := legs.NewSubscriber(h, sync.MutexWrap(datastore.NewMapDatastore()), recorder.linkSystem(), mock.GetTopic(), nil)
+	So(err, ShouldBeNil)
+	peerInfo, err := peer.AddrInfoFromString(pando.Host.Addrs()[0].String() + "/ipfs/" + pando.Host.ID().String())
+	So(err, ShouldBeNil)
+	So(h.Connect(context.Background(), *peerInfo), ShouldBeNil)
+
+	return h.ID(), func() *blockRecorder {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		// the sync fails when blocks are withheld, only the blocks received
+		// are checked
+		_, _ = sub.Sync(ctx, pando.Host.ID(), root, nil, nil)
+		_ = sub.Close()
+		return recorder
+	}
+}
+
+func TestAccessControl(t *testing.T) {
+	Convey("test the restricted blocks are not sent to the consumers", t, func() {
+		pando, err := mock.NewPandoMock()
+		So(err, ShouldBeNil)
+		provider, key, err := mock.GetPrivkyAndPeerID()
+		So(err, ShouldBeNil)
+
+		ctx := context.Background()
+		ac := pando.Access
+
+		restricted := storeMetadata(pando, provider, key, "private", nil)
+		public := storeMetadata(pando, provider, key, "public", cidlink.Link{Cid: restricted})
+		restrict := true
+
+		Convey("a consumer not granted only receives the public blocks", func() {
+			_, sync := fetch(pando, public)
+			_, err := ac.Update(ctx, provider, &access.Update{Collection: "private", Restricted: &restrict})
+			So(err, ShouldBeNil)
+			received := sync()
+			So(received.has(public), ShouldBeTrue)
+			So(received.has(restricted), ShouldBeFalse)
+		})
+
+		Convey("a consumer granted receives the restricted blocks", func() {
+			reader, sync := fetch(pando, public)
+			_, err := ac.Update(ctx, provider, &access.Update{Collection: "private", Restricted: &restrict, Grant: []peer.ID{reader}})
+			So(err, ShouldBeNil)
+			received := sync()
+			So(received.has(public), ShouldBeTrue)
+			So(received.has(restricted), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,84 @@
+package legs
+
+import (
+	"context"
+	"github.com/ipfs/go-graphsync"
+	"github.com/kenlabs/pando/pkg/policy"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"golang.org/x/time/rate"
+	"time"
+)
+
+// SetConsumerRatelimiter sets the rate-limiter of the consumers fetching DAGs
+// from Pando.
+func (c *Core) SetConsumerRatelimiter(rl *policy.Limiter) {
+	c.consumerLimiter = rl
+}
+
+// consumerRequestHook limits the requests of the consumers by the tiers of
+// their peer types, the responses out of limit are paused until allowed.
+func (c *Core) consumerRequestHook() graphsync.OnIncomingRequestHook {
+	return func(p peer.ID, request graphsync.RequestData, hookActions graphsync.IncomingRequestHookActions) {
+		limiter := c.consumerLimiter
+		if limiter == nil {
+			return
+		}
+		peerRateLimiter := limiter.PeerLimiter(p)
+		if peerRateLimiter == nil {
+			var err error
+			peerRateLimiter, err = limiter.AssignPeer(p)
+			if err != nil {
+				logger.Errorf("add consumer limiter failed, error: %v", err)
+				return
+			}
+		}
+		limiter.Requested(p)
+		if limiter.Allow() && peerRateLimiter.Allow() {
+			return
+		}
+
+		logger.Warnf("response %d to consumer %s was paused because of the rate limit policy", request.ID(), p)
+		hookActions.PauseResponse()
+		limiter.Throttled(p)
+		go c.unpauseResponse(request.ID(), limiter, peerRateLimiter)
+	}
+}
+
+// consumerBlockHook counts the bytes sent to the consumers, and pauses the
+// responses to the consumers out of the byte budgets of their tiers.
+func (c *Core) consumerBlockHook() graphsync.OnOutgoingBlockHook {
+	return func(p peer.ID, request graphsync.RequestData, block graphsync.BlockData, hookActions graphsync.OutgoingBlockHookActions) {
+		limiter := c.consumerLimiter
+		if limiter == nil {
+			return
+		}
+		size := block.BlockSizeOnWire()
+		if size == 0 {
+			// The block is not sent, the consumer has it
+			return
+		}
+		limiter.Transferred(p, size)
+		delay := reserveBytes(limiter.ByteLimiter(p), size)
+		if delay == 0 {
+			return
+		}
+		logger.Debugf("response %d to consumer %s paused %v because of the byte budget", request.ID(), p, delay)
+		hookActions.PauseResponse()
+		limiter.Throttled(p)
+		go c.unpauseAfter(request.ID(), delay)
+	}
+}
+
+func (c *Core) unpauseResponse(request graphsync.RequestID, limiter *policy.Limiter, peerRateLimiter *rate.Limiter) {
+	for {
+		time.Sleep(time.Second)
+		if limiter.Allow() && peerRateLimiter.Allow() {
+			break
+		}
+	}
+	if err := c.GS.Unpause(context.Background(), request); err != nil {
+		logger.Warnf("unpause response %d failed, error: %s", request, err.Error())
+	} else {
+		logger.Debugf("response %d unpaused", request)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/dgraph-io/badger/v3"
+	datatransfer "github.com/filecoin-project/go-data-transfer"
 	dt "github.com/filecoin-project/go-data-transfer/impl"
 	dtnetwork "github.com/filecoin-project/go-data-transfer/network"
 	gstransport "github.com/filecoin-project/go-data-transfer/transport/graphsync"
@@ -16,6 +17,8 @@ import (
 	gsimpl "github.com/ipfs/go-graphsync/impl"
 	gsnet "github.com/ipfs/go-graphsync/network"
 	"github.com/kenlabs/pando-store/pkg/store"
+	"github.com/kenlabs/pando/pkg/access"
+	"github.com/kenlabs/pando/pkg/ingest"
 	"github.com/kenlabs/pando/pkg/metadata"
 	"github.com/kenlabs/pando/pkg/metrics"
 	"github.com/kenlabs/pando/pkg/option"
@@ -27,6 +30,7 @@ import (
 	"github.com/multiformats/go-multiaddr"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -51,10 +55,28 @@ type Core struct {
 	recvMetaCh        chan<- *metadata.MetaRecord
 	backupGenInterval time.Duration
 	rateLimiter       *policy.Limiter
+	consumerLimiter   *policy.Limiter
+	// access checks the consumers fetching DAGs from Pando, all their
+	// requests are rejected if it is nil
+	access    *access.Controller
+	ingestLog *ingest.Log
 
 	waitForPendingSyncs sync.WaitGroup
 	watchDone           chan struct{}
 	options             *option.DaemonOptions
+	dtManager           datatransfer.Manager
+
+	// syncCtx is the context of the auto-syncs, canceled if they are not
+	// drained on shutdown
+	syncCtx      context.Context
+	cancelSyncs  context.CancelFunc
+	closing      chan struct{}
+	autoSyncDone chan struct{}
+	closeOnce    sync.Once
+	closeErr     error
+
+	// pendingSyncs is the number of the auto-syncs in progress
+	pendingSyncs int64
 }
 
 func NewLegsCore(ctx context.Context,
@@ -64,8 +86,9 @@ func NewLegsCore(ctx context.Context,
 	ps *store.PandoStore,
 	outMetaCh chan<- *metadata.MetaRecord,
 	backupGenInterval time.Duration,
-	rateLimiter *policy.Limiter, reg *registry.Registry, options *option.DaemonOptions) (*Core, error) {
+	rateLimiter *policy.Limiter, reg *registry.Registry, ac *access.Controller, options *option.DaemonOptions) (*Core, error) {
 
+	syncCtx, cancelSyncs := context.WithCancel(context.Background())
 	c := &Core{
 		Host:              host,
 		DS:                ds,
@@ -75,12 +98,18 @@ func NewLegsCore(ctx context.Context,
 		recvMetaCh:        outMetaCh,
 		backupGenInterval: backupGenInterval,
 		rateLimiter:       rateLimiter,
+		access:            ac,
 		watchDone:         make(chan struct{}),
 		options:           options,
+		syncCtx:           syncCtx,
+		cancelSyncs:       cancelSyncs,
+		closing:           make(chan struct{}),
+		autoSyncDone:      make(chan struct{}),
 	}
 
 	ls, gs, err := c.initSub(ctx, host, ds, ps, reg)
 	if err != nil {
+		cancelSyncs()
 		return nil, fmt.Errorf("failed to create legs subscriber, err: %s", err.Error())
 	}
 	c.LS = ls
@@ -88,7 +117,9 @@ func NewLegsCore(ctx context.Context,
 
 	err = c.restoreLatestSync()
 	if err != nil {
+		cancelSyncs()
 		_ = ls.Close()
+		_ = c.dtManager.Stop(ctx)
 		return nil, err
 	}
 
@@ -118,16 +149,28 @@ func (c *Core) initSub(ctx context.Context, h host.Host, ds datastore.Batching,
 	if err != nil {
 		return nil, nil, err
 	}
-	// todo
-	//defer dtManager.Stop(ctx)
+	// The subscriber does not stop the data-transfer manager it is given, it
+	// is stopped on closing the core.
+	c.dtManager = dtManager
 	ls, err := golegs.NewSubscriber(h, nil, lnkSys, PubSubTopic, nil,
 		golegs.AllowPeer(reg.Authorized), golegs.DtManager(dtManager, gs))
 	if err != nil {
+		_ = dtManager.Stop(ctx)
 		return nil, nil, err
 	}
 
 	if c.options.RateLimit.Enable {
 		gs.RegisterOutgoingRequestHook(c.rateLimitHook())
+		gs.RegisterIncomingBlockHook(c.byteBudgetHook())
+	}
+	if c.options.RateLimit.Consumer.Enable {
+		gs.RegisterIncomingRequestHook(c.consumerRequestHook())
+		gs.RegisterOutgoingBlockHook(c.consumerBlockHook())
+	}
+	if err = c.registerAccessControl(gs, lnkSys); err != nil {
+		_ = ls.Close()
+		_ = dtManager.Stop(ctx)
+		return nil, nil, err
 	}
 	dtManager.SubscribeToEvents(onDataTransferComplete)
 
@@ -135,36 +178,90 @@ func (c *Core) initSub(ctx context.Context, h host.Host, ds datastore.Batching,
 }
 
 func (c *Core) Close() error {
-	// Close leg transport.
-	err := c.LS.Close()
+	return c.Shutdown(context.Background())
+}
 
-	c.cancelSyncFn()
-	<-c.watchDone
-	c.waitForPendingSyncs.Wait()
+// Shutdown stops starting the auto-syncs, and waits for the pending ones to
+// finish until ctx is done, then cancels them.  The legs transport and the
+// data-transfer manager are closed after the syncs.
+func (c *Core) Shutdown(ctx context.Context) error {
+	c.closeOnce.Do(func() {
+		close(c.closing)
+		<-c.autoSyncDone
+
+		drained := make(chan struct{})
+		go func() {
+			c.waitForPendingSyncs.Wait()
+			close(drained)
+		}()
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			logger.Warnw("Canceling the pending syncs over the shutdown deadline", "pending", c.PendingSyncs())
+		}
+		c.cancelSyncs()
+
+		// Close leg transport.
+		c.closeErr = c.LS.Close()
+
+		c.cancelSyncFn()
+		<-c.watchDone
+		<-drained
 
-	return err
+		if err := c.dtManager.Stop(ctx); err != nil && c.closeErr == nil {
+			c.closeErr = err
+		}
+	})
+	return c.closeErr
 }
 
 func (c *Core) autoSync() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	for provInfo := range c.reg.SyncChan() {
+	defer close(c.autoSyncDone)
+	ctx := c.syncCtx
+	for {
+		var provInfo *registry.ProviderInfo
+		var ok bool
+		select {
+		case provInfo, ok = <-c.reg.SyncChan():
+			if !ok {
+				return
+			}
+		case <-c.closing:
+			return
+		}
 		c.waitForPendingSyncs.Add(1)
+		atomic.AddInt64(&c.pendingSyncs, 1)
 		go func(pubID, provID peer.ID, pubAddr multiaddr.Multiaddr) {
 			defer c.waitForPendingSyncs.Done()
+			defer atomic.AddInt64(&c.pendingSyncs, -1)
 
 			log := logger.With("publisher", pubID, "provider", provID, "addr", pubAddr)
 			log.Info("Auto-syncing the latest meta-data with publisher")
+			c.setProviderStatus(ctx, provID, registry.StatusSyncing, "auto-syncing with publisher")
 
 			_, err := c.LS.Sync(ctx, pubID, cid.Undef, nil, pubAddr)
 			if err != nil {
 				log.Errorw("Failed to auto-sync with publisher", "err", err)
+				c.setProviderStatus(ctx, provID, registry.StatusStale, fmt.Sprintf("failed to auto-sync: %v", err))
 				return
 			}
+			c.setProviderStatus(ctx, provID, registry.StatusActive, "auto-synced with publisher")
 		}(provInfo.Publisher, provInfo.AddrInfo.ID, provInfo.AddrInfo.Addrs[0])
 	}
 }
 
+// PendingSyncs returns the number of the auto-syncs with the publishers in
+// progress
+func (c *Core) PendingSyncs() int {
+	return int(atomic.LoadInt64(&c.pendingSyncs))
+}
+
+func (c *Core) setProviderStatus(ctx context.Context, provID peer.ID, status registry.Status, reason string) {
+	if err := c.reg.SetStatus(ctx, provID, status, reason); err != nil {
+		logger.Errorw("Failed to set provider status", "provider", provID, "status", status, "err", err)
+	}
+}
+
 // restoreLatestSync reads the latest sync for each previously synced provider,
 // from the datastore, and sets this in the Subscriber.
 func (c *Core) restoreLatestSync() error {
@@ -214,6 +311,11 @@ func (c *Core) SetRatelimiter(rl *policy.Limiter) {
 	c.rateLimiter = rl
 }
 
+// SetIngestLog sets the log the ingested metadata are appended to
+func (c *Core) SetIngestLog(l *ingest.Log) {
+	c.ingestLog = l
+}
+
 // watchSyncFinished reads legs.SyncFinished events and records the latest sync
 // for the peer that was synced.
 func (c *Core) watchSyncFinished(onSyncFin <-chan golegs.SyncFinished) {
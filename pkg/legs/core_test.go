@@ -64,7 +64,7 @@ func TestGetMetaRecord(t *testing.T) {
 		if err != nil {
 			t.Error(err)
 		}
-		c, err := legs.NewLegsCore(ctx, p.Host, p.DS, p.CS, p.PS, nil, time.Minute, nil, p.Registry, opt)
+		c, err := legs.NewLegsCore(ctx, p.Host, p.DS, p.CS, p.PS, nil, time.Minute, nil, p.Registry, p.Access, opt)
 		So(err, ShouldBeNil)
 		err = c.Close()
 		So(err, ShouldBeNil)
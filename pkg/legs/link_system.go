@@ -111,7 +111,15 @@ func MkLinkSystem(ps *store.PandoStore, core *Core, reg *registry.Registry) ipld
 					}(peerid)
 				}
 				metrics.Counter(lctx.Ctx, metrics.ProviderPayloadCount, peerid.String(), 1)()
-				return ps.Store(lctx.Ctx, c, block.RawData(), peerid, nil)
+				if err = ps.Store(lctx.Ctx, c, block.RawData(), peerid, nil); err != nil {
+					return err
+				}
+				if core != nil && core.ingestLog != nil {
+					if err = core.ingestLog.Ingested(lctx.Ctx, c, peerid, metadataCollectionStr); err != nil {
+						log.Errorw("Failed to append ingested metadata to ingest log", "err", err)
+					}
+				}
+				return nil
 			}
 			block, err := blocks.NewBlockWithCid(origBuf, c)
 			if err != nil {
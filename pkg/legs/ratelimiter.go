@@ -3,7 +3,6 @@ package legs
 import (
 	"context"
 	"github.com/ipfs/go-graphsync"
-	"github.com/kenlabs/pando/pkg/account"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"golang.org/x/time/rate"
 	"time"
@@ -11,16 +10,22 @@ import (
 
 func (c *Core) rateLimitHook() graphsync.OnOutgoingRequestHook {
 	return func(p peer.ID, request graphsync.RequestData, hookActions graphsync.OutgoingRequestHookActions) {
-		accountInfo := account.FetchPeerType(p, c.rateLimiter.Config().Registry)
 		peerRateLimiter := c.rateLimiter.PeerLimiter(p)
 		if peerRateLimiter == nil {
-			peerRateLimiter = c.addPeerLimiter(p, accountInfo.PeerType, accountInfo.AccountLevel)
+			var err error
+			peerRateLimiter, err = c.rateLimiter.AssignPeer(p)
+			if err != nil {
+				logger.Errorf("add peer limiter failed, error: %v", err)
+				return
+			}
 		}
-		logger.Debugf("rate limit for peer %s is %f token/s, accountLevel is %v",
-			p, peerRateLimiter.Limit(), accountInfo.AccountLevel)
+		logger.Debugf("rate limit for peer %s is %f token/s, tier is %s",
+			p, peerRateLimiter.Limit(), c.rateLimiter.PeerTier(p))
+		c.rateLimiter.Requested(p)
 		if !c.rateLimiter.Allow() || !peerRateLimiter.Allow() {
 			const limitError = "your request was paused because of the rate limit policy"
 			go c.pauseRequest(request.ID())
+			c.rateLimiter.Throttled(p)
 			logger.Warnf(limitError)
 			go c.unpauseRequest(request.ID(), peerRateLimiter)
 			logger.Debugf("leave rateLimitHook")
@@ -30,6 +35,48 @@ func (c *Core) rateLimitHook() graphsync.OnOutgoingRequestHook {
 	}
 }
 
+// byteBudgetHook pauses the requests of the peers that transferred more bytes
+// than the byte budget of their rate tier, until the budget is refilled.
+func (c *Core) byteBudgetHook() graphsync.OnIncomingBlockHook {
+	return func(p peer.ID, responseData graphsync.ResponseData, blockData graphsync.BlockData, hookActions graphsync.IncomingBlockHookActions) {
+		size := blockData.BlockSizeOnWire()
+		if size == 0 {
+			// The block is from the local store
+			return
+		}
+		c.rateLimiter.Transferred(p, size)
+		delay := reserveBytes(c.rateLimiter.ByteLimiter(p), size)
+		if delay == 0 {
+			return
+		}
+		logger.Debugf("request %d from peer %s paused %v because of the byte budget", responseData.RequestID(), p, delay)
+		hookActions.PauseRequest()
+		c.rateLimiter.Throttled(p)
+		go c.unpauseAfter(responseData.RequestID(), delay)
+	}
+}
+
+// reserveBytes takes size bytes from the byte budget, and returns how long to
+// wait until the budget is refilled.  It returns 0 if the budget is nil.
+func reserveBytes(byteLimiter *rate.Limiter, size uint64) time.Duration {
+	if byteLimiter == nil {
+		return 0
+	}
+	n := byteLimiter.Burst()
+	if size < uint64(n) {
+		n = int(size)
+	}
+	return byteLimiter.ReserveN(time.Now(), n).Delay()
+}
+
+// unpauseAfter unpauses the request or response after the delay
+func (c *Core) unpauseAfter(request graphsync.RequestID, delay time.Duration) {
+	time.Sleep(delay)
+	if err := c.GS.Unpause(context.Background(), request); err != nil {
+		logger.Warnf("unpause request %d failed, error: %s", request, err.Error())
+	}
+}
+
 func (c *Core) pauseRequest(request graphsync.RequestID) {
 	if err := c.GS.Pause(context.Background(), request); err != nil {
 		logger.Warnf("pause request failed, error: %s", err.Error())
@@ -48,29 +95,3 @@ func (c *Core) unpauseRequest(request graphsync.RequestID, peerRateLimiter *rate
 		c.unpauseRequest(request, peerRateLimiter)
 	}
 }
-
-func (c *Core) addPeerLimiter(peerID peer.ID, peerType account.PeerType, accountLevel int) *rate.Limiter {
-	const action = "add peer limiter"
-	var limiter *rate.Limiter
-	var err error
-	baseTokenRate := c.rateLimiter.Config().BaseTokenRate
-	switch peerType {
-	case account.UnregisteredPeer:
-		limiter, err = c.rateLimiter.UnregisteredLimiter(baseTokenRate)
-		checkError(action, err)
-	case account.WhiteListPeer:
-		limiter, err = c.rateLimiter.WhitelistLimiter(baseTokenRate)
-		checkError(action, err)
-	case account.RegisteredPeer:
-		limiter, err = c.rateLimiter.RegisteredLimiter(baseTokenRate, accountLevel, c.rateLimiter.Config().Registry.AccountLevelCount())
-		checkError(action, err)
-	}
-
-	return c.rateLimiter.AddPeerLimiter(peerID, limiter)
-}
-
-func checkError(action string, e error) {
-	if e != nil {
-		logger.Errorf("%s failed, error: %v", action, e)
-	}
-}
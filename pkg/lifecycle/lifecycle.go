@@ -0,0 +1,95 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kenlabs/pando/pkg/util/log"
+)
+
+var logger = log.NewSubsystemLogger()
+
+// Hook starts and stops a component.  OnStart is nil for the components
+// started by their constructors, and OnStop is nil for the ones needing no
+// shutdown.
+type Hook struct {
+	Name    string
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+// Lifecycle starts the components in the order they are appended, which is
+// the order of their dependencies, and stops them in reverse, so that a
+// component is stopped before the ones it depends on.
+type Lifecycle struct {
+	mutex   sync.Mutex
+	hooks   []Hook
+	started int
+}
+
+func New() *Lifecycle {
+	return &Lifecycle{}
+}
+
+// Append appends the hook of a component depending on the appended ones.  A
+// component without OnStart is started once the ones before it are, so that
+// the constructed components are stopped even if Start is never called.
+func (l *Lifecycle) Append(hook Hook) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if hook.OnStart == nil && l.started == len(l.hooks) {
+		l.started++
+	}
+	l.hooks = append(l.hooks, hook)
+}
+
+// Start starts the components not started yet in order.  If one of them
+// fails, the error is returned without starting the rest, and the started
+// ones are left to Stop.
+func (l *Lifecycle) Start(ctx context.Context) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for l.started < len(l.hooks) {
+		hook := l.hooks[l.started]
+		if hook.OnStart != nil {
+			logger.Infow("starting component", "component", hook.Name)
+			if err := hook.OnStart(ctx); err != nil {
+				return fmt.Errorf("failed to start %s: %w", hook.Name, err)
+			}
+		}
+		l.started++
+	}
+	return nil
+}
+
+// Stop stops the started components in reverse, the ones after a failed
+// component are still stopped.  The components drain their work until the
+// deadline of ctx, and are stopped without waiting after it.  The first error
+// is returned.
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var firstErr error
+	for ; l.started > 0; l.started-- {
+		hook := l.hooks[l.started-1]
+		if hook.OnStop == nil {
+			continue
+		}
+		start := time.Now()
+		if err := hook.OnStop(ctx); err != nil {
+			logger.Errorw("failed to stop component", "component", hook.Name, "err", err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to stop %s: %w", hook.Name, err)
+			}
+			continue
+		}
+		logger.Infow("stopped component", "component", hook.Name, "elapsed", time.Since(start))
+	}
+	// The components not started are dropped with the stopped ones, they are
+	// appended again to be restarted.
+	l.hooks = nil
+	return firstErr
+}
@@ -0,0 +1,92 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLifecycle(t *testing.T) {
+	Convey("TestLifecycle", t, func() {
+		var events []string
+		hook := func(name string, withStart bool, startErr error, stopErr error) Hook {
+			h := Hook{
+				Name: name,
+				OnStop: func(ctx context.Context) error {
+					events = append(events, "stop "+name)
+					return stopErr
+				},
+			}
+			if withStart {
+				h.OnStart = func(ctx context.Context) error {
+					events = append(events, "start "+name)
+					return startErr
+				}
+			}
+			return h
+		}
+		l := New()
+
+		Convey("start in order and stop in reverse", func() {
+			l.Append(hook("store", false, nil, nil))
+			l.Append(hook("legs", false, nil, nil))
+			l.Append(hook("http", true, nil, nil))
+			l.Append(hook("graphql", true, nil, nil))
+			So(l.Start(context.Background()), ShouldBeNil)
+			So(l.Stop(context.Background()), ShouldBeNil)
+			So(events, ShouldResemble, []string{
+				"start http", "start graphql",
+				"stop graphql", "stop http", "stop legs", "stop store",
+			})
+			So(l.Stop(context.Background()), ShouldBeNil)
+			So(events, ShouldHaveLength, 6)
+		})
+		Convey("stop the started components only if a component fails to start", func() {
+			l.Append(hook("store", false, nil, nil))
+			l.Append(hook("http", true, nil, nil))
+			l.Append(hook("graphql", true, errors.New("address in use"), nil))
+			l.Append(hook("profile", true, nil, nil))
+			err := l.Start(context.Background())
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "graphql")
+			So(l.Stop(context.Background()), ShouldBeNil)
+			So(events, ShouldResemble, []string{
+				"start http", "start graphql", "stop http", "stop store",
+			})
+		})
+		Convey("stop the constructed components without start", func() {
+			l.Append(hook("store", false, nil, nil))
+			l.Append(hook("legs", false, nil, nil))
+			So(l.Stop(context.Background()), ShouldBeNil)
+			So(events, ShouldResemble, []string{"stop legs", "stop store"})
+		})
+		Convey("stop all the components and return the first error", func() {
+			l.Append(hook("store", false, nil, errors.New("store error")))
+			l.Append(hook("legs", false, nil, errors.New("legs error")))
+			l.Append(hook("http", true, nil, nil))
+			So(l.Start(context.Background()), ShouldBeNil)
+			err := l.Stop(context.Background())
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "legs error")
+			So(events, ShouldResemble, []string{"start http", "stop http", "stop legs", "stop store"})
+		})
+		Convey("share the deadline of stopping", func() {
+			var deadlines []time.Time
+			for _, name := range []string{"store", "legs"} {
+				l.Append(Hook{Name: name, OnStop: func(ctx context.Context) error {
+					deadline, _ := ctx.Deadline()
+					deadlines = append(deadlines, deadline)
+					return nil
+				}})
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			So(l.Stop(ctx), ShouldBeNil)
+			So(deadlines, ShouldHaveLength, 2)
+			So(deadlines[0], ShouldEqual, deadlines[1])
+		})
+	})
+}
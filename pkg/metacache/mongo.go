@@ -0,0 +1,173 @@
+package metacache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// mongoQuerier runs the queries on the database of the provider in MongoDB,
+// the queries are translated to find commands or aggregation pipelines.
+type mongoQuerier struct {
+	client   *mongo.Client
+	maxLimit int
+	timeout  time.Duration
+}
+
+func NewMongoQuerier(client *mongo.Client, maxLimit int, timeout time.Duration) Querier {
+	return &mongoQuerier{
+		client:   client,
+		maxLimit: maxLimit,
+		timeout:  timeout,
+	}
+}
+
+func (m *mongoQuerier) Query(ctx context.Context, providerID string, q *Query) ([]map[string]interface{}, error) {
+	if err := q.Validate(m.maxLimit); err != nil {
+		return nil, err
+	}
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+
+	collection := m.client.Database(providerID).Collection(q.Collection,
+		options.Collection().SetReadPreference(readpref.Primary()))
+	var cursor *mongo.Cursor
+	var err error
+	if q.Group != nil {
+		opts := options.Aggregate().SetMaxTime(m.timeout)
+		cursor, err = collection.Aggregate(ctx, mongoPipeline(q), opts)
+	} else {
+		opts := options.Find().SetLimit(int64(q.Limit)).SetMaxTime(m.timeout)
+		if len(q.Projection) != 0 {
+			opts.SetProjection(mongoProjection(q.Projection))
+		}
+		if len(q.Sort) != 0 {
+			opts.SetSort(mongoSort(q.Sort))
+		}
+		cursor, err = collection.Find(ctx, mongoFilter(q.Filter), opts)
+	}
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	var docs []bson.M
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, mongoError(err)
+	}
+	results := make([]map[string]interface{}, len(docs))
+	for i, doc := range docs {
+		results[i] = doc
+	}
+	return results, nil
+}
+
+func mongoError(err error) error {
+	if mongo.IsTimeout(err) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Name == "MaxTimeMSExpired" {
+		return ErrTimeout
+	}
+	return err
+}
+
+// mongoFilter translates the filter to a query filter document
+func mongoFilter(f *Filter) bson.D {
+	if f == nil {
+		return bson.D{}
+	}
+	switch {
+	case len(f.And) != 0:
+		return bson.D{{Key: "$and", Value: mongoFilters(f.And)}}
+	case len(f.Or) != 0:
+		return bson.D{{Key: "$or", Value: mongoFilters(f.Or)}}
+	case f.Not != nil:
+		// $not only applies to the operators of a field
+		return bson.D{{Key: "$nor", Value: bson.A{mongoFilter(f.Not)}}}
+	}
+	return bson.D{{Key: f.Field, Value: bson.D{{Key: "$" + string(f.Op), Value: mongoValue(f.Value)}}}}
+}
+
+func mongoFilters(filters []*Filter) bson.A {
+	docs := make(bson.A, len(filters))
+	for i, f := range filters {
+		docs[i] = mongoFilter(f)
+	}
+	return docs
+}
+
+func mongoValue(v interface{}) interface{} {
+	if values, ok := v.([]interface{}); ok {
+		return bson.A(values)
+	}
+	return v
+}
+
+func mongoProjection(fields []string) bson.D {
+	projection := make(bson.D, len(fields))
+	for i, field := range fields {
+		projection[i] = bson.E{Key: field, Value: 1}
+	}
+	return projection
+}
+
+func mongoSort(fields []SortField) bson.D {
+	sort := make(bson.D, len(fields))
+	for i, s := range fields {
+		order := 1
+		if s.Desc {
+			order = -1
+		}
+		sort[i] = bson.E{Key: s.Field, Value: order}
+	}
+	return sort
+}
+
+// mongoPipeline translates the query with a group to an aggregation pipeline.
+// The By fields are grouped as the fields of _id by their positions, since the
+// keys of _id cannot be paths, and projected back to their paths.
+func mongoPipeline(q *Query) mongo.Pipeline {
+	var id interface{}
+	project := bson.D{{Key: "_id", Value: 0}}
+	if len(q.Group.By) != 0 {
+		byFields := make(bson.D, len(q.Group.By))
+		for i, field := range q.Group.By {
+			key := fmt.Sprintf("f%d", i)
+			byFields[i] = bson.E{Key: key, Value: "$" + field}
+			project = append(project, bson.E{Key: field, Value: "$_id." + key})
+		}
+		id = byFields
+	}
+
+	group := bson.D{{Key: "_id", Value: id}}
+	for _, agg := range q.Group.Aggregations {
+		var acc bson.E
+		if agg.Func == AggCount {
+			acc = bson.E{Key: "$sum", Value: 1}
+		} else {
+			acc = bson.E{Key: "$" + string(agg.Func), Value: "$" + agg.Field}
+		}
+		group = append(group, bson.E{Key: agg.Name, Value: bson.D{acc}})
+		project = append(project, bson.E{Key: agg.Name, Value: 1})
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: mongoFilter(q.Filter)}},
+		{{Key: "$group", Value: group}},
+		{{Key: "$project", Value: project}},
+	}
+	if len(q.Sort) != 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: mongoSort(q.Sort)}})
+	}
+	return append(pipeline, bson.D{{Key: "$limit", Value: q.Limit}})
+}
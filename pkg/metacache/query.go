@@ -0,0 +1,346 @@
+package metacache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kenlabs/pando/pkg/option"
+)
+
+var (
+	// ErrInvalidQuery is wrapped by the errors of the queries failing the
+	// validation
+	ErrInvalidQuery = errors.New("invalid query")
+	// ErrTimeout is returned when a query runs over the timeout
+	ErrTimeout = errors.New("query timed out")
+)
+
+const (
+	// maxFilterDepth is the max nesting of the And, Or and Not filters
+	maxFilterDepth = 8
+	// maxFilterConditions is the max number of the field conditions of a filter
+	maxFilterConditions = 64
+	// maxInValues is the max number of the values of the in and nin conditions
+	maxInValues = 256
+	// maxFields is the max number of the fields of a projection, a sort, a
+	// group and its aggregations
+	maxFields = 32
+)
+
+// Op is the operator of a field condition
+type Op string
+
+const (
+	OpEq     Op = "eq"
+	OpNe     Op = "ne"
+	OpGt     Op = "gt"
+	OpGte    Op = "gte"
+	OpLt     Op = "lt"
+	OpLte    Op = "lte"
+	OpIn     Op = "in"
+	OpNin    Op = "nin"
+	OpExists Op = "exists"
+)
+
+// AggFunc is the function of an aggregation over the documents of a group
+type AggFunc string
+
+const (
+	AggCount AggFunc = "count"
+	AggSum   AggFunc = "sum"
+	AggAvg   AggFunc = "avg"
+	AggMin   AggFunc = "min"
+	AggMax   AggFunc = "max"
+)
+
+// Filter selects the documents.  It is either a field condition with Field,
+// Op and Value, or one of And, Or and Not over the other filters.  The fields
+// are dot separated paths in the documents.
+type Filter struct {
+	And []*Filter `json:",omitempty"`
+	Or  []*Filter `json:",omitempty"`
+	Not *Filter   `json:",omitempty"`
+
+	Field string      `json:",omitempty"`
+	Op    Op          `json:",omitempty"`
+	Value interface{} `json:",omitempty"`
+}
+
+// SortField sorts the results by a field, ascending unless Desc
+type SortField struct {
+	Field string
+	Desc  bool `json:",omitempty"`
+}
+
+// Aggregation computes Func over Field of the documents of a group, the
+// result is named Name.  Field is not used by count.
+type Aggregation struct {
+	Name  string
+	Func  AggFunc
+	Field string `json:",omitempty"`
+}
+
+// Group groups the documents by the values of the fields in By, all the
+// documents are in one group without By.  A result is returned for each
+// group with the By fields and the aggregations.
+type Group struct {
+	By           []string      `json:",omitempty"`
+	Aggregations []Aggregation `json:",omitempty"`
+}
+
+// Query is a query over one collection of the metadata cache of a provider.
+// The results are the documents matching Filter with the fields in
+// Projection, or the groups of them with Group, sorted by Sort and up to
+// Limit.  Projection cannot be used with Group, and Sort only sorts the
+// groups by their By fields and aggregations.
+type Query struct {
+	Collection string
+	Filter     *Filter     `json:",omitempty"`
+	Projection []string    `json:",omitempty"`
+	Sort       []SortField `json:",omitempty"`
+	Limit      int         `json:",omitempty"`
+	Group      *Group      `json:",omitempty"`
+}
+
+// Querier runs the queries on a backend of the metadata cache
+type Querier interface {
+	// Query runs the validated query on the metadata cache of the provider
+	Query(ctx context.Context, providerID string, q *Query) ([]map[string]interface{}, error)
+}
+
+// NewQuerier creates the Querier of the backend of the metadata cache
+func NewQuerier(cfg *option.MetaCache) (Querier, error) {
+	if cfg.QueryMaxLimit <= 0 {
+		return nil, fmt.Errorf("invalid max limit of metadata query: %d", cfg.QueryMaxLimit)
+	}
+	switch cfg.Type {
+	case "mongodb":
+		if cfg.Client == nil {
+			return nil, errors.New("metacache client is not connected")
+		}
+		return NewMongoQuerier(cfg.Client, cfg.QueryMaxLimit,
+			time.Duration(cfg.QueryTimeoutInDurationFormat())), nil
+	default:
+		return nil, fmt.Errorf("metadata store type: %s not supported", cfg.Type)
+	}
+}
+
+// Validate checks the query and sets the default Limit of maxLimit
+func (q *Query) Validate(maxLimit int) error {
+	if err := checkName(q.Collection); err != nil {
+		return fmt.Errorf("%w: collection: %v", ErrInvalidQuery, err)
+	}
+	if strings.HasPrefix(q.Collection, "system.") {
+		return fmt.Errorf("%w: collection: %q is reserved", ErrInvalidQuery, q.Collection)
+	}
+	if q.Filter != nil {
+		conditions := 0
+		if err := q.Filter.validate(0, &conditions); err != nil {
+			return fmt.Errorf("%w: filter: %v", ErrInvalidQuery, err)
+		}
+	}
+	if len(q.Projection) > maxFields {
+		return fmt.Errorf("%w: projection: more than %d fields", ErrInvalidQuery, maxFields)
+	}
+	for _, field := range q.Projection {
+		if err := checkField(field); err != nil {
+			return fmt.Errorf("%w: projection: %v", ErrInvalidQuery, err)
+		}
+	}
+
+	var outputs map[string]bool
+	if q.Group != nil {
+		if len(q.Projection) != 0 {
+			return fmt.Errorf("%w: projection cannot be used with group", ErrInvalidQuery)
+		}
+		var err error
+		if outputs, err = q.Group.validate(); err != nil {
+			return fmt.Errorf("%w: group: %v", ErrInvalidQuery, err)
+		}
+	}
+	if len(q.Sort) > maxFields {
+		return fmt.Errorf("%w: sort: more than %d fields", ErrInvalidQuery, maxFields)
+	}
+	for _, s := range q.Sort {
+		if err := checkField(s.Field); err != nil {
+			return fmt.Errorf("%w: sort: %v", ErrInvalidQuery, err)
+		}
+		if outputs != nil && !outputs[s.Field] {
+			return fmt.Errorf("%w: sort: %q is not a field of the groups", ErrInvalidQuery, s.Field)
+		}
+	}
+
+	switch {
+	case q.Limit < 0:
+		return fmt.Errorf("%w: negative limit", ErrInvalidQuery)
+	case q.Limit > maxLimit:
+		return fmt.Errorf("%w: limit is over the max of %d", ErrInvalidQuery, maxLimit)
+	case q.Limit == 0:
+		q.Limit = maxLimit
+	}
+	return nil
+}
+
+func (f *Filter) validate(depth int, conditions *int) error {
+	if depth >= maxFilterDepth {
+		return fmt.Errorf("nested deeper than %d", maxFilterDepth)
+	}
+	set := 0
+	for _, ok := range []bool{len(f.And) != 0, len(f.Or) != 0, f.Not != nil, f.Field != "" || f.Op != ""} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("a filter must be exactly one of And, Or, Not and a field condition")
+	}
+
+	for _, subs := range [][]*Filter{f.And, f.Or} {
+		for _, sub := range subs {
+			if sub == nil {
+				return errors.New("empty filter")
+			}
+			if err := sub.validate(depth+1, conditions); err != nil {
+				return err
+			}
+		}
+	}
+	if f.Not != nil {
+		return f.Not.validate(depth+1, conditions)
+	}
+	if f.Field == "" && f.Op == "" {
+		return nil
+	}
+
+	*conditions++
+	if *conditions > maxFilterConditions {
+		return fmt.Errorf("more than %d conditions", maxFilterConditions)
+	}
+	if err := checkField(f.Field); err != nil {
+		return err
+	}
+	switch f.Op {
+	case OpEq, OpNe:
+		if !isScalar(f.Value) {
+			return fmt.Errorf("value of %s on %q must be a string, number, bool or null", f.Op, f.Field)
+		}
+	case OpGt, OpGte, OpLt, OpLte:
+		switch f.Value.(type) {
+		case string, float64:
+		default:
+			return fmt.Errorf("value of %s on %q must be a string or number", f.Op, f.Field)
+		}
+	case OpIn, OpNin:
+		values, ok := f.Value.([]interface{})
+		if !ok {
+			return fmt.Errorf("value of %s on %q must be a list", f.Op, f.Field)
+		}
+		if len(values) > maxInValues {
+			return fmt.Errorf("more than %d values of %s on %q", maxInValues, f.Op, f.Field)
+		}
+		for _, v := range values {
+			if !isScalar(v) {
+				return fmt.Errorf("values of %s on %q must be strings, numbers, bools or nulls", f.Op, f.Field)
+			}
+		}
+	case OpExists:
+		if _, ok := f.Value.(bool); !ok {
+			return fmt.Errorf("value of %s on %q must be a bool", f.Op, f.Field)
+		}
+	default:
+		return fmt.Errorf("unknown operator %q", f.Op)
+	}
+	return nil
+}
+
+// validate checks the group and returns the fields of its results
+func (g *Group) validate() (map[string]bool, error) {
+	if len(g.By)+len(g.Aggregations) > maxFields {
+		return nil, fmt.Errorf("more than %d fields", maxFields)
+	}
+	outputs := make(map[string]bool)
+	for _, field := range g.By {
+		if err := checkField(field); err != nil {
+			return nil, err
+		}
+		if outputs[field] {
+			return nil, fmt.Errorf("duplicated field %q", field)
+		}
+		// The By fields are projected back to their paths, which collide
+		// for a field and its parent
+		for other := range outputs {
+			if strings.HasPrefix(field, other+".") || strings.HasPrefix(other, field+".") {
+				return nil, fmt.Errorf("field %q overlaps field %q", field, other)
+			}
+		}
+		outputs[field] = true
+	}
+	for _, agg := range g.Aggregations {
+		if err := checkName(agg.Name); err != nil {
+			return nil, fmt.Errorf("aggregation name: %v", err)
+		}
+		if agg.Name == "_id" {
+			return nil, errors.New("aggregation name _id is reserved")
+		}
+		// The results of the aggregations are at the top level, next to the By
+		// fields
+		for field := range outputs {
+			if strings.SplitN(field, ".", 2)[0] == agg.Name {
+				return nil, fmt.Errorf("duplicated field %q", agg.Name)
+			}
+		}
+		switch agg.Func {
+		case AggCount:
+			if agg.Field != "" {
+				return nil, fmt.Errorf("%s aggregation %q takes no field", agg.Func, agg.Name)
+			}
+		case AggSum, AggAvg, AggMin, AggMax:
+			if err := checkField(agg.Field); err != nil {
+				return nil, fmt.Errorf("aggregation %q: %v", agg.Name, err)
+			}
+		default:
+			return nil, fmt.Errorf("unknown aggregation function %q", agg.Func)
+		}
+		outputs[agg.Name] = true
+	}
+	return outputs, nil
+}
+
+// checkField checks a dot separated path of the fields of the documents.  The
+// names starting with $ are the operators of the backends and not allowed.
+func checkField(field string) error {
+	if field == "" {
+		return errors.New("empty field")
+	}
+	for _, name := range strings.Split(field, ".") {
+		if err := checkName(name); err != nil {
+			return fmt.Errorf("field %q: %v", field, err)
+		}
+	}
+	return nil
+}
+
+func checkName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("empty name")
+	case strings.HasPrefix(name, "$"):
+		return fmt.Errorf("name %q starts with $", name)
+	case strings.ContainsAny(name, ".\x00"):
+		return fmt.Errorf("name %q contains . or null character", name)
+	}
+	return nil
+}
+
+// isScalar checks the JSON values of the conditions, the documents and the
+// other values are not allowed to be compared.
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case nil, string, float64, bool:
+		return true
+	}
+	return false
+}
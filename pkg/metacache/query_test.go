@@ -0,0 +1,113 @@
+package metacache
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func parseQuery(s string) *Query {
+	q := &Query{}
+	So(json.Unmarshal([]byte(s), q), ShouldBeNil)
+	return q
+}
+
+func TestValidate(t *testing.T) {
+	Convey("TestValidate", t, func() {
+		Convey("should accept the valid queries and set the default limit", func() {
+			q := parseQuery(`{"Collection": "deals",
+				"Filter": {"And": [
+					{"Field": "size", "Op": "gte", "Value": 10},
+					{"Or": [{"Field": "miner.id", "Op": "in", "Value": ["f01", "f02"]}, {"Not": {"Field": "label", "Op": "exists", "Value": true}}]}
+				]},
+				"Projection": ["size", "miner.id"],
+				"Sort": [{"Field": "size", "Desc": true}]}`)
+			So(q.Validate(100), ShouldBeNil)
+			So(q.Limit, ShouldEqual, 100)
+
+			q = parseQuery(`{"Collection": "deals", "Limit": 5,
+				"Group": {"By": ["miner.id"], "Aggregations": [{"Name": "n", "Func": "count"}, {"Name": "total", "Func": "sum", "Field": "size"}]},
+				"Sort": [{"Field": "total"}, {"Field": "miner.id"}]}`)
+			So(q.Validate(100), ShouldBeNil)
+			So(q.Limit, ShouldEqual, 5)
+		})
+
+		Convey("should reject the invalid queries", func() {
+			for _, s := range []string{
+				`{"Collection": ""}`,
+				`{"Collection": "$cmd"}`,
+				`{"Collection": "system.users"}`,
+				`{"Collection": "deals", "Limit": 101}`,
+				`{"Collection": "deals", "Limit": -1}`,
+				`{"Collection": "deals", "Filter": {"Field": "$where", "Op": "eq", "Value": "1"}}`,
+				`{"Collection": "deals", "Filter": {"Field": "a..b", "Op": "eq", "Value": "1"}}`,
+				`{"Collection": "deals", "Filter": {"Field": "size", "Op": "where", "Value": "1"}}`,
+				`{"Collection": "deals", "Filter": {"Field": "size", "Op": "eq", "Value": {"$gt": 1}}}`,
+				`{"Collection": "deals", "Filter": {"Field": "size", "Op": "in", "Value": [{"$gt": 1}]}}`,
+				`{"Collection": "deals", "Filter": {"Field": "size", "Op": "gt", "Value": true}}`,
+				`{"Collection": "deals", "Filter": {"Field": "size", "Op": "exists", "Value": 1}}`,
+				`{"Collection": "deals", "Filter": {"And": [{"Field": "size", "Op": "eq", "Value": 1}], "Field": "size", "Op": "eq", "Value": 1}}`,
+				`{"Collection": "deals", "Filter": {}}`,
+				`{"Collection": "deals", "Projection": ["$size"]}`,
+				`{"Collection": "deals", "Projection": ["size"], "Group": {"By": ["size"]}}`,
+				`{"Collection": "deals", "Group": {"Aggregations": [{"Name": "n", "Func": "function"}]}}`,
+				`{"Collection": "deals", "Group": {"Aggregations": [{"Name": "n", "Func": "sum"}]}}`,
+				`{"Collection": "deals", "Group": {"By": ["n.a"], "Aggregations": [{"Name": "n", "Func": "count"}]}}`,
+				`{"Collection": "deals", "Group": {"By": ["miner", "miner.id"]}}`,
+				`{"Collection": "deals", "Group": {"By": ["miner.id", "miner"]}}`,
+				`{"Collection": "deals", "Group": {"By": ["size"]}, "Sort": [{"Field": "label"}]}`,
+			} {
+				err := parseQuery(s).Validate(100)
+				So(err, ShouldNotBeNil)
+				So(errors.Is(err, ErrInvalidQuery), ShouldBeTrue)
+			}
+		})
+
+		Convey("should reject the filters nested too deep", func() {
+			f := &Filter{Field: "size", Op: OpEq, Value: 1.0}
+			for i := 0; i < maxFilterDepth; i++ {
+				f = &Filter{Not: f}
+			}
+			err := (&Query{Collection: "deals", Filter: f}).Validate(100)
+			So(errors.Is(err, ErrInvalidQuery), ShouldBeTrue)
+		})
+	})
+}
+
+func TestMongoTranslation(t *testing.T) {
+	Convey("TestMongoTranslation", t, func() {
+		Convey("should translate the filter", func() {
+			q := parseQuery(`{"Collection": "deals", "Filter": {"Or": [
+				{"Field": "miner.id", "Op": "in", "Value": ["f01"]},
+				{"Not": {"Field": "size", "Op": "lt", "Value": 10}}]}}`)
+			So(q.Validate(100), ShouldBeNil)
+			So(mongoFilter(q.Filter), ShouldResemble, bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "miner.id", Value: bson.D{{Key: "$in", Value: bson.A{"f01"}}}}},
+				bson.D{{Key: "$nor", Value: bson.A{bson.D{{Key: "size", Value: bson.D{{Key: "$lt", Value: 10.0}}}}}}},
+			}}})
+			So(mongoFilter(nil), ShouldResemble, bson.D{})
+		})
+
+		Convey("should translate the group to a pipeline", func() {
+			q := parseQuery(`{"Collection": "deals", "Limit": 5,
+				"Group": {"By": ["miner.id"], "Aggregations": [{"Name": "n", "Func": "count"}, {"Name": "avg", "Func": "avg", "Field": "size"}]},
+				"Sort": [{"Field": "n", "Desc": true}]}`)
+			So(q.Validate(100), ShouldBeNil)
+			pipeline := mongoPipeline(q)
+			So(pipeline, ShouldHaveLength, 5)
+			So(pipeline[1], ShouldResemble, bson.D{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: bson.D{{Key: "f0", Value: "$miner.id"}}},
+				{Key: "n", Value: bson.D{{Key: "$sum", Value: 1}}},
+				{Key: "avg", Value: bson.D{{Key: "$avg", Value: "$size"}}},
+			}}})
+			So(pipeline[2], ShouldResemble, bson.D{{Key: "$project", Value: bson.D{
+				{Key: "_id", Value: 0}, {Key: "miner.id", Value: "$_id.f0"}, {Key: "n", Value: 1}, {Key: "avg", Value: 1},
+			}}})
+			So(pipeline[3], ShouldResemble, bson.D{{Key: "$sort", Value: bson.D{{Key: "n", Value: -1}}}})
+			So(pipeline[4], ShouldResemble, bson.D{{Key: "$limit", Value: 5}})
+		})
+	})
+}
@@ -2,6 +2,7 @@ package metadata
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/kenlabs/pando/pkg/metadata/est_utils"
@@ -24,16 +25,21 @@ var (
 )
 
 type BackupSystem struct {
-	backupCfg *option.Backup
-	apiKey    string
-	toCheck   chan uint64
+	backupCfg  *option.Backup
+	apiKey     string
+	toCheck    chan uint64
+	closing    chan struct{}
+	uploadDone chan struct{}
+	closeOnce  sync.Once
 }
 
 func NewBackupSys(backupCfg *option.Backup) (*BackupSystem, error) {
 	bs := &BackupSystem{
-		apiKey:    "Bearer " + backupCfg.APIKey,
-		toCheck:   make(chan uint64, 1),
-		backupCfg: backupCfg,
+		apiKey:     "Bearer " + backupCfg.APIKey,
+		toCheck:    make(chan uint64, 1),
+		backupCfg:  backupCfg,
+		closing:    make(chan struct{}),
+		uploadDone: make(chan struct{}),
 	}
 	err := bs.run()
 	if err != nil {
@@ -53,26 +59,15 @@ func (bs *BackupSystem) run() error {
 		return err
 	}
 	go func() {
-		for range time.NewTicker(backupInterval).C {
-			files, err := ioutil.ReadDir(BackupTmpPath)
-			if err != nil {
-				logger.Errorf("wrong back up dir path: %s", BackupTmpPath)
-			}
-			for _, file := range files {
-				if file.IsDir() {
-					// dir should not back up
-					continue
-				}
-				_, err = bs.BackupToEstuary(path.Join(BackupTmpPath, file.Name()))
-				if err != nil {
-					//todo metrics
-					logger.Warnf("failed back up, err : %s", err.Error())
-					continue
-				}
-				err = os.Remove(path.Join(BackupTmpPath, file.Name()))
-				if err != nil {
-					logger.Error("failed to remove the backed up car file")
-				}
+		defer close(bs.uploadDone)
+		ticker := time.NewTicker(backupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				bs.uploadFiles()
+			case <-bs.closing:
+				return
 			}
 		}
 	}()
@@ -82,6 +77,50 @@ func (bs *BackupSystem) run() error {
 	return nil
 }
 
+// uploadFiles backs up the car files to estuary then deletes them.  It stops
+// between the files on closing, the rest are uploaded after restarting.
+func (bs *BackupSystem) uploadFiles() {
+	files, err := ioutil.ReadDir(BackupTmpPath)
+	if err != nil {
+		logger.Errorf("wrong back up dir path: %s", BackupTmpPath)
+	}
+	for _, file := range files {
+		select {
+		case <-bs.closing:
+			return
+		default:
+		}
+		if file.IsDir() {
+			// dir should not back up
+			continue
+		}
+		_, err = bs.BackupToEstuary(path.Join(BackupTmpPath, file.Name()))
+		if err != nil {
+			//todo metrics
+			logger.Warnf("failed back up, err : %s", err.Error())
+			continue
+		}
+		err = os.Remove(path.Join(BackupTmpPath, file.Name()))
+		if err != nil {
+			logger.Error("failed to remove the backed up car file")
+		}
+	}
+}
+
+// Shutdown stops the backup, and waits for the upload in progress until ctx
+// is done.
+func (bs *BackupSystem) Shutdown(ctx context.Context) error {
+	bs.closeOnce.Do(func() {
+		close(bs.closing)
+	})
+	select {
+	case <-bs.uploadDone:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("backup upload is not finished: %w", ctx.Err())
+	}
+}
+
 func (bs *BackupSystem) checkDeal(checkInterval time.Duration) {
 	waitCheckList := make([]uint64, 0)
 	mux := sync.Mutex{}
@@ -93,7 +132,14 @@ func (bs *BackupSystem) checkDeal(checkInterval time.Duration) {
 		}
 	}()
 
-	for range time.NewTicker(checkInterval).C {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-bs.closing:
+			return
+		}
 		for idx, checkId := range waitCheckList {
 			if len(waitCheckList) < idx+1 {
 				continue
@@ -129,3 +129,30 @@ func TestBackUpWithStopLink(t *testing.T) {
 
 	})
 }
+
+func TestBackupShutdown(t *testing.T) {
+	Convey("when shutdown then stop backing up the car files", t, func() {
+		tmpDir := t.TempDir()
+		patch := gomonkey.ApplyGlobalVar(&metadata.BackupTmpPath, tmpDir)
+		defer patch.Reset()
+		cfg := &option.Backup{
+			ShuttleGateway:    "http://127.0.0.1:1",
+			BackupEstInterval: (time.Millisecond * 100).String(),
+			EstCheckInterval:  time.Hour.String(),
+		}
+		bs, err := metadata.NewBackupSys(cfg)
+		So(err, ShouldBeNil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		So(bs.Shutdown(ctx), ShouldBeNil)
+		So(bs.Shutdown(ctx), ShouldBeNil)
+
+		// The files are kept for the backup after restarting
+		So(genTmpCarFiles(tmpDir), ShouldBeNil)
+		time.Sleep(time.Millisecond * 300)
+		files, err := os.ReadDir(tmpDir)
+		So(err, ShouldBeNil)
+		So(files, ShouldHaveLength, 1)
+	})
+}
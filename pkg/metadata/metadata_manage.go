@@ -16,6 +16,7 @@ import (
 	"github.com/kenlabs/pando/pkg/option"
 	"github.com/kenlabs/pando/pkg/registry"
 	"github.com/libp2p/go-libp2p-core/peer"
+	"io/ioutil"
 	"os"
 	"path"
 
@@ -81,6 +82,10 @@ type MetaManager struct {
 	backupCfg         *option.Backup
 	ctx               context.Context
 	cncl              context.CancelFunc
+	closing           chan struct{}
+	genDone           chan struct{}
+	closeOnce         sync.Once
+	closeErr          error
 }
 
 type MetaRecord struct {
@@ -110,6 +115,8 @@ func New(ctx context.Context, ds datastore.Batching, ls *ipld.LinkSystem, regist
 		backupCfg:    backupCfg,
 		ctx:          cctx,
 		cncl:         cncl,
+		closing:      make(chan struct{}),
+		genDone:      make(chan struct{}),
 	}
 
 	go mm.dealReceivedMeta()
@@ -162,6 +169,16 @@ func (mm *MetaManager) dealReceivedMeta() {
 //	}
 //}
 
+// BackupBacklog returns the number of the metadata queued for the backup CAR
+// files, and the number of the CAR files waiting to be backed up.
+func (mm *MetaManager) BackupBacklog() (int, int, error) {
+	files, err := ioutil.ReadDir(BackupTmpPath)
+	if err != nil {
+		return len(mm.backupCh), 0, err
+	}
+	return len(mm.backupCh), len(files), nil
+}
+
 func (mm *MetaManager) GetMetaInCh() chan<- *MetaRecord {
 	return mm.recvCh
 }
@@ -177,14 +194,24 @@ func (mm *MetaManager) genCarForProviders(ctx context.Context) {
 			mm.backupCfg.BackupGenInterval, err.Error())
 	}
 	go func() {
-		for range time.NewTicker(interval).C {
+		defer close(mm.genDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
 			select {
-			case _ = <-ctx.Done():
+			case <-ticker.C:
+			case <-mm.closing:
+				return
+			case <-ctx.Done():
 				return
-			default:
 			}
 			providersInfo := mm.registry.AllProviderInfo()
 			for _, info := range providersInfo {
+				select {
+				case <-mm.closing:
+					return
+				default:
+				}
 				lastBackup := info.LastBackupMeta
 				lastSync, err := mm.ds.Get(ctx, datastore.NewKey(syncPrefix+info.AddrInfo.ID.String()))
 				if err != nil {
@@ -222,11 +249,33 @@ func (mm *MetaManager) genCarForProviders(ctx context.Context) {
 	}()
 }
 
+// Close stops the manager without waiting for the backup in progress
 func (mm *MetaManager) Close() {
-	mm.cncl()
-	//close(mm.outStateTreeCh)
-	close(mm.backupCh)
-	close(mm.recvCh)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = mm.Shutdown(ctx)
+}
+
+// Shutdown stops generating the backup car files and uploading them, the
+// car file and the upload in progress are waited for until ctx is done.  The
+// metadata must not be sent to the manager after it.
+func (mm *MetaManager) Shutdown(ctx context.Context) error {
+	mm.closeOnce.Do(func() {
+		close(mm.closing)
+		select {
+		case <-mm.genDone:
+		case <-ctx.Done():
+			logger.Warn("canceling the backup car file generation over the shutdown deadline")
+		}
+		mm.cncl()
+		<-mm.genDone
+
+		mm.closeErr = mm.EstBackupSys.Shutdown(ctx)
+		//close(mm.outStateTreeCh)
+		close(mm.backupCh)
+		close(mm.recvCh)
+	})
+	return mm.closeErr
 }
 
 func (mm *MetaManager) ExportMetaCar(ctx context.Context, filepath string, root cid.Cid, lastBackup cid.Cid) error {
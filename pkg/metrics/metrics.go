@@ -31,16 +31,26 @@ var (
 
 	GetProviderHeadLatency = stats.Float64("get/provider/provider_head_latency",
 		"Time to respond to get provider's head", stats.UnitMilliseconds)
+	GetProviderChainLatency = stats.Float64("get/provider/provider_chain_latency",
+		"Time to respond to walk provider's chain", stats.UnitMilliseconds)
 
 	// metadata handlers
 	GetMetadataListLatency = stats.Float64("get/metadata/list_latency",
 		"Time to fetch metadata snapshots", stats.UnitMilliseconds)
 	GetMetadataSnapshotLatency = stats.Float64("get/metadata/snapshot_latency",
 		"Time to fetch snapshot info", stats.UnitMilliseconds)
+	GetMetadataSnapshotDiffLatency = stats.Float64("get/metadata/snapshot_diff_latency",
+		"Time to stream a snapshot diff", stats.UnitMilliseconds)
 	GetMetadataInclusionLatency = stats.Float64("get/metadata/inclusion_latency",
 		"Time to fetch meta inclusion", stats.UnitMilliseconds)
 	PostMetadataQueryLatency = stats.Float64("post/metadata/query_latency",
 		"Time to query metadata", stats.UnitMilliseconds)
+	GetMetadataBlockLatency = stats.Float64("get/metadata/block_latency",
+		"Time to fetch a metadata block", stats.UnitMilliseconds)
+
+	// car handlers
+	GetCarLatency = stats.Float64("get/ipfs/car_latency",
+		"Time to fetch the car of a dag", stats.UnitMilliseconds)
 
 	// go-legs graph persistence
 	GraphPersistenceLatency = stats.Float64("sync/graph/persistence_latency",
@@ -53,24 +63,35 @@ var (
 	// payload count received from provider
 	ProviderPayloadCount = stats.Int64("sync/payload/count",
 		"Provider payload count", stats.UnitDimensionless)
+
+	// API requests rejected by rate limiter
+	APIThrottledCount = stats.Int64("api/throttled/count",
+		"API requests throttled by rate limiter", stats.UnitDimensionless)
 )
 
 // Views
 var (
 	providerTagKey, _ = tag.NewKey("provider")
+	routeTagKey, _    = tag.NewKey("route")
+	limitTagKey, _    = tag.NewKey("limit")
 	bounds            = []float64{0, 1, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100, 200, 300, 400, 500, 1000, 2000, 5000}
 	builtinViews      = []*view.View{
 		{Measure: PostProviderRegisterLatency, Aggregation: view.Distribution(bounds...)},
 		{Measure: GetProviderHeadLatency, Aggregation: view.Distribution(bounds...)},
+		{Measure: GetProviderChainLatency, Aggregation: view.Distribution(bounds...)},
 		{Measure: GetRegisteredProviderInfoLatency, Aggregation: view.Distribution(bounds...)},
 		{Measure: GetPandoSubscribeLatency, Aggregation: view.Distribution(bounds...)},
 		{Measure: GetMetadataListLatency, Aggregation: view.Distribution(bounds...)},
 		{Measure: GetMetadataSnapshotLatency, Aggregation: view.Distribution(bounds...)},
+		{Measure: GetMetadataSnapshotDiffLatency, Aggregation: view.Distribution(bounds...)},
 		{Measure: GetMetadataInclusionLatency, Aggregation: view.Distribution(bounds...)},
 		{Measure: PostMetadataQueryLatency, Aggregation: view.Distribution(bounds...)},
+		{Measure: GetMetadataBlockLatency, Aggregation: view.Distribution(bounds...)},
+		{Measure: GetCarLatency, Aggregation: view.Distribution(bounds...)},
 		{Measure: GraphPersistenceLatency, Aggregation: view.Distribution(bounds...)},
 		{Measure: ProviderNotificationCount, Aggregation: view.Count(), TagKeys: []tag.Key{providerTagKey}},
 		{Measure: ProviderPayloadCount, Aggregation: view.Count(), TagKeys: []tag.Key{providerTagKey}},
+		{Measure: APIThrottledCount, Aggregation: view.Count(), TagKeys: []tag.Key{routeTagKey, limitTagKey}},
 	}
 )
 
@@ -97,6 +118,15 @@ func Counter(ctx context.Context, m *stats.Int64Measure, t string, c int64) func
 	}
 }
 
+// Throttled counts an API request of the route rejected by the limit(ip or key)
+func Throttled(ctx context.Context, route string, limit string) {
+	_ = stats.RecordWithOptions(
+		ctx,
+		stats.WithTags(tag.Insert(routeTagKey, route), tag.Insert(limitTagKey, limit)),
+		stats.WithMeasurements(APIThrottledCount.M(1)),
+	)
+}
+
 var logger = log.NewSubsystemLogger()
 
 // Handler creates an HTTP router for serving metric info
@@ -0,0 +1,272 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/kenlabs/pando/pkg/legs"
+	"github.com/kenlabs/pando/pkg/registry"
+	"github.com/kenlabs/pando/pkg/util/log"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"strings"
+	"time"
+)
+
+var logger = log.NewSubsystemLogger()
+
+// Version is the version of the exported documents, it is increased when the
+// format changes incompatibly.
+const Version = 1
+
+var ErrBadDocument = errors.New("bad migration document")
+
+// ErrPartialImport is returned with the result when the registry has been
+// imported but the sync heads have not
+var ErrPartialImport = errors.New("migration document partially imported")
+
+// Mode is how a document is imported
+type Mode string
+
+const (
+	// ModeMerge keeps the local entries on conflict
+	ModeMerge Mode = "merge"
+	// ModeReplace removes the local entries not in the document
+	ModeReplace Mode = "replace"
+)
+
+// ParseMode parses an import mode from string, empty for merge
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", ModeMerge:
+		return ModeMerge, nil
+	case ModeReplace:
+		return ModeReplace, nil
+	default:
+		return "", fmt.Errorf("unknown import mode: %q, should be merge or replace", s)
+	}
+}
+
+// SyncHead is the latest metadata synced from a provider
+type SyncHead struct {
+	Provider peer.ID
+	Cid      cid.Cid
+}
+
+// Document is the exported state of a Pando node, to migrate or seed other
+// nodes.  The metadata themselves are not included.
+type Document struct {
+	Version   int
+	Exported  time.Time
+	Registry  *registry.Snapshot
+	SyncHeads []*SyncHead
+}
+
+// Result is the number of entries imported
+type Result struct {
+	registry.ImportResult
+	SyncHeads int
+}
+
+// Validate checks the document before importing
+func (d *Document) Validate() error {
+	if d.Version != Version {
+		return fmt.Errorf("%w: unsupported version %d, expected %d", ErrBadDocument, d.Version, Version)
+	}
+	if d.Registry == nil {
+		return fmt.Errorf("%w: no registry", ErrBadDocument)
+	}
+	if err := d.Registry.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadDocument, err)
+	}
+
+	heads := make(map[peer.ID]struct{}, len(d.SyncHeads))
+	for _, head := range d.SyncHeads {
+		if head == nil {
+			return fmt.Errorf("%w: nil sync head", ErrBadDocument)
+		}
+		if err := head.Provider.Validate(); err != nil {
+			return fmt.Errorf("%w: invalid sync head provider: %v", ErrBadDocument, err)
+		}
+		if _, ok := heads[head.Provider]; ok {
+			return fmt.Errorf("%w: duplicate sync head of %s", ErrBadDocument, head.Provider)
+		}
+		heads[head.Provider] = struct{}{}
+		if !head.Cid.Defined() {
+			return fmt.Errorf("%w: undefined sync head cid of %s", ErrBadDocument, head.Provider)
+		}
+	}
+	return nil
+}
+
+// LatestSyncSetter sets the latest sync of a provider in use, it is
+// implemented by the legs subscriber.
+type LatestSyncSetter interface {
+	SetLatestSync(peerID peer.ID, latestSync cid.Cid) error
+}
+
+// Migrator exports and imports the registry and the sync heads of a node
+type Migrator struct {
+	reg *registry.Registry
+	ds  datastore.Batching
+	ls  LatestSyncSetter
+}
+
+// New creates a Migrator, ls may be nil if the node does not sync
+func New(reg *registry.Registry, ds datastore.Batching, ls LatestSyncSetter) *Migrator {
+	return &Migrator{
+		reg: reg,
+		ds:  ds,
+		ls:  ls,
+	}
+}
+
+// Export returns the document of the node
+func (m *Migrator) Export(ctx context.Context) (*Document, error) {
+	snapshot, err := m.reg.Export(ctx)
+	if err != nil {
+		return nil, err
+	}
+	heads, err := m.syncHeads(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read sync heads: %w", err)
+	}
+
+	return &Document{
+		Version:   Version,
+		Exported:  time.Now(),
+		Registry:  snapshot,
+		SyncHeads: heads,
+	}, nil
+}
+
+// Import validates and imports the document.  The sync heads are staged in a
+// batch before importing the registry, and committed after it, so nothing is
+// changed if the registry fails to import.  If the batch then fails to
+// commit, the result of the registry is returned with ErrPartialImport.  The
+// sync heads removed in replace mode are still known by the subscriber until
+// restart.
+func (m *Migrator) Import(ctx context.Context, doc *Document, mode Mode, operator string) (*Result, error) {
+	if doc == nil {
+		return nil, fmt.Errorf("%w: nil document", ErrBadDocument)
+	}
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+	replace := mode == ModeReplace
+
+	batch, heads, err := m.stageSyncHeads(ctx, doc.SyncHeads, replace)
+	if err != nil {
+		return nil, fmt.Errorf("cannot stage sync heads: %w", err)
+	}
+
+	imported, err := m.reg.Import(ctx, doc.Registry, replace, operator)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{ImportResult: *imported}
+
+	if err = batch.Commit(ctx); err == nil {
+		err = m.ds.Sync(ctx, datastore.NewKey(legs.SyncPrefix))
+	}
+	if err != nil {
+		return result, fmt.Errorf("%w: cannot import sync heads: %v", ErrPartialImport, err)
+	}
+	for _, head := range heads {
+		if m.ls != nil {
+			if err = m.ls.SetLatestSync(head.Provider, head.Cid); err != nil {
+				logger.Errorw("failed to set latest sync", "provider", head.Provider, "err", err)
+			}
+		}
+	}
+	result.SyncHeads = len(heads)
+
+	logger.Infow("imported migration document", "mode", mode, "exported", doc.Exported, "syncHeads", result.SyncHeads)
+	return result, nil
+}
+
+func (m *Migrator) syncHeads(ctx context.Context) ([]*SyncHead, error) {
+	results, err := m.ds.Query(ctx, query.Query{
+		Prefix: legs.SyncPrefix,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	heads := make([]*SyncHead, 0)
+	for result := range results.Next() {
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		head, err := decodeSyncHead(result.Entry)
+		if err != nil {
+			logger.Warnw("skipping invalid sync head", "key", result.Entry.Key, "err", err)
+			continue
+		}
+		heads = append(heads, head)
+	}
+	return heads, nil
+}
+
+func decodeSyncHead(ent query.Entry) (*SyncHead, error) {
+	_, c, err := cid.CidFromBytes(ent.Value)
+	if err != nil {
+		return nil, err
+	}
+	providerID, err := peer.Decode(strings.TrimPrefix(ent.Key, legs.SyncPrefix))
+	if err != nil {
+		return nil, err
+	}
+	return &SyncHead{Provider: providerID, Cid: c}, nil
+}
+
+func syncHeadKey(providerID peer.ID) datastore.Key {
+	return datastore.NewKey(legs.SyncPrefix + providerID.String())
+}
+
+// stageSyncHeads returns the batch of the sync head changes, and the sync heads
+// put in it.
+func (m *Migrator) stageSyncHeads(ctx context.Context, heads []*SyncHead, replace bool) (datastore.Batch, []*SyncHead, error) {
+	local, err := m.syncHeads(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	localHeads := make(map[peer.ID]struct{}, len(local))
+	for _, head := range local {
+		localHeads[head.Provider] = struct{}{}
+	}
+	batch, err := m.ds.Batch(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if replace {
+		imported := make(map[peer.ID]struct{}, len(heads))
+		for _, head := range heads {
+			imported[head.Provider] = struct{}{}
+		}
+		for id := range localHeads {
+			if _, ok := imported[id]; ok {
+				continue
+			}
+			if err = batch.Delete(ctx, syncHeadKey(id)); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	var staged []*SyncHead
+	for _, head := range heads {
+		if _, ok := localHeads[head.Provider]; ok && !replace {
+			continue
+		}
+		if err = batch.Put(ctx, syncHeadKey(head.Provider), head.Cid.Bytes()); err != nil {
+			return nil, nil, err
+		}
+		staged = append(staged, head)
+	}
+	return batch, staged, nil
+}
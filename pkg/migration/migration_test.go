@@ -0,0 +1,190 @@
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/kenlabs/pando/pkg/option"
+	"github.com/kenlabs/pando/pkg/registry"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+const testCid = "bafy2bzaceamsvbggjokf7bnmlzdkkhbcxbrkuvyqhhvx7vs36q4vvhrw5zghe"
+
+type mockSubscriber map[peer.ID]cid.Cid
+
+func (m mockSubscriber) SetLatestSync(peerID peer.ID, latestSync cid.Cid) error {
+	m[peerID] = latestSync
+	return nil
+}
+
+// failingCommitDs fails to commit the batches
+type failingCommitDs struct {
+	datastore.Batching
+}
+
+type failingBatch struct {
+	datastore.Batch
+}
+
+func (d failingCommitDs) Batch(ctx context.Context) (datastore.Batch, error) {
+	b, err := d.Batching.Batch(ctx)
+	return failingBatch{b}, err
+}
+
+func (failingBatch) Commit(context.Context) error {
+	return errors.New("commit failed")
+}
+
+func newRegistry(ds datastore.Datastore) (*registry.Registry, error) {
+	cfg := &option.Discovery{
+		Policy: option.Policy{
+			Allow: true,
+			Trust: true,
+		},
+		RediscoverWait: option.Duration(time.Minute).String(),
+	}
+	return registry.NewRegistry(context.Background(), cfg, &option.AccountLevel{Threshold: []int{1, 10, 99}}, ds, nil)
+}
+
+func newPeerID() peer.ID {
+	_, pubKey, err := crypto.GenerateEd25519Key(nil)
+	So(err, ShouldBeNil)
+	id, err := peer.IDFromPublicKey(pubKey)
+	So(err, ShouldBeNil)
+	return id
+}
+
+func TestExportImport(t *testing.T) {
+	Convey("test export a node and import into another", t, func() {
+		ctx := context.Background()
+		srcDs := dssync.MutexWrap(datastore.NewMapDatastore())
+		src, err := newRegistry(srcDs)
+		So(err, ShouldBeNil)
+		defer src.Close()
+
+		providerID := newPeerID()
+		bannedID := newPeerID()
+		headCid, err := cid.Decode(testCid)
+		So(err, ShouldBeNil)
+		So(src.Register(ctx, &registry.ProviderInfo{
+			AddrInfo: peer.AddrInfo{ID: providerID},
+			Name:     "exported",
+		}), ShouldBeNil)
+		So(src.Ban(ctx, bannedID, "spam"), ShouldBeNil)
+		So(src.UpdatePolicy(ctx, &registry.PolicyUpdate{Action: registry.PolicySetTrust, Value: false}, "admin"), ShouldBeNil)
+		seq := uint64(time.Now().UnixNano())
+		So(src.CheckSequence(providerID, seq), ShouldBeNil)
+		So(srcDs.Put(ctx, syncHeadKey(providerID), headCid.Bytes()), ShouldBeNil)
+
+		doc, err := New(src, srcDs, nil).Export(ctx)
+		So(err, ShouldBeNil)
+		So(doc.Version, ShouldEqual, Version)
+		So(len(doc.SyncHeads), ShouldEqual, 1)
+		// The document is transferred as JSON
+		docBytes, err := json.Marshal(doc)
+		So(err, ShouldBeNil)
+		doc = new(Document)
+		So(json.Unmarshal(docBytes, doc), ShouldBeNil)
+
+		dstDs := dssync.MutexWrap(datastore.NewMapDatastore())
+		dst, err := newRegistry(dstDs)
+		So(err, ShouldBeNil)
+		defer dst.Close()
+		localID := newPeerID()
+		So(dst.Register(ctx, &registry.ProviderInfo{
+			AddrInfo: peer.AddrInfo{ID: localID},
+			Name:     "local",
+		}), ShouldBeNil)
+		subscriber := mockSubscriber{}
+		m := New(dst, dstDs, subscriber)
+
+		Convey("merge keeps the local entries", func() {
+			res, err := m.Import(ctx, doc, ModeMerge, "admin")
+			So(err, ShouldBeNil)
+			So(res.Providers, ShouldEqual, 1)
+			So(res.Policy, ShouldBeTrue)
+			So(res.Banned, ShouldEqual, 1)
+			So(res.Sequences, ShouldEqual, 1)
+			So(res.SyncHeads, ShouldEqual, 1)
+
+			So(dst.IsRegistered(localID), ShouldBeTrue)
+			So(dst.ProviderInfo(providerID)[0].Name, ShouldEqual, "exported")
+			So(dst.IsBanned(bannedID), ShouldBeTrue)
+			So(dst.Policy().Trust, ShouldBeFalse)
+			So(dst.CheckSequence(providerID, seq), ShouldNotBeNil)
+			So(subscriber[providerID], ShouldResemble, headCid)
+
+			// Import again changes nothing
+			res, err = m.Import(ctx, doc, ModeMerge, "admin")
+			So(err, ShouldBeNil)
+			So(res.Providers, ShouldEqual, 0)
+			So(res.Policy, ShouldBeFalse)
+			So(res.SyncHeads, ShouldEqual, 0)
+		})
+
+		Convey("merge skips the providers banned locally", func() {
+			So(dst.Ban(ctx, providerID, "local ban"), ShouldBeNil)
+			res, err := m.Import(ctx, doc, ModeMerge, "admin")
+			So(err, ShouldBeNil)
+			So(res.Providers, ShouldEqual, 0)
+			So(res.SkippedBanned, ShouldResemble, []peer.ID{providerID})
+			So(dst.IsRegistered(providerID), ShouldBeFalse)
+		})
+
+		Convey("the registry import is reported when the sync heads fail", func() {
+			res, err := New(dst, failingCommitDs{dstDs}, subscriber).Import(ctx, doc, ModeMerge, "admin")
+			So(errors.Is(err, ErrPartialImport), ShouldBeTrue)
+			So(res.Providers, ShouldEqual, 1)
+			So(res.SyncHeads, ShouldEqual, 0)
+			So(dst.IsRegistered(providerID), ShouldBeTrue)
+			has, err := dstDs.Has(ctx, syncHeadKey(providerID))
+			So(err, ShouldBeNil)
+			So(has, ShouldBeFalse)
+			So(subscriber, ShouldBeEmpty)
+		})
+
+		Convey("replace removes the local entries", func() {
+			_, err := m.Import(ctx, doc, ModeReplace, "admin")
+			So(err, ShouldBeNil)
+			So(dst.IsRegistered(localID), ShouldBeFalse)
+			So(dst.IsRegistered(providerID), ShouldBeTrue)
+
+			exported, err := m.Export(ctx)
+			So(err, ShouldBeNil)
+			So(len(exported.Registry.Providers), ShouldEqual, 1)
+			So(exported.SyncHeads[0].Cid, ShouldResemble, headCid)
+		})
+
+		Convey("invalid documents are rejected before importing", func() {
+			bad := *doc
+			bad.Version = Version + 1
+			_, err := m.Import(ctx, &bad, ModeMerge, "admin")
+			So(err, ShouldNotBeNil)
+
+			bad = *doc
+			bad.SyncHeads = []*SyncHead{{Provider: providerID}}
+			_, err = m.Import(ctx, &bad, ModeMerge, "admin")
+			So(err, ShouldNotBeNil)
+
+			snapshot := *doc.Registry
+			snapshot.Providers = append(snapshot.Providers, snapshot.Providers[0])
+			bad = *doc
+			bad.Registry = &snapshot
+			_, err = m.Import(ctx, &bad, ModeMerge, "admin")
+			So(err, ShouldNotBeNil)
+
+			So(dst.IsRegistered(providerID), ShouldBeFalse)
+		})
+
+		_, err = ParseMode("unknown")
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,46 @@
+package option
+
+import "time"
+
+const (
+	defaultAPIRateLimitEnable = false
+	defaultAPIIPRate          = 10.0
+	defaultAPIIPBurst         = 20
+	defaultAPIKeyRate         = 50.0
+	defaultAPIKeyBurst        = 100
+	defaultAPIIdleTimeout     = Duration(10 * time.Minute)
+)
+
+// defaultAPIRouteCosts charge the expensive queries more tokens
+var defaultAPIRouteCosts = []APIRouteCost{
+	{Route: "POST /metadata/query", Cost: 10},
+	{Route: "POST /search", Cost: 5},
+}
+
+// APIRateLimit limits the requests to the HTTP and GraphQL APIs, the clients
+// are limited by API key if authenticated, or by IP.
+type APIRateLimit struct {
+	Enable bool `yaml:"Enable"`
+	// IPRate is the number of tokens per second of each client IP
+	IPRate  float64 `yaml:"IPRate"`
+	IPBurst int     `yaml:"IPBurst"`
+	// KeyRate is the number of tokens per second of each API key
+	KeyRate  float64 `yaml:"KeyRate"`
+	KeyBurst int     `yaml:"KeyBurst"`
+	// IdleTimeout is the time the token bucket of an idle client is kept
+	IdleTimeout string `yaml:"IdleTimeout"`
+	// RouteCosts are the tokens taken by the requests of the routes, the other
+	// routes take 1 token.
+	RouteCosts []APIRouteCost `yaml:"RouteCosts"`
+}
+
+// APIRouteCost is the number of tokens a request of the route takes
+type APIRouteCost struct {
+	// Route is the method and the path pattern, like "POST /metadata/query"
+	Route string `yaml:"Route"`
+	Cost  int    `yaml:"Cost"`
+}
+
+func (a *APIRateLimit) IdleTimeoutInDurationFormat() Duration {
+	return unmarshalDurationString(a.IdleTimeout)
+}
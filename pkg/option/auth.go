@@ -0,0 +1,28 @@
+package option
+
+import "time"
+
+const (
+	defaultAuthEnable          = false
+	defaultAuthAnonymousRead   = true
+	defaultAuthSignatureMaxAge = Duration(5 * time.Minute)
+	defaultAuthAdminKeyFile    = "admin.key"
+)
+
+// Auth authenticates the requests to the HTTP, GraphQL and admin APIs by API
+// keys or by the signatures of the peers.
+type Auth struct {
+	Enable bool `yaml:"Enable"`
+	// AnonymousRead allows the requests without credentials to read the HTTP
+	// and GraphQL APIs
+	AnonymousRead bool `yaml:"AnonymousRead"`
+	// SignatureMaxAge is the time a peer-signed request is valid
+	SignatureMaxAge string `yaml:"SignatureMaxAge"`
+	// AdminKeyFile is the file under PandoRoot the bootstrap admin key is
+	// written to, when there is no admin key
+	AdminKeyFile string `yaml:"AdminKeyFile"`
+}
+
+func (a *Auth) SignatureMaxAgeInDurationFormat() Duration {
+	return unmarshalDurationString(a.SignatureMaxAge)
+}
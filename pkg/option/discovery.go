@@ -5,12 +5,14 @@ import (
 )
 
 const (
-	defaultLotusGateway     = "https://api.chain.love"
-	defaultPollInterval     = Duration(24 * time.Hour)
-	defaultPollRetryAfter   = Duration(5 * time.Hour)
-	defaultPollStopAfter    = Duration(7 * 24 * time.Hour)
-	defaultRediscoverWait   = Duration(5 * time.Minute)
-	defaultDiscoveryTimeout = Duration(2 * time.Minute)
+	defaultLotusGateway      = "https://api.chain.love"
+	defaultPollInterval      = Duration(24 * time.Hour)
+	defaultPollRetryAfter    = Duration(5 * time.Hour)
+	defaultPollStopAfter     = Duration(7 * 24 * time.Hour)
+	defaultRediscoverWait    = Duration(5 * time.Minute)
+	defaultRediscoverEvery   = Duration(24 * time.Hour)
+	defaultDiscoveryTimeout  = Duration(2 * time.Minute)
+	defaultDiscoveryCacheTTL = Duration(10 * time.Minute)
 )
 
 type Discovery struct {
@@ -32,7 +34,45 @@ type Discovery struct {
 	PollStopAfter  string `yaml:"PollStopAfter"`
 	Policy         Policy `yaml:"Policy"`
 	RediscoverWait string `yaml:"RediscoverWait"`
-	Timeout        string `yaml:"Timeout"`
+	// RediscoverInterval is the amount of time between re-discoveries of the
+	// providers with miner accounts, to refresh their account levels.  Zero
+	// disables re-discovery.
+	RediscoverInterval string `yaml:"RediscoverInterval"`
+	Timeout            string `yaml:"Timeout"`
+	// LotusTimeout bounds each discovery of Filecoin addresses by
+	// LotusGateway, empty to be bounded by Timeout only.
+	LotusTimeout string       `yaml:"LotusTimeout"`
+	EVM          EVMDiscovery `yaml:"EVM"`
+	DNS          DNSDiscovery `yaml:"DNS"`
+	// CacheTTL is the amount of time to cache the discovered result of each
+	// discovery address.  Zero disables caching.
+	CacheTTL string `yaml:"CacheTTL"`
+}
+
+// EVMDiscovery discovers the providers identified by EVM account addresses
+// (0x...), by querying the balance from a JSON-RPC endpoint.
+type EVMDiscovery struct {
+	// Endpoint is the JSON-RPC URL, empty disables EVM discovery.
+	Endpoint string `yaml:"Endpoint"`
+	Timeout  string `yaml:"Timeout"`
+}
+
+// DNSDiscovery discovers the providers identified by domain names, which
+// publish their peer ids in TXT records.
+type DNSDiscovery struct {
+	Enable bool `yaml:"Enable"`
+	// Resolver is the address(host:port) of the DNS server, empty to use the
+	// system resolver.
+	Resolver string `yaml:"Resolver"`
+	Timeout  string `yaml:"Timeout"`
+}
+
+func (e *EVMDiscovery) TimeoutInDurationFormat() Duration {
+	return unmarshalDurationString(e.Timeout)
+}
+
+func (d *DNSDiscovery) TimeoutInDurationFormat() Duration {
+	return unmarshalDurationString(d.Timeout)
 }
 
 func (d *Discovery) PollIntervalInDurationFormat() Duration {
@@ -51,10 +91,22 @@ func (d *Discovery) RediscoverWaitInDurationFormat() Duration {
 	return unmarshalDurationString(d.RediscoverWait)
 }
 
+func (d *Discovery) RediscoverIntervalInDurationFormat() Duration {
+	return unmarshalDurationString(d.RediscoverInterval)
+}
+
 func (d *Discovery) TimeoutInDurationFormat() Duration {
 	return unmarshalDurationString(d.Timeout)
 }
 
+func (d *Discovery) LotusTimeoutInDurationFormat() Duration {
+	return unmarshalDurationString(d.LotusTimeout)
+}
+
+func (d *Discovery) CacheTTLInDurationFormat() Duration {
+	return unmarshalDurationString(d.CacheTTL)
+}
+
 func unmarshalDurationString(durationStr string) Duration {
 	d := Duration(0)
 	_ = d.UnmarshalText([]byte(durationStr))
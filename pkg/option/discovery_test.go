@@ -10,15 +10,30 @@ func TestDiscoveryFormat(t *testing.T) {
 	Convey("test discovery format", t, func() {
 		durationText := "1s"
 		ds := &Discovery{
-			PollInterval:   durationText,
-			RediscoverWait: durationText,
-			Timeout:        durationText,
+			PollInterval:       durationText,
+			RediscoverWait:     durationText,
+			RediscoverInterval: durationText,
+			Timeout:            durationText,
+			LotusTimeout:       durationText,
+			CacheTTL:           durationText,
+			EVM:                EVMDiscovery{Timeout: durationText},
+			DNS:                DNSDiscovery{Timeout: durationText},
 		}
 		d := ds.PollIntervalInDurationFormat()
 		So(d, ShouldResemble, Duration(time.Second))
 		d = ds.RediscoverWaitInDurationFormat()
 		So(d, ShouldResemble, Duration(time.Second))
+		d = ds.RediscoverIntervalInDurationFormat()
+		So(d, ShouldResemble, Duration(time.Second))
 		d = ds.TimeoutInDurationFormat()
 		So(d, ShouldResemble, Duration(time.Second))
+		d = ds.LotusTimeoutInDurationFormat()
+		So(d, ShouldResemble, Duration(time.Second))
+		d = ds.CacheTTLInDurationFormat()
+		So(d, ShouldResemble, Duration(time.Second))
+		d = ds.EVM.TimeoutInDurationFormat()
+		So(d, ShouldResemble, Duration(time.Second))
+		d = ds.DNS.TimeoutInDurationFormat()
+		So(d, ShouldResemble, Duration(time.Second))
 	})
 }
@@ -0,0 +1,34 @@
+package option
+
+import "time"
+
+const (
+	defaultHealthCheckTimeout         = Duration(5 * time.Second)
+	defaultHealthMaxSyncBacklog       = 100
+	defaultHealthMaxBackupFiles       = 10
+	defaultHealthGatewayCheckInterval = Duration(time.Minute)
+)
+
+// Health configures the health checks of the components for the readiness
+type Health struct {
+	// CheckTimeout is the time a check of a component can take, the
+	// component fails if its check is over it.
+	CheckTimeout string `yaml:"CheckTimeout"`
+	// MaxSyncBacklog is the number of the pending syncs with the publishers
+	// over which the sync component warns.
+	MaxSyncBacklog int `yaml:"MaxSyncBacklog"`
+	// MaxBackupFiles is the number of the CAR files waiting for backup over
+	// which the backup component warns.
+	MaxBackupFiles int `yaml:"MaxBackupFiles"`
+	// GatewayCheckInterval is the time the reachability of the backup
+	// gateways is cached, so that the probes do not request them each time.
+	GatewayCheckInterval string `yaml:"GatewayCheckInterval"`
+}
+
+func (h *Health) CheckTimeoutInDurationFormat() Duration {
+	return unmarshalDurationString(h.CheckTimeout)
+}
+
+func (h *Health) GatewayCheckIntervalInDurationFormat() Duration {
+	return unmarshalDurationString(h.GatewayCheckInterval)
+}
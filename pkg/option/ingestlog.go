@@ -0,0 +1,23 @@
+package option
+
+import "time"
+
+const (
+	defaultIngestLogMaxEvents            = 1000000
+	defaultIngestLogSnapShotPollInterval = Duration(5 * time.Second)
+)
+
+// IngestLog configures the persisted log of the ingestion events streamed to
+// the consumers.
+type IngestLog struct {
+	// MaxEvents is the number of the latest events kept, the older events are
+	// pruned and cannot be resumed from.
+	MaxEvents int `yaml:"MaxEvents"`
+	// SnapShotPollInterval is the interval of checking the new snapshots for
+	// the snapshot heights assigned to the ingested metadata.
+	SnapShotPollInterval string `yaml:"SnapShotPollInterval"`
+}
+
+func (l *IngestLog) SnapShotPollIntervalInDurationFormat() Duration {
+	return unmarshalDurationString(l.SnapShotPollInterval)
+}
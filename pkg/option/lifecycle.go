@@ -0,0 +1,20 @@
+package option
+
+import "time"
+
+const (
+	defaultShutdownTimeout = Duration(30 * time.Second)
+)
+
+// Lifecycle configures the start and the shutdown of the components of the
+// daemon
+type Lifecycle struct {
+	// ShutdownTimeout is the deadline of the graceful shutdown.  The pending
+	// syncs and backup uploads are drained within it, then the components
+	// are stopped without waiting for them.
+	ShutdownTimeout string `yaml:"ShutdownTimeout"`
+}
+
+func (l *Lifecycle) ShutdownTimeoutInDurationFormat() Duration {
+	return unmarshalDurationString(l.ShutdownTimeout)
+}
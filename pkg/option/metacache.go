@@ -1,14 +1,29 @@
 package option
 
-import "go.mongodb.org/mongo-driver/mongo"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
 
 const (
 	defaultMetaCacheType          = "mongodb"
 	defaultMetaCacheConnectionURI = "mongodb://47.88.56.82:27018"
+	defaultMetaCacheQueryMaxLimit = 1000
+	defaultMetaCacheQueryTimeout  = Duration(10 * time.Second)
 )
 
 type MetaCache struct {
 	Type          string `yaml:"Type"`
 	ConnectionURI string `yaml:"ConnectionURI"`
-	Client        *mongo.Client
+	// QueryMaxLimit is the max number of the results of a metadata query, and
+	// the limit of the queries without limit.
+	QueryMaxLimit int `yaml:"QueryMaxLimit"`
+	// QueryTimeout is the time a metadata query can run.
+	QueryTimeout string `yaml:"QueryTimeout"`
+	Client       *mongo.Client
+}
+
+func (m *MetaCache) QueryTimeoutInDurationFormat() Duration {
+	return unmarshalDurationString(m.QueryTimeout)
 }
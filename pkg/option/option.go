@@ -39,7 +39,13 @@ type DaemonOptions struct {
 	Discovery     Discovery     `yaml:"Discovery"`
 	AccountLevel  AccountLevel  `yaml:"AccountLevel"`
 	RateLimit     RateLimit     `yaml:"RateLimit"`
+	APIRateLimit  APIRateLimit  `yaml:"APIRateLimit"`
+	Auth          Auth          `yaml:"Auth"`
 	Backup        Backup        `yaml:"Backup"`
+	Webhook       Webhook       `yaml:"Webhook"`
+	IngestLog     IngestLog     `yaml:"IngestLog"`
+	Health        Health        `yaml:"Health"`
+	Lifecycle     Lifecycle     `yaml:"Lifecycle"`
 }
 
 // New creates a default DaemonOptions.
@@ -96,6 +102,9 @@ func New(root *cobra.Command) *DaemonOptions {
 	opt.flags.StringVar(&opt.ServerAddress.ProfileListenAddress, "profile-listen-addr", defaultProfileListenAddress,
 		fmt.Sprintf("Profile server listen address(in multiaddress format, like %s).", defaultProfileListenAddress))
 
+	opt.flags.BoolVar(&opt.ServerAddress.DisableProfileServer, "profile-server-disable", defaultDisableProfileServer,
+		"Disable profile server.")
+
 	opt.flags.BoolVar(&opt.ServerAddress.DisableP2PServer, "p2p-server-disable", defaultDisableP2PServer,
 		"Disable libp2p server.")
 
@@ -105,6 +114,22 @@ func New(root *cobra.Command) *DaemonOptions {
 	opt.flags.StringVar(&opt.ServerAddress.ExternalIP, "external-ip", "unknown",
 		fmt.Sprintf("Pando public IP address for API serve"))
 
+	opt.flags.StringVar(&opt.ServerAddress.TLSCertFile, "tls-cert-file", "",
+		"TLS certificate file of the HTTP servers, reloaded when it changes.")
+
+	opt.flags.StringVar(&opt.ServerAddress.TLSKeyFile, "tls-key-file", "",
+		"TLS key file of the HTTP servers, reloaded when it changes.")
+
+	opt.ServerAddress.ReadHeaderTimeout = defaultReadHeaderTimeout.String()
+
+	opt.ServerAddress.ReadTimeout = defaultReadTimeout.String()
+
+	opt.ServerAddress.WriteTimeout = defaultWriteTimeout.String()
+
+	opt.ServerAddress.IdleTimeout = defaultIdleTimeout.String()
+
+	opt.ServerAddress.MaxRequestBodySize = defaultMaxRequestBodySize
+
 	// options for datastore
 	opt.flags.StringVar(&opt.DataStore.Type, "datastore-type", defaultDataStoreType,
 		"Datastore type, support levelds only for now.")
@@ -126,6 +151,10 @@ func New(root *cobra.Command) *DaemonOptions {
 	opt.flags.StringVar(&opt.MetaCache.ConnectionURI, "metacache-connection-uri", defaultMetaCacheConnectionURI,
 		"Connection URI of metacache")
 
+	opt.flags.IntVar(&opt.MetaCache.QueryMaxLimit, "metacache-query-max-limit", defaultMetaCacheQueryMaxLimit,
+		"Max number of the results of a metadata query")
+	opt.MetaCache.QueryTimeout = defaultMetaCacheQueryTimeout.String()
+
 	// options for discovery
 	opt.flags.StringVar(&opt.Discovery.LotusGateway, "discovery-lotus-gateway", defaultLotusGateway,
 		"Lotus gateway address.")
@@ -144,8 +173,19 @@ func New(root *cobra.Command) *DaemonOptions {
 
 	opt.Discovery.RediscoverWait = defaultRediscoverWait.String()
 
+	opt.flags.StringVar(&opt.Discovery.RediscoverInterval, "discovery-rediscover-interval", defaultRediscoverEvery.String(),
+		"Interval for Pando to re-discover providers and refresh their account levels, 0 to disable.")
+
 	opt.Discovery.Timeout = defaultDiscoveryTimeout.String()
 
+	opt.flags.StringVar(&opt.Discovery.EVM.Endpoint, "discovery-evm-endpoint", "",
+		"JSON-RPC endpoint to discover providers by EVM addresses, empty to disable.")
+
+	opt.flags.BoolVar(&opt.Discovery.DNS.Enable, "discovery-dns-enable", false,
+		"Enable discovering providers by domain names.")
+
+	opt.Discovery.CacheTTL = defaultDiscoveryCacheTTL.String()
+
 	// options for rate limits
 	opt.flags.IntSliceVar(&opt.AccountLevel.Threshold, "account-level", defaultAccountLevel,
 		"Rank the accounts then set rate limits for them.")
@@ -159,6 +199,41 @@ func New(root *cobra.Command) *DaemonOptions {
 	opt.flags.Float64Var(&opt.RateLimit.SingleDAGSize, "ratelimit-single-dag-size", defaultSingleDAGSize,
 		"Estimated single DAG size to receive from providers.")
 
+	opt.RateLimit.PeerIdleTimeout = defaultPeerIdleTimeout.String()
+	opt.RateLimit.ReconcileInterval = defaultReconcileInterval.String()
+
+	opt.flags.BoolVar(&opt.RateLimit.Consumer.Enable, "ratelimit-consumer-enable", defaultEnable,
+		"Enable rate limiter of the consumers fetching from Pando (default: false).")
+
+	// options for API rate limits
+	opt.flags.BoolVar(&opt.APIRateLimit.Enable, "api-ratelimit-enable", defaultAPIRateLimitEnable,
+		"Enable rate limiter of the HTTP and GraphQL APIs (default: false).")
+
+	opt.flags.Float64Var(&opt.APIRateLimit.IPRate, "api-ratelimit-ip-rate", defaultAPIIPRate,
+		"Tokens per second of each client IP of the APIs.")
+
+	opt.APIRateLimit.IPBurst = defaultAPIIPBurst
+
+	opt.flags.Float64Var(&opt.APIRateLimit.KeyRate, "api-ratelimit-key-rate", defaultAPIKeyRate,
+		"Tokens per second of each API key of the APIs.")
+
+	opt.APIRateLimit.KeyBurst = defaultAPIKeyBurst
+
+	opt.APIRateLimit.IdleTimeout = defaultAPIIdleTimeout.String()
+
+	opt.APIRateLimit.RouteCosts = append([]APIRouteCost(nil), defaultAPIRouteCosts...)
+
+	// options for API authentication
+	opt.flags.BoolVar(&opt.Auth.Enable, "auth-enable", defaultAuthEnable,
+		"Enable authentication of the HTTP, GraphQL and admin APIs (default: false).")
+
+	opt.flags.BoolVar(&opt.Auth.AnonymousRead, "auth-anonymous-read", defaultAuthAnonymousRead,
+		"Allow the requests without credentials to read the HTTP and GraphQL APIs.")
+
+	opt.Auth.SignatureMaxAge = defaultAuthSignatureMaxAge.String()
+
+	opt.Auth.AdminKeyFile = defaultAuthAdminKeyFile
+
 	// options for backup
 	opt.flags.StringVar(&opt.Backup.EstuaryGateway, "backup-estuary-gateway", defaultEstGateway,
 		"Estuary gateway address used to backup metadata files.")
@@ -178,6 +253,33 @@ func New(root *cobra.Command) *DaemonOptions {
 	opt.flags.StringVar(&opt.Backup.EstCheckInterval, "backup-check-estuary-interval", defaultEstCheckInterval.String(),
 		"Interval for Pando to check backup deal status in estuary.")
 
+	// options for webhook
+	opt.flags.IntVar(&opt.Webhook.MaxRetries, "webhook-max-retries", defaultWebhookMaxRetries,
+		"Number of retries of a failed webhook delivery.")
+
+	opt.Webhook.RetryInterval = defaultWebhookRetryInterval.String()
+
+	opt.Webhook.Timeout = defaultWebhookTimeout.String()
+
+	// options for ingest log
+	opt.flags.IntVar(&opt.IngestLog.MaxEvents, "ingest-log-max-events", defaultIngestLogMaxEvents,
+		"Number of the latest ingestion events kept for the consumers to resume from.")
+
+	opt.IngestLog.SnapShotPollInterval = defaultIngestLogSnapShotPollInterval.String()
+
+	// options for health checks
+	opt.Health.CheckTimeout = defaultHealthCheckTimeout.String()
+
+	opt.Health.MaxSyncBacklog = defaultHealthMaxSyncBacklog
+
+	opt.Health.MaxBackupFiles = defaultHealthMaxBackupFiles
+
+	opt.Health.GatewayCheckInterval = defaultHealthGatewayCheckInterval.String()
+
+	// options for lifecycle
+	opt.flags.StringVar(&opt.Lifecycle.ShutdownTimeout, "shutdown-timeout", defaultShutdownTimeout.String(),
+		"Deadline of draining the pending syncs and backup uploads on shutdown.")
+
 	_ = opt.viper.BindPFlags(opt.flags)
 
 	return opt
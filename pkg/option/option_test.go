@@ -27,10 +27,35 @@ func TestOptions(t *testing.T) {
 			So(opt.DataStore.Type, ShouldEqual, defaultDataStoreType)
 			So(opt.DataStore.Dir, ShouldEqual, defaultDataStoreDir)
 			So(opt.Discovery.Policy.Allow, ShouldEqual, defaultAllow)
+			So(opt.MetaCache.QueryMaxLimit, ShouldEqual, defaultMetaCacheQueryMaxLimit)
+			So(opt.MetaCache.QueryTimeout, ShouldEqual, defaultMetaCacheQueryTimeout.String())
+			So(opt.Health.CheckTimeout, ShouldEqual, defaultHealthCheckTimeout.String())
+			So(opt.Health.MaxSyncBacklog, ShouldEqual, defaultHealthMaxSyncBacklog)
+			So(opt.Health.MaxBackupFiles, ShouldEqual, defaultHealthMaxBackupFiles)
+			So(opt.Health.GatewayCheckInterval, ShouldEqual, defaultHealthGatewayCheckInterval.String())
+			So(opt.ServerAddress.ProfileListenAddress, ShouldEqual, defaultProfileListenAddress)
+			So(opt.ServerAddress.DisableProfileServer, ShouldEqual, defaultDisableProfileServer)
+			So(opt.ServerAddress.TLSEnabled(), ShouldBeFalse)
+			So(opt.ServerAddress.ReadHeaderTimeout, ShouldEqual, defaultReadHeaderTimeout.String())
+			So(opt.ServerAddress.ReadTimeout, ShouldEqual, defaultReadTimeout.String())
+			So(opt.ServerAddress.WriteTimeout, ShouldEqual, defaultWriteTimeout.String())
+			So(opt.ServerAddress.IdleTimeout, ShouldEqual, defaultIdleTimeout.String())
+			So(opt.ServerAddress.MaxRequestBodySize, ShouldEqual, defaultMaxRequestBodySize)
+			So(opt.ServerAddress.CORSAllowOrigins, ShouldBeEmpty)
+			So(opt.Lifecycle.ShutdownTimeout, ShouldEqual, defaultShutdownTimeout.String())
 			So(opt.Discovery.LotusGateway, ShouldEqual, defaultLotusGateway)
 			So(opt.Discovery.Timeout, ShouldEqual, defaultDiscoveryTimeout.String())
 			So(opt.Discovery.PollInterval, ShouldEqual, defaultPollInterval.String())
 			So(opt.Discovery.RediscoverWait, ShouldEqual, defaultRediscoverWait.String())
+			So(opt.Discovery.RediscoverInterval, ShouldEqual, defaultRediscoverEvery.String())
+			So(opt.Discovery.CacheTTL, ShouldEqual, defaultDiscoveryCacheTTL.String())
+			So(opt.Discovery.EVM.Endpoint, ShouldBeEmpty)
+			So(opt.Discovery.DNS.Enable, ShouldBeFalse)
+			So(opt.Webhook.MaxRetries, ShouldEqual, defaultWebhookMaxRetries)
+			So(opt.Webhook.RetryInterval, ShouldEqual, defaultWebhookRetryInterval.String())
+			So(opt.Webhook.Timeout, ShouldEqual, defaultWebhookTimeout.String())
+			So(opt.IngestLog.MaxEvents, ShouldEqual, defaultIngestLogMaxEvents)
+			So(opt.IngestLog.SnapShotPollInterval, ShouldEqual, defaultIngestLogSnapShotPollInterval.String())
 			So(opt.AccountLevel.Threshold, ShouldResemble, defaultAccountLevel)
 			So(opt.RateLimit.SingleDAGSize, ShouldEqual, defaultSingleDAGSize)
 			So(opt.Backup.EstuaryGateway, ShouldEqual, defaultEstGateway)
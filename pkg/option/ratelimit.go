@@ -1,13 +1,80 @@
 package option
 
+import "time"
+
 const (
-	defaultEnable        = false
-	defaultBandwidth     = 1.0 // Mbps
-	defaultSingleDAGSize = 1.0 // Mb
+	defaultEnable            = false
+	defaultBandwidth         = 1.0 // Mbps
+	defaultSingleDAGSize     = 1.0 // Mb
+	defaultPeerIdleTimeout   = Duration(30 * time.Minute)
+	defaultReconcileInterval = Duration(5 * time.Minute)
 )
 
 type RateLimit struct {
 	Enable        bool    `yaml:"Enable"`
 	Bandwidth     float64 `yaml:"Bandwidth"`
 	SingleDAGSize float64 `yaml:"SingleDAGSize"`
+	// PeerIdleTimeout is the time the rate limiter of an idle peer is kept, 0
+	// to keep forever.
+	PeerIdleTimeout string `yaml:"PeerIdleTimeout"`
+	// ReconcileInterval is how often the rate limiters of all the peers are
+	// re-assigned against the registry, 0 to follow the registry events only.
+	ReconcileInterval string `yaml:"ReconcileInterval"`
+	// Policy assigns the peers to rate tiers, the built-in tiers are used if
+	// it is empty.
+	Policy RatePolicy `yaml:"Policy"`
+	// Consumer limits the consumers fetching DAGs from Pando
+	Consumer ConsumerRateLimit `yaml:"Consumer"`
+}
+
+// ConsumerRateLimit limits the consumers by their own tiers, the base rate is
+// the same as the one of the providers.
+type ConsumerRateLimit struct {
+	Enable bool       `yaml:"Enable"`
+	Policy RatePolicy `yaml:"Policy"`
+}
+
+func (r *RateLimit) PeerIdleTimeoutInDurationFormat() Duration {
+	return unmarshalDurationString(r.PeerIdleTimeout)
+}
+
+func (r *RateLimit) ReconcileIntervalInDurationFormat() Duration {
+	return unmarshalDurationString(r.ReconcileInterval)
+}
+
+// RatePolicy defines the rate tiers and how the peers are assigned to them
+type RatePolicy struct {
+	Tiers []RateTier `yaml:"Tiers"`
+	// Unregistered is the tier of the peers not registered
+	Unregistered string `yaml:"Unregistered"`
+	// Whitelist is the tier of the trusted peers
+	Whitelist string `yaml:"Whitelist"`
+	// Levels are the tiers of the registered peers from account level 1, the
+	// higher levels use the last one.  Empty to weight the rate by account
+	// level as the built-in policy.
+	Levels []string `yaml:"Levels"`
+	// Overrides assign the peers to tiers regardless of their types
+	Overrides []RateOverride `yaml:"Overrides"`
+}
+
+// RateTier is a named set of limits shared by the peers in it
+type RateTier struct {
+	Name string `yaml:"Name"`
+	// Rate is the number of DAG sync requests per second
+	Rate float64 `yaml:"Rate"`
+	// RateFactor is the rate as a multiple of the base rate measured from the
+	// bandwidth, used if Rate is zero.
+	RateFactor float64 `yaml:"RateFactor"`
+	// Burst is the number of requests allowed at once, zero for the rate
+	// rounded up.
+	Burst int `yaml:"Burst"`
+	// ByteBudget is the number of bytes per second transferred, zero for
+	// unlimited.
+	ByteBudget int64 `yaml:"ByteBudget"`
+}
+
+// RateOverride assigns a peer to a tier
+type RateOverride struct {
+	PeerID string `yaml:"PeerID"`
+	Tier   string `yaml:"Tier"`
 }
@@ -1,5 +1,7 @@
 package option
 
+import "time"
+
 const (
 	defaultAdminListenAddress   = "/ip4/127.0.0.1/tcp/8999"
 	defaultHttpAPIListenAddress = "/ip4/0.0.0.0/tcp/9000"
@@ -8,7 +10,14 @@ const (
 	defaultDisableP2PServer = false
 	defaultP2PAddress       = "/ip4/0.0.0.0/tcp/9002"
 
-	defaultProfileListenAddress = "/ip4/0.0.0.0/tcp/9010"
+	defaultDisableProfileServer = false
+	defaultProfileListenAddress = "/ip4/127.0.0.1/tcp/9010"
+
+	defaultReadHeaderTimeout  = Duration(10 * time.Second)
+	defaultReadTimeout        = Duration(time.Minute)
+	defaultWriteTimeout       = Duration(0)
+	defaultIdleTimeout        = Duration(2 * time.Minute)
+	defaultMaxRequestBodySize = 32 << 20
 )
 
 type ServerAddress struct {
@@ -19,7 +28,54 @@ type ServerAddress struct {
 	DisableP2PServer bool   `yaml:"DisableP2PServer"`
 	P2PAddress       string `yaml:"P2PAddress"`
 
+	DisableProfileServer bool   `yaml:"DisableProfileServer"`
 	ProfileListenAddress string `yaml:"ProfileListenAddress"`
 
 	ExternalIP string `yaml:"ExternalIP"`
+
+	// TLSCertFile and TLSKeyFile serve the HTTP listeners over TLS if both are
+	// set, the files are reloaded when they change.
+	TLSCertFile string `yaml:"TLSCertFile"`
+	TLSKeyFile  string `yaml:"TLSKeyFile"`
+
+	// The timeouts of the HTTP listeners, 0 for no timeout.  WriteTimeout
+	// also bounds the streams of the events, the diffs and the CARs.
+	ReadHeaderTimeout string `yaml:"ReadHeaderTimeout"`
+	ReadTimeout       string `yaml:"ReadTimeout"`
+	WriteTimeout      string `yaml:"WriteTimeout"`
+	IdleTimeout       string `yaml:"IdleTimeout"`
+
+	// MaxRequestBodySize is the max size in bytes of the request bodies, 0
+	// for no limit.
+	MaxRequestBodySize int64 `yaml:"MaxRequestBodySize"`
+
+	// CORSAllowOrigins are the origins allowed by CORS, all the origins are
+	// allowed if it is empty.
+	CORSAllowOrigins []string `yaml:"CORSAllowOrigins"`
+
+	// TrustedProxies are the IPs or CIDRs of the reverse proxies whose
+	// X-Forwarded-For and X-Real-IP headers give the client IPs, the headers
+	// are ignored if it is empty.
+	TrustedProxies []string `yaml:"TrustedProxies"`
+}
+
+// TLSEnabled checks if the HTTP listeners are served over TLS
+func (s *ServerAddress) TLSEnabled() bool {
+	return s.TLSCertFile != "" && s.TLSKeyFile != ""
+}
+
+func (s *ServerAddress) ReadHeaderTimeoutInDurationFormat() Duration {
+	return unmarshalDurationString(s.ReadHeaderTimeout)
+}
+
+func (s *ServerAddress) ReadTimeoutInDurationFormat() Duration {
+	return unmarshalDurationString(s.ReadTimeout)
+}
+
+func (s *ServerAddress) WriteTimeoutInDurationFormat() Duration {
+	return unmarshalDurationString(s.WriteTimeout)
+}
+
+func (s *ServerAddress) IdleTimeoutInDurationFormat() Duration {
+	return unmarshalDurationString(s.IdleTimeout)
 }
@@ -0,0 +1,38 @@
+package option
+
+import "time"
+
+const (
+	defaultWebhookMaxRetries    = 5
+	defaultWebhookRetryInterval = Duration(10 * time.Second)
+	defaultWebhookTimeout       = Duration(10 * time.Second)
+)
+
+// Webhook configures the webhooks notified of the registry events
+type Webhook struct {
+	Endpoints []WebhookEndpoint `yaml:"Endpoints"`
+	// MaxRetries is the number of retries of a failed delivery
+	MaxRetries int `yaml:"MaxRetries"`
+	// RetryInterval is the wait before the first retry, it is doubled for each
+	// of the following retries.
+	RetryInterval string `yaml:"RetryInterval"`
+	Timeout       string `yaml:"Timeout"`
+}
+
+// WebhookEndpoint is an outbound webhook
+type WebhookEndpoint struct {
+	URL string `yaml:"URL"`
+	// Secret signs the payload with HMAC-SHA256 in the X-Pando-Signature
+	// header, empty to not sign.
+	Secret string `yaml:"Secret"`
+	// Events are the event types notified, empty for all.
+	Events []string `yaml:"Events"`
+}
+
+func (w *Webhook) RetryIntervalInDurationFormat() Duration {
+	return unmarshalDurationString(w.RetryInterval)
+}
+
+func (w *Webhook) TimeoutInDurationFormat() Duration {
+	return unmarshalDurationString(w.Timeout)
+}
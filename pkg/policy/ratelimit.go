@@ -2,13 +2,18 @@ package policy
 
 import (
 	"fmt"
+	"github.com/kenlabs/pando/pkg/account"
+	"github.com/kenlabs/pando/pkg/option"
 	"github.com/kenlabs/pando/pkg/registry"
+	"github.com/kenlabs/pando/pkg/util/log"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"golang.org/x/time/rate"
-	"math"
 	"sync"
+	"time"
 )
 
+var logger = log.NewSubsystemLogger()
+
 type LimiterConfig struct {
 	Registry   *registry.Registry
 	TotalRate  float64
@@ -18,19 +23,46 @@ type LimiterConfig struct {
 	PeerBurst int
 
 	BaseTokenRate float64
+	// RatePolicy assigns the peers to rate tiers, see DefaultRatePolicy
+	RatePolicy option.RatePolicy
+	// PeerIdleTimeout is the time the rate-limiter of an idle peer is kept,
+	// zero to keep forever.
+	PeerIdleTimeout time.Duration
+	// ReconcileInterval is how often all the peers with rate-limiter are
+	// re-tiered, to catch up the registry events dropped by the subscription.
+	// Zero to rely on the events only.
+	ReconcileInterval time.Duration
+}
+
+// peerLimiter is the rate-limiter of a peer with its activity
+type peerLimiter struct {
+	limiter *rate.Limiter
+	// tier is nil if the rate-limiter is not of a tier
+	tier          *tierLimits
+	lastSeen      time.Time
+	throttled     uint64
+	lastThrottled time.Time
+	// requests and bytes are the usage of the peer
+	requests uint64
+	bytes    uint64
 }
 
 type Limiter struct {
-	gateLimiter         *rate.Limiter
-	whitelistLimiter    *rate.Limiter
-	unregisteredLimiter *rate.Limiter
+	gateLimiter *rate.Limiter
 
-	registeredLimiter map[int]*rate.Limiter
+	policy *ratePolicy
+	// tiers are the limiters of the tiers in use, by tier name
+	tiers map[string]*tierLimits
 
-	peers map[peer.ID]*rate.Limiter
+	peers map[peer.ID]*peerLimiter
 	mu    *sync.RWMutex
+	// throttled counts the requests paused by the rate-limiters
+	throttled uint64
 
 	config LimiterConfig
+
+	cancelWatch func()
+	watchDone   chan struct{}
 }
 
 var tokenRateZeroError = fmt.Errorf("token rate is zero")
@@ -39,10 +71,15 @@ func NewLimiter(c LimiterConfig) (*Limiter, error) {
 	if !rateIsValid(c.TotalRate) || !rateIsValid(float64(c.TotalBurst)) {
 		return nil, fmt.Errorf("total rate or total burst is zero")
 	}
+	p, err := newRatePolicy(c.RatePolicy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate policy: %v", err)
+	}
 	return &Limiter{
 		gateLimiter: rate.NewLimiter(rate.Limit(c.TotalRate), c.TotalBurst),
+		policy:      p,
 		mu:          &sync.RWMutex{},
-		peers:       make(map[peer.ID]*rate.Limiter),
+		peers:       make(map[peer.ID]*peerLimiter),
 		config:      c,
 	}, nil
 }
@@ -55,64 +92,135 @@ func (i *Limiter) GateLimiter() *rate.Limiter {
 	return i.gateLimiter
 }
 
+// tierLimiter returns the rate-limiter shared by the peers in the tier, it
+// must be called with mu locked.
+func (i *Limiter) tierLimiter(tier *option.RateTier, baseTokenRate float64) (*rate.Limiter, error) {
+	if i.tiers == nil {
+		i.tiers = make(map[string]*tierLimits)
+	}
+
+	limits, exists := i.tiers[tier.Name]
+	if !exists {
+		var err error
+		limits, err = newTierLimits(tier, baseTokenRate)
+		if err != nil {
+			return nil, err
+		}
+		i.tiers[tier.Name] = limits
+	}
+	return limits.limiter, nil
+}
+
 func (i *Limiter) UnregisteredLimiter(baseTokenRate float64) (*rate.Limiter, error) {
 	i.mu.Lock()
 	defer i.mu.Unlock()
 
-	if i.unregisteredLimiter != nil {
-		return i.unregisteredLimiter, nil
-	}
+	return i.tierLimiter(i.policy.tiers[i.policy.cfg.Unregistered], baseTokenRate)
+}
 
-	tokenRate := math.Ceil(0.1 * baseTokenRate)
-	if !rateIsValid(tokenRate) {
-		return nil, tokenRateZeroError
-	}
-	i.unregisteredLimiter = rate.NewLimiter(rate.Limit(tokenRate), int(tokenRate))
+func (i *Limiter) WhitelistLimiter(baseTokenRate float64) (*rate.Limiter, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
 
-	return i.unregisteredLimiter, nil
+	return i.tierLimiter(i.policy.tiers[i.policy.cfg.Whitelist], baseTokenRate)
 }
 
-func (i *Limiter) WhitelistLimiter(baseTokenRate float64) (*rate.Limiter, error) {
+func (i *Limiter) RegisteredLimiter(baseTokenRate float64, accountLevel int, levelCount int) (*rate.Limiter, error) {
 	i.mu.Lock()
 	defer i.mu.Unlock()
 
-	if i.whitelistLimiter != nil {
-		return i.whitelistLimiter, nil
+	if accountLevel < 1 || levelCount < 1 || accountLevel > levelCount {
+		return nil, fmt.Errorf("accountLevel or levelCount is invalid, given: accountLevel=%v, levelCount=%v",
+			accountLevel, levelCount)
 	}
+	return i.tierLimiter(i.policy.levelTier(accountLevel, levelCount), baseTokenRate)
+}
 
-	tokenRate := math.Ceil(0.5 * baseTokenRate)
-	if !rateIsValid(tokenRate) {
-		return nil, tokenRateZeroError
+// OverrideLimiter returns the rate-limiter of the tier the peer is assigned to
+// by the rate policy overrides, or nil if the peer is not overridden.
+func (i *Limiter) OverrideLimiter(peerID peer.ID) (*rate.Limiter, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	name, exists := i.policy.overrides[peerID]
+	if !exists {
+		return nil, nil
 	}
-	i.whitelistLimiter = rate.NewLimiter(rate.Limit(tokenRate), int(tokenRate))
+	return i.tierLimiter(i.policy.tiers[name], i.config.BaseTokenRate)
+}
+
+// RatePolicy returns the rate policy in use
+func (i *Limiter) RatePolicy() option.RatePolicy {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
 
-	return i.whitelistLimiter, nil
+	return i.policy.cfg
 }
 
-func (i *Limiter) RegisteredLimiter(baseTokenRate float64, accountLevel int, levelCount int) (*rate.Limiter, error) {
-	i.mu.Lock()
-	defer i.mu.Unlock()
+// SetRatePolicy replaces the rate policy after validating it, and re-tiers the
+// peers with rate-limiters.
+func (i *Limiter) SetRatePolicy(cfg option.RatePolicy) error {
+	p, err := newRatePolicy(cfg)
+	if err != nil {
+		return err
+	}
 
-	if i.registeredLimiter == nil {
-		i.registeredLimiter = make(map[int]*rate.Limiter)
+	i.mu.Lock()
+	i.policy = p
+	i.tiers = nil
+	peerIDs := make([]peer.ID, 0, len(i.peers))
+	for peerID := range i.peers {
+		peerIDs = append(peerIDs, peerID)
 	}
+	i.mu.Unlock()
+	logger.Infow("rate policy changed", "tiers", len(p.cfg.Tiers), "overrides", len(p.overrides))
 
-	limiter, exists := i.registeredLimiter[accountLevel]
-	if !exists {
-		if accountLevel < 1 || levelCount < 1 || accountLevel > levelCount {
-			return nil, fmt.Errorf("accountLevel or levelCount is invalid, given: accountLevel=%v, levelCount=%v",
-				accountLevel, levelCount)
+	for _, peerID := range peerIDs {
+		if err = i.retier(peerID); err != nil {
+			logger.Errorw("failed to re-tier peer limiter", "peer", peerID, "err", err)
 		}
-		weight := float64(accountLevel) / float64(levelCount)
-		tokenRate := math.Ceil(0.4 * weight * baseTokenRate)
-		if !rateIsValid(tokenRate) {
-			return nil, tokenRateZeroError
-		}
-		limiter = rate.NewLimiter(rate.Limit(tokenRate), int(tokenRate))
-		i.registeredLimiter[accountLevel] = limiter
 	}
+	return nil
+}
 
-	return limiter, nil
+// PeerTypeLimiter returns the rate-limiter of the tier of the peer type and the
+// account level.
+func (i *Limiter) PeerTypeLimiter(peerType account.PeerType, accountLevel int) (*rate.Limiter, error) {
+	baseTokenRate := i.Config().BaseTokenRate
+	switch peerType {
+	case account.UnregisteredPeer:
+		return i.UnregisteredLimiter(baseTokenRate)
+	case account.WhiteListPeer:
+		return i.WhitelistLimiter(baseTokenRate)
+	case account.RegisteredPeer:
+		return i.RegisteredLimiter(baseTokenRate, accountLevel, i.Config().Registry.AccountLevelCount())
+	}
+	return nil, fmt.Errorf("unknown peer type: %v", peerType)
+}
+
+// AssignPeer assigns the peer to the tier of its override, or of its peer type
+// and account level in registry, and sets the rate-limiter of the tier as the
+// one of the peer.
+func (i *Limiter) AssignPeer(peerID peer.ID) (*rate.Limiter, error) {
+	limiter, err := i.assignedLimiter(peerID)
+	if err != nil {
+		return nil, err
+	}
+	return i.AddPeerLimiter(peerID, limiter), nil
+}
+
+// assignedLimiter returns the rate-limiter of the override of the peer, or of
+// its peer type and account level
+func (i *Limiter) assignedLimiter(peerID peer.ID) (*rate.Limiter, error) {
+	limiter, err := i.OverrideLimiter(peerID)
+	if err != nil {
+		return nil, err
+	}
+	if limiter != nil {
+		return limiter, nil
+	}
+	accountInfo := account.FetchPeerType(peerID, i.config.Registry)
+	return i.PeerTypeLimiter(accountInfo.PeerType, accountInfo.AccountLevel)
 }
 
 // AddPeerLimiter append a new rate-limiter for a peer into the peers array of Limiter
@@ -120,30 +228,246 @@ func (i *Limiter) AddPeerLimiter(peerID peer.ID, limiter *rate.Limiter) *rate.Li
 	i.mu.Lock()
 	defer i.mu.Unlock()
 
-	i.peers[peerID] = limiter
+	// Keep the throttling history if the peer is re-tiered
+	pl, exists := i.peers[peerID]
+	if !exists {
+		pl = &peerLimiter{lastSeen: time.Now()}
+		i.peers[peerID] = pl
+	}
+	i.setPeerLimiter(pl, limiter)
 
 	return limiter
 }
 
-// PeerLimiter return a rate-limiter for specified peer if exists, or return nil
+// replacePeerLimiter replaces the rate-limiter of a peer, and returns the
+// previous one.  The peers without rate-limiter, such as the ones evicted
+// meanwhile, are not added and false is returned.
+func (i *Limiter) replacePeerLimiter(peerID peer.ID, limiter *rate.Limiter) (*rate.Limiter, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	pl, exists := i.peers[peerID]
+	if !exists {
+		return nil, false
+	}
+	prev := pl.limiter
+	i.setPeerLimiter(pl, limiter)
+	return prev, true
+}
+
+// setPeerLimiter sets the rate-limiter and the tier of a peer, it is called
+// with the lock held.
+func (i *Limiter) setPeerLimiter(pl *peerLimiter, limiter *rate.Limiter) {
+	pl.limiter = limiter
+	pl.tier = nil
+	for _, limits := range i.tiers {
+		if limits.limiter == limiter {
+			pl.tier = limits
+			break
+		}
+	}
+}
+
+// RemovePeerLimiter removes the rate-limiter of a peer, returns false if the
+// peer has no rate-limiter
+func (i *Limiter) RemovePeerLimiter(peerID peer.ID) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	_, exists := i.peers[peerID]
+	delete(i.peers, peerID)
+
+	return exists
+}
+
+// ByteLimiter returns the byte budget of the tier the peer is in, or nil if
+// the tier has no byte budget or the peer has no rate-limiter yet.
+func (i *Limiter) ByteLimiter(peerID peer.ID) *rate.Limiter {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if pl, exists := i.peers[peerID]; exists && pl.tier != nil {
+		return pl.tier.bytes
+	}
+	return nil
+}
+
+// PeerLimiter return a rate-limiter for specified peer if exists, or return nil.
+// The peer is seen active.
 func (i *Limiter) PeerLimiter(peerID peer.ID) *rate.Limiter {
 	i.mu.Lock()
-	limiter, exists := i.peers[peerID]
+	pl, exists := i.peers[peerID]
 
 	if !exists {
 		i.mu.Unlock()
 		return nil
 	}
+	pl.lastSeen = time.Now()
 
 	i.mu.Unlock()
 
-	return limiter
+	return pl.limiter
+}
+
+// Throttled records a request of the peer paused by the rate-limiters
+func (i *Limiter) Throttled(peerID peer.ID) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.throttled++
+	if pl, exists := i.peers[peerID]; exists {
+		pl.throttled++
+		pl.lastThrottled = time.Now()
+	}
+}
+
+// Requested records a request of the peer
+func (i *Limiter) Requested(peerID peer.ID) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if pl, exists := i.peers[peerID]; exists {
+		pl.requests++
+	}
+}
+
+// Transferred records the bytes transferred to or from the peer
+func (i *Limiter) Transferred(peerID peer.ID, bytes uint64) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if pl, exists := i.peers[peerID]; exists {
+		pl.bytes += bytes
+	}
 }
 
 func (i *Limiter) Config() LimiterConfig {
 	return i.config
 }
 
+// WatchRegistry follows the registrations and account level changes of the
+// registry in config to re-tier the rate-limiters of the changed peers, and
+// evicts the rate-limiters of idle peers.  As the subscription drops events
+// when it falls behind, all the peers are also re-tiered every reconcile
+// interval.  Call Close to stop.
+func (i *Limiter) WatchRegistry() {
+	events, cancel := i.config.Registry.Subscribe()
+	i.cancelWatch = cancel
+	i.watchDone = make(chan struct{})
+
+	go func() {
+		defer close(i.watchDone)
+
+		var evictTick <-chan time.Time
+		if i.config.PeerIdleTimeout > 0 {
+			ticker := time.NewTicker(i.config.PeerIdleTimeout)
+			defer ticker.Stop()
+			evictTick = ticker.C
+		}
+		var reconcileTick <-chan time.Time
+		if i.config.ReconcileInterval > 0 {
+			ticker := time.NewTicker(i.config.ReconcileInterval)
+			defer ticker.Stop()
+			reconcileTick = ticker.C
+		}
+
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				switch e.Type {
+				case registry.EventRegistered, registry.EventUpdated, registry.EventAccountLevelChanged:
+				default:
+					continue
+				}
+				if err := i.retier(e.Provider); err != nil {
+					logger.Errorw("failed to re-tier peer limiter", "peer", e.Provider, "event", e.Type, "err", err)
+				}
+			case now := <-evictTick:
+				i.evictIdle(now)
+			case <-reconcileTick:
+				i.reconcile()
+			}
+		}
+	}()
+}
+
+// retier re-assigns a peer with rate-limiter to its tier.  Peers without
+// rate-limiter get the right one when they first request.  The tier is looked
+// up without the lock, so the rate-limiter is only replaced if the peer still
+// has one and is not added back if evicted meanwhile.
+func (i *Limiter) retier(peerID peer.ID) error {
+	i.mu.RLock()
+	_, exists := i.peers[peerID]
+	i.mu.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	limiter, err := i.assignedLimiter(peerID)
+	if err != nil {
+		return err
+	}
+	prev, exists := i.replacePeerLimiter(peerID, limiter)
+	if exists && limiter != prev {
+		logger.Infow("re-tiered peer limiter", "peer", peerID, "tier", i.PeerTier(peerID), "rate", limiter.Limit())
+	}
+	return nil
+}
+
+// reconcile re-tiers all the peers with rate-limiter against the registry
+func (i *Limiter) reconcile() {
+	i.mu.RLock()
+	peerIDs := make([]peer.ID, 0, len(i.peers))
+	for peerID := range i.peers {
+		peerIDs = append(peerIDs, peerID)
+	}
+	i.mu.RUnlock()
+
+	for _, peerID := range peerIDs {
+		if err := i.retier(peerID); err != nil {
+			logger.Errorw("failed to reconcile peer limiter", "peer", peerID, "err", err)
+		}
+	}
+}
+
+// evictIdle removes the rate-limiters of the peers not seen for the idle
+// timeout, they are assigned again when they request next time.
+func (i *Limiter) evictIdle(now time.Time) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for peerID, pl := range i.peers {
+		if now.Sub(pl.lastSeen) > i.config.PeerIdleTimeout {
+			delete(i.peers, peerID)
+			logger.Debugw("evicted idle peer limiter", "peer", peerID, "lastSeen", pl.lastSeen)
+		}
+	}
+}
+
+// PeerTier returns the name of the tier the peer is in, or empty if the peer
+// has no rate-limiter of a tier.
+func (i *Limiter) PeerTier(peerID peer.ID) string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if pl, exists := i.peers[peerID]; exists && pl.tier != nil {
+		return pl.tier.tier.Name
+	}
+	return ""
+}
+
+// Close stops watching the registry
+func (i *Limiter) Close() error {
+	if i.cancelWatch != nil {
+		i.cancelWatch()
+		<-i.watchDone
+	}
+	return nil
+}
+
 func rateIsValid(tokenRate float64) bool {
 	return tokenRate > 0
 }
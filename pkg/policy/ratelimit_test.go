@@ -3,11 +3,13 @@ package policy
 import (
 	"fmt"
 	. "github.com/agiledragon/gomonkey/v2"
+	"github.com/kenlabs/pando/pkg/account"
 	"github.com/kenlabs/pando/pkg/registry"
 	"github.com/libp2p/go-libp2p-core/peer"
 	. "github.com/smartystreets/goconvey/convey"
 	"golang.org/x/time/rate"
 	"math"
+	"reflect"
 	"testing"
 )
 
@@ -227,3 +229,42 @@ func TestLimiter_AddPeerLimiter(t *testing.T) {
 		})
 	})
 }
+
+func TestLimiter_PeerTypeLimiter(t *testing.T) {
+	Convey("when give different peerType then get different rateLimiter", t, func() {
+		limiter := &Limiter{}
+		patch := ApplyMethod(reflect.TypeOf(limiter), "Config", func(_ *Limiter) LimiterConfig {
+			return LimiterConfig{
+				BaseTokenRate: 0,
+				Registry:      &registry.Registry{},
+			}
+		})
+		defer patch.Reset()
+		patch2 := ApplyMethod(reflect.TypeOf(limiter), "UnregisteredLimiter", func(_ *Limiter, _ float64) (*rate.Limiter, error) {
+			return nil, fmt.Errorf("unknown error")
+		})
+		defer patch2.Reset()
+		patch3 := ApplyMethod(reflect.TypeOf(limiter), "WhitelistLimiter", func(_ *Limiter, _ float64) (*rate.Limiter, error) {
+			return nil, fmt.Errorf("unknown error")
+		})
+		defer patch3.Reset()
+		patch4 := ApplyMethod(reflect.TypeOf(limiter), "RegisteredLimiter", func(_ *Limiter, _ float64, _ int, _ int) (*rate.Limiter, error) {
+			return nil, fmt.Errorf("unknown error")
+		})
+		defer patch4.Reset()
+		patch5 := ApplyMethod(reflect.TypeOf(limiter.Config().Registry), "AccountLevelCount", func(_ *registry.Registry) int {
+			return -1
+		})
+		defer patch5.Reset()
+
+		for _, peerType := range []account.PeerType{account.RegisteredPeer, account.UnregisteredPeer, account.WhiteListPeer} {
+			peerLimiter, err := limiter.PeerTypeLimiter(peerType, 0)
+			So(peerLimiter, ShouldBeNil)
+			So(err, ShouldNotBeNil)
+		}
+
+		peerLimiter, err := limiter.PeerTypeLimiter(0, 0)
+		So(peerLimiter, ShouldBeNil)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,99 @@
+package policy
+
+import (
+	"github.com/libp2p/go-libp2p-core/peer"
+	"golang.org/x/time/rate"
+	"sort"
+	"time"
+)
+
+// BucketState is the state of a token bucket
+type BucketState struct {
+	Limit  float64
+	Burst  int
+	Tokens float64
+}
+
+// PeerLimiterState is the state of the rate-limiter of a peer
+type PeerLimiterState struct {
+	PeerID peer.ID
+	// Tier is empty if the rate-limiter is not of a tier
+	Tier   string
+	Bucket BucketState
+	// ByteBudget is the byte budget of the tier, nil for unlimited
+	ByteBudget    *BucketState `json:",omitempty"`
+	LastSeen      time.Time
+	Throttled     uint64
+	LastThrottled *time.Time `json:",omitempty"`
+	// Requests and Bytes are the usage of the peer since its rate-limiter was
+	// created.
+	Requests uint64
+	Bytes    uint64
+}
+
+// LimiterState is the state of the gate rate-limiter and the rate-limiters
+// of the peers.
+type LimiterState struct {
+	Gate BucketState
+	// Throttled is the number of requests paused by the rate-limiters
+	Throttled uint64
+	Peers     []*PeerLimiterState
+}
+
+// State returns the state of the rate-limiters, the peers are sorted by ID.
+func (i *Limiter) State() *LimiterState {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	now := time.Now()
+	state := &LimiterState{
+		Gate:      bucketState(i.gateLimiter, now),
+		Throttled: i.throttled,
+		Peers:     make([]*PeerLimiterState, 0, len(i.peers)),
+	}
+	for peerID, pl := range i.peers {
+		ps := &PeerLimiterState{
+			PeerID:    peerID,
+			LastSeen:  pl.lastSeen,
+			Throttled: pl.throttled,
+			Requests:  pl.requests,
+			Bytes:     pl.bytes,
+		}
+		if pl.limiter != nil {
+			ps.Bucket = bucketState(pl.limiter, now)
+		}
+		if pl.tier != nil {
+			ps.Tier = pl.tier.tier.Name
+			if pl.tier.bytes != nil {
+				bytes := bucketState(pl.tier.bytes, now)
+				ps.ByteBudget = &bytes
+			}
+		}
+		if !pl.lastThrottled.IsZero() {
+			lastThrottled := pl.lastThrottled
+			ps.LastThrottled = &lastThrottled
+		}
+		state.Peers = append(state.Peers, ps)
+	}
+	sort.Slice(state.Peers, func(a, b int) bool {
+		return state.Peers[a].PeerID < state.Peers[b].PeerID
+	})
+	return state
+}
+
+// bucketState reads the tokens of a rate.Limiter by reserving the full burst
+// and cancelling the reservation.
+func bucketState(limiter *rate.Limiter, now time.Time) BucketState {
+	state := BucketState{
+		Limit: float64(limiter.Limit()),
+		Burst: limiter.Burst(),
+	}
+	if limiter.Limit() == rate.Inf {
+		state.Tokens = float64(state.Burst)
+		return state
+	}
+	r := limiter.ReserveN(now, state.Burst)
+	state.Tokens = float64(state.Burst) - r.DelayFrom(now).Seconds()*state.Limit
+	r.CancelAt(now)
+	return state
+}
@@ -0,0 +1,139 @@
+package policy
+
+import (
+	"context"
+	"github.com/kenlabs/pando/pkg/option"
+	"github.com/kenlabs/pando/pkg/registry"
+	"github.com/kenlabs/pando/pkg/registry/discovery"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+	"math/big"
+	"testing"
+	"time"
+)
+
+type fixedBalanceDiscoverer struct {
+	fil int64
+}
+
+func (d *fixedBalanceDiscoverer) Discover(_ context.Context, peerID peer.ID, _ string) (*discovery.Discovered, error) {
+	return &discovery.Discovered{
+		AddrInfo: peer.AddrInfo{ID: peerID},
+		Balance:  big.NewInt(1).Mul(big.NewInt(d.fil), registry.FIL),
+		Type:     discovery.MinerType,
+	}, nil
+}
+
+func newTestRegistry() (*registry.Registry, error) {
+	cfg := &option.Discovery{
+		Policy: option.Policy{
+			Allow: true,
+			Trust: true,
+		},
+		RediscoverWait: option.Duration(time.Minute).String(),
+	}
+	return registry.NewRegistry(context.Background(), cfg, &option.AccountLevel{Threshold: []int{1, 10}},
+		nil, &fixedBalanceDiscoverer{fil: 5})
+}
+
+func TestLimiterLifecycle(t *testing.T) {
+	Convey("TestLimiterLifecycle", t, func() {
+		reg, err := newTestRegistry()
+		So(err, ShouldBeNil)
+		defer reg.Close()
+		limiter, err := NewLimiter(LimiterConfig{
+			TotalRate:       baseTokenRate,
+			TotalBurst:      int(baseTokenRate),
+			BaseTokenRate:   baseTokenRate,
+			Registry:        reg,
+			PeerIdleTimeout: time.Minute,
+		})
+		So(err, ShouldBeNil)
+
+		peerID, err := peer.Decode(testTierPeer)
+		So(err, ShouldBeNil)
+		ctx := context.Background()
+		err = reg.Register(ctx, &registry.ProviderInfo{AddrInfo: peer.AddrInfo{ID: peerID}, DiscoveryAddr: "f01000"})
+		So(err, ShouldBeNil)
+
+		Convey("assign and re-tier peers", func() {
+			_, err := limiter.AssignPeer(peerID)
+			So(err, ShouldBeNil)
+			So(limiter.PeerTier(peerID), ShouldEqual, whitelistTier)
+			So(limiter.PeerTier(peer.ID("unknown")), ShouldBeEmpty)
+
+			// No longer trusted, so limited by account level
+			err = reg.UpdatePolicy(ctx, &registry.PolicyUpdate{Action: registry.PolicyAddTrustExcept, PeerID: peerID}, "test")
+			So(err, ShouldBeNil)
+			So(limiter.retier(peerID), ShouldBeNil)
+			So(limiter.PeerTier(peerID), ShouldEqual, "level-2")
+
+			// Peers without rate-limiter are not assigned
+			So(limiter.retier(peer.ID("unknown")), ShouldBeNil)
+			So(limiter.PeerLimiter(peer.ID("unknown")), ShouldBeNil)
+
+			// Peers evicted while re-tiered are not added back
+			assigned, err := limiter.assignedLimiter(peerID)
+			So(err, ShouldBeNil)
+			So(limiter.RemovePeerLimiter(peerID), ShouldBeTrue)
+			_, exists := limiter.replacePeerLimiter(peerID, assigned)
+			So(exists, ShouldBeFalse)
+			So(limiter.PeerLimiter(peerID), ShouldBeNil)
+		})
+
+		Convey("reconcile peers whose registry events are missed", func() {
+			_, err := limiter.AssignPeer(peerID)
+			So(err, ShouldBeNil)
+			So(limiter.PeerTier(peerID), ShouldEqual, whitelistTier)
+
+			err = reg.UpdatePolicy(ctx, &registry.PolicyUpdate{Action: registry.PolicyAddTrustExcept, PeerID: peerID}, "test")
+			So(err, ShouldBeNil)
+			So(limiter.PeerTier(peerID), ShouldEqual, whitelistTier)
+			limiter.reconcile()
+			So(limiter.PeerTier(peerID), ShouldEqual, "level-2")
+		})
+
+		Convey("re-tier peers when rate policy changes", func() {
+			_, err := limiter.AssignPeer(peerID)
+			So(err, ShouldBeNil)
+
+			cfg := DefaultRatePolicy()
+			cfg.Tiers = append(cfg.Tiers, option.RateTier{Name: "partner", Rate: 100})
+			cfg.Overrides = []option.RateOverride{{PeerID: testTierPeer, Tier: "partner"}}
+			So(limiter.SetRatePolicy(cfg), ShouldBeNil)
+			So(limiter.PeerTier(peerID), ShouldEqual, "partner")
+		})
+
+		Convey("record throttling and evict idle peers", func() {
+			_, err := limiter.AssignPeer(peerID)
+			So(err, ShouldBeNil)
+			limiter.Throttled(peerID)
+			limiter.Throttled(peerID)
+			limiter.Requested(peerID)
+			limiter.Transferred(peerID, 100)
+			limiter.Transferred(peerID, 28)
+			// Usage of the peers without rate-limiter is not recorded
+			limiter.Requested(peer.ID("unknown"))
+
+			state := limiter.State()
+			So(state.Throttled, ShouldEqual, 2)
+			So(state.Gate.Limit, ShouldEqual, baseTokenRate)
+			So(state.Gate.Tokens, ShouldAlmostEqual, baseTokenRate, 0.1)
+			So(state.Peers, ShouldHaveLength, 1)
+			So(state.Peers[0].PeerID, ShouldEqual, peerID)
+			So(state.Peers[0].Tier, ShouldEqual, whitelistTier)
+			So(state.Peers[0].Throttled, ShouldEqual, 2)
+			So(state.Peers[0].LastThrottled, ShouldNotBeNil)
+			So(state.Peers[0].Requests, ShouldEqual, 1)
+			So(state.Peers[0].Bytes, ShouldEqual, 128)
+
+			// Reading the state does not take tokens
+			So(limiter.State().Gate.Tokens, ShouldAlmostEqual, baseTokenRate, 0.1)
+
+			limiter.evictIdle(time.Now())
+			So(limiter.PeerLimiter(peerID), ShouldNotBeNil)
+			limiter.evictIdle(time.Now().Add(2 * time.Minute))
+			So(limiter.PeerLimiter(peerID), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,140 @@
+package policy
+
+import (
+	"fmt"
+	"github.com/kenlabs/pando/pkg/option"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"golang.org/x/time/rate"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	unregisteredTier = "unregistered"
+	whitelistTier    = "whitelist"
+	// levelTierPrefix names the built-in tiers of account levels
+	levelTierPrefix = "level-"
+)
+
+// DefaultRatePolicy is the built-in policy: unregistered peers get 0.1 of the
+// base rate, trusted peers get 0.5, and registered peers get 0.4 weighted by
+// account level / level count.
+func DefaultRatePolicy() option.RatePolicy {
+	return option.RatePolicy{
+		Tiers: []option.RateTier{
+			{Name: unregisteredTier, RateFactor: 0.1},
+			{Name: whitelistTier, RateFactor: 0.5},
+		},
+		Unregistered: unregisteredTier,
+		Whitelist:    whitelistTier,
+	}
+}
+
+// ratePolicy is the validated option.RatePolicy
+type ratePolicy struct {
+	cfg       option.RatePolicy
+	tiers     map[string]*option.RateTier
+	overrides map[peer.ID]string
+}
+
+func newRatePolicy(cfg option.RatePolicy) (*ratePolicy, error) {
+	if len(cfg.Tiers) == 0 {
+		def := DefaultRatePolicy()
+		cfg.Tiers = def.Tiers
+		if cfg.Unregistered == "" {
+			cfg.Unregistered = def.Unregistered
+		}
+		if cfg.Whitelist == "" {
+			cfg.Whitelist = def.Whitelist
+		}
+	}
+
+	p := &ratePolicy{
+		cfg:       cfg,
+		tiers:     make(map[string]*option.RateTier, len(cfg.Tiers)),
+		overrides: make(map[peer.ID]string, len(cfg.Overrides)),
+	}
+	for i := range cfg.Tiers {
+		tier := &cfg.Tiers[i]
+		if tier.Name == "" {
+			return nil, fmt.Errorf("rate tier %d has no name", i)
+		}
+		if len(cfg.Levels) == 0 && strings.HasPrefix(tier.Name, levelTierPrefix) {
+			return nil, fmt.Errorf("rate tier %q is reserved for the built-in account level tiers", tier.Name)
+		}
+		if _, ok := p.tiers[tier.Name]; ok {
+			return nil, fmt.Errorf("duplicate rate tier %q", tier.Name)
+		}
+		if tier.Rate < 0 || tier.RateFactor < 0 || (tier.Rate == 0 && tier.RateFactor == 0) {
+			return nil, fmt.Errorf("rate tier %q should have a positive rate or rate factor", tier.Name)
+		}
+		if tier.Burst < 0 || tier.ByteBudget < 0 {
+			return nil, fmt.Errorf("rate tier %q has negative burst or byte budget", tier.Name)
+		}
+		p.tiers[tier.Name] = tier
+	}
+
+	refs := append([]string{cfg.Unregistered, cfg.Whitelist}, cfg.Levels...)
+	for _, o := range cfg.Overrides {
+		peerID, err := peer.Decode(o.PeerID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer id %q of rate override: %v", o.PeerID, err)
+		}
+		p.overrides[peerID] = o.Tier
+		refs = append(refs, o.Tier)
+	}
+	for _, name := range refs {
+		if _, ok := p.tiers[name]; !ok {
+			return nil, fmt.Errorf("unknown rate tier %q", name)
+		}
+	}
+	return p, nil
+}
+
+// levelTier returns the tier of the account level, the built-in tier is
+// created if no level is configured.
+func (p *ratePolicy) levelTier(accountLevel int, levelCount int) *option.RateTier {
+	if len(p.cfg.Levels) == 0 {
+		weight := float64(accountLevel) / float64(levelCount)
+		return &option.RateTier{
+			Name:       levelTierPrefix + strconv.Itoa(accountLevel),
+			RateFactor: 0.4 * weight,
+		}
+	}
+	if accountLevel > len(p.cfg.Levels) {
+		accountLevel = len(p.cfg.Levels)
+	}
+	return p.tiers[p.cfg.Levels[accountLevel-1]]
+}
+
+// tierLimits are the limiters shared by the peers in a tier
+type tierLimits struct {
+	tier    *option.RateTier
+	limiter *rate.Limiter
+	// bytes is nil if the tier has no byte budget
+	bytes *rate.Limiter
+}
+
+func newTierLimits(tier *option.RateTier, baseTokenRate float64) (*tierLimits, error) {
+	tokenRate := tier.Rate
+	if tokenRate == 0 {
+		tokenRate = math.Ceil(tier.RateFactor * baseTokenRate)
+	}
+	if !rateIsValid(tokenRate) {
+		return nil, tokenRateZeroError
+	}
+	burst := tier.Burst
+	if burst == 0 {
+		burst = int(math.Ceil(tokenRate))
+	}
+
+	limits := &tierLimits{
+		tier:    tier,
+		limiter: rate.NewLimiter(rate.Limit(tokenRate), burst),
+	}
+	if tier.ByteBudget != 0 {
+		limits.bytes = rate.NewLimiter(rate.Limit(tier.ByteBudget), int(tier.ByteBudget))
+	}
+	return limits, nil
+}
@@ -0,0 +1,164 @@
+package policy
+
+import (
+	"github.com/kenlabs/pando/pkg/option"
+	"github.com/kenlabs/pando/pkg/registry"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/time/rate"
+	"testing"
+)
+
+const testTierPeer = "12D3KooWSS3sEujyAXB9SWUvVtQZmxH6vTi9NitqaaRQoUjeEk3M"
+
+func testRatePolicy() option.RatePolicy {
+	return option.RatePolicy{
+		Tiers: []option.RateTier{
+			{Name: "free", RateFactor: 0.1},
+			{Name: "trusted", RateFactor: 0.5},
+			{Name: "basic", Rate: 5, Burst: 10, ByteBudget: 1024},
+			{Name: "premium", Rate: 20},
+		},
+		Unregistered: "free",
+		Whitelist:    "trusted",
+		Levels:       []string{"basic", "premium"},
+		Overrides: []option.RateOverride{
+			{PeerID: testTierPeer, Tier: "premium"},
+		},
+	}
+}
+
+func TestNewRatePolicy(t *testing.T) {
+	Convey("TestNewRatePolicy", t, func() {
+		Convey("use the built-in tiers if no tier is configured", func() {
+			p, err := newRatePolicy(option.RatePolicy{})
+			So(err, ShouldBeNil)
+			So(p.cfg, ShouldResemble, DefaultRatePolicy())
+		})
+
+		Convey("reject invalid policies", func() {
+			tests := []func(cfg *option.RatePolicy){
+				func(cfg *option.RatePolicy) { cfg.Tiers[0].Name = "" },
+				func(cfg *option.RatePolicy) { cfg.Tiers[1].Name = "free" },
+				func(cfg *option.RatePolicy) { cfg.Tiers[0].RateFactor = 0 },
+				func(cfg *option.RatePolicy) { cfg.Tiers[2].Burst = -1 },
+				func(cfg *option.RatePolicy) { cfg.Unregistered = "unknown" },
+				func(cfg *option.RatePolicy) { cfg.Levels = append(cfg.Levels, "unknown") },
+				func(cfg *option.RatePolicy) { cfg.Overrides[0].PeerID = "invalid" },
+				func(cfg *option.RatePolicy) { cfg.Overrides[0].Tier = "unknown" },
+				func(cfg *option.RatePolicy) {
+					cfg.Levels = nil
+					cfg.Tiers[3].Name = "level-1"
+				},
+			}
+			for _, modify := range tests {
+				cfg := testRatePolicy()
+				modify(&cfg)
+				p, err := newRatePolicy(cfg)
+				So(p, ShouldBeNil)
+				So(err, ShouldNotBeNil)
+			}
+		})
+
+		Convey("map account levels to tiers", func() {
+			p, err := newRatePolicy(testRatePolicy())
+			So(err, ShouldBeNil)
+			So(p.levelTier(1, 5).Name, ShouldEqual, "basic")
+			So(p.levelTier(2, 5).Name, ShouldEqual, "premium")
+			So(p.levelTier(5, 5).Name, ShouldEqual, "premium")
+
+			p, err = newRatePolicy(option.RatePolicy{})
+			So(err, ShouldBeNil)
+			So(p.levelTier(1, 5).Name, ShouldEqual, "level-1")
+			So(p.levelTier(1, 5).RateFactor, ShouldAlmostEqual, 0.08)
+		})
+	})
+}
+
+func TestNewTierLimits(t *testing.T) {
+	Convey("TestNewTierLimits", t, func() {
+		Convey("weight the base rate by rate factor", func() {
+			limits, err := newTierLimits(&option.RateTier{Name: "t", RateFactor: 0.5}, 40)
+			So(err, ShouldBeNil)
+			So(limits.limiter.Limit(), ShouldEqual, rate.Limit(20))
+			So(limits.limiter.Burst(), ShouldEqual, 20)
+			So(limits.bytes, ShouldBeNil)
+		})
+
+		Convey("use the absolute rate, burst and byte budget", func() {
+			limits, err := newTierLimits(&option.RateTier{Name: "t", Rate: 2.5, Burst: 8, ByteBudget: 100}, 40)
+			So(err, ShouldBeNil)
+			So(limits.limiter.Limit(), ShouldEqual, rate.Limit(2.5))
+			So(limits.limiter.Burst(), ShouldEqual, 8)
+			So(limits.bytes.Limit(), ShouldEqual, rate.Limit(100))
+		})
+
+		Convey("return error when token rate is 0", func() {
+			limits, err := newTierLimits(&option.RateTier{Name: "t", RateFactor: 0.5}, 0)
+			So(limits, ShouldBeNil)
+			So(err, ShouldEqual, tokenRateZeroError)
+		})
+	})
+}
+
+func TestLimiterRatePolicy(t *testing.T) {
+	Convey("TestLimiterRatePolicy", t, func() {
+		limiter, err := NewLimiter(LimiterConfig{
+			TotalRate:     baseTokenRate,
+			TotalBurst:    int(baseTokenRate),
+			BaseTokenRate: baseTokenRate,
+			Registry:      &registry.Registry{},
+			RatePolicy:    testRatePolicy(),
+		})
+		So(err, ShouldBeNil)
+		overridden, err := peer.Decode(testTierPeer)
+		So(err, ShouldBeNil)
+
+		Convey("assign peers to the configured tiers", func() {
+			unregisteredLimiter, err := limiter.UnregisteredLimiter(baseTokenRate)
+			So(err, ShouldBeNil)
+			So(unregisteredLimiter.Limit(), ShouldEqual, rate.Limit(4))
+
+			registeredLimiter, err := limiter.RegisteredLimiter(baseTokenRate, 1, 5)
+			So(err, ShouldBeNil)
+			So(registeredLimiter.Limit(), ShouldEqual, rate.Limit(5))
+			So(registeredLimiter.Burst(), ShouldEqual, 10)
+
+			overrideLimiter, err := limiter.OverrideLimiter(overridden)
+			So(err, ShouldBeNil)
+			So(overrideLimiter.Limit(), ShouldEqual, rate.Limit(20))
+
+			notOverridden, err := limiter.OverrideLimiter(peer.ID("other"))
+			So(err, ShouldBeNil)
+			So(notOverridden, ShouldBeNil)
+		})
+
+		Convey("follow the byte budget of the peer tier", func() {
+			peerID := peer.ID("basic peer")
+			So(limiter.ByteLimiter(peerID), ShouldBeNil)
+
+			registeredLimiter, err := limiter.RegisteredLimiter(baseTokenRate, 1, 5)
+			So(err, ShouldBeNil)
+			limiter.AddPeerLimiter(peerID, registeredLimiter)
+			So(limiter.ByteLimiter(peerID).Limit(), ShouldEqual, rate.Limit(1024))
+
+			limiter.RemovePeerLimiter(peerID)
+			So(limiter.ByteLimiter(peerID), ShouldBeNil)
+		})
+
+		Convey("replace the rate policy", func() {
+			So(limiter.SetRatePolicy(option.RatePolicy{Unregistered: "unknown"}), ShouldNotBeNil)
+			So(limiter.RatePolicy(), ShouldResemble, testRatePolicy())
+
+			So(limiter.SetRatePolicy(option.RatePolicy{}), ShouldBeNil)
+			So(limiter.RatePolicy(), ShouldResemble, DefaultRatePolicy())
+
+			overrideLimiter, err := limiter.OverrideLimiter(overridden)
+			So(err, ShouldBeNil)
+			So(overrideLimiter, ShouldBeNil)
+			registeredLimiter, err := limiter.RegisteredLimiter(baseTokenRate, 1, 5)
+			So(err, ShouldBeNil)
+			So(registeredLimiter.Limit(), ShouldEqual, rate.Limit(4))
+		})
+	})
+}
@@ -0,0 +1,201 @@
+package purge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	"github.com/kenlabs/pando-store/pkg/metastore"
+	"github.com/kenlabs/pando-store/pkg/statestore"
+	"github.com/kenlabs/pando-store/pkg/statestore/registry"
+	"github.com/kenlabs/pando-store/pkg/store"
+	"github.com/kenlabs/pando/pkg/legs"
+	"github.com/kenlabs/pando/pkg/policy"
+	"github.com/kenlabs/pando/pkg/task"
+	"github.com/kenlabs/pando/pkg/util/log"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+var logger = log.NewSubsystemLogger()
+
+// TaskKind is the kind of the background tasks that purge providers
+const TaskKind = "purge"
+
+// ErrBlocksUnsupported is returned when purging the blocks with a PandoStore
+// not supporting the removal of a provider.
+var ErrBlocksUnsupported = errors.New("pando-store does not support purging the blocks of a provider")
+
+// BlockStore is the API of PandoStore for purging the blocks of a provider.
+type BlockStore interface {
+	// PendingSnapshot returns how many blocks of the provider are waiting for
+	// the next snapshot, busy is true while a snapshot is being generated.
+	PendingSnapshot(providerID peer.ID) (pending int, busy bool)
+	// EvictCached drops the block from the write cache without persisting it
+	EvictCached(c cid.Cid)
+	// RemoveProvider removes the provider info, its metadata list and last
+	// update height, from the state store.
+	RemoveProvider(ctx context.Context, providerID peer.ID) error
+}
+
+// snapshotPollInterval is how often the purger checks whether the metadata of
+// a provider is still waiting for a snapshot
+var snapshotPollInterval = time.Second
+
+// Options selects what is purged for a provider
+type Options struct {
+	Blocks      bool
+	SyncHead    bool
+	MetaCache   bool
+	RateLimiter bool
+}
+
+// AllOptions purges everything Pando keeps for a provider
+var AllOptions = Options{
+	Blocks:      true,
+	SyncHead:    true,
+	MetaCache:   true,
+	RateLimiter: true,
+}
+
+// Result records what has been purged for a provider
+type Result struct {
+	Provider           peer.ID
+	BlocksRemoved      int
+	StateRemoved       bool
+	SyncHeadRemoved    bool
+	MetaCacheDropped   bool
+	RateLimiterRemoved bool
+}
+
+// Purger removes the data of a provider from all the stores of Pando
+type Purger struct {
+	// blocks is nil if PandoStore does not support purging blocks
+	blocks     BlockStore
+	stateStore *statestore.MetaStateStore
+	blockDS    datastore.Datastore
+	ds         datastore.Datastore
+	metaCache  *mongo.Client
+	limiter    *policy.Limiter
+	tasks      *task.Manager
+}
+
+func New(ps *store.PandoStore, ds datastore.Datastore, metaCache *mongo.Client, limiter *policy.Limiter, tasks *task.Manager) *Purger {
+	p := &Purger{
+		stateStore: ps.StateStore,
+		blockDS:    ps.BasicDS,
+		ds:         ds,
+		metaCache:  metaCache,
+		limiter:    limiter,
+		tasks:      tasks,
+	}
+	if blocks, ok := interface{}(ps).(BlockStore); ok {
+		p.blocks = blocks
+	}
+	return p
+}
+
+// Submit purges the provider in a background task and returns the task
+func (p *Purger) Submit(providerID peer.ID, opts Options) *task.Task {
+	return p.tasks.Submit(TaskKind, providerID.String(), func(ctx context.Context) (interface{}, error) {
+		return p.Purge(ctx, providerID, opts)
+	})
+}
+
+// Purge removes the data of the provider selected by opts.
+//
+// When purging blocks, Purge first waits until the latest metadata of the
+// provider is included in a snapshot, so the provider should be deregistered
+// or banned beforehand. The blocks and the provider state are then removed from
+// PandoStore; the snapshots already generated are history and are kept. It
+// fails with ErrBlocksUnsupported if PandoStore does not implement BlockStore.
+func (p *Purger) Purge(ctx context.Context, providerID peer.ID, opts Options) (*Result, error) {
+	res := &Result{Provider: providerID}
+
+	if opts.RateLimiter && p.limiter != nil {
+		res.RateLimiterRemoved = p.limiter.RemovePeerLimiter(providerID)
+	}
+
+	if opts.SyncHead {
+		err := p.ds.Delete(ctx, datastore.NewKey(legs.SyncPrefix+providerID.String()))
+		if err != nil {
+			return res, fmt.Errorf("failed to remove sync head: %v", err)
+		}
+		res.SyncHeadRemoved = true
+	}
+
+	if opts.MetaCache && p.metaCache != nil {
+		err := p.metaCache.Database(providerID.String()).Drop(ctx)
+		if err != nil {
+			return res, fmt.Errorf("failed to drop metacache database: %v", err)
+		}
+		res.MetaCacheDropped = true
+	}
+
+	if opts.Blocks {
+		removed, err := p.purgeBlocks(ctx, providerID)
+		res.BlocksRemoved = removed
+		if err != nil {
+			return res, err
+		}
+		if err = p.blocks.RemoveProvider(ctx, providerID); err != nil {
+			return res, fmt.Errorf("failed to remove provider state: %v", err)
+		}
+		res.StateRemoved = true
+	}
+
+	logger.Infow("purged provider", "provider", providerID, "blocks", res.BlocksRemoved, "state", res.StateRemoved,
+		"syncHead", res.SyncHeadRemoved, "metaCache", res.MetaCacheDropped, "rateLimiter", res.RateLimiterRemoved)
+	return res, nil
+}
+
+// waitSnapshot waits until no metadata of the provider is waiting for the next
+// snapshot, then all its metadata is listed in the provider state.
+func (p *Purger) waitSnapshot(ctx context.Context, providerID peer.ID) (*registry.ProviderInfo, error) {
+	for {
+		if pending, busy := p.blocks.PendingSnapshot(providerID); !busy && pending == 0 {
+			state, err := p.stateStore.GetProviderInfo(ctx, providerID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get the metadata list of provider: %v", err)
+			}
+			return state, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(snapshotPollInterval):
+		}
+	}
+}
+
+func (p *Purger) purgeBlocks(ctx context.Context, providerID peer.ID) (int, error) {
+	if p.blocks == nil {
+		return 0, ErrBlocksUnsupported
+	}
+	state, err := p.waitSnapshot(ctx, providerID)
+	if err != nil {
+		return 0, err
+	}
+	if state == nil {
+		return 0, nil
+	}
+
+	var removed int
+	for _, c := range state.MetaList {
+		if ctx.Err() != nil {
+			return removed, ctx.Err()
+		}
+		// evicted first, so that the cache can not write it back after deleted
+		p.blocks.EvictCached(c)
+		key := metastore.MetaPrefix.Child(dshelp.MultihashToDsKey(c.Hash()))
+		if err = p.blockDS.Delete(ctx, key); err != nil {
+			return removed, fmt.Errorf("failed to remove block %s: %v", c, err)
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,113 @@
+package purge
+
+import (
+	"context"
+	"errors"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	"github.com/kenlabs/pando-store/pkg/config"
+	"github.com/kenlabs/pando-store/pkg/metastore"
+	"github.com/kenlabs/pando-store/pkg/store"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p-core/test"
+	"github.com/multiformats/go-multihash"
+	. "github.com/smartystreets/goconvey/convey"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func storeBlocks(ps *store.PandoStore, provider peer.ID, n int) ([]cid.Cid, error) {
+	var cids []cid.Cid
+	for i := 0; i < n; i++ {
+		data := []byte(provider.String() + strconv.Itoa(i))
+		c, err := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}.Sum(data)
+		if err != nil {
+			return nil, err
+		}
+		if err = ps.Store(context.Background(), c, data, provider, nil); err != nil {
+			return nil, err
+		}
+		cids = append(cids, c)
+	}
+	return cids, nil
+}
+
+func blockKey(c cid.Cid) datastore.Key {
+	return metastore.MetaPrefix.Child(dshelp.MultihashToDsKey(c.Hash()))
+}
+
+// testBlockStore reports the blocks of a provider pending until they are in
+// its state, and records the evicted blocks and the removed providers.
+type testBlockStore struct {
+	ps      *store.PandoStore
+	evicted []cid.Cid
+	removed []peer.ID
+}
+
+func (bs *testBlockStore) PendingSnapshot(providerID peer.ID) (int, bool) {
+	info, err := bs.ps.StateStore.GetProviderInfo(context.Background(), providerID)
+	if err != nil || info == nil {
+		return 1, false
+	}
+	return 0, false
+}
+
+func (bs *testBlockStore) EvictCached(c cid.Cid) {
+	bs.evicted = append(bs.evicted, c)
+}
+
+func (bs *testBlockStore) RemoveProvider(_ context.Context, providerID peer.ID) error {
+	bs.removed = append(bs.removed, providerID)
+	return nil
+}
+
+func TestPurgeBlocks(t *testing.T) {
+	Convey("Test purge the blocks and state of a provider", t, func() {
+		ctx := context.Background()
+		snapshotPollInterval = 100 * time.Millisecond
+		mds := dssync.MutexWrap(datastore.NewMapDatastore())
+		ps, err := store.NewStoreFromDatastore(ctx, mds, &config.StoreConfig{
+			SnapShotInterval: "1s",
+			CacheSize:        config.DefaultCacheSize,
+		})
+		So(err, ShouldBeNil)
+		defer ps.Close()
+
+		provider, err := test.RandPeerID()
+		So(err, ShouldBeNil)
+		cids, err := storeBlocks(ps, provider, 5)
+		So(err, ShouldBeNil)
+
+		Convey("fails if PandoStore does not support it", func() {
+			purger := New(ps, mds, nil, nil, nil)
+			if purger.blocks != nil {
+				// PandoStore supports it
+				return
+			}
+			res, err := purger.Purge(ctx, provider, Options{Blocks: true})
+			So(errors.Is(err, ErrBlocksUnsupported), ShouldBeTrue)
+			So(res.BlocksRemoved, ShouldEqual, 0)
+			So(res.StateRemoved, ShouldBeFalse)
+		})
+
+		Convey("evicts and deletes the blocks, then removes the state", func() {
+			blocks := &testBlockStore{ps: ps}
+			purger := New(ps, mds, nil, nil, nil)
+			purger.blocks = blocks
+			res, err := purger.Purge(ctx, provider, Options{Blocks: true})
+			So(err, ShouldBeNil)
+			So(res.BlocksRemoved, ShouldEqual, len(cids))
+			So(res.StateRemoved, ShouldBeTrue)
+			So(blocks.evicted, ShouldResemble, cids)
+			So(blocks.removed, ShouldResemble, []peer.ID{provider})
+			for _, c := range cids {
+				has, err := mds.Has(ctx, blockKey(c))
+				So(err, ShouldBeNil)
+				So(has, ShouldBeFalse)
+			}
+		})
+	})
+}
@@ -28,11 +28,11 @@ func (r *Registry) getAccountLevel(balance *big.Int) (int, error) {
 }
 
 func (r *Registry) ProviderAccountLevel(provider peer.ID) (int, error) {
-	info := r.ProviderInfo(provider)[0]
+	info := r.ProviderInfo(provider)
 	if info == nil {
 		return -1, fmt.Errorf("not register provider")
 	}
-	return info.AccountLevel, nil
+	return info[0].AccountLevel, nil
 }
 
 func (r *Registry) AccountLevelCount() int {
@@ -0,0 +1,208 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/kenlabs/pando/pkg/registry/internal/syserr"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"net/http"
+	"path"
+	"time"
+)
+
+// bannedKeyPath is where the banned peers are stored
+const bannedKeyPath = "/registry/banned"
+
+// BanRecord holds the reason and time a peer was banned
+type BanRecord struct {
+	PeerID peer.ID
+	Reason string
+	Time   time.Time
+}
+
+func (b *BanRecord) dsKey() datastore.Key {
+	return datastore.NewKey(path.Join(bannedKeyPath, b.PeerID.String()))
+}
+
+// Deregister removes a provider from the registry.  The provider can register
+// again afterwards unless it is also banned.
+func (r *Registry) Deregister(ctx context.Context, providerID peer.ID) error {
+	errCh := make(chan error, 1)
+	r.actions <- func() {
+		errCh <- r.syncDeregister(ctx, providerID)
+	}
+	err := <-errCh
+	if err != nil {
+		return err
+	}
+
+	logger.Infow("deregistered provider", "id", providerID)
+	return nil
+}
+
+// Ban bans the peer, so that it is neither authorized to sync with Pando nor
+// allowed to register.  Banning does not remove the provider from registry,
+// use Deregister for that.
+func (r *Registry) Ban(ctx context.Context, peerID peer.ID, reason string) error {
+	if err := peerID.Validate(); err != nil {
+		return syserr.New(fmt.Errorf("invalid peer id: %v", err), http.StatusBadRequest)
+	}
+	rec := &BanRecord{
+		PeerID: peerID,
+		Reason: reason,
+		Time:   time.Now(),
+	}
+
+	errCh := make(chan error, 1)
+	r.actions <- func() {
+		errCh <- r.syncBan(ctx, rec)
+	}
+	err := <-errCh
+	if err != nil {
+		return err
+	}
+
+	logger.Infow("banned peer", "id", peerID, "reason", reason)
+	return nil
+}
+
+// Unban lifts the ban of the peer
+func (r *Registry) Unban(ctx context.Context, peerID peer.ID) error {
+	errCh := make(chan error, 1)
+	r.actions <- func() {
+		errCh <- r.syncUnban(ctx, peerID)
+	}
+	err := <-errCh
+	if err != nil {
+		return err
+	}
+
+	logger.Infow("unbanned peer", "id", peerID)
+	return nil
+}
+
+// IsBanned checks if the peer is banned
+func (r *Registry) IsBanned(peerID peer.ID) bool {
+	bannedOk := make(chan bool)
+	r.actions <- func() {
+		_, ok := r.banned[peerID]
+		bannedOk <- ok
+	}
+	return <-bannedOk
+}
+
+// BannedPeers returns the ban records of all banned peers
+func (r *Registry) BannedPeers() []*BanRecord {
+	var recs []*BanRecord
+	done := make(chan struct{})
+	r.actions <- func() {
+		recs = make([]*BanRecord, 0, len(r.banned))
+		for _, rec := range r.banned {
+			recs = append(recs, rec)
+		}
+		close(done)
+	}
+	<-done
+	return recs
+}
+
+func (r *Registry) syncDeregister(ctx context.Context, providerID peer.ID) error {
+	info, ok := r.providers[providerID]
+	if !ok {
+		return syserr.New(ErrNotRegistered, http.StatusNotFound)
+	}
+	delete(r.providers, providerID)
+	r.index.remove(info)
+
+	if r.dstore == nil {
+		return nil
+	}
+	if err := r.dstore.Delete(ctx, info.dsKey()); err != nil {
+		err = fmt.Errorf("could not delete provider: %s", err)
+		return syserr.New(err, http.StatusInternalServerError)
+	}
+	if err := r.syncDeleteStatusHistory(ctx, providerID); err != nil {
+		err = fmt.Errorf("could not delete status history: %s", err)
+		return syserr.New(err, http.StatusInternalServerError)
+	}
+	return nil
+}
+
+func (r *Registry) syncBan(ctx context.Context, rec *BanRecord) error {
+	r.banned[rec.PeerID] = rec
+
+	if _, ok := r.providers[rec.PeerID]; ok {
+		if err := r.syncSetStatus(ctx, rec.PeerID, StatusBanned, rec.Reason, true); err != nil {
+			return err
+		}
+	}
+
+	if r.dstore == nil {
+		return nil
+	}
+	value, err := json.Marshal(rec)
+	if err != nil {
+		return syserr.New(err, http.StatusInternalServerError)
+	}
+	dsKey := rec.dsKey()
+	if err = r.dstore.Put(ctx, dsKey, value); err != nil {
+		return syserr.New(fmt.Errorf("could not persist ban: %s", err), http.StatusInternalServerError)
+	}
+	if err = r.dstore.Sync(ctx, dsKey); err != nil {
+		return syserr.New(fmt.Errorf("cannot sync ban: %s", err), http.StatusInternalServerError)
+	}
+	return nil
+}
+
+func (r *Registry) syncUnban(ctx context.Context, peerID peer.ID) error {
+	rec, ok := r.banned[peerID]
+	if !ok {
+		return syserr.New(ErrNotBanned, http.StatusNotFound)
+	}
+	delete(r.banned, peerID)
+
+	if info, ok := r.providers[peerID]; ok && info.Status == StatusBanned {
+		if err := r.syncSetStatus(ctx, peerID, StatusRegistered, "unbanned", true); err != nil {
+			return err
+		}
+	}
+
+	if r.dstore == nil {
+		return nil
+	}
+	if err := r.dstore.Delete(ctx, rec.dsKey()); err != nil {
+		return syserr.New(fmt.Errorf("could not delete ban: %s", err), http.StatusInternalServerError)
+	}
+	return nil
+}
+
+func (r *Registry) loadPersistedBans(ctx context.Context) (int, error) {
+	if r.dstore == nil {
+		return 0, nil
+	}
+
+	results, err := r.dstore.Query(ctx, query.Query{
+		Prefix: bannedKeyPath,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer results.Close()
+
+	var count int
+	for result := range results.Next() {
+		if result.Error != nil {
+			return 0, fmt.Errorf("cannot read banned peers: %v", result.Error)
+		}
+		rec := new(BanRecord)
+		if err = json.Unmarshal(result.Entry.Value, rec); err != nil {
+			return 0, err
+		}
+		r.banned[rec.PeerID] = rec
+		count++
+	}
+	return count, nil
+}
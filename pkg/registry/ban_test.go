@@ -0,0 +1,75 @@
+package registry
+
+import (
+	"context"
+	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/kenlabs/pando/pkg/option"
+	"github.com/kenlabs/pando/pkg/registry/internal/syserr"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multiaddr"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBanAndDeregister(t *testing.T) {
+	Convey("test ban, deregister and reload bans after restart", t, func() {
+		cfg := &option.Discovery{
+			Policy: option.Policy{
+				Allow: true,
+				Trust: true,
+			},
+			RediscoverWait: option.Duration(time.Minute).String(),
+		}
+		ctx := context.Background()
+		dir := t.TempDir()
+		dstore, err := leveldb.NewDatastore(dir, nil)
+		So(err, ShouldBeNil)
+		r, err := NewRegistry(ctx, cfg, &MockAclCfg, dstore, nil)
+		So(err, ShouldBeNil)
+
+		peerID, err := peer.Decode(trustedID)
+		So(err, ShouldBeNil)
+		maddr, err := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/3002")
+		So(err, ShouldBeNil)
+		info := &ProviderInfo{
+			AddrInfo: peer.AddrInfo{
+				ID:    peerID,
+				Addrs: []multiaddr.Multiaddr{maddr},
+			},
+		}
+		So(r.Register(ctx, info), ShouldBeNil)
+		So(r.IsRegistered(peerID), ShouldBeTrue)
+
+		err = r.Unban(ctx, peerID)
+		So(err.(*syserr.SysError).Status(), ShouldEqual, http.StatusNotFound)
+
+		So(r.Ban(ctx, peerID, "misbehaving"), ShouldBeNil)
+		So(r.IsBanned(peerID), ShouldBeTrue)
+		So(r.Authorized(peerID), ShouldBeFalse)
+		err = r.Register(ctx, info)
+		So(err.(*syserr.SysError).Status(), ShouldEqual, http.StatusForbidden)
+
+		So(r.Deregister(ctx, peerID), ShouldBeNil)
+		So(r.IsRegistered(peerID), ShouldBeFalse)
+		err = r.Deregister(ctx, peerID)
+		So(err.(*syserr.SysError).Status(), ShouldEqual, http.StatusNotFound)
+		So(r.Close(), ShouldBeNil)
+
+		// The ban survives restart, the deregistered provider is gone.
+		dstore, err = leveldb.NewDatastore(dir, nil)
+		So(err, ShouldBeNil)
+		r, err = NewRegistry(ctx, cfg, &MockAclCfg, dstore, nil)
+		So(err, ShouldBeNil)
+		So(r.IsRegistered(peerID), ShouldBeFalse)
+		banned := r.BannedPeers()
+		So(len(banned), ShouldEqual, 1)
+		So(banned[0].Reason, ShouldEqual, "misbehaving")
+
+		So(r.Unban(ctx, peerID), ShouldBeNil)
+		So(r.Authorized(peerID), ShouldBeTrue)
+		So(r.Register(ctx, info), ShouldBeNil)
+		So(r.Close(), ShouldBeNil)
+	})
+}
@@ -0,0 +1,209 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// AddrSeparator separates the identities in a discovery address, that are
+// discovered by their own sources and merged.
+const AddrSeparator = ","
+
+// DNSAddrPrefix is the optional prefix of DNS based identities
+const DNSAddrPrefix = "dns:"
+
+var ErrNoSource = errors.New("no discoverer for the discovery address")
+
+var (
+	filecoinAddrRe = regexp.MustCompile(`^[ft](0[0-9]+|[13][a-z2-7]+)$`)
+	evmAddrRe      = regexp.MustCompile(`^0x[0-9a-fA-F]{40}(:0x[0-9a-fA-F]{130})?$`)
+	domainRe       = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z]{2,63}\.?$`)
+)
+
+// IsFilecoinAddr checks if addr is a Filecoin id(f0), secp256k1(f1) or BLS(f3)
+// address, on mainnet or testnet.
+func IsFilecoinAddr(addr string) bool {
+	return filecoinAddrRe.MatchString(addr)
+}
+
+// IsEVMAddr checks if addr is a hex encoded EVM account address, optionally
+// followed by the signature proving its ownership, see evm.Discoverer.
+func IsEVMAddr(addr string) bool {
+	return evmAddrRe.MatchString(addr)
+}
+
+// IsDNSAddr checks if addr is a domain name, optionally prefixed by "dns:"
+func IsDNSAddr(addr string) bool {
+	return domainRe.MatchString(strings.TrimPrefix(addr, DNSAddrPrefix))
+}
+
+// Source is a Discoverer used for the discovery addresses it matches
+type Source struct {
+	Name       string
+	Match      func(addr string) bool
+	Discoverer Discoverer
+	// Timeout bounds each discovery of the source, zero for no timeout
+	Timeout time.Duration
+}
+
+type cacheEntry struct {
+	discovered *Discovered
+	expires    time.Time
+}
+
+// Chain is a Discoverer that routes each identity in a discovery address to
+// the first source matching it, and merges the discovered results.
+type Chain struct {
+	sources  []Source
+	cacheTTL time.Duration
+
+	mutex sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// NewChain creates a Chain of the sources, the successful results are cached
+// for cacheTTL, zero disables caching.
+func NewChain(cacheTTL time.Duration, sources ...Source) *Chain {
+	return &Chain{
+		sources:  sources,
+		cacheTTL: cacheTTL,
+		cache:    make(map[string]cacheEntry),
+	}
+}
+
+func (c *Chain) Discover(ctx context.Context, peerID peer.ID, discoveryAddr string) (*Discovered, error) {
+	var results []*Discovered
+	for _, addr := range strings.Split(discoveryAddr, AddrSeparator) {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		discovered, err := c.discoverOne(ctx, peerID, addr)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, discovered)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrNoSource, discoveryAddr)
+	}
+	return merge(peerID, results), nil
+}
+
+func (c *Chain) discoverOne(ctx context.Context, peerID peer.ID, addr string) (*Discovered, error) {
+	cacheKey := peerID.String() + "/" + addr
+	if discovered := c.cached(cacheKey); discovered != nil {
+		return discovered, nil
+	}
+
+	for _, src := range c.sources {
+		if !src.Match(addr) {
+			continue
+		}
+		sctx := ctx
+		if src.Timeout != 0 {
+			var cancel context.CancelFunc
+			sctx, cancel = context.WithTimeout(ctx, src.Timeout)
+			defer cancel()
+		}
+		discovered, err := src.Discoverer.Discover(sctx, peerID, addr)
+		if err != nil {
+			return nil, fmt.Errorf("%s discovery of %s failed: %w", src.Name, addr, err)
+		}
+		c.store(cacheKey, discovered)
+		return discovered, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrNoSource, addr)
+}
+
+func (c *Chain) cached(key string) *Discovered {
+	if c.cacheTTL == 0 {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.cache[key]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.cache, key)
+		return nil
+	}
+	return entry.discovered
+}
+
+func (c *Chain) store(key string, discovered *Discovered) {
+	if c.cacheTTL == 0 {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	// Drop the expired entries, so that the cache does not keep growing with
+	// providers that are gone.
+	for k, entry := range c.cache {
+		if now.After(entry.expires) {
+			delete(c.cache, k)
+		}
+	}
+	c.cache[key] = cacheEntry{
+		discovered: discovered,
+		expires:    now.Add(c.cacheTTL),
+	}
+}
+
+// merge combines the results of the identities of a provider: the addresses are
+// joined, the balances are summed by chain, and the provider is a miner if any
+// of its identities is.
+func merge(peerID peer.ID, results []*Discovered) *Discovered {
+	if len(results) == 1 {
+		return results[0]
+	}
+
+	merged := &Discovered{
+		AddrInfo: peer.AddrInfo{ID: peerID},
+		Balance:  big.NewInt(0),
+		Type:     OtherType,
+	}
+	seen := make(map[string]struct{})
+	for _, d := range results {
+		for _, a := range d.AddrInfo.Addrs {
+			if _, ok := seen[a.String()]; ok {
+				continue
+			}
+			seen[a.String()] = struct{}{}
+			merged.AddrInfo.Addrs = append(merged.AddrInfo.Addrs, a)
+		}
+		if d.Balance != nil {
+			merged.Balance.Add(merged.Balance, d.Balance)
+		}
+		for chain, balance := range d.Balances {
+			if merged.Balances == nil {
+				merged.Balances = make(map[string]*big.Int)
+			}
+			if merged.Balances[chain] == nil {
+				merged.Balances[chain] = big.NewInt(0)
+			}
+			merged.Balances[chain].Add(merged.Balances[chain], balance)
+		}
+		if d.Type == MinerType {
+			merged.Type = MinerType
+		}
+	}
+	if merged.AddrInfo.Addrs == nil {
+		merged.AddrInfo.Addrs = []multiaddr.Multiaddr{}
+	}
+	return merged
+}
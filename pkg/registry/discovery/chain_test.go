@@ -0,0 +1,109 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multiaddr"
+	. "github.com/smartystreets/goconvey/convey"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stubDiscoverer struct {
+	discovered *Discovered
+	calls      int
+	delay      time.Duration
+}
+
+func (s *stubDiscoverer) Discover(ctx context.Context, _ peer.ID, _ string) (*Discovered, error) {
+	s.calls++
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if s.discovered == nil {
+		return nil, errors.New("not found")
+	}
+	return s.discovered, nil
+}
+
+func TestAddrFormats(t *testing.T) {
+	Convey("test discovery address formats", t, func() {
+		for _, addr := range []string{"f01000", "t01000", "f1abjxfbp274xpdqcpuaykwkfb43omjotacm2p3za", "f3vvmn62lofvhjd2ugzca6sof2j2ubwok6cj4xxbfzz4yuxfkgobpihhd2thlanmsh3w2ptld2gqkn2jvlss4a"} {
+			So(IsFilecoinAddr(addr), ShouldBeTrue)
+		}
+		for _, addr := range []string{"f2abc", "f0abc", "01000", "stitest999999"} {
+			So(IsFilecoinAddr(addr), ShouldBeFalse)
+		}
+		So(IsEVMAddr("0x52908400098527886E0F7030069857D2E4169EE7"), ShouldBeTrue)
+		So(IsEVMAddr("0x52908400098527886E0F7030069857D2E4169EE7:0x"+strings.Repeat("ab", 65)), ShouldBeTrue)
+		So(IsEVMAddr("0x5290840009852788"), ShouldBeFalse)
+		So(IsEVMAddr("0x52908400098527886E0F7030069857D2E4169EE7:0xabcd"), ShouldBeFalse)
+		So(IsDNSAddr("example.com"), ShouldBeTrue)
+		So(IsDNSAddr("dns:sub.example.com"), ShouldBeTrue)
+		So(IsDNSAddr("t01000"), ShouldBeFalse)
+	})
+}
+
+func TestChain(t *testing.T) {
+	Convey("test chained discoverers", t, func() {
+		peerID, err := peer.Decode("12D3KooWRqmtFv7ccFfjR7RDcevoMEMXdCHNR8JNN8aNiH2dgk8Z")
+		So(err, ShouldBeNil)
+		maddr, err := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/9000")
+		So(err, ShouldBeNil)
+
+		lotus := &stubDiscoverer{discovered: &Discovered{
+			AddrInfo: peer.AddrInfo{ID: peerID, Addrs: []multiaddr.Multiaddr{maddr}},
+			Balance:  big.NewInt(5),
+			Type:     MinerType,
+		}}
+		evm := &stubDiscoverer{discovered: &Discovered{
+			AddrInfo: peer.AddrInfo{ID: peerID},
+			Balance:  big.NewInt(0),
+			Balances: map[string]*big.Int{EVMChain: big.NewInt(3)},
+			Type:     OtherType,
+		}}
+		dns := &stubDiscoverer{delay: time.Second}
+		chain := NewChain(time.Minute,
+			Source{Name: "lotus", Match: IsFilecoinAddr, Discoverer: lotus},
+			Source{Name: "evm", Match: IsEVMAddr, Discoverer: evm},
+			Source{Name: "dns", Match: IsDNSAddr, Discoverer: dns, Timeout: 10 * time.Millisecond},
+		)
+		ctx := context.Background()
+
+		Convey("route by address format and cache the results", func() {
+			d, err := chain.Discover(ctx, peerID, "t01000")
+			So(err, ShouldBeNil)
+			So(d, ShouldEqual, lotus.discovered)
+			_, err = chain.Discover(ctx, peerID, "t01000")
+			So(err, ShouldBeNil)
+			So(lotus.calls, ShouldEqual, 1)
+			So(evm.calls, ShouldEqual, 0)
+		})
+
+		Convey("merge the results of multiple identities", func() {
+			d, err := chain.Discover(ctx, peerID, "t01000, 0x52908400098527886E0F7030069857D2E4169EE7")
+			So(err, ShouldBeNil)
+			So(d.AddrInfo.ID, ShouldEqual, peerID)
+			So(d.AddrInfo.Addrs, ShouldResemble, []multiaddr.Multiaddr{maddr})
+			// balances of different chains are not summed
+			So(d.Balance.Int64(), ShouldEqual, 5)
+			So(d.Balances, ShouldHaveLength, 1)
+			So(d.Balances[EVMChain].Int64(), ShouldEqual, 3)
+			So(d.Type, ShouldEqual, MinerType)
+		})
+
+		Convey("fail on unknown formats and source timeouts", func() {
+			_, err := chain.Discover(ctx, peerID, "stitest999999")
+			So(errors.Is(err, ErrNoSource), ShouldBeTrue)
+			_, err = chain.Discover(ctx, peerID, "")
+			So(errors.Is(err, ErrNoSource), ShouldBeTrue)
+			_, err = chain.Discover(ctx, peerID, "t01000,example.com")
+			So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+		})
+	})
+}
@@ -12,6 +12,12 @@ const (
 	MinerType
 )
 
+// Chains of the discovered balances
+const (
+	FilecoinChain = "filecoin"
+	EVMChain      = "evm"
+)
+
 // Discoverer is the interface that supplies functionality to discover providers
 type Discoverer interface {
 	Discover(ctx context.Context, peerID peer.ID, discoveryAddr string) (*Discovered, error)
@@ -20,6 +26,11 @@ type Discoverer interface {
 // Discovered holds information about a provider that is discovered
 type Discovered struct {
 	AddrInfo peer.AddrInfo
-	Balance  *big.Int
+	// Balance is the Filecoin balance in attoFIL, the account level is
+	// evaluated from it.
+	Balance *big.Int
+	// Balances are the balances of the other chains by chain, each in the
+	// base unit of its chain, e.g. wei.  They are never added to Balance.
+	Balances map[string]*big.Int
 	Type     int
 }
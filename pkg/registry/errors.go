@@ -10,4 +10,10 @@ var (
 	ErrWrongWeight = errors.New("provider should not have weight before evaluating")
 	ErrNotVerified = errors.New("provider cannot be verified")
 	ErrTooSoon     = errors.New("not enough time since previous discovery")
+
+	ErrBadPolicyUpdate = errors.New("bad policy update")
+	ErrBanned          = errors.New("peer is banned")
+	ErrNotBanned       = errors.New("peer is not banned")
+	ErrNotRegistered   = errors.New("provider is not registered")
+	ErrNotQuarantined  = errors.New("provider is not quarantined")
 )
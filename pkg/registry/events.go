@@ -0,0 +1,143 @@
+package registry
+
+import (
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"sync"
+	"time"
+)
+
+// eventBufferSize is the number of events buffered for each subscriber, the
+// events are dropped for the subscriber if its buffer is full.
+const eventBufferSize = 64
+
+// EventType is the kind of change happened in registry
+type EventType string
+
+const (
+	// EventRegistered is published when a new provider is registered, Current
+	// is the *ProviderInfo
+	EventRegistered EventType = "registered"
+	// EventUpdated is published when the info of a registered provider is
+	// updated, Current is the *ProviderInfo
+	EventUpdated EventType = "updated"
+	// EventPublisherChanged is published when the publisher of a provider is
+	// changed, Previous and Current are the peer.ID of the publishers
+	EventPublisherChanged EventType = "publisher-changed"
+	// EventLostContact is published when the publisher of a provider has not
+	// responded for too long, Previous is the last contact time
+	EventLostContact EventType = "lost-contact"
+	// EventAccountLevelChanged is published when the account level of a
+	// provider is changed by re-discovery
+	EventAccountLevelChanged EventType = "account-level-changed"
+)
+
+// EventTypes are all the types of events published by registry
+var EventTypes = []EventType{
+	EventRegistered,
+	EventUpdated,
+	EventPublisherChanged,
+	EventLostContact,
+	EventAccountLevelChanged,
+}
+
+// ParseEventType parses an event type from string
+func ParseEventType(s string) (EventType, error) {
+	for _, t := range EventTypes {
+		if string(t) == s {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown event type: %q", s)
+}
+
+// Event describes a change of a provider in registry.  Previous and Current
+// are the changed values, their types depend on the event type.
+type Event struct {
+	Type     EventType
+	Provider peer.ID
+	Time     time.Time
+	Previous interface{} `json:",omitempty"`
+	Current  interface{} `json:",omitempty"`
+}
+
+type eventBus struct {
+	mutex  sync.Mutex
+	subs   map[chan *Event]struct{}
+	closed bool
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		subs: make(map[chan *Event]struct{}),
+	}
+}
+
+func (b *eventBus) subscribe() (<-chan *Event, func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	ch := make(chan *Event, eventBufferSize)
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subs[ch] = struct{}{}
+
+	cancel := func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+func (b *eventBus) publish(e *Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			logger.Warnw("event subscriber is too slow, dropping event", "type", e.Type, "provider", e.Provider)
+		}
+	}
+}
+
+func (b *eventBus) close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for ch := range b.subs {
+		close(ch)
+	}
+	b.subs = nil
+	b.closed = true
+}
+
+// Subscribe returns a channel of the events of registry, and a function to
+// cancel the subscription.  The channel is closed when the subscription is
+// canceled or the registry is closed.
+func (r *Registry) Subscribe() (<-chan *Event, func()) {
+	return r.events.subscribe()
+}
+
+func (r *Registry) publish(eventType EventType, providerID peer.ID, previous, current interface{}) {
+	// The subscribers encode the events later, while the registry may change
+	// the info in place, so they are given a copy.
+	if info, ok := current.(*ProviderInfo); ok {
+		cp := *info
+		current = &cp
+	}
+	r.events.publish(&Event{
+		Type:     eventType,
+		Provider: providerID,
+		Time:     time.Now(),
+		Previous: previous,
+		Current:  current,
+	})
+}
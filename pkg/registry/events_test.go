@@ -0,0 +1,96 @@
+package registry
+
+import (
+	"context"
+	"github.com/kenlabs/pando/pkg/option"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+// waitEvent skips the events of other types, and returns nil if no event of the
+// type is received in time.
+func waitEvent(events <-chan *Event, eventType EventType) *Event {
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if e.Type == eventType {
+				return e
+			}
+		case <-timeout:
+			return nil
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	Convey("test registry publishes provider events", t, func() {
+		cfg := &option.Discovery{
+			Policy: option.Policy{
+				Allow: true,
+				Trust: true,
+			},
+			RediscoverWait: option.Duration(time.Minute).String(),
+		}
+		ctx := context.Background()
+		r, err := NewRegistry(ctx, cfg, &MockAclCfg, nil, nil)
+		So(err, ShouldBeNil)
+		events, cancel := r.Subscribe()
+		defer cancel()
+
+		providerID, err := peer.Decode(trustedID)
+		So(err, ShouldBeNil)
+		publisherID, err := peer.Decode("12D3KooWRqmtFv7ccFfjR7RDcevoMEMXdCHNR8JNN8aNiH2dgk8Z")
+		So(err, ShouldBeNil)
+
+		So(r.Register(ctx, &ProviderInfo{AddrInfo: peer.AddrInfo{ID: providerID}}), ShouldBeNil)
+		e := waitEvent(events, EventRegistered)
+		So(e, ShouldNotBeNil)
+		So(e.Provider, ShouldEqual, providerID)
+		So(e.Current.(*ProviderInfo).AddrInfo.ID, ShouldEqual, providerID)
+
+		// The info of the event is not changed with the registry
+		done := make(chan struct{})
+		r.actions <- func() {
+			r.providers[providerID].LastContactTime = time.Now()
+			close(done)
+		}
+		<-done
+		So(e.Current.(*ProviderInfo).LastContactTime.IsZero(), ShouldBeTrue)
+
+		So(r.Register(ctx, &ProviderInfo{AddrInfo: peer.AddrInfo{ID: providerID}, Publisher: publisherID}), ShouldBeNil)
+		e = waitEvent(events, EventUpdated)
+		So(e, ShouldNotBeNil)
+		So(e.Current.(*ProviderInfo).Publisher, ShouldEqual, publisherID)
+		e = waitEvent(events, EventPublisherChanged)
+		So(e, ShouldNotBeNil)
+		So(e.Previous, ShouldEqual, peer.ID(""))
+		So(e.Current, ShouldEqual, publisherID)
+
+		// Lost contact for too long
+		lastContact := time.Now().Add(-time.Hour)
+		r.actions <- func() {
+			info := *r.providers[providerID]
+			info.LastContactTime = lastContact
+			r.providers[providerID] = &info
+		}
+		r.pollProviders(time.Minute, time.Minute)
+		e = waitEvent(events, EventLostContact)
+		So(e, ShouldNotBeNil)
+		So(e.Previous, ShouldResemble, lastContact)
+
+		_, err = ParseEventType("unknown")
+		So(err, ShouldNotBeNil)
+		eventType, err := ParseEventType("lost-contact")
+		So(err, ShouldBeNil)
+		So(eventType, ShouldEqual, EventLostContact)
+
+		So(r.Close(), ShouldBeNil)
+		So(waitEvent(events, EventRegistered), ShouldBeNil)
+	})
+}
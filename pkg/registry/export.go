@@ -0,0 +1,271 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ipfs/go-datastore"
+	"github.com/kenlabs/pando/pkg/option"
+	"github.com/kenlabs/pando/pkg/registry/internal/syserr"
+	"github.com/kenlabs/pando/pkg/registry/policy"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"net/http"
+	"time"
+)
+
+var ErrBadSnapshot = errors.New("bad registry snapshot")
+
+// Snapshot is the persisted state of registry, exported to migrate or seed
+// other Pando instances.  The status histories are not included.
+type Snapshot struct {
+	Providers []*ProviderInfo
+	// Policy is the policy changed at runtime, nil if it has never been
+	// changed and the config is used.
+	Policy    *option.Policy `json:",omitempty"`
+	Banned    []*BanRecord
+	Sequences []*SequenceRecord
+}
+
+// ImportResult is the number of entries imported into registry
+type ImportResult struct {
+	Providers int
+	Policy    bool
+	Banned    int
+	Sequences int
+	// SkippedBanned are the providers not imported as they are banned locally
+	SkippedBanned []peer.ID
+}
+
+// Validate checks the snapshot before importing
+func (s *Snapshot) Validate() error {
+	providers := make(map[peer.ID]struct{}, len(s.Providers))
+	for _, info := range s.Providers {
+		if info == nil {
+			return fmt.Errorf("%w: nil provider", ErrBadSnapshot)
+		}
+		if err := info.AddrInfo.ID.Validate(); err != nil {
+			return fmt.Errorf("%w: invalid provider id: %v", ErrBadSnapshot, err)
+		}
+		if _, ok := providers[info.AddrInfo.ID]; ok {
+			return fmt.Errorf("%w: duplicate provider %s", ErrBadSnapshot, info.AddrInfo.ID)
+		}
+		providers[info.AddrInfo.ID] = struct{}{}
+		if info.Status != "" {
+			if _, err := ParseStatus(string(info.Status)); err != nil {
+				return fmt.Errorf("%w: provider %s: %v", ErrBadSnapshot, info.AddrInfo.ID, err)
+			}
+		}
+		if info.Publisher != "" {
+			if err := info.Publisher.Validate(); err != nil {
+				return fmt.Errorf("%w: provider %s: invalid publisher: %v", ErrBadSnapshot, info.AddrInfo.ID, err)
+			}
+		}
+	}
+
+	if s.Policy != nil {
+		if _, err := policy.New(*s.Policy); err != nil {
+			return fmt.Errorf("%w: invalid policy: %v", ErrBadSnapshot, err)
+		}
+	}
+
+	for _, rec := range s.Banned {
+		if rec == nil {
+			return fmt.Errorf("%w: nil ban record", ErrBadSnapshot)
+		}
+		if err := rec.PeerID.Validate(); err != nil {
+			return fmt.Errorf("%w: invalid banned peer id: %v", ErrBadSnapshot, err)
+		}
+	}
+
+	for _, rec := range s.Sequences {
+		if rec == nil {
+			return fmt.Errorf("%w: nil sequence record", ErrBadSnapshot)
+		}
+		if err := rec.PeerID.Validate(); err != nil {
+			return fmt.Errorf("%w: invalid sequence peer id: %v", ErrBadSnapshot, err)
+		}
+	}
+	return nil
+}
+
+// Export returns a snapshot of the registry
+func (r *Registry) Export(ctx context.Context) (*Snapshot, error) {
+	snapshot := &Snapshot{}
+	errCh := make(chan error, 1)
+	r.actions <- func() {
+		var err error
+		snapshot.Policy, err = r.syncPersistedPolicy(ctx)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		snapshot.Providers = make([]*ProviderInfo, 0, len(r.providers))
+		for _, info := range r.providers {
+			snapshot.Providers = append(snapshot.Providers, info)
+		}
+		snapshot.Banned = make([]*BanRecord, 0, len(r.banned))
+		for _, rec := range r.banned {
+			snapshot.Banned = append(snapshot.Banned, rec)
+		}
+		errCh <- nil
+	}
+	if err := <-errCh; err != nil {
+		return nil, syserr.New(fmt.Errorf("cannot read persisted policy: %s", err), http.StatusInternalServerError)
+	}
+	snapshot.Sequences = r.sequences.records()
+	return snapshot, nil
+}
+
+// Import imports the snapshot into registry after validating it.  With
+// replace, the providers, bans and sequences not in the snapshot are removed,
+// and the policy is replaced if the snapshot has one.  Otherwise the snapshot
+// is merged: the local providers, bans and runtime policy are kept on
+// conflict, the providers banned locally are skipped, and the larger sequences
+// are kept.
+func (r *Registry) Import(ctx context.Context, snapshot *Snapshot, replace bool, operator string) (*ImportResult, error) {
+	if snapshot == nil {
+		return nil, syserr.New(fmt.Errorf("%w: nil snapshot", ErrBadSnapshot), http.StatusBadRequest)
+	}
+	if err := snapshot.Validate(); err != nil {
+		return nil, syserr.New(err, http.StatusBadRequest)
+	}
+
+	result := &ImportResult{}
+	errCh := make(chan error, 1)
+	r.actions <- func() {
+		errCh <- r.syncImport(ctx, snapshot, replace, operator, result)
+	}
+	if err := <-errCh; err != nil {
+		return nil, err
+	}
+
+	var err error
+	result.Sequences, err = r.sequences.importRecords(snapshot.Sequences, replace)
+	if err != nil {
+		return nil, syserr.New(err, http.StatusInternalServerError)
+	}
+
+	logger.Infow("imported registry snapshot", "replace", replace, "providers", result.Providers,
+		"skippedBanned", len(result.SkippedBanned), "policy", result.Policy, "banned", result.Banned, "sequences", result.Sequences, "operator", operator)
+	return result, nil
+}
+
+func (r *Registry) syncImport(ctx context.Context, snapshot *Snapshot, replace bool, operator string, result *ImportResult) error {
+	if replace {
+		imported := make(map[peer.ID]struct{}, len(snapshot.Providers))
+		for _, info := range snapshot.Providers {
+			imported[info.AddrInfo.ID] = struct{}{}
+		}
+		for id := range r.providers {
+			if _, ok := imported[id]; ok {
+				continue
+			}
+			if err := r.syncDeregister(ctx, id); err != nil {
+				return err
+			}
+		}
+		for id := range r.banned {
+			if err := r.syncUnban(ctx, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	if snapshot.Policy != nil {
+		persisted, err := r.syncPersistedPolicy(ctx)
+		if err != nil {
+			return syserr.New(fmt.Errorf("cannot read persisted policy: %s", err), http.StatusInternalServerError)
+		}
+		if replace || persisted == nil {
+			if err = r.syncImportPolicy(ctx, *snapshot.Policy, operator); err != nil {
+				return err
+			}
+			result.Policy = true
+		}
+	}
+
+	for _, info := range snapshot.Providers {
+		if _, banned := r.banned[info.AddrInfo.ID]; banned && !replace {
+			result.SkippedBanned = append(result.SkippedBanned, info.AddrInfo.ID)
+			continue
+		}
+		prev, ok := r.providers[info.AddrInfo.ID]
+		if ok && !replace {
+			continue
+		}
+		newInfo := *info
+		if newInfo.Status == "" {
+			newInfo.Status = StatusRegistered
+			newInfo.StatusChanged = time.Now()
+		}
+		r.index.update(prev, &newInfo)
+		r.providers[newInfo.AddrInfo.ID] = &newInfo
+		if err := r.syncPersistProvider(ctx, &newInfo); err != nil {
+			err = fmt.Errorf("could not persist provider: %s", err)
+			return syserr.New(err, http.StatusInternalServerError)
+		}
+		if ok {
+			r.publish(EventUpdated, newInfo.AddrInfo.ID, nil, &newInfo)
+		} else {
+			r.publish(EventRegistered, newInfo.AddrInfo.ID, nil, &newInfo)
+		}
+		result.Providers++
+	}
+
+	for _, rec := range snapshot.Banned {
+		if _, ok := r.banned[rec.PeerID]; ok {
+			continue
+		}
+		banRec := *rec
+		if err := r.syncBan(ctx, &banRec); err != nil {
+			return err
+		}
+		result.Banned++
+	}
+	return nil
+}
+
+func (r *Registry) syncImportPolicy(ctx context.Context, cfg option.Policy, operator string) error {
+	p, err := policy.New(cfg)
+	if err != nil {
+		return syserr.New(err, http.StatusBadRequest)
+	}
+	r.policy = p
+
+	if err = r.syncPersistPolicy(ctx, cfg); err != nil {
+		err = fmt.Errorf("could not persist policy: %s", err)
+		return syserr.New(err, http.StatusInternalServerError)
+	}
+	rec := &PolicyAuditRecord{
+		Time:     time.Now(),
+		Operator: operator,
+		Update:   PolicyUpdate{Action: PolicyImport},
+		Policy:   cfg,
+	}
+	if err = r.syncPersistPolicyAudit(ctx, rec); err != nil {
+		err = fmt.Errorf("could not persist policy audit: %s", err)
+		return syserr.New(err, http.StatusInternalServerError)
+	}
+	return nil
+}
+
+// syncPersistedPolicy returns the policy changed at runtime, or nil if it has
+// never been changed.
+func (r *Registry) syncPersistedPolicy(ctx context.Context) (*option.Policy, error) {
+	if r.dstore == nil {
+		return nil, nil
+	}
+	value, err := r.dstore.Get(ctx, datastore.NewKey(policyKey))
+	if err != nil {
+		if err == datastore.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	cfg := new(option.Policy)
+	if err = json.Unmarshal(value, cfg); err != nil {
+		return nil, fmt.Errorf("cannot decode persisted policy: %s", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,79 @@
+package registry
+
+import (
+	"github.com/libp2p/go-libp2p-core/peer"
+	"strconv"
+)
+
+type peerSet map[peer.ID]struct{}
+
+// peerIndex maps an indexed value to the peers having it
+type peerIndex map[string]peerSet
+
+func (m peerIndex) add(key string, id peer.ID) {
+	set, ok := m[key]
+	if !ok {
+		set = make(peerSet)
+		m[key] = set
+	}
+	set[id] = struct{}{}
+}
+
+func (m peerIndex) remove(key string, id peer.ID) {
+	set, ok := m[key]
+	if !ok {
+		return
+	}
+	delete(set, id)
+	if len(set) == 0 {
+		delete(m, key)
+	}
+}
+
+// providerIndex holds the secondary indexes of the registered providers.  It
+// is only accessed on the run goroutine of registry.
+type providerIndex struct {
+	byAddr      peerIndex
+	byName      peerIndex
+	byPublisher peerIndex
+	byLevel     peerIndex
+}
+
+func newProviderIndex() *providerIndex {
+	return &providerIndex{
+		byAddr:      make(peerIndex),
+		byName:      make(peerIndex),
+		byPublisher: make(peerIndex),
+		byLevel:     make(peerIndex),
+	}
+}
+
+func (x *providerIndex) add(info *ProviderInfo) {
+	id := info.AddrInfo.ID
+	if info.DiscoveryAddr != "" {
+		x.byAddr.add(info.DiscoveryAddr, id)
+	}
+	if info.Name != "" {
+		x.byName.add(info.Name, id)
+	}
+	if info.Publisher != "" {
+		x.byPublisher.add(string(info.Publisher), id)
+	}
+	x.byLevel.add(strconv.Itoa(info.AccountLevel), id)
+}
+
+func (x *providerIndex) remove(info *ProviderInfo) {
+	id := info.AddrInfo.ID
+	x.byAddr.remove(info.DiscoveryAddr, id)
+	x.byName.remove(info.Name, id)
+	x.byPublisher.remove(string(info.Publisher), id)
+	x.byLevel.remove(strconv.Itoa(info.AccountLevel), id)
+}
+
+// update replaces the indexes of prev, which may be nil, with the ones of info
+func (x *providerIndex) update(prev, info *ProviderInfo) {
+	if prev != nil {
+		x.remove(prev)
+	}
+	x.add(info)
+}
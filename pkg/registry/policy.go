@@ -0,0 +1,200 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/kenlabs/pando/pkg/option"
+	"github.com/kenlabs/pando/pkg/registry/internal/syserr"
+	"github.com/kenlabs/pando/pkg/registry/policy"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"net/http"
+	"path"
+	"time"
+)
+
+const (
+	// policyKey is where the runtime policy is stored, it overrides the
+	// policy in config once it exists.
+	policyKey = "/registry/policy"
+	// policyAuditKeyPath is where the audit records of policy changes are stored
+	policyAuditKeyPath = "/registry/audit/policy"
+)
+
+// PolicyAction is the kind of change applied to the policy at runtime
+type PolicyAction string
+
+const (
+	PolicySetAllow          PolicyAction = "set-allow"
+	PolicySetTrust          PolicyAction = "set-trust"
+	PolicyAddExcept         PolicyAction = "add-except"
+	PolicyRemoveExcept      PolicyAction = "remove-except"
+	PolicyAddTrustExcept    PolicyAction = "add-trust-except"
+	PolicyRemoveTrustExcept PolicyAction = "remove-trust-except"
+	// PolicyImport replaces the policy with the one of an imported snapshot
+	PolicyImport PolicyAction = "import"
+)
+
+// PolicyUpdate describes a single change of the policy.  Value is used by the
+// set actions and PeerID by the except actions.
+type PolicyUpdate struct {
+	Action PolicyAction
+	PeerID peer.ID `json:",omitempty"`
+	Value  bool
+}
+
+// PolicyAuditRecord records who changed the policy, when and how, together
+// with the policy after the change was applied.
+type PolicyAuditRecord struct {
+	Time     time.Time
+	Operator string
+	Update   PolicyUpdate
+	Policy   option.Policy
+}
+
+// Policy returns the policy currently used by the registry
+func (r *Registry) Policy() option.Policy {
+	return r.policy.Config()
+}
+
+// UpdatePolicy applies the change to the policy, persists the new policy so
+// that it overrides the config on restart, and records the change for audit.
+func (r *Registry) UpdatePolicy(ctx context.Context, update *PolicyUpdate, operator string) error {
+	if update == nil {
+		return syserr.New(ErrBadPolicyUpdate, http.StatusBadRequest)
+	}
+	errCh := make(chan error, 1)
+	r.actions <- func() {
+		errCh <- r.syncUpdatePolicy(ctx, update, operator)
+	}
+	err := <-errCh
+	if err != nil {
+		return err
+	}
+
+	logger.Infow("updated policy", "action", update.Action, "peer", update.PeerID, "value", update.Value, "operator", operator)
+	return nil
+}
+
+// PolicyAudit returns the audit records of policy changes in time order
+func (r *Registry) PolicyAudit(ctx context.Context) ([]*PolicyAuditRecord, error) {
+	if r.dstore == nil {
+		return nil, nil
+	}
+	results, err := r.dstore.Query(ctx, query.Query{
+		Prefix: policyAuditKeyPath,
+		Orders: []query.Order{query.OrderByKey{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	records := make([]*PolicyAuditRecord, 0)
+	for result := range results.Next() {
+		if result.Error != nil {
+			return nil, fmt.Errorf("cannot read policy audit: %v", result.Error)
+		}
+		rec := new(PolicyAuditRecord)
+		if err = json.Unmarshal(result.Entry.Value, rec); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func (r *Registry) syncUpdatePolicy(ctx context.Context, update *PolicyUpdate, operator string) error {
+	var err error
+	switch update.Action {
+	case PolicySetAllow:
+		err = r.policy.SetAllow(update.Value)
+	case PolicySetTrust:
+		r.policy.SetTrust(update.Value)
+	case PolicyAddExcept, PolicyRemoveExcept, PolicyAddTrustExcept, PolicyRemoveTrustExcept:
+		if err = update.PeerID.Validate(); err != nil {
+			return syserr.New(fmt.Errorf("invalid peer id: %v", err), http.StatusBadRequest)
+		}
+		switch update.Action {
+		case PolicyAddExcept:
+			r.policy.AddExcept(update.PeerID)
+		case PolicyRemoveExcept:
+			err = r.policy.RemoveExcept(update.PeerID)
+		case PolicyAddTrustExcept:
+			r.policy.AddTrustExcept(update.PeerID)
+		case PolicyRemoveTrustExcept:
+			r.policy.RemoveTrustExcept(update.PeerID)
+		}
+	default:
+		return syserr.New(fmt.Errorf("%w: unknown action %q", ErrBadPolicyUpdate, update.Action), http.StatusBadRequest)
+	}
+	if err != nil {
+		return syserr.New(err, http.StatusBadRequest)
+	}
+
+	cfg := r.policy.Config()
+	if err = r.syncPersistPolicy(ctx, cfg); err != nil {
+		err = fmt.Errorf("could not persist policy: %s", err)
+		return syserr.New(err, http.StatusInternalServerError)
+	}
+
+	rec := &PolicyAuditRecord{
+		Time:     time.Now(),
+		Operator: operator,
+		Update:   *update,
+		Policy:   cfg,
+	}
+	if err = r.syncPersistPolicyAudit(ctx, rec); err != nil {
+		err = fmt.Errorf("could not persist policy audit: %s", err)
+		return syserr.New(err, http.StatusInternalServerError)
+	}
+	return nil
+}
+
+func (r *Registry) syncPersistPolicy(ctx context.Context, cfg option.Policy) error {
+	if r.dstore == nil {
+		return nil
+	}
+	value, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	dsKey := datastore.NewKey(policyKey)
+	if err = r.dstore.Put(ctx, dsKey, value); err != nil {
+		return err
+	}
+	return r.dstore.Sync(ctx, dsKey)
+}
+
+func (r *Registry) syncPersistPolicyAudit(ctx context.Context, rec *PolicyAuditRecord) error {
+	if r.dstore == nil {
+		return nil
+	}
+	value, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	// Zero padded so that the keys are ordered by time.
+	dsKey := datastore.NewKey(path.Join(policyAuditKeyPath, fmt.Sprintf("%020d", rec.Time.UnixNano())))
+	if err = r.dstore.Put(ctx, dsKey, value); err != nil {
+		return err
+	}
+	return r.dstore.Sync(ctx, dsKey)
+}
+
+// loadPersistedPolicy replaces the policy from config with the persisted one,
+// if the policy has ever been changed at runtime.
+func (r *Registry) loadPersistedPolicy(ctx context.Context) (bool, error) {
+	cfg, err := r.syncPersistedPolicy(ctx)
+	if err != nil || cfg == nil {
+		return false, err
+	}
+	p, err := policy.New(*cfg)
+	if err != nil {
+		return false, fmt.Errorf("invalid persisted policy: %s", err)
+	}
+	r.policy = p
+	return true, nil
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/kenlabs/pando/pkg/option"
+	"sort"
 	"sync"
 
 	"github.com/libp2p/go-libp2p-core/peer"
@@ -108,3 +109,91 @@ func (p *Policy) Check(peerID peer.ID) (bool, bool) {
 
 	return true, true
 }
+
+// SetAllow changes the default allow policy.  Disabling the default allow is
+// rejected if no peer is left in the except list, since that would not allow
+// any providers.
+func (p *Policy) SetAllow(allow bool) error {
+	p.rwmutex.Lock()
+	defer p.rwmutex.Unlock()
+
+	if !allow && len(p.except) == 0 {
+		return errors.New("policy does not allow any providers")
+	}
+	p.allow = allow
+	return nil
+}
+
+// SetTrust changes the default trust policy.
+func (p *Policy) SetTrust(trust bool) {
+	p.rwmutex.Lock()
+	defer p.rwmutex.Unlock()
+	p.trust = trust
+}
+
+// AddExcept adds the peer to the exceptions of the allow policy.
+func (p *Policy) AddExcept(peerID peer.ID) {
+	p.rwmutex.Lock()
+	defer p.rwmutex.Unlock()
+
+	if p.except == nil {
+		p.except = make(map[peer.ID]struct{})
+	}
+	p.except[peerID] = struct{}{}
+}
+
+// RemoveExcept removes the peer from the exceptions of the allow policy.
+func (p *Policy) RemoveExcept(peerID peer.ID) error {
+	p.rwmutex.Lock()
+	defer p.rwmutex.Unlock()
+
+	if _, ok := p.except[peerID]; !ok {
+		return nil
+	}
+	if !p.allow && len(p.except) == 1 {
+		return errors.New("policy does not allow any providers")
+	}
+	delete(p.except, peerID)
+	return nil
+}
+
+// AddTrustExcept adds the peer to the exceptions of the trust policy.
+func (p *Policy) AddTrustExcept(peerID peer.ID) {
+	p.rwmutex.Lock()
+	defer p.rwmutex.Unlock()
+
+	if p.trustExcept == nil {
+		p.trustExcept = make(map[peer.ID]struct{})
+	}
+	p.trustExcept[peerID] = struct{}{}
+}
+
+// RemoveTrustExcept removes the peer from the exceptions of the trust policy.
+func (p *Policy) RemoveTrustExcept(peerID peer.ID) {
+	p.rwmutex.Lock()
+	defer p.rwmutex.Unlock()
+	delete(p.trustExcept, peerID)
+}
+
+// Config returns the current policy in its configuration form, so that it can
+// be persisted and later loaded with New.
+func (p *Policy) Config() option.Policy {
+	p.rwmutex.RLock()
+	defer p.rwmutex.RUnlock()
+
+	return option.Policy{
+		Allow:       p.allow,
+		Except:      peerIDStrings(p.except),
+		Trust:       p.trust,
+		TrustExcept: peerIDStrings(p.trustExcept),
+	}
+}
+
+func peerIDStrings(ids map[peer.ID]struct{}) []string {
+	strs := make([]string, 0, len(ids))
+	for id := range ids {
+		strs = append(strs, id.String())
+	}
+	sort.Strings(strs)
+	return strs
+}
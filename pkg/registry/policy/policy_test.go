@@ -123,3 +123,61 @@ func TestPolicyAccess(t *testing.T) {
 		t.Error("account ID", trustedID, "should be trusted")
 	}
 }
+
+func TestPolicyUpdate(t *testing.T) {
+	policyCfg := option.Policy{
+		Allow:       false,
+		Except:      []string{exceptIDStr},
+		Trust:       false,
+		TrustExcept: []string{},
+	}
+
+	p, err := New(policyCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = p.RemoveExcept(exceptID); err == nil {
+		t.Error("expected error when removing the only allowed peer")
+	}
+	p.AddExcept(trustedID)
+	if !p.Allowed(trustedID) {
+		t.Error("account ID should be allowed after adding to except list")
+	}
+	if err = p.RemoveExcept(exceptID); err != nil {
+		t.Fatal(err)
+	}
+	if p.Allowed(exceptID) {
+		t.Error("account ID should not be allowed after removing from except list")
+	}
+
+	p.AddTrustExcept(trustedID)
+	if !p.Trusted(trustedID) {
+		t.Error("account ID should be trusted after adding to trust except list")
+	}
+	p.SetTrust(true)
+	if p.Trusted(trustedID) {
+		t.Error("account ID should not be trusted when default trust is switched")
+	}
+	p.RemoveTrustExcept(trustedID)
+	if !p.Trusted(trustedID) {
+		t.Error("account ID should be trusted after removing from trust except list")
+	}
+
+	if err = p.SetAllow(true); err != nil {
+		t.Fatal(err)
+	}
+	cfg := p.Config()
+	if !cfg.Allow || !cfg.Trust || len(cfg.Except) != 1 || cfg.Except[0] != trustedIDStr || len(cfg.TrustExcept) != 0 {
+		t.Errorf("unexpected policy config: %+v", cfg)
+	}
+
+	// A policy built from the config must behave the same.
+	p2, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p2.Allowed(trustedID) || !p2.Allowed(exceptID) {
+		t.Error("policy from config does not match the original one")
+	}
+}
@@ -0,0 +1,63 @@
+package registry
+
+import (
+	"context"
+	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/kenlabs/pando/pkg/option"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestUpdatePolicy(t *testing.T) {
+	Convey("test update policy and reload it after restart", t, func() {
+		cfg := &option.Discovery{
+			Policy: option.Policy{
+				Allow: true,
+				Trust: true,
+			},
+			RediscoverWait: option.Duration(time.Minute).String(),
+		}
+		ctx := context.Background()
+		dir := t.TempDir()
+		dstore, err := leveldb.NewDatastore(dir, nil)
+		So(err, ShouldBeNil)
+		r, err := NewRegistry(ctx, cfg, &MockAclCfg, dstore, nil)
+		So(err, ShouldBeNil)
+
+		peerID, err := peer.Decode(trustedID)
+		So(err, ShouldBeNil)
+		So(r.Authorized(peerID), ShouldBeTrue)
+
+		err = r.UpdatePolicy(ctx, &PolicyUpdate{Action: PolicyAddExcept, PeerID: peerID}, "tester")
+		So(err, ShouldBeNil)
+		So(r.Authorized(peerID), ShouldBeFalse)
+		err = r.UpdatePolicy(ctx, &PolicyUpdate{Action: PolicySetTrust, Value: false}, "tester")
+		So(err, ShouldBeNil)
+		err = r.UpdatePolicy(ctx, &PolicyUpdate{Action: "unknown"}, "tester")
+		So(err, ShouldNotBeNil)
+		err = r.UpdatePolicy(ctx, &PolicyUpdate{Action: PolicyAddExcept}, "tester")
+		So(err, ShouldNotBeNil)
+
+		records, err := r.PolicyAudit(ctx)
+		So(err, ShouldBeNil)
+		So(len(records), ShouldEqual, 2)
+		So(records[0].Update.Action, ShouldEqual, PolicyAddExcept)
+		So(records[1].Update.Action, ShouldEqual, PolicySetTrust)
+		So(records[1].Operator, ShouldEqual, "tester")
+		So(r.Close(), ShouldBeNil)
+
+		// The persisted policy overrides the config.
+		dstore, err = leveldb.NewDatastore(dir, nil)
+		So(err, ShouldBeNil)
+		r, err = NewRegistry(ctx, cfg, &MockAclCfg, dstore, nil)
+		So(err, ShouldBeNil)
+		policyCfg := r.Policy()
+		So(policyCfg.Allow, ShouldBeTrue)
+		So(policyCfg.Trust, ShouldBeFalse)
+		So(policyCfg.Except, ShouldResemble, []string{trustedID})
+		So(r.Authorized(peerID), ShouldBeFalse)
+		So(r.Close(), ShouldBeNil)
+	})
+}
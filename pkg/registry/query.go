@@ -0,0 +1,229 @@
+package registry
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/kenlabs/pando/pkg/registry/internal/syserr"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultQueryLimit = 100
+	maxQueryLimit     = 1000
+)
+
+// ProviderSortKey is the field the queried providers are sorted by
+type ProviderSortKey string
+
+const (
+	SortByID            ProviderSortKey = "id"
+	SortByName          ProviderSortKey = "name"
+	SortByLastContact   ProviderSortKey = "lastContact"
+	SortByAccountLevel  ProviderSortKey = "level"
+	SortByStatusChanged ProviderSortKey = "statusChanged"
+)
+
+// sortValue returns the value of the sort key as a string that sorts the same
+// way as the value.
+func (k ProviderSortKey) sortValue(info *ProviderInfo) (string, error) {
+	switch k {
+	case SortByID, "":
+		return "", nil
+	case SortByName:
+		return info.Name, nil
+	case SortByLastContact:
+		return fmt.Sprintf("%020d", info.LastContactTime.UnixNano()), nil
+	case SortByAccountLevel:
+		// Offset so that the unverified level -1 sorts first
+		return fmt.Sprintf("%011d", int64(info.AccountLevel)+1), nil
+	case SortByStatusChanged:
+		return fmt.Sprintf("%020d", info.StatusChanged.UnixNano()), nil
+	default:
+		return "", fmt.Errorf("unknown sort key: %s", k)
+	}
+}
+
+// ProviderQuery selects, sorts and paginates the registered providers.  Empty
+// fields do not filter.
+type ProviderQuery struct {
+	DiscoveryAddr string          `json:",omitempty"`
+	Name          string          `json:",omitempty"`
+	Publisher     peer.ID         `json:",omitempty"`
+	AccountLevel  *int            `json:",omitempty"`
+	Status        Status          `json:",omitempty"`
+	SortBy        ProviderSortKey `json:",omitempty"`
+	Desc          bool            `json:",omitempty"`
+	// Limit is the max number of providers in a page, defaults to 100
+	Limit int `json:",omitempty"`
+	// Cursor is the NextCursor of the previous page
+	Cursor string `json:",omitempty"`
+}
+
+// ProviderPage is a page of queried providers
+type ProviderPage struct {
+	Providers []*ProviderInfo
+	// NextCursor is empty if there is no more page
+	NextCursor string `json:",omitempty"`
+	// Total is the number of providers matching the filters
+	Total int
+}
+
+type queryItem struct {
+	key  string
+	info *ProviderInfo
+}
+
+// cursor is where a page ends, it is encoded as the sort value and the id of
+// the last provider in the page.
+func encodeCursor(item queryItem) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(item.key + "\x00" + item.info.AddrInfo.ID.String()))
+}
+
+func decodeCursor(cursor string) (string, peer.ID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", "", err
+	}
+	parts := strings.SplitN(string(raw), "\x00", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("malformed cursor")
+	}
+	id, err := peer.Decode(parts[1])
+	if err != nil {
+		return "", "", err
+	}
+	return parts[0], id, nil
+}
+
+// QueryProviders returns a page of the registered providers matching the query
+func (r *Registry) QueryProviders(q *ProviderQuery) (*ProviderPage, error) {
+	if q == nil {
+		q = &ProviderQuery{}
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	} else if limit > maxQueryLimit {
+		limit = maxQueryLimit
+	}
+	if q.Status != "" {
+		if _, err := ParseStatus(string(q.Status)); err != nil {
+			return nil, syserr.New(err, http.StatusBadRequest)
+		}
+	}
+	if _, err := q.SortBy.sortValue(&ProviderInfo{}); err != nil {
+		return nil, syserr.New(err, http.StatusBadRequest)
+	}
+	var afterKey string
+	var afterID peer.ID
+	if q.Cursor != "" {
+		var err error
+		afterKey, afterID, err = decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, syserr.New(fmt.Errorf("invalid cursor: %v", err), http.StatusBadRequest)
+		}
+	}
+
+	var items []queryItem
+	done := make(chan struct{})
+	r.actions <- func() {
+		items = r.syncFilterProviders(q)
+		close(done)
+	}
+	<-done
+
+	less := func(a, b queryItem) bool {
+		if a.key != b.key {
+			return a.key < b.key
+		}
+		return a.info.AddrInfo.ID < b.info.AddrInfo.ID
+	}
+	if q.Desc {
+		asc := less
+		less = func(a, b queryItem) bool { return asc(b, a) }
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return less(items[i], items[j])
+	})
+
+	page := &ProviderPage{
+		Providers: make([]*ProviderInfo, 0),
+		Total:     len(items),
+	}
+	start := 0
+	if q.Cursor != "" {
+		last := queryItem{key: afterKey, info: &ProviderInfo{AddrInfo: peer.AddrInfo{ID: afterID}}}
+		start = sort.Search(len(items), func(i int) bool {
+			return less(last, items[i])
+		})
+	}
+	end := start + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	for _, item := range items[start:end] {
+		page.Providers = append(page.Providers, item.info)
+	}
+	if end < len(items) {
+		page.NextCursor = encodeCursor(items[end-1])
+	}
+	return page, nil
+}
+
+// syncFilterProviders selects the providers matching the filters of query,
+// using the most selective index.
+func (r *Registry) syncFilterProviders(q *ProviderQuery) []queryItem {
+	var candidates []peerSet
+	if q.DiscoveryAddr != "" {
+		candidates = append(candidates, r.index.byAddr[q.DiscoveryAddr])
+	}
+	if q.Name != "" {
+		candidates = append(candidates, r.index.byName[q.Name])
+	}
+	if q.Publisher != "" {
+		candidates = append(candidates, r.index.byPublisher[string(q.Publisher)])
+	}
+	if q.AccountLevel != nil {
+		candidates = append(candidates, r.index.byLevel[strconv.Itoa(*q.AccountLevel)])
+	}
+
+	match := func(info *ProviderInfo) bool {
+		return (q.DiscoveryAddr == "" || info.DiscoveryAddr == q.DiscoveryAddr) &&
+			(q.Name == "" || info.Name == q.Name) &&
+			(q.Publisher == "" || info.Publisher == q.Publisher) &&
+			(q.AccountLevel == nil || info.AccountLevel == *q.AccountLevel) &&
+			(q.Status == "" || info.Status == q.Status)
+	}
+
+	var items []queryItem
+	add := func(info *ProviderInfo) {
+		if !match(info) {
+			return
+		}
+		// The sort key has been validated.
+		key, _ := q.SortBy.sortValue(info)
+		items = append(items, queryItem{key: key, info: info})
+	}
+
+	if len(candidates) == 0 {
+		for _, info := range r.providers {
+			add(info)
+		}
+		return items
+	}
+
+	smallest := candidates[0]
+	for _, set := range candidates[1:] {
+		if len(set) < len(smallest) {
+			smallest = set
+		}
+	}
+	for id := range smallest {
+		add(r.providers[id])
+	}
+	return items
+}
@@ -0,0 +1,95 @@
+package registry
+
+import (
+	"context"
+	"github.com/kenlabs/pando/pkg/option"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestQueryProviders(t *testing.T) {
+	Convey("test query providers with filters, sorting and pagination", t, func() {
+		cfg := &option.Discovery{
+			Policy: option.Policy{
+				Allow: true,
+				Trust: true,
+			},
+			RediscoverWait: option.Duration(time.Minute).String(),
+		}
+		ctx := context.Background()
+		r, err := NewRegistry(ctx, cfg, &MockAclCfg, nil, nil)
+		So(err, ShouldBeNil)
+		defer r.Close()
+
+		publisher, err := peer.Decode(trustedID)
+		So(err, ShouldBeNil)
+		now := time.Now()
+		var ids []peer.ID
+		for i := 0; i < 25; i++ {
+			_, pubKey, err := crypto.GenerateEd25519Key(nil)
+			So(err, ShouldBeNil)
+			id, err := peer.IDFromPublicKey(pubKey)
+			So(err, ShouldBeNil)
+			ids = append(ids, id)
+
+			info := &ProviderInfo{
+				AddrInfo:        peer.AddrInfo{ID: id},
+				Name:            "even",
+				DiscoveryAddr:   "t0" + id.String(),
+				AccountLevel:    i % 3,
+				LastContactTime: now.Add(time.Duration(i) * time.Second),
+			}
+			if i%2 == 1 {
+				info.Name = "odd"
+				info.Publisher = publisher
+			}
+			errCh := make(chan error, 1)
+			r.actions <- func() {
+				errCh <- r.syncRegister(ctx, info)
+			}
+			So(<-errCh, ShouldBeNil)
+		}
+
+		So(r.ProviderInfoByAddr("t0"+ids[3].String()).AddrInfo.ID, ShouldEqual, ids[3])
+		So(r.ProviderInfoByAddr("unknown"), ShouldBeNil)
+
+		page, err := r.QueryProviders(&ProviderQuery{Name: "odd"})
+		So(err, ShouldBeNil)
+		So(page.Total, ShouldEqual, 12)
+		So(page.NextCursor, ShouldBeEmpty)
+
+		page, err = r.QueryProviders(&ProviderQuery{Publisher: publisher, AccountLevel: new(int)})
+		So(err, ShouldBeNil)
+		// i = 3, 9, 15, 21
+		So(page.Total, ShouldEqual, 4)
+
+		// Walk through all pages sorted by last contact in descending order
+		var got []peer.ID
+		q := &ProviderQuery{SortBy: SortByLastContact, Desc: true, Limit: 10}
+		for pages := 0; ; pages++ {
+			So(pages, ShouldBeLessThan, 3)
+			page, err = r.QueryProviders(q)
+			So(err, ShouldBeNil)
+			So(page.Total, ShouldEqual, 25)
+			for _, info := range page.Providers {
+				got = append(got, info.AddrInfo.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		So(len(got), ShouldEqual, 25)
+		for i := range got {
+			So(got[i], ShouldEqual, ids[24-i])
+		}
+
+		_, err = r.QueryProviders(&ProviderQuery{SortBy: "unknown"})
+		So(err, ShouldNotBeNil)
+		_, err = r.QueryProviders(&ProviderQuery{Cursor: "invalid"})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,97 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"time"
+)
+
+// rediscover re-discovers the providers with miner accounts and refreshes their
+// account levels.  It must be called with discoWait incremented.
+func (r *Registry) rediscover() {
+	defer r.discoWait.Done()
+
+	type target struct {
+		id   peer.ID
+		addr string
+	}
+	var targets []target
+	done := make(chan struct{})
+	r.actions <- func() {
+		now := time.Now()
+		for _, info := range r.providers {
+			if info.DiscoveryAddr == "" {
+				continue
+			}
+			// Skip the providers discovered recently
+			if completed, ok := r.discoTimes[info.DiscoveryAddr]; ok &&
+				(completed.IsZero() || now.Sub(completed) < r.rediscoverWait) {
+				continue
+			}
+			r.discoTimes[info.DiscoveryAddr] = time.Time{}
+			targets = append(targets, target{info.AddrInfo.ID, info.DiscoveryAddr})
+		}
+		close(done)
+	}
+	<-done
+
+	logger.Infow("re-discovering providers", "count", len(targets))
+	for _, t := range targets {
+		select {
+		case <-r.closing:
+			return
+		default:
+		}
+
+		err := r.rediscoverProvider(t.id, t.addr)
+		if err != nil {
+			logger.Warnw("failed to re-discover provider", "id", t.id, "addr", t.addr, "err", err)
+		}
+		r.actions <- func() {
+			r.discoTimes[t.addr] = time.Now()
+		}
+	}
+}
+
+func (r *Registry) rediscoverProvider(providerID peer.ID, discoAddr string) error {
+	ctx := context.Background()
+	if r.discoveryTimeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.discoveryTimeout)
+		defer cancel()
+	}
+	discovered, err := r.discoverer.Discover(ctx, providerID, discoAddr)
+	if err != nil {
+		return err
+	}
+	level, err := r.getAccountLevel(discovered.Balance)
+	if err != nil {
+		return fmt.Errorf("failed to get the account level: %s", err)
+	}
+
+	errCh := make(chan error, 1)
+	r.actions <- func() {
+		errCh <- r.syncSetAccountLevel(ctx, providerID, discoAddr, level)
+	}
+	return <-errCh
+}
+
+// syncSetAccountLevel updates the account level of the provider, unless it has
+// been deregistered or changed its discovery address meanwhile.
+func (r *Registry) syncSetAccountLevel(ctx context.Context, providerID peer.ID, discoAddr string, level int) error {
+	info, ok := r.providers[providerID]
+	if !ok || info.DiscoveryAddr != discoAddr || info.AccountLevel == level {
+		return nil
+	}
+
+	newInfo := *info
+	newInfo.AccountLevel = level
+	if err := r.syncRegister(ctx, &newInfo); err != nil {
+		return err
+	}
+
+	logger.Infow("account level changed", "id", providerID, "from", info.AccountLevel, "to", level)
+	r.publish(EventAccountLevelChanged, providerID, info.AccountLevel, level)
+	return nil
+}
@@ -0,0 +1,88 @@
+package registry
+
+import (
+	"context"
+	"github.com/kenlabs/pando/pkg/option"
+	"github.com/kenlabs/pando/pkg/registry/discovery"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+)
+
+type balanceDiscoverer struct {
+	mutex   sync.Mutex
+	balance *big.Int
+}
+
+func (d *balanceDiscoverer) setBalance(fil int64) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.balance = big.NewInt(1).Mul(big.NewInt(fil), FIL)
+}
+
+func (d *balanceDiscoverer) Discover(_ context.Context, peerID peer.ID, _ string) (*discovery.Discovered, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return &discovery.Discovered{
+		AddrInfo: peer.AddrInfo{ID: peerID},
+		Balance:  d.balance,
+		Type:     discovery.MinerType,
+	}, nil
+}
+
+func TestRediscover(t *testing.T) {
+	Convey("test re-discovery refreshes account level and publishes event", t, func() {
+		cfg := &option.Discovery{
+			Policy: option.Policy{
+				Allow: true,
+				Trust: true,
+			},
+			RediscoverWait: option.Duration(time.Minute).String(),
+		}
+		disco := &balanceDiscoverer{}
+		disco.setBalance(0)
+		ctx := context.Background()
+		r, err := NewRegistry(ctx, cfg, &MockAclCfg, nil, disco)
+		So(err, ShouldBeNil)
+		defer r.Close()
+		events, cancel := r.Subscribe()
+		defer cancel()
+
+		peerID, err := peer.Decode(trustedID)
+		So(err, ShouldBeNil)
+		So(r.Register(ctx, &ProviderInfo{
+			AddrInfo:      peer.AddrInfo{ID: peerID},
+			DiscoveryAddr: "t01000",
+		}), ShouldBeNil)
+		level, err := r.ProviderAccountLevel(peerID)
+		So(err, ShouldBeNil)
+		So(level, ShouldEqual, 1)
+
+		disco.setBalance(50)
+		r.discoWait.Add(1)
+		r.rediscover()
+		level, err = r.ProviderAccountLevel(peerID)
+		So(err, ShouldBeNil)
+		So(level, ShouldEqual, 3)
+
+		e := waitEvent(events, EventAccountLevelChanged)
+		So(e, ShouldNotBeNil)
+		So(e.Provider, ShouldEqual, peerID)
+		So(e.Previous, ShouldEqual, 1)
+		So(e.Current, ShouldEqual, 3)
+
+		// Re-discovered too recently
+		disco.setBalance(100)
+		r.discoWait.Add(1)
+		r.rediscover()
+		level, err = r.ProviderAccountLevel(peerID)
+		So(err, ShouldBeNil)
+		So(level, ShouldEqual, 3)
+
+		_, err = r.ProviderAccountLevel("unknown")
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -35,6 +35,8 @@ type Registry struct {
 	closing   chan struct{}
 	dstore    datastore.Datastore
 	providers map[peer.ID]*ProviderInfo
+	index     *providerIndex
+	banned    map[peer.ID]*BanRecord
 	sequences *sequences
 
 	discoverer   discovery.Discoverer
@@ -46,6 +48,8 @@ type Registry struct {
 	discoveryTimeout time.Duration
 	rediscoverWait   time.Duration
 
+	events *eventBus
+
 	syncChan      chan *ProviderInfo
 	periodicTimer *time.Timer
 }
@@ -71,6 +75,11 @@ type ProviderInfo struct {
 	LastContactTime time.Time
 
 	LastBackupMeta cid.Cid
+
+	// Status is the liveness state of the provider
+	Status Status `json:",omitempty"`
+	// StatusChanged is the time the provider changed to the current status
+	StatusChanged time.Time
 }
 
 func (p *ProviderInfo) dsKey() datastore.Key {
@@ -99,7 +108,9 @@ func NewRegistry(ctx context.Context, cfg *option.Discovery, cfglevel *option.Ac
 		closing:   make(chan struct{}),
 		policy:    discoPolicy,
 		providers: map[peer.ID]*ProviderInfo{},
-		sequences: newSequences(0),
+		index:     newProviderIndex(),
+		banned:    map[peer.ID]*BanRecord{},
+		sequences: newSequences(0, dstore),
 
 		rediscoverWait:   time.Duration(cfg.RediscoverWaitInDurationFormat()),
 		discoveryTimeout: time.Duration(cfg.TimeoutInDurationFormat()),
@@ -111,6 +122,15 @@ func NewRegistry(ctx context.Context, cfg *option.Discovery, cfglevel *option.Ac
 
 		dstore:   dstore,
 		syncChan: make(chan *ProviderInfo, 1),
+		events:   newEventBus(),
+	}
+
+	policyLoaded, err := r.loadPersistedPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if policyLoaded {
+		logger.Infow("loaded persisted policy, overriding config", "policy", r.policy.Config())
 	}
 
 	count, err := r.loadPersistedProviders(ctx)
@@ -119,11 +139,24 @@ func NewRegistry(ctx context.Context, cfg *option.Discovery, cfglevel *option.Ac
 	}
 	logger.Infow("loaded providers into registry", "count", count)
 
+	count, err = r.loadPersistedBans(ctx)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infow("loaded banned peers into registry", "count", count)
+
+	count, err = r.sequences.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infow("loaded register sequences into registry", "count", count)
+
 	go r.run()
 	go r.runPollCheck(
 		time.Duration(cfg.PollIntervalInDurationFormat()),
 		time.Duration(cfg.PollRetryAfterInDurationFormat()),
 		time.Duration(cfg.PollStopAfterInDurationFormat()),
+		time.Duration(cfg.RediscoverIntervalInDurationFormat()),
 	)
 	return r, nil
 }
@@ -134,6 +167,7 @@ func (r *Registry) Close() error {
 	r.closeOnce.Do(func() {
 		close(r.closing)
 		<-r.closed
+		r.events.close()
 
 		if r.dstore != nil {
 			err = r.dstore.Close()
@@ -161,6 +195,11 @@ func (r *Registry) run() {
 // Register is used to directly register a provider, bypassing discovery and
 // adding discovered data directly to the registry.
 func (r *Registry) Register(ctx context.Context, info *ProviderInfo) error {
+	// Banned peers can not register whatever the policy is
+	if r.IsBanned(info.AddrInfo.ID) {
+		return syserr.New(ErrBanned, http.StatusForbidden)
+	}
+
 	// If provider is not allowed, then ignore request
 	if !r.policy.Allowed(info.AddrInfo.ID) {
 		return syserr.New(ErrNotAllowed, http.StatusForbidden)
@@ -188,7 +227,7 @@ func (r *Registry) Register(ctx context.Context, info *ProviderInfo) error {
 			logger.Warnf("falied to get the account level. %s", err.Error())
 			return fmt.Errorf("falied to get the account level. %s", err.Error())
 		}
-		logger.Debugf("discovering successed, peerID: %s, account balance: %s", info.AddrInfo.ID.String(), discoveredData.Balance.String())
+		logger.Debugf("discovering successed, peerID: %s, account balance: %s, other balances: %v", info.AddrInfo.ID.String(), discoveredData.Balance.String(), discoveredData.Balances)
 	}
 
 	errCh := make(chan error, 1)
@@ -227,12 +266,9 @@ func (r *Registry) IsTrusted(providerID peer.ID) bool {
 func (r *Registry) ProviderInfoByAddr(discoAddr string) *ProviderInfo {
 	infoChan := make(chan *ProviderInfo)
 	r.actions <- func() {
-		// TODO: consider adding a map of discoAddr->providerID
-		for _, info := range r.providers {
-			if info.DiscoveryAddr == discoAddr {
-				infoChan <- info
-				break
-			}
+		for id := range r.index.byAddr[discoAddr] {
+			infoChan <- r.providers[id]
+			break
 		}
 		close(infoChan)
 	}
@@ -285,12 +321,41 @@ func (r *Registry) CheckSequence(peerID peer.ID, seq uint64) error {
 }
 
 func (r *Registry) syncRegister(ctx context.Context, info *ProviderInfo) error {
+	prev, ok := r.providers[info.AddrInfo.ID]
+	newProvider := !ok
+	// The status is kept by the updated info unless changed explicitly.
+	if info.Status == "" {
+		if !newProvider {
+			info.Status = prev.Status
+			info.StatusChanged = prev.StatusChanged
+		} else {
+			info.Status = StatusRegistered
+			info.StatusChanged = time.Now()
+		}
+	}
+
+	r.index.update(prev, info)
 	r.providers[info.AddrInfo.ID] = info
 	err := r.syncPersistProvider(ctx, info)
 	if err != nil {
 		err = fmt.Errorf("could not persist provider: %s", err)
 		return syserr.New(err, http.StatusInternalServerError)
 	}
+
+	if newProvider {
+		r.publish(EventRegistered, info.AddrInfo.ID, nil, info)
+		return r.syncRecordTransition(ctx, &StatusTransition{
+			Provider: info.AddrInfo.ID,
+			To:       StatusRegistered,
+			Reason:   "registered",
+			Time:     info.StatusChanged,
+		})
+	}
+
+	r.publish(EventUpdated, info.AddrInfo.ID, nil, info)
+	if prev.Publisher != info.Publisher {
+		r.publish(EventPublisherChanged, info.AddrInfo.ID, prev.Publisher, info.Publisher)
+	}
 	return nil
 }
 
@@ -347,8 +412,13 @@ func (r *Registry) loadPersistedProviders(ctx context.Context) (int, error) {
 		if err != nil {
 			return 0, err
 		}
+		// Providers persisted before status was introduced
+		if pinfo.Status == "" {
+			pinfo.Status = StatusRegistered
+		}
 
 		r.providers[peerID] = pinfo
+		r.index.add(pinfo)
 		count++
 	}
 	return count, nil
@@ -363,22 +433,31 @@ func (r *Registry) Authorized(peerID peer.ID) bool {
 		return false
 	}
 
-	// Peer is allowed but not trusted, see if it is a registered provider.
-	if !trusted {
-		regOk := make(chan bool)
-		r.actions <- func() {
-			_, ok := r.providers[peerID]
-			regOk <- ok
+	// Peer is allowed but may be banned or quarantined, or not trusted, see if
+	// it is a registered provider.
+	regOk := make(chan bool)
+	r.actions <- func() {
+		if _, ok := r.banned[peerID]; ok {
+			regOk <- false
+			return
 		}
-		return <-regOk
+		info, ok := r.providers[peerID]
+		if ok && info.Status == StatusQuarantined {
+			regOk <- false
+			return
+		}
+		regOk <- ok || trusted
 	}
-
-	return true
+	return <-regOk
 }
 
 // RegisterOrUpdate attempts to register an unregistered provider, or updates
 // the addresses and latest meta data of an already registered provider.
 func (r *Registry) RegisterOrUpdate(ctx context.Context, providerID peer.ID, lastBackup cid.Cid, publisherID peer.ID, metaID cid.Cid, contact bool) error {
+	if r.IsBanned(providerID) {
+		return syserr.New(ErrBanned, http.StatusForbidden)
+	}
+
 	var fullRegister bool
 	// Check that the provider has been discovered and validated
 	infos := r.ProviderInfo(providerID)
@@ -430,20 +509,25 @@ func (r *Registry) RegisterOrUpdate(ctx context.Context, providerID peer.ID, las
 	// If there is a new providerID or publisherID then do a full Register that
 	// check the allow policy.
 	if fullRegister {
-		return r.Register(ctx, info)
+		if err := r.Register(ctx, info); err != nil {
+			return err
+		}
+	} else {
+		// If laready registered and no new IDs, register without verification.
+		errCh := make(chan error, 1)
+		r.actions <- func() {
+			errCh <- r.syncRegister(ctx, info)
+		}
+		err := <-errCh
+		if err != nil {
+			return err
+		}
+		logger.Debugw("Updated registered provider info", "id", info.AddrInfo.ID, "addrs", info.AddrInfo.Addrs)
 	}
 
-	// If laready registered and no new IDs, register without verification.
-	errCh := make(chan error, 1)
-	r.actions <- func() {
-		errCh <- r.syncRegister(ctx, info)
-	}
-	err := <-errCh
-	if err != nil {
-		return err
+	if contact {
+		return r.SetStatus(ctx, providerID, StatusActive, "received metadata")
 	}
-
-	logger.Debugw("Updated registered provider info", "id", info.AddrInfo.ID, "addrs", info.AddrInfo.Addrs)
 	return nil
 }
 
@@ -483,8 +567,17 @@ func (r *Registry) pollProviders(interval, stopAfter time.Duration) {
 				if err = r.syncRegister(context.Background(), info); err != nil {
 					logger.Errorw("Failed to update provider info", "err", err)
 				}
+				r.publish(EventLostContact, info.AddrInfo.ID, info.LastContactTime, nil)
+				err = r.syncSetStatus(context.Background(), info.AddrInfo.ID, StatusLost, "lost contact with publisher", false)
+				if err != nil {
+					logger.Errorw("Failed to update provider status", "err", err)
+				}
 				continue
 			}
+			err = r.syncSetStatus(context.Background(), info.AddrInfo.ID, StatusStale, "no update within poll interval", false)
+			if err != nil {
+				logger.Errorw("Failed to update provider status", "err", err)
+			}
 			select {
 			case r.syncChan <- info:
 			default:
@@ -494,11 +587,19 @@ func (r *Registry) pollProviders(interval, stopAfter time.Duration) {
 	}
 }
 
-func (r *Registry) runPollCheck(interval, retryAfter, stopAfter time.Duration) {
+func (r *Registry) runPollCheck(interval, retryAfter, stopAfter, rediscoverInterval time.Duration) {
 	if retryAfter < time.Minute {
 		retryAfter = time.Minute
 	}
 	timer := time.NewTimer(retryAfter)
+
+	// Re-discovery is disabled without discoverer or interval
+	var rediscoverC <-chan time.Time
+	if r.discoverer != nil && rediscoverInterval != 0 {
+		rediscoverTicker := time.NewTicker(rediscoverInterval)
+		defer rediscoverTicker.Stop()
+		rediscoverC = rediscoverTicker.C
+	}
 running:
 	for {
 		select {
@@ -506,6 +607,11 @@ running:
 			r.cleanup()
 			r.pollProviders(interval, stopAfter)
 			timer.Reset(retryAfter)
+		case <-rediscoverC:
+			// Added here so that it is never added after waiting for
+			// discoveries on closing.
+			r.discoWait.Add(1)
+			go r.rediscover()
 		case <-r.closing:
 			break running
 		}
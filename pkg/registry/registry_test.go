@@ -2,17 +2,21 @@ package registry_test
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	dataStoreFactory "github.com/ipfs/go-ds-leveldb"
 	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/kenlabs/pando/pkg/api/v1/model"
 	"github.com/kenlabs/pando/pkg/lotus"
 	"github.com/kenlabs/pando/pkg/option"
 	"github.com/kenlabs/pando/pkg/registry"
 	. "github.com/kenlabs/pando/pkg/registry"
 	"github.com/kenlabs/pando/pkg/registry/internal/syserr"
 	"github.com/kenlabs/pando/test/mock"
+	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/multiformats/go-multiaddr"
 	. "github.com/smartystreets/goconvey/convey"
@@ -155,3 +159,37 @@ func TestAutoRegister(t *testing.T) {
 	})
 
 }
+
+func TestReplayRegisterRequestAfterRestart(t *testing.T) {
+	Convey("test a register request can not be replayed after restart", t, func() {
+		ctx := context.Background()
+		dir := t.TempDir()
+		privKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+		So(err, ShouldBeNil)
+		peerID, err := peer.IDFromPrivateKey(privKey)
+		So(err, ShouldBeNil)
+		envelope, err := model.MakeRegisterRequest(peerID, privKey, []string{minerAddr}, "", "")
+		So(err, ShouldBeNil)
+
+		submit := func(r *Registry) error {
+			req, err := model.ReadRegisterRequest(envelope)
+			So(err, ShouldBeNil)
+			return r.CheckSequence(req.PeerID, req.Seq)
+		}
+
+		dstore, err := dataStoreFactory.NewDatastore(dir, nil)
+		So(err, ShouldBeNil)
+		r, err := NewRegistry(ctx, &mock.MockDiscoveryCfg, &mock.MockAclCfg, dstore, nil)
+		So(err, ShouldBeNil)
+		So(submit(r), ShouldBeNil)
+		So(submit(r), ShouldNotBeNil)
+		So(r.Close(), ShouldBeNil)
+
+		dstore, err = dataStoreFactory.NewDatastore(dir, nil)
+		So(err, ShouldBeNil)
+		r, err = NewRegistry(ctx, &mock.MockDiscoveryCfg, &mock.MockAclCfg, dstore, nil)
+		So(err, ShouldBeNil)
+		So(submit(r), ShouldResemble, errors.New("sequence less than or equal to last seen"))
+		So(r.Close(), ShouldBeNil)
+	})
+}
@@ -1,31 +1,47 @@
 package registry
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"path"
 	"sync"
 	"time"
 
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
 	"github.com/libp2p/go-libp2p-core/peer"
 )
 
 const defaultMaxAge = 48 * time.Hour
 
+// sequenceKeyPath is where the last seen sequences are stored, so that the
+// register requests can not be replayed after restart.
+const sequenceKeyPath = "/registry/seq"
+
 type sequences struct {
 	maxAge time.Duration
 	mutex  sync.Mutex
 	seqs   map[peer.ID]uint64
+	dstore datastore.Datastore
 }
 
-func newSequences(maxAge time.Duration) *sequences {
+func newSequences(maxAge time.Duration, dstore datastore.Datastore) *sequences {
 	if maxAge == 0 {
 		maxAge = defaultMaxAge
 	}
 	return &sequences{
 		maxAge: maxAge,
 		seqs:   make(map[peer.ID]uint64),
+		dstore: dstore,
 	}
 }
 
+func sequenceDsKey(id peer.ID) datastore.Key {
+	return datastore.NewKey(path.Join(sequenceKeyPath, id.String()))
+}
+
 func (s *sequences) check(id peer.ID, sequence uint64) error {
 	oldestAllowed := uint64(time.Now().Add(-s.maxAge).UnixNano())
 	if sequence < oldestAllowed {
@@ -39,10 +55,31 @@ func (s *sequences) check(id peer.ID, sequence uint64) error {
 	if ok && sequence <= prevSeq {
 		return errors.New("sequence less than or equal to last seen")
 	}
+	// The sequence must be persisted before being accepted, otherwise it
+	// could be replayed after restart.
+	if err := s.persist(id, sequence); err != nil {
+		return fmt.Errorf("could not persist sequence: %s", err)
+	}
 	s.seqs[id] = sequence
 	return nil
 }
 
+func (s *sequences) persist(id peer.ID, sequence uint64) error {
+	if s.dstore == nil {
+		return nil
+	}
+	value, err := json.Marshal(sequence)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	dsKey := sequenceDsKey(id)
+	if err = s.dstore.Put(ctx, dsKey, value); err != nil {
+		return err
+	}
+	return s.dstore.Sync(ctx, dsKey)
+}
+
 func (s *sequences) retire() {
 	oldestAllowed := uint64(time.Now().Add(-s.maxAge).UnixNano())
 	active := make(map[peer.ID]uint64)
@@ -53,7 +90,103 @@ func (s *sequences) retire() {
 	for id, seq := range s.seqs {
 		if seq > oldestAllowed {
 			active[id] = seq
+			continue
+		}
+		if s.dstore != nil {
+			if err := s.dstore.Delete(context.Background(), sequenceDsKey(id)); err != nil {
+				logger.Errorw("cannot delete retired sequence", "id", id, "err", err)
+			}
 		}
 	}
 	s.seqs = active
 }
+
+// load reads the persisted sequences, the ones that are already too old to be
+// accepted are retired at once.
+func (s *sequences) load(ctx context.Context) (int, error) {
+	if s.dstore == nil {
+		return 0, nil
+	}
+
+	results, err := s.dstore.Query(ctx, query.Query{
+		Prefix: sequenceKeyPath,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer results.Close()
+
+	seqs := make(map[peer.ID]uint64)
+	for result := range results.Next() {
+		if result.Error != nil {
+			return 0, fmt.Errorf("cannot read sequences: %v", result.Error)
+		}
+		id, err := peer.Decode(path.Base(result.Entry.Key))
+		if err != nil {
+			return 0, fmt.Errorf("cannot decode peer id of sequence: %v", err)
+		}
+		var seq uint64
+		if err = json.Unmarshal(result.Entry.Value, &seq); err != nil {
+			return 0, fmt.Errorf("cannot decode sequence: %v", err)
+		}
+		seqs[id] = seq
+	}
+
+	s.mutex.Lock()
+	s.seqs = seqs
+	s.mutex.Unlock()
+	s.retire()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.seqs), nil
+}
+
+// SequenceRecord is the last seen register sequence of a peer
+type SequenceRecord struct {
+	PeerID   peer.ID
+	Sequence uint64
+}
+
+func (s *sequences) records() []*SequenceRecord {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	recs := make([]*SequenceRecord, 0, len(s.seqs))
+	for id, seq := range s.seqs {
+		recs = append(recs, &SequenceRecord{PeerID: id, Sequence: seq})
+	}
+	return recs
+}
+
+// importRecords sets the imported sequences, replacing all the sequences or
+// only the ones smaller than imported, so that no sequence accepted before
+// can be replayed.
+func (s *sequences) importRecords(recs []*SequenceRecord, replace bool) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if replace {
+		if s.dstore != nil {
+			for id := range s.seqs {
+				if err := s.dstore.Delete(context.Background(), sequenceDsKey(id)); err != nil {
+					return 0, fmt.Errorf("could not delete sequence: %s", err)
+				}
+			}
+		}
+		s.seqs = make(map[peer.ID]uint64)
+	}
+
+	var count int
+	for _, rec := range recs {
+		if prevSeq, ok := s.seqs[rec.PeerID]; ok && rec.Sequence <= prevSeq {
+			continue
+		}
+		if err := s.persist(rec.PeerID, rec.Sequence); err != nil {
+			return count, fmt.Errorf("could not persist sequence: %s", err)
+		}
+		s.seqs[rec.PeerID] = rec.Sequence
+		count++
+	}
+	return count, nil
+}
@@ -9,7 +9,7 @@ import (
 
 func TestSequence(t *testing.T) {
 	Convey("test sequence", t, func() {
-		sq := newSequences(time.Second * 5)
+		sq := newSequences(time.Second*5, nil)
 		err := sq.check("dsadsa", uint64(time.Now().Add(-time.Second*6).UnixNano()))
 		So(err, ShouldResemble, errors.New("sequence too small"))
 		err = sq.check("dsadsa", uint64(time.Now().Add(-time.Second*3).UnixNano()))
@@ -0,0 +1,283 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/kenlabs/pando/pkg/registry/internal/syserr"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"net/http"
+	"path"
+	"time"
+)
+
+// statusKeyPath is where the status transitions of providers are stored
+const statusKeyPath = "/registry/status"
+
+// maxStatusHistory is the number of the latest status transitions kept for
+// each provider, the older ones are pruned when new ones are recorded.
+const maxStatusHistory = 100
+
+// Status is the liveness state of a registered provider
+type Status string
+
+const (
+	// StatusRegistered is the status of a provider never synced since registered
+	StatusRegistered Status = "registered"
+	// StatusSyncing is the status of a provider being synced by Pando
+	StatusSyncing Status = "syncing"
+	// StatusActive is the status of a provider that Pando received metadata
+	// from, or synced with successfully
+	StatusActive Status = "active"
+	// StatusStale is the status of a provider that has not been updated for
+	// the poll interval, or failed to be synced
+	StatusStale Status = "stale"
+	// StatusLost is the status of a provider that has not been updated for too
+	// long, its publisher has been removed
+	StatusLost Status = "lost"
+	// StatusQuarantined is the status of a provider suspended by admin, it is
+	// not authorized to sync with Pando until released
+	StatusQuarantined Status = "quarantined"
+	// StatusBanned is the status of a registered provider that is banned
+	StatusBanned Status = "banned"
+)
+
+var validStatus = map[Status]struct{}{
+	StatusRegistered:  {},
+	StatusSyncing:     {},
+	StatusActive:      {},
+	StatusStale:       {},
+	StatusLost:        {},
+	StatusQuarantined: {},
+	StatusBanned:      {},
+}
+
+// ParseStatus checks that s is a valid status
+func ParseStatus(s string) (Status, error) {
+	if _, ok := validStatus[Status(s)]; !ok {
+		return "", fmt.Errorf("unknown provider status: %s", s)
+	}
+	return Status(s), nil
+}
+
+// sticky returns true if the status is set by admin, and can not be changed by
+// sync results or poll outcomes.
+func (s Status) sticky() bool {
+	return s == StatusQuarantined || s == StatusBanned
+}
+
+// StatusTransition records a status change of provider
+type StatusTransition struct {
+	Provider peer.ID
+	From     Status
+	To       Status
+	Reason   string
+	Time     time.Time
+}
+
+func (t *StatusTransition) dsKey() datastore.Key {
+	// Zero padded so that the keys of a provider are ordered by time.
+	return datastore.NewKey(path.Join(statusKeyPath, t.Provider.String(), fmt.Sprintf("%020d", t.Time.UnixNano())))
+}
+
+// SetStatus changes the status of the provider according to sync results or
+// poll outcomes.  It has no effect if the provider is quarantined or banned.
+func (r *Registry) SetStatus(ctx context.Context, providerID peer.ID, status Status, reason string) error {
+	errCh := make(chan error, 1)
+	r.actions <- func() {
+		errCh <- r.syncSetStatus(ctx, providerID, status, reason, false)
+	}
+	return <-errCh
+}
+
+// Quarantine suspends the provider, it is not authorized to sync with Pando
+// until it is released.
+func (r *Registry) Quarantine(ctx context.Context, providerID peer.ID, reason string) error {
+	errCh := make(chan error, 1)
+	r.actions <- func() {
+		info, ok := r.providers[providerID]
+		if !ok {
+			errCh <- syserr.New(ErrNotRegistered, http.StatusNotFound)
+			return
+		}
+		if info.Status == StatusBanned {
+			errCh <- syserr.New(ErrBanned, http.StatusConflict)
+			return
+		}
+		errCh <- r.syncSetStatus(ctx, providerID, StatusQuarantined, reason, true)
+	}
+	err := <-errCh
+	if err != nil {
+		return err
+	}
+
+	logger.Infow("quarantined provider", "id", providerID, "reason", reason)
+	return nil
+}
+
+// Release lifts the quarantine of the provider
+func (r *Registry) Release(ctx context.Context, providerID peer.ID) error {
+	errCh := make(chan error, 1)
+	r.actions <- func() {
+		info, ok := r.providers[providerID]
+		if !ok {
+			errCh <- syserr.New(ErrNotRegistered, http.StatusNotFound)
+			return
+		}
+		if info.Status != StatusQuarantined {
+			errCh <- syserr.New(ErrNotQuarantined, http.StatusConflict)
+			return
+		}
+		errCh <- r.syncSetStatus(ctx, providerID, StatusRegistered, "released", true)
+	}
+	err := <-errCh
+	if err != nil {
+		return err
+	}
+
+	logger.Infow("released provider", "id", providerID)
+	return nil
+}
+
+// ProvidersByStatus returns the registered providers in the status
+func (r *Registry) ProvidersByStatus(status Status) []*ProviderInfo {
+	var infos []*ProviderInfo
+	done := make(chan struct{})
+	r.actions <- func() {
+		for _, info := range r.providers {
+			if info.Status == status {
+				infos = append(infos, info)
+			}
+		}
+		close(done)
+	}
+	<-done
+	return infos
+}
+
+// StatusHistory returns the status transitions of the provider in time order
+func (r *Registry) StatusHistory(ctx context.Context, providerID peer.ID) ([]*StatusTransition, error) {
+	if r.dstore == nil {
+		return nil, nil
+	}
+	results, err := r.dstore.Query(ctx, query.Query{
+		Prefix: path.Join(statusKeyPath, providerID.String()),
+		Orders: []query.Order{query.OrderByKey{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	transitions := make([]*StatusTransition, 0)
+	for result := range results.Next() {
+		if result.Error != nil {
+			return nil, fmt.Errorf("cannot read status history: %v", result.Error)
+		}
+		t := new(StatusTransition)
+		if err = json.Unmarshal(result.Entry.Value, t); err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, t)
+	}
+	return transitions, nil
+}
+
+// syncSetStatus replaces the provider info with one in the new status, and
+// records the transition.  Sticky status is only changed if forced.  No event
+// is published, the status changes are only in the history.
+func (r *Registry) syncSetStatus(ctx context.Context, providerID peer.ID, status Status, reason string, force bool) error {
+	info, ok := r.providers[providerID]
+	if !ok {
+		return syserr.New(ErrNotRegistered, http.StatusNotFound)
+	}
+	if info.Status == status || (info.Status.sticky() && !force) {
+		return nil
+	}
+
+	newInfo := *info
+	newInfo.Status = status
+	newInfo.StatusChanged = time.Now()
+	r.index.update(info, &newInfo)
+	r.providers[providerID] = &newInfo
+	if err := r.syncPersistProvider(ctx, &newInfo); err != nil {
+		err = fmt.Errorf("could not persist provider: %s", err)
+		return syserr.New(err, http.StatusInternalServerError)
+	}
+	return r.syncRecordTransition(ctx, &StatusTransition{
+		Provider: providerID,
+		From:     info.Status,
+		To:       status,
+		Reason:   reason,
+		Time:     newInfo.StatusChanged,
+	})
+}
+
+func (r *Registry) syncRecordTransition(ctx context.Context, t *StatusTransition) error {
+	logger.Infow("provider status changed", "id", t.Provider, "from", t.From, "to", t.To, "reason", t.Reason)
+
+	if r.dstore == nil {
+		return nil
+	}
+	value, err := json.Marshal(t)
+	if err != nil {
+		return syserr.New(err, http.StatusInternalServerError)
+	}
+	if err = r.dstore.Put(ctx, t.dsKey(), value); err != nil {
+		err = fmt.Errorf("could not persist status transition: %s", err)
+		return syserr.New(err, http.StatusInternalServerError)
+	}
+	if err = r.syncPruneStatusHistory(ctx, t.Provider); err != nil {
+		logger.Warnw("failed to prune status history", "id", t.Provider, "err", err)
+	}
+	return nil
+}
+
+// syncPruneStatusHistory removes the oldest status transitions of the provider
+// beyond maxStatusHistory
+func (r *Registry) syncPruneStatusHistory(ctx context.Context, providerID peer.ID) error {
+	results, err := r.dstore.Query(ctx, query.Query{
+		Prefix:   path.Join(statusKeyPath, providerID.String()),
+		Orders:   []query.Order{query.OrderByKey{}},
+		KeysOnly: true,
+	})
+	if err != nil {
+		return err
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(entries)-maxStatusHistory; i++ {
+		if err = r.dstore.Delete(ctx, datastore.NewKey(entries[i].Key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncDeleteStatusHistory removes the status transitions of the provider
+func (r *Registry) syncDeleteStatusHistory(ctx context.Context, providerID peer.ID) error {
+	if r.dstore == nil {
+		return nil
+	}
+	results, err := r.dstore.Query(ctx, query.Query{
+		Prefix:   path.Join(statusKeyPath, providerID.String()),
+		KeysOnly: true,
+	})
+	if err != nil {
+		return err
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return err
+	}
+	for _, ent := range entries {
+		if err = r.dstore.Delete(ctx, datastore.NewKey(ent.Key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package registry
+
+import (
+	"context"
+	"github.com/ipfs/go-cid"
+	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/kenlabs/pando/pkg/option"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/multiformats/go-multiaddr"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestProviderStatus(t *testing.T) {
+	Convey("test provider status transitions and history", t, func() {
+		cfg := &option.Discovery{
+			Policy: option.Policy{
+				Allow: true,
+				Trust: true,
+			},
+			RediscoverWait: option.Duration(time.Minute).String(),
+		}
+		ctx := context.Background()
+		dir := t.TempDir()
+		dstore, err := leveldb.NewDatastore(dir, nil)
+		So(err, ShouldBeNil)
+		r, err := NewRegistry(ctx, cfg, &MockAclCfg, dstore, nil)
+		So(err, ShouldBeNil)
+
+		peerID, err := peer.Decode(trustedID)
+		So(err, ShouldBeNil)
+		maddr, err := multiaddr.NewMultiaddr("/ip4/127.0.0.1/tcp/3002")
+		So(err, ShouldBeNil)
+		So(r.Register(ctx, &ProviderInfo{
+			AddrInfo: peer.AddrInfo{
+				ID:    peerID,
+				Addrs: []multiaddr.Multiaddr{maddr},
+			},
+		}), ShouldBeNil)
+		So(r.ProviderInfo(peerID)[0].Status, ShouldEqual, StatusRegistered)
+
+		metaCid, err := cid.Decode("bafy2bzaceamp42wmmgr2g2ymg46euououzfyck7szknvfacqscohrvaikwfay")
+		So(err, ShouldBeNil)
+		So(r.RegisterOrUpdate(ctx, peerID, cid.Undef, peer.ID(""), metaCid, true), ShouldBeNil)
+		So(r.ProviderInfo(peerID)[0].Status, ShouldEqual, StatusActive)
+		So(len(r.ProvidersByStatus(StatusActive)), ShouldEqual, 1)
+		So(len(r.ProvidersByStatus(StatusStale)), ShouldEqual, 0)
+
+		So(r.SetStatus(ctx, peerID, StatusStale, "test"), ShouldBeNil)
+		So(len(r.ProvidersByStatus(StatusStale)), ShouldEqual, 1)
+
+		// Quarantined providers are not authorized, and the status is kept
+		// until released.
+		So(r.Quarantine(ctx, peerID, "test"), ShouldBeNil)
+		So(r.Authorized(peerID), ShouldBeFalse)
+		So(r.SetStatus(ctx, peerID, StatusActive, "test"), ShouldBeNil)
+		So(r.ProviderInfo(peerID)[0].Status, ShouldEqual, StatusQuarantined)
+		So(r.Release(ctx, peerID), ShouldBeNil)
+		So(r.Release(ctx, peerID), ShouldNotBeNil)
+		So(r.Authorized(peerID), ShouldBeTrue)
+
+		So(r.Ban(ctx, peerID, "test"), ShouldBeNil)
+		So(r.ProviderInfo(peerID)[0].Status, ShouldEqual, StatusBanned)
+		So(r.Unban(ctx, peerID), ShouldBeNil)
+		So(r.ProviderInfo(peerID)[0].Status, ShouldEqual, StatusRegistered)
+		So(r.Close(), ShouldBeNil)
+
+		// The status and history survive restart
+		dstore, err = leveldb.NewDatastore(dir, nil)
+		So(err, ShouldBeNil)
+		r, err = NewRegistry(ctx, cfg, &MockAclCfg, dstore, nil)
+		So(err, ShouldBeNil)
+		So(r.ProviderInfo(peerID)[0].Status, ShouldEqual, StatusRegistered)
+		history, err := r.StatusHistory(ctx, peerID)
+		So(err, ShouldBeNil)
+		var transitions []Status
+		for _, t := range history {
+			transitions = append(transitions, t.To)
+		}
+		So(transitions, ShouldResemble, []Status{
+			StatusRegistered, StatusActive, StatusStale, StatusQuarantined,
+			StatusRegistered, StatusBanned, StatusRegistered,
+		})
+		So(history[1].From, ShouldEqual, StatusRegistered)
+
+		// Only the latest transitions are kept, and no events are published
+		// for them
+		events, cancel := r.Subscribe()
+		defer cancel()
+		for i := 0; i < maxStatusHistory; i++ {
+			status := StatusActive
+			if i%2 == 1 {
+				status = StatusStale
+			}
+			So(r.SetStatus(ctx, peerID, status, "test"), ShouldBeNil)
+		}
+		history, err = r.StatusHistory(ctx, peerID)
+		So(err, ShouldBeNil)
+		So(len(history), ShouldEqual, maxStatusHistory)
+		So(history[0].To, ShouldEqual, StatusActive)
+		So(history[maxStatusHistory-1].To, ShouldEqual, StatusStale)
+		So(waitEvent(events, EventUpdated), ShouldBeNil)
+
+		So(r.Deregister(ctx, peerID), ShouldBeNil)
+		history, err = r.StatusHistory(ctx, peerID)
+		So(err, ShouldBeNil)
+		So(len(history), ShouldEqual, 0)
+		So(r.Close(), ShouldBeNil)
+
+		_, err = ParseStatus("unknown")
+		So(err, ShouldNotBeNil)
+	})
+}
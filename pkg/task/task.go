@@ -0,0 +1,139 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"github.com/kenlabs/pando/pkg/util/log"
+	"sort"
+	"sync"
+	"time"
+)
+
+var logger = log.NewSubsystemLogger()
+
+type Status string
+
+const (
+	Running   Status = "running"
+	Succeeded Status = "succeeded"
+	Failed    Status = "failed"
+	Canceled  Status = "canceled"
+)
+
+// Func is the work done by a task, the returned result is recorded in the
+// task once it finished.
+type Func func(ctx context.Context) (interface{}, error)
+
+// Task is a snapshot of a background task, it is never modified after being
+// returned by the Manager.
+type Task struct {
+	ID       string
+	Kind     string
+	Target   string
+	Status   Status
+	Started  time.Time
+	Finished time.Time   `json:",omitempty"`
+	Error    string      `json:",omitempty"`
+	Result   interface{} `json:",omitempty"`
+}
+
+// Manager runs tasks in background and keeps track of their status
+type Manager struct {
+	mutex  sync.RWMutex
+	tasks  map[string]*Task
+	nextID uint64
+	ctx    context.Context
+	cncl   context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewManager(ctx context.Context) *Manager {
+	cctx, cncl := context.WithCancel(ctx)
+	return &Manager{
+		tasks: make(map[string]*Task),
+		ctx:   cctx,
+		cncl:  cncl,
+	}
+}
+
+// Submit starts fn in background and returns the task tracking it
+func (m *Manager) Submit(kind string, target string, fn Func) *Task {
+	m.mutex.Lock()
+	m.nextID++
+	t := &Task{
+		ID:      fmt.Sprintf("%s-%d", kind, m.nextID),
+		Kind:    kind,
+		Target:  target,
+		Status:  Running,
+		Started: time.Now(),
+	}
+	m.tasks[t.ID] = t
+	m.wg.Add(1)
+	m.mutex.Unlock()
+
+	go func() {
+		defer m.wg.Done()
+		result, err := fn(m.ctx)
+		m.finish(t.ID, result, err)
+	}()
+
+	logger.Infow("task submitted", "id", t.ID, "target", target)
+	return t
+}
+
+func (m *Manager) finish(id string, result interface{}, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Tasks are replaced rather than modified, so that the snapshots handed
+	// out are safe to read.
+	t := *m.tasks[id]
+	t.Finished = time.Now()
+	t.Result = result
+	switch {
+	case err == nil:
+		t.Status = Succeeded
+	case m.ctx.Err() != nil:
+		t.Status = Canceled
+		t.Error = err.Error()
+	default:
+		t.Status = Failed
+		t.Error = err.Error()
+	}
+	m.tasks[id] = &t
+
+	if err != nil {
+		logger.Errorw("task failed", "id", id, "err", err)
+	} else {
+		logger.Infow("task finished", "id", id)
+	}
+}
+
+// Get returns the task of the id, or nil if there is no such task
+func (m *Manager) Get(id string) *Task {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.tasks[id]
+}
+
+// List returns all the tasks ordered by start time
+func (m *Manager) List() []*Task {
+	m.mutex.RLock()
+	tasks := make([]*Task, 0, len(m.tasks))
+	for _, t := range m.tasks {
+		tasks = append(tasks, t)
+	}
+	m.mutex.RUnlock()
+
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].Started.Before(tasks[j].Started)
+	})
+	return tasks
+}
+
+// Close cancels the running tasks and waits for them to return
+func (m *Manager) Close() error {
+	m.cncl()
+	m.wg.Wait()
+	return nil
+}
@@ -0,0 +1,47 @@
+package task
+
+import (
+	"context"
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	Convey("test submit and track tasks", t, func() {
+		m := NewManager(context.Background())
+
+		done := m.Submit("test", "ok", func(ctx context.Context) (interface{}, error) {
+			return 1, nil
+		})
+		failed := m.Submit("test", "fail", func(ctx context.Context) (interface{}, error) {
+			return nil, errors.New("failed")
+		})
+		blocked := m.Submit("test", "block", func(ctx context.Context) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		So(done.ID, ShouldNotEqual, failed.ID)
+		So(blocked.Status, ShouldEqual, Running)
+
+		waitFinished := func(id string) *Task {
+			for i := 0; i < 100; i++ {
+				if t := m.Get(id); t.Status != Running {
+					return t
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			return m.Get(id)
+		}
+		So(waitFinished(done.ID).Status, ShouldEqual, Succeeded)
+		So(m.Get(done.ID).Result, ShouldEqual, 1)
+		So(waitFinished(failed.ID).Status, ShouldEqual, Failed)
+		So(m.Get(failed.ID).Error, ShouldEqual, "failed")
+		So(m.Get("unknown"), ShouldBeNil)
+		So(len(m.List()), ShouldEqual, 3)
+
+		So(m.Close(), ShouldBeNil)
+		So(m.Get(blocked.ID).Status, ShouldEqual, Canceled)
+	})
+}
@@ -0,0 +1,215 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/kenlabs/pando/pkg/option"
+	"github.com/kenlabs/pando/pkg/registry"
+	"github.com/kenlabs/pando/pkg/util/log"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+var logger = log.NewSubsystemLogger()
+
+const (
+	// EventHeader carries the type of the event delivered
+	EventHeader = "X-Pando-Event"
+	// SignatureHeader carries "sha256=" followed by the hex encoded
+	// HMAC-SHA256 of the payload, keyed by the secret of the webhook
+	SignatureHeader = "X-Pando-Signature"
+
+	// queueSize is the number of events buffered for each webhook, the events
+	// are dropped for the webhook if its queue is full.
+	queueSize = 256
+)
+
+type endpoint struct {
+	url    string
+	secret []byte
+	events map[registry.EventType]struct{}
+	queue  chan *registry.Event
+}
+
+func (e *endpoint) accepts(eventType registry.EventType) bool {
+	if len(e.events) == 0 {
+		return true
+	}
+	_, ok := e.events[eventType]
+	return ok
+}
+
+// Dispatcher delivers the registry events to the configured webhooks, and
+// retries the failed deliveries with exponential backoff.
+type Dispatcher struct {
+	endpoints     []*endpoint
+	client        *http.Client
+	maxRetries    int
+	retryInterval time.Duration
+
+	ctx         context.Context
+	cancel      context.CancelFunc
+	cancelWatch func()
+	wg          sync.WaitGroup
+}
+
+// New creates a Dispatcher of the webhooks in config
+func New(cfg *option.Webhook) (*Dispatcher, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		client:        &http.Client{Timeout: time.Duration(cfg.TimeoutInDurationFormat())},
+		maxRetries:    cfg.MaxRetries,
+		retryInterval: time.Duration(cfg.RetryIntervalInDurationFormat()),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+
+	for _, ep := range cfg.Endpoints {
+		u, err := url.Parse(ep.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			cancel()
+			return nil, fmt.Errorf("invalid webhook url: %q", ep.URL)
+		}
+		events := make(map[registry.EventType]struct{}, len(ep.Events))
+		for _, s := range ep.Events {
+			eventType, err := registry.ParseEventType(s)
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			events[eventType] = struct{}{}
+		}
+		d.endpoints = append(d.endpoints, &endpoint{
+			url:    u.String(),
+			secret: []byte(ep.Secret),
+			events: events,
+			queue:  make(chan *registry.Event, queueSize),
+		})
+	}
+	return d, nil
+}
+
+// WatchRegistry delivers the events of the registry until Close is called
+func (d *Dispatcher) WatchRegistry(r *registry.Registry) {
+	d.watch(r.Subscribe())
+}
+
+func (d *Dispatcher) watch(events <-chan *registry.Event, cancel func()) {
+	d.cancelWatch = cancel
+
+	for _, ep := range d.endpoints {
+		d.wg.Add(1)
+		go d.run(ep)
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer func() {
+			for _, ep := range d.endpoints {
+				close(ep.queue)
+			}
+		}()
+		for e := range events {
+			for _, ep := range d.endpoints {
+				if !ep.accepts(e.Type) {
+					continue
+				}
+				select {
+				case ep.queue <- e:
+				default:
+					logger.Warnw("webhook is too slow, dropping event", "url", ep.url, "type", e.Type, "provider", e.Provider)
+				}
+			}
+		}
+	}()
+}
+
+func (d *Dispatcher) run(ep *endpoint) {
+	defer d.wg.Done()
+	for e := range ep.queue {
+		if err := d.deliver(ep, e); err != nil {
+			logger.Errorw("failed to deliver event to webhook", "url", ep.url, "type", e.Type, "provider", e.Provider, "err", err)
+		}
+	}
+}
+
+// deliver posts the event to the webhook, retrying on network errors, server
+// errors and 429 Too Many Requests.
+func (d *Dispatcher) deliver(ep *endpoint, e *registry.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	wait := d.retryInterval
+	for attempt := 0; ; attempt++ {
+		retry, err := d.post(ep, e.Type, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= d.maxRetries {
+			return err
+		}
+		logger.Debugw("retrying webhook delivery", "url", ep.url, "attempt", attempt+1, "err", err)
+
+		select {
+		case <-time.After(wait):
+		case <-d.ctx.Done():
+			return d.ctx.Err()
+		}
+		wait *= 2
+	}
+}
+
+func (d *Dispatcher) post(ep *endpoint, eventType registry.EventType, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, ep.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(eventType))
+	if len(ep.secret) != 0 {
+		req.Header.Set(SignatureHeader, Sign(ep.secret, body))
+	}
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return d.ctx.Err() == nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, Body)
+		_ = Body.Close()
+	}(res.Body)
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+	retry := res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook responded %s", res.Status)
+}
+
+// Sign returns the value of SignatureHeader for the payload
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Close stops watching the registry and aborts the pending deliveries
+func (d *Dispatcher) Close() error {
+	if d.cancelWatch != nil {
+		d.cancelWatch()
+	}
+	d.cancel()
+	d.wg.Wait()
+	return nil
+}
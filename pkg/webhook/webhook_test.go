@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"encoding/json"
+	"github.com/kenlabs/pando/pkg/option"
+	"github.com/kenlabs/pando/pkg/registry"
+	"github.com/libp2p/go-libp2p-core/peer"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testPeerID = "12D3KooWRqmtFv7ccFfjR7RDcevoMEMXdCHNR8JNN8aNiH2dgk8Z"
+
+type delivery struct {
+	eventType string
+	signature string
+	body      []byte
+}
+
+func TestDispatcher(t *testing.T) {
+	Convey("test deliver events to webhooks", t, func() {
+		var mutex sync.Mutex
+		var deliveries []delivery
+		attempts := 0
+		received := make(chan struct{}, 10)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			// Fail the first attempt to test retrying
+			attempts++
+			if attempts == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			deliveries = append(deliveries, delivery{
+				eventType: r.Header.Get(EventHeader),
+				signature: r.Header.Get(SignatureHeader),
+				body:      body,
+			})
+			received <- struct{}{}
+		}))
+		defer srv.Close()
+
+		d, err := New(&option.Webhook{
+			Endpoints: []option.WebhookEndpoint{{
+				URL:    srv.URL,
+				Secret: "secret",
+				Events: []string{string(registry.EventRegistered)},
+			}},
+			MaxRetries:    3,
+			RetryInterval: option.Duration(10 * time.Millisecond).String(),
+			Timeout:       option.Duration(time.Second).String(),
+		})
+		So(err, ShouldBeNil)
+		events := make(chan *registry.Event, 2)
+		d.watch(events, func() { close(events) })
+
+		providerID, err := peer.Decode(testPeerID)
+		So(err, ShouldBeNil)
+		events <- &registry.Event{Type: registry.EventUpdated, Provider: providerID, Time: time.Now()}
+		events <- &registry.Event{Type: registry.EventRegistered, Provider: providerID, Time: time.Now()}
+
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.Fatal("expected delivery")
+		}
+		So(d.Close(), ShouldBeNil)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		So(attempts, ShouldEqual, 2)
+		So(len(deliveries), ShouldEqual, 1)
+		So(deliveries[0].eventType, ShouldEqual, string(registry.EventRegistered))
+		So(deliveries[0].signature, ShouldEqual, Sign([]byte("secret"), deliveries[0].body))
+		var e registry.Event
+		So(json.Unmarshal(deliveries[0].body, &e), ShouldBeNil)
+		So(e.Provider, ShouldEqual, providerID)
+	})
+
+	Convey("test invalid webhook config", t, func() {
+		_, err := New(&option.Webhook{Endpoints: []option.WebhookEndpoint{{URL: "ftp://localhost"}}})
+		So(err, ShouldNotBeNil)
+		_, err = New(&option.Webhook{Endpoints: []option.WebhookEndpoint{{URL: "http://localhost", Events: []string{"unknown"}}}})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -8,6 +8,7 @@ import (
 	dssync "github.com/ipfs/go-datastore/sync"
 	"github.com/kenlabs/pando-store/pkg/config"
 	"github.com/kenlabs/pando-store/pkg/store"
+	"github.com/kenlabs/pando/pkg/access"
 	"github.com/kenlabs/pando/pkg/legs"
 	"github.com/kenlabs/pando/pkg/metadata"
 	"github.com/kenlabs/pando/pkg/option"
@@ -29,6 +30,7 @@ type PandoMock struct {
 	Host     host.Host
 	Core     *legs.Core
 	Registry *registry.Registry
+	Access   *access.Controller
 	Discover discovery.Discoverer
 	outMeta  chan *metadata.MetaRecord
 }
@@ -82,7 +84,11 @@ func NewPandoMock() (*PandoMock, error) {
 	if err != nil {
 		return nil, err
 	}
-	core, err := legs.NewLegsCore(ctx, h, ds, cs, ps, outCh, time.Minute, limiter, r, opt)
+	ac, err := access.New(ctx, ds, ps)
+	if err != nil {
+		return nil, err
+	}
+	core, err := legs.NewLegsCore(ctx, h, ds, cs, ps, outCh, time.Minute, limiter, r, ac, opt)
 	if err != nil {
 		return nil, err
 	}
@@ -94,6 +100,7 @@ func NewPandoMock() (*PandoMock, error) {
 		Host:     h,
 		Core:     core,
 		Registry: r,
+		Access:   ac,
 		Discover: mockDisco,
 		outMeta:  outCh,
 		Opt:      opt,